@@ -0,0 +1,87 @@
+package multifs
+
+import "context"
+
+// Op identifies the operation an AccessFunc is asked about.
+type Op int
+
+const (
+	OpOpen Op = iota
+	OpStat
+	OpReadDir
+	// OpWrite opens an existing file for writing, OpCreate opens a file
+	// for writing with os.O_CREATE.
+	OpWrite
+	OpCreate
+	OpRemove
+	OpMkdir
+	// OpRename is asked about both the source and the destination.
+	OpRename
+	OpChmod
+	OpChtimes
+	OpChown
+	OpSymlink
+	OpLink
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpOpen:
+		return "open"
+	case OpStat:
+		return "stat"
+	case OpReadDir:
+		return "readdir"
+	case OpWrite:
+		return "write"
+	case OpCreate:
+		return "create"
+	case OpRemove:
+		return "remove"
+	case OpMkdir:
+		return "mkdir"
+	case OpRename:
+		return "rename"
+	case OpChmod:
+		return "chmod"
+	case OpChtimes:
+		return "chtimes"
+	case OpChown:
+		return "chown"
+	case OpSymlink:
+		return "symlink"
+	case OpLink:
+		return "link"
+	}
+	return "unknown"
+}
+
+// AccessFunc decides whether op may be performed on name, a path inside the
+// mount. A non-nil error, typically fs.ErrPermission, denies the operation.
+// Callers can be told apart through values carried by ctx, such as their
+// Identity.
+type AccessFunc func(ctx context.Context, op Op, name string) error
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying who, the identity of an
+// authenticated caller. The network adapters set it for the requests of
+// the users they authenticate.
+func WithIdentity(ctx context.Context, who string) context.Context {
+	return context.WithValue(ctx, identityKey{}, who)
+}
+
+// Identity returns the identity carried by ctx, or "" if there is none.
+// It can be passed to WithAudit as is.
+func Identity(ctx context.Context) string {
+	who, _ := ctx.Value(identityKey{}).(string)
+	return who
+}
+
+// WithAccessFunc attaches an access filter to a mount. Entries that the
+// filter refuses to Stat are also hidden from directory listings.
+func WithAccessFunc(fn AccessFunc) MountOption {
+	return func(o *mountOptions) {
+		o.access = append(o.access, fn)
+	}
+}
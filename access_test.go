@@ -0,0 +1,105 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type callerKey struct{}
+
+func TestAccessFunc(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{
+		"etc/hosts":        &fstest.MapFile{Data: []byte("hosts")},
+		"etc/shadow":       &fstest.MapFile{Data: []byte("secret")},
+		"home/u/.ssh/id":   &fstest.MapFile{Data: []byte("key")},
+		"home/u/notes.txt": &fstest.MapFile{Data: []byte("notes")},
+	}
+	deny := func(ctx context.Context, op Op, name string) error {
+		if ctx.Value(callerKey{}) == "admin" {
+			return nil
+		}
+		if name == "etc/shadow" || strings.Contains(name, ".ssh") {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+	if err := mux.Mount("snap", fs1, WithAccessFunc(deny)); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if _, err := fs.ReadFile(mux, "snap/etc/shadow"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission, got %v", err)
+	}
+	if _, err := mux.Stat("snap/home/u/.ssh/id"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission on Stat, got %v", err)
+	}
+	if _, err := fs.ReadFile(mux, "snap/etc/hosts"); err != nil {
+		t.Fatalf("ReadFile allowed file: %v", err)
+	}
+
+	entries, err := mux.ReadDir("snap/etc")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "hosts" {
+		t.Fatalf("denied entries not filtered: %v", entries)
+	}
+	entries, err = mux.ReadDir("snap/home/u")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "notes.txt" {
+		t.Fatalf("denied entries not filtered: %v", entries)
+	}
+
+	// Other callers can be let through
+	ctx := context.WithValue(context.Background(), callerKey{}, "admin")
+	if _, err := mux.StatContext(ctx, "snap/etc/shadow"); err != nil {
+		t.Fatalf("admin Stat: %v", err)
+	}
+	entries, err = mux.ReadDirContext(ctx, "snap/etc")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("admin ReadDir: %v, %v", entries, err)
+	}
+}
+
+func TestAccessFuncAppliesToFoldedPaths(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"etc/shadow": &fstest.MapFile{Data: []byte("secret")}}
+	deny := func(ctx context.Context, op Op, name string) error {
+		if name == "etc/shadow" {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+	if err := mux.Mount("snap", fs1, WithAccessFunc(deny), WithCaseInsensitivePaths()); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if _, err := fs.ReadFile(mux, "snap/ETC/Shadow"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission through case folding, got %v", err)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	if who := Identity(context.Background()); who != "" {
+		t.Fatalf("Identity of an anonymous context: %q", who)
+	}
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"a.txt": &fstest.MapFile{}}, WithAccessFunc(func(ctx context.Context, op Op, name string) error {
+		if Identity(ctx) != "alice" {
+			return fs.ErrPermission
+		}
+		return nil
+	}))
+	if _, err := mux.OpenContext(WithIdentity(context.Background(), "alice"), "snap/a.txt"); err != nil {
+		t.Fatalf("alice: %v", err)
+	}
+	if _, err := mux.OpenContext(WithIdentity(context.Background(), "bob"), "snap/a.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("bob: expected ErrPermission, got %v", err)
+	}
+}
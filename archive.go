@@ -0,0 +1,325 @@
+package multifs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveName returns the name of name in an archive of root: the whole
+// namespace keeps its paths, a subtree is stored under its base name.
+func archiveName(root, name string) string {
+	if root == "." {
+		return name
+	}
+	return path.Join(path.Base(root), strings.TrimPrefix(strings.TrimPrefix(name, root), "/"))
+}
+
+// TarOptions configures WriteTarWith.
+type TarOptions struct {
+	// DetectHoles stores runs of zeros as holes, which costs an extra read
+	// of files whose filesystem does not implement SparseFS.
+	DetectHoles bool
+}
+
+// WriteTar streams root, a directory or a file, into w as a tar archive,
+// preserving modes, modification times and symbolic links. Archiving "."
+// stores each mount under its id. Files with holes reported by SparseFS
+// are stored as PAX sparse files. Files transformed by WithTransform
+// without LogicalSize are spooled to learn their size.
+func (m *MultiFS) WriteTar(ctx context.Context, w io.Writer, root string) error {
+	return m.WriteTarWith(ctx, w, TarOptions{}, root)
+}
+
+// WriteTarWith is WriteTar with options.
+func (m *MultiFS) WriteTarWith(ctx context.Context, w io.Writer, opts TarOptions, root string) error {
+	tw := tar.NewWriter(w)
+	err := WalkDir(ctx, m, root, WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		aname := archiveName(root, name)
+		if aname == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = m.ReadLinkContext(ctx, name); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = aname
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if info.Mode().IsRegular() && m.storedSize(name) {
+			return m.writeSpooled(ctx, tw, hdr, name)
+		}
+		if info.Mode().IsRegular() {
+			extents, ok, err := m.extents(ctx, name, opts.DetectHoles)
+			if err != nil {
+				return err
+			}
+			if ok && sparse(extents, hdr.Size) {
+				return m.writeSparse(ctx, tw, w, hdr, name, extents)
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return m.copyTo(ctx, tw, name)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// WriteZip is WriteTar for zip archives. File contents are deflated.
+func (m *MultiFS) WriteZip(ctx context.Context, w io.Writer, root string) error {
+	zw := zip.NewWriter(w)
+	err := WalkDir(ctx, m, root, WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		aname := archiveName(root, name)
+		if aname == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = aname
+		if info.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			// Zip stores the target of a link as its content.
+			link, err := m.ReadLinkContext(ctx, name)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(fw, link)
+			return err
+		case info.Mode().IsRegular():
+			return m.copyTo(ctx, fw, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (m *MultiFS) copyTo(ctx context.Context, w io.Writer, name string) error {
+	f, err := m.OpenContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = copyBuffer(w, f)
+	return err
+}
+
+// storedSize reports whether name has the stored size of a file whose
+// contents are transformed when read.
+func (m *MultiFS) storedSize(name string) bool {
+	r, err := m.resolve("stat", name)
+	if err != nil || r.mnt == nil {
+		return false
+	}
+	t := r.mnt.opts.transform
+	return t != nil && !t.LogicalSize && t.matches(r.subpath)
+}
+
+// writeSpooled stores name, whose size is only known once read, by
+// spooling its contents first.
+func (m *MultiFS) writeSpooled(ctx context.Context, tw *tar.Writer, hdr *tar.Header, name string) error {
+	f, err := m.OpenContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	sf := &spoolFile{File: f}
+	defer sf.Close()
+	if err := sf.fill(math.MaxInt64); err != nil {
+		return err
+	}
+	hdr.Size = sf.n
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = copyBuffer(tw, io.NewSectionReader(sf, 0, sf.n))
+	return err
+}
+
+// writeSparse stores name in the PAX 1.0 sparse format, which
+// archive/tar reads but cannot write: the extended header is written to w
+// directly, and the data is a map of the extents followed by their
+// contents.
+func (m *MultiFS) writeSparse(ctx context.Context, tw *tar.Writer, w io.Writer, hdr *tar.Header, name string, extents []Extent) error {
+	extents = alignExtents(extents, hdr.Size)
+	spmap := strconv.AppendInt(nil, int64(len(extents)), 10)
+	spmap = append(spmap, '\n')
+	size := int64(0)
+	for _, e := range extents {
+		spmap = strconv.AppendInt(spmap, e.Offset, 10)
+		spmap = append(spmap, '\n')
+		spmap = strconv.AppendInt(spmap, e.Length, 10)
+		spmap = append(spmap, '\n')
+		size += e.Length
+	}
+	spmap = append(spmap, make([]byte, -len(spmap)&(tarBlock-1))...)
+	size += int64(len(spmap))
+
+	records := map[string]string{
+		"GNU.sparse.major":    "1",
+		"GNU.sparse.minor":    "0",
+		"GNU.sparse.name":     hdr.Name,
+		"GNU.sparse.realsize": strconv.FormatInt(hdr.Size, 10),
+	}
+	if hdr.Uname != "" {
+		records["uname"] = hdr.Uname
+	}
+	if hdr.Gname != "" {
+		records["gname"] = hdr.Gname
+	}
+	base := path.Base(hdr.Name)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if _, err := w.Write(paxHeader(truncate("PaxHeaders.0/"+base, 100), records)); err != nil {
+		return err
+	}
+
+	// The rest of the header must not need a PAX header of its own
+	hdr.Name = truncate("GNUSparseFile.0/"+base, 100)
+	hdr.Size = size
+	hdr.Uname, hdr.Gname = truncate(hdr.Uname, 32), truncate(hdr.Gname, 32)
+	hdr.ModTime = hdr.ModTime.Round(time.Second)
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	hdr.PAXRecords = nil
+	hdr.Format = tar.FormatUSTAR | tar.FormatGNU
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(spmap); err != nil {
+		return err
+	}
+
+	f, err := m.OpenContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pos := int64(0)
+	for _, e := range extents {
+		if err := skip(f, e.Offset-pos); err != nil {
+			return err
+		}
+		if _, err := copyN(tw, f, e.Length); err != nil {
+			return err
+		}
+		pos = e.Offset + e.Length
+	}
+	return nil
+}
+
+const tarBlock = 512
+
+// alignExtents widens extents to block boundaries, as GNU tar reads the
+// data of each extent in whole blocks, and ends them with an empty extent
+// at size, from which it takes the size of the file.
+func alignExtents(extents []Extent, size int64) []Extent {
+	var aligned []Extent
+	for _, e := range extents {
+		start := e.Offset &^ (tarBlock - 1)
+		end := min((e.Offset+e.Length+tarBlock-1)&^(tarBlock-1), size)
+		if last := len(aligned) - 1; last >= 0 && aligned[last].Offset+aligned[last].Length >= start {
+			aligned[last].Length = end - aligned[last].Offset
+			continue
+		}
+		aligned = append(aligned, Extent{Offset: start, Length: end - start})
+	}
+	if n := len(aligned); n == 0 || aligned[n-1].Offset+aligned[n-1].Length < size {
+		aligned = append(aligned, Extent{Offset: size})
+	}
+	return aligned
+}
+
+// paxHeader encodes a PAX extended header for the entry that follows it.
+func paxHeader(name string, records map[string]string) []byte {
+	var data []byte
+	for _, k := range slices.Sorted(maps.Keys(records)) {
+		// Each record starts with its own length, digits included
+		rec := " " + k + "=" + records[k] + "\n"
+		n := len(rec)
+		for n < len(rec)+len(strconv.Itoa(n)) {
+			n = len(rec) + len(strconv.Itoa(n))
+		}
+		data = append(strconv.AppendInt(data, int64(n), 10), rec...)
+	}
+
+	blk := make([]byte, tarBlock)
+	copy(blk[0:100], name)
+	copy(blk[100:108], "0000644\x00")
+	copy(blk[108:116], "0000000\x00")
+	copy(blk[116:124], "0000000\x00")
+	copy(blk[124:136], fmt.Sprintf("%011o\x00", len(data)))
+	copy(blk[136:148], "00000000000\x00")
+	blk[156] = tar.TypeXHeader
+	copy(blk[257:263], "ustar\x00")
+	copy(blk[263:265], "00")
+	copy(blk[148:156], "        ")
+	sum := 0
+	for _, b := range blk {
+		sum += int(b)
+	}
+	copy(blk[148:156], fmt.Sprintf("%06o\x00 ", sum))
+
+	data = append(data, make([]byte, -len(data)&(tarBlock-1))...)
+	return append(blk, data...)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
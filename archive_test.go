@@ -0,0 +1,135 @@
+package multifs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newArchiveMux() *MultiFS {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"docs/a.txt": &fstest.MapFile{Data: []byte("hello"), Mode: 0o640, ModTime: mtime},
+		"docs/link":  &fstest.MapFile{Data: []byte("a.txt"), Mode: fs.ModeSymlink | 0o777},
+		"run.sh":     &fstest.MapFile{Data: []byte("#!/bin/sh"), Mode: 0o755},
+	})
+	mux.Mount("other", fstest.MapFS{"b.txt": &fstest.MapFile{Data: []byte("b")}})
+	return mux
+}
+
+func TestWriteTar(t *testing.T) {
+	mux := newArchiveMux()
+
+	var buf bytes.Buffer
+	if err := mux.WriteTar(context.Background(), &buf, "."); err != nil {
+		t.Fatalf("WriteTar: %v", err)
+	}
+
+	var got []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entry := fmt.Sprintf("%s %o %q", hdr.Name, hdr.Mode&0o777, data)
+		if hdr.Typeflag == tar.TypeSymlink {
+			entry += " -> " + hdr.Linkname
+		}
+		if hdr.Name == "snap/docs/a.txt" && !hdr.ModTime.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("mtime of %s: got %v", hdr.Name, hdr.ModTime)
+		}
+		got = append(got, entry)
+	}
+
+	want := strings.Join([]string{
+		`other/ 555 ""`,
+		`other/b.txt 0 "b"`,
+		`snap/ 555 ""`,
+		`snap/docs/ 555 ""`,
+		`snap/docs/a.txt 640 "hello"`,
+		`snap/docs/link 777 "" -> a.txt`,
+		`snap/run.sh 755 "#!/bin/sh"`,
+	}, "\n")
+	if g := strings.Join(got, "\n"); g != want {
+		t.Fatalf("got\n%s\nwant\n%s", g, want)
+	}
+}
+
+func TestWriteZipSubtree(t *testing.T) {
+	mux := newArchiveMux()
+
+	var buf bytes.Buffer
+	if err := mux.WriteZip(context.Background(), &buf, "snap/docs"); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	var got []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got = append(got, fmt.Sprintf("%s %v %q", f.Name, f.Mode(), data))
+	}
+	want := `docs/ dr-xr-xr-x "",docs/a.txt -rw-r----- "hello",docs/link Lrwxrwxrwx "a.txt"`
+	if g := strings.Join(got, ","); g != want {
+		t.Fatalf("got  %s\nwant %s", g, want)
+	}
+}
+
+func TestWriteArchiveTransformed(t *testing.T) {
+	text := strings.Repeat("hello, world\n", 100)
+	mux := NewMultiFS()
+	mux.Mount("z", fstest.MapFS{"a.gz": &fstest.MapFile{Data: gzipped(t, text)}}, WithTransform(Gunzip))
+
+	var buf bytes.Buffer
+	if err := mux.WriteTar(context.Background(), &buf, "z"); err != nil {
+		t.Fatalf("WriteTar: %v", err)
+	}
+	tr := tar.NewReader(&buf)
+	tr.Next() // z/
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if data, err := io.ReadAll(tr); err != nil || hdr.Size != int64(len(text)) || string(data) != text {
+		t.Fatalf("%s: size %d, %d bytes, %v", hdr.Name, hdr.Size, len(data), err)
+	}
+
+	buf.Reset()
+	if err := mux.WriteZip(context.Background(), &buf, "z"); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	rc, err := zr.File[1].Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rc.Close()
+	if data, err := io.ReadAll(rc); err != nil || string(data) != text {
+		t.Fatalf("zip: %d bytes, %v", len(data), err)
+	}
+}
@@ -0,0 +1,52 @@
+package multifs
+
+import (
+	"context"
+	"path"
+	"time"
+)
+
+// AuditRecord is a successful access to a file through MultiFS.
+type AuditRecord struct {
+	Time time.Time
+	Who  string
+	Op   string
+	Path string
+}
+
+// AuditSink receives the audit trail. It is called synchronously after
+// each open, so slow sinks should buffer.
+type AuditSink interface {
+	Audit(rec AuditRecord)
+}
+
+// AuditFunc adapts a function to an AuditSink.
+type AuditFunc func(rec AuditRecord)
+
+func (f AuditFunc) Audit(rec AuditRecord) { f(rec) }
+
+// WithAudit records every successful open inside a mount, including the
+// opens behind fs.ReadFile and directory listings, and every write, to
+// sink. who extracts the identity of the caller from the context passed to
+// OpenContext and the other Context methods, and may be nil.
+func WithAudit(sink AuditSink, who func(ctx context.Context) string) Option {
+	return func(o *options) {
+		o.audit = sink
+		o.auditWho = who
+	}
+}
+
+func (r resolved) audit(ctx context.Context, op string) {
+	if r.opts.audit == nil {
+		return
+	}
+	rec := AuditRecord{
+		Time: time.Now(),
+		Op:   op,
+		Path: path.Join(r.id, r.subpath),
+	}
+	if r.opts.auditWho != nil {
+		rec.Who = r.opts.auditWho(ctx)
+	}
+	r.opts.audit.Audit(rec)
+}
@@ -0,0 +1,43 @@
+package multifs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type userKey struct{}
+
+func TestAudit(t *testing.T) {
+	var trail []string
+	sink := AuditFunc(func(rec AuditRecord) {
+		if rec.Time.IsZero() {
+			t.Error("audit record without a time")
+		}
+		trail = append(trail, fmt.Sprintf("%s %s %s", rec.Who, rec.Op, rec.Path))
+	})
+	who := func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}
+	mux := NewMultiFS(WithAudit(sink, who))
+	mux.Mount("snap", fstest.MapFS{"etc/passwd": &fstest.MapFile{Data: []byte("root")}})
+
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+	f, err := mux.OpenContext(ctx, "snap/etc/passwd")
+	if err != nil {
+		t.Fatalf("OpenContext: %v", err)
+	}
+	f.Close()
+	fs.ReadFile(mux, "snap/etc/passwd")
+	mux.Open("snap/missing")
+	mux.Open(".")
+
+	want := "alice open snap/etc/passwd, open snap/etc/passwd"
+	if got := strings.Join(trail, ","); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
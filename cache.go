@@ -0,0 +1,407 @@
+package multifs
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig sizes the cache enabled on a mount by WithCache. Zero fields
+// take the defaults.
+type CacheConfig struct {
+	// MaxEntries bounds the number of paths with cached results, 1024 by
+	// default.
+	MaxEntries int
+	// MaxFileSize is the size up to which the contents of a file read to
+	// the end are kept, 64 KiB by default. A negative size disables the
+	// caching of contents.
+	MaxFileSize int64
+	// MaxBytes bounds the total size of cached contents, 16 MiB by default.
+	MaxBytes int64
+	// TTL, when set, is how long results are served from the cache.
+	TTL time.Duration
+}
+
+// WithCache keeps the most recently used Stat results, directory listings
+// and small file contents of the mount in memory, so that repeated
+// lookups do not reach the backend. Writes made through MultiFS drop the
+// affected paths; changes made to the backend otherwise must be reported
+// with InvalidateCache.
+func WithCache(c CacheConfig) MountOption {
+	return func(o *mountOptions) {
+		if c.MaxEntries <= 0 {
+			c.MaxEntries = 1024
+		}
+		if c.MaxFileSize == 0 {
+			c.MaxFileSize = 64 << 10
+		}
+		if c.MaxBytes <= 0 {
+			c.MaxBytes = 16 << 20
+		}
+		o.cache = &c
+	}
+}
+
+// InvalidateCache drops the cached results of the given paths inside mount
+// id, along with the listings of their parents, or the whole cache of the
+// mount when no path is given. The size cached by ComputeSize is dropped
+// in either case.
+func (m *MultiFS) InvalidateCache(id string, names ...string) error {
+	mnt, ok := m.mount(id)
+	if !ok {
+		return fs.ErrNotExist
+	}
+	mnt.size.drop()
+	if mnt.cache == nil {
+		return nil
+	}
+	if len(names) == 0 {
+		mnt.cache.purge()
+		return nil
+	}
+	for _, name := range names {
+		if !fs.ValidPath(name) {
+			return &fs.PathError{Op: "invalidate", Path: name, Err: fs.ErrInvalid}
+		}
+		mnt.invalidate(name)
+	}
+	return nil
+}
+
+// invalidate drops the cached results of name, given as seen through the
+// mount.
+func (mnt *mount) invalidate(name string) {
+	mnt.size.drop()
+	if mnt.cache == nil {
+		return
+	}
+	if bname, err := mnt.backendName("invalidate", name); err == nil {
+		mnt.cache.invalidate(bname)
+	}
+}
+
+// invalidatingFile drops the cached results of a file being written once
+// more when it is closed, as reads in between may have cached them again.
+type invalidatingFile struct {
+	File
+	mnt  *mount
+	name string
+}
+
+func (f *invalidatingFile) Close() error {
+	err := f.File.Close()
+	f.mnt.invalidate(f.name)
+	return err
+}
+
+// cache is an LRU of results keyed by backend path.
+type cache struct {
+	cfg CacheConfig
+
+	mu      sync.Mutex
+	lru     list.List
+	entries map[string]*list.Element
+	bytes   int64
+	// pending holds the files being prefetched.
+	pending map[string]struct{}
+}
+
+type cacheEntry struct {
+	name    string
+	expires time.Time
+	info    fs.FileInfo
+	// listing is set once the directory has been read to the end.
+	listing []fs.DirEntry
+	listed  bool
+	// data is set once the file has been read to the end.
+	data   []byte
+	loaded bool
+}
+
+func newCache(cfg CacheConfig) *cache {
+	return &cache{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		pending: make(map[string]struct{}),
+	}
+}
+
+// get returns a copy of the live entry for name. c.mu must be held.
+func (c *cache) get(name string) (cacheEntry, bool) {
+	el, ok := c.entries[name]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.drop(el)
+		return cacheEntry{}, false
+	}
+	c.lru.MoveToFront(el)
+	return *e, true
+}
+
+// update applies fn to the entry for name, creating it if needed, then
+// evicts entries beyond the limits.
+func (c *cache) update(name string, fn func(e *cacheEntry)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var e *cacheEntry
+	if el, ok := c.entries[name]; ok {
+		e = el.Value.(*cacheEntry)
+		c.bytes -= int64(len(e.data))
+		c.lru.MoveToFront(el)
+	} else {
+		e = &cacheEntry{name: name}
+		c.entries[name] = c.lru.PushFront(e)
+	}
+	if c.cfg.TTL > 0 {
+		e.expires = time.Now().Add(c.cfg.TTL)
+	}
+	fn(e)
+	c.bytes += int64(len(e.data))
+
+	for len(c.entries) > c.cfg.MaxEntries || c.bytes > c.cfg.MaxBytes {
+		c.drop(c.lru.Back())
+	}
+}
+
+func (c *cache) drop(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.name)
+	c.bytes -= int64(len(e.data))
+}
+
+// invalidate drops name, its parent and, as name may be a directory
+// renamed or removed, every entry under it.
+func (c *cache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range []string{name, path.Dir(name)} {
+		if el, ok := c.entries[name]; ok {
+			c.drop(el)
+		}
+	}
+	if name == "." {
+		c.purgeLocked()
+		return
+	}
+	prefix := name + "/"
+	for n, el := range c.entries {
+		if strings.HasPrefix(n, prefix) {
+			c.drop(el)
+		}
+	}
+}
+
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purgeLocked()
+}
+
+func (c *cache) purgeLocked() {
+	c.lru.Init()
+	clear(c.entries)
+	c.bytes = 0
+}
+
+func (c *cache) stat(name string) (fs.FileInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.get(name)
+	return e.info, ok && e.info != nil
+}
+
+func (c *cache) storeStat(name string, info fs.FileInfo) {
+	c.update(name, func(e *cacheEntry) { e.info = info })
+}
+
+// open returns a file served from the cache, if name has been read or
+// listed entirely before.
+func (c *cache) open(name string) (fs.File, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.get(name)
+	switch {
+	case !ok || e.info == nil:
+		return nil, false
+	case e.listed:
+		return &cachedDir{info: e.info, entries: e.listing}, true
+	case e.loaded:
+		return &cachedFile{info: e.info, Reader: bytes.NewReader(e.data)}, true
+	}
+	return nil, false
+}
+
+// listing returns the cached listing of the directory name.
+func (c *cache) listing(name string) ([]fs.DirEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.get(name)
+	return e.listing, ok && e.listed
+}
+
+// claim reports whether the contents of name are neither cached nor being
+// prefetched already, marking them as being prefetched if so.
+func (c *cache) claim(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.get(name); ok && e.loaded {
+		return false
+	}
+	if _, ok := c.pending[name]; ok {
+		return false
+	}
+	c.pending[name] = struct{}{}
+	return true
+}
+
+func (c *cache) release(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, name)
+}
+
+// record wraps a file freshly opened on the backend so that reading it to
+// the end fills the cache.
+func (c *cache) record(name string, f fs.File) fs.File {
+	if isDir(f) {
+		d := f.(fs.ReadDirFile)
+		return compose(d, nil, nil, nil, &recordingDir{ReadDirFile: d, c: c, name: name})
+	}
+	if c.cfg.MaxFileSize < 0 {
+		return f
+	}
+	rf := &recordingFile{File: f, c: c, name: name}
+	var seeker io.Seeker
+	if _, ok := f.(io.Seeker); ok {
+		seeker = rf
+	}
+	readerAt, _ := f.(io.ReaderAt)
+	return compose(rf, seeker, readerAt, nil, nil)
+}
+
+type recordingDir struct {
+	fs.ReadDirFile
+	c       *cache
+	name    string
+	entries []fs.DirEntry
+	spoiled bool
+}
+
+func (d *recordingDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	if d.spoiled {
+		return entries, err
+	}
+	d.entries = append(d.entries, entries...)
+	switch {
+	case n <= 0 && err == nil, n > 0 && err == io.EOF:
+		d.spoiled = true
+		if info, serr := d.Stat(); serr == nil {
+			listing := d.entries
+			d.c.update(d.name, func(e *cacheEntry) {
+				e.info, e.listing, e.listed = info, listing, true
+			})
+		}
+	case err != nil:
+		d.spoiled = true
+	}
+	return entries, err
+}
+
+type recordingFile struct {
+	fs.File
+	c       *cache
+	name    string
+	data    []byte
+	spoiled bool
+}
+
+func (f *recordingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if f.spoiled {
+		return n, err
+	}
+	f.data = append(f.data, p[:n]...)
+	switch {
+	case int64(len(f.data)) > f.c.cfg.MaxFileSize:
+		f.spoil()
+	case err == io.EOF:
+		f.spoiled = true
+		if info, serr := f.Stat(); serr == nil && info.Size() == int64(len(f.data)) {
+			data := f.data
+			f.c.update(f.name, func(e *cacheEntry) {
+				e.info, e.data, e.loaded = info, data, true
+			})
+		}
+	case err != nil:
+		f.spoil()
+	}
+	return n, err
+}
+
+// Seek stops the recording: the contents are only cached when read in one
+// pass from the start.
+func (f *recordingFile) Seek(offset int64, whence int) (int64, error) {
+	f.spoil()
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f *recordingFile) spoil() {
+	f.spoiled = true
+	f.data = nil
+}
+
+type cachedFile struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+var _ io.ReaderAt = (*cachedFile)(nil)
+var _ io.Seeker = (*cachedFile)(nil)
+
+func (f *cachedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *cachedFile) Close() error               { return nil }
+
+type cachedDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+var _ fs.ReadDirFile = (*cachedDir)(nil)
+
+func (d *cachedDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *cachedDir) Close() error               { return nil }
+
+func (d *cachedDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *cachedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.pos:]
+	if len(rest) == 0 && n > 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(rest) {
+		n = len(rest)
+	}
+	d.pos += n
+	return slices.Clone(rest[:n]), nil
+}
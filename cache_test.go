@@ -0,0 +1,198 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// slowFS counts the calls reaching the backend.
+type slowFS struct {
+	fs.FS
+	calls atomic.Int64
+}
+
+func (s *slowFS) Open(name string) (fs.File, error) {
+	s.calls.Add(1)
+	return s.FS.Open(name)
+}
+
+func (s *slowFS) Stat(name string) (fs.FileInfo, error) {
+	s.calls.Add(1)
+	return fs.Stat(s.FS, name)
+}
+
+var cachedFiles = fstest.MapFS{
+	"docs/a.txt": &fstest.MapFile{Data: []byte("hello")},
+	"docs/b.txt": &fstest.MapFile{Data: []byte("bee")},
+	"big.bin":    &fstest.MapFile{Data: make([]byte, 100)},
+}
+
+func newCachedMux(t *testing.T, cfg CacheConfig) (*MultiFS, *slowFS) {
+	t.Helper()
+	return newCachedMuxOn(t, cfg, maps.Clone(cachedFiles))
+}
+
+func newCachedMuxOn(t *testing.T, cfg CacheConfig, fsys fs.FS) (*MultiFS, *slowFS) {
+	t.Helper()
+	backend := &slowFS{FS: fsys}
+	mux := NewMultiFS()
+	if err := mux.Mount("s", backend, WithCache(cfg)); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	return mux, backend
+}
+
+func TestCacheHits(t *testing.T) {
+	for _, b := range backendsOf(t, cachedFiles) {
+		t.Run(b.name, func(t *testing.T) {
+			testCacheHits(t, b.fsys)
+		})
+	}
+}
+
+func testCacheHits(t *testing.T, fsys fs.FS) {
+	mux, backend := newCachedMuxOn(t, CacheConfig{MaxFileSize: 10}, fsys)
+
+	for i := 0; i < 3; i++ {
+		if got := listNames(t, mux, "s/docs"); got != "a.txt,b.txt" {
+			t.Fatalf("listing: got %s", got)
+		}
+		data, err := fs.ReadFile(mux, "s/docs/a.txt")
+		if err != nil || string(data) != "hello" {
+			t.Fatalf("ReadFile: %q, %v", data, err)
+		}
+		if info, err := mux.Stat("s/big.bin"); err != nil || info.Size() != 100 {
+			t.Fatalf("Stat: %v, %v", info, err)
+		}
+	}
+	if n := backend.calls.Load(); n != 3 {
+		t.Fatalf("backend calls: got %d, want 3", n)
+	}
+
+	// Contents beyond MaxFileSize are read from the backend every time
+	for i := 0; i < 2; i++ {
+		if _, err := fs.ReadFile(mux, "s/big.bin"); err != nil {
+			t.Fatalf("ReadFile big.bin: %v", err)
+		}
+	}
+	if n := backend.calls.Load(); n != 5 {
+		t.Fatalf("backend calls: got %d, want 5", n)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	mux, backend := newCachedMux(t, CacheConfig{})
+
+	fs.ReadFile(mux, "s/docs/a.txt")
+	listNames(t, mux, "s/docs")
+	files := backend.FS.(fstest.MapFS)
+	files["docs/a.txt"] = &fstest.MapFile{Data: []byte("changed")}
+	files["docs/c.txt"] = &fstest.MapFile{}
+
+	if data, _ := fs.ReadFile(mux, "s/docs/a.txt"); string(data) != "hello" {
+		t.Fatalf("cached contents: got %q", data)
+	}
+	if err := mux.InvalidateCache("s", "docs/a.txt"); err != nil {
+		t.Fatalf("InvalidateCache: %v", err)
+	}
+	if data, _ := fs.ReadFile(mux, "s/docs/a.txt"); string(data) != "changed" {
+		t.Fatalf("contents after invalidation: got %q", data)
+	}
+	if got := listNames(t, mux, "s/docs"); got != "a.txt,b.txt,c.txt" {
+		t.Fatalf("parent listing after invalidation: got %s", got)
+	}
+
+	files["docs/d.txt"] = &fstest.MapFile{}
+	if err := mux.InvalidateCache("s"); err != nil {
+		t.Fatalf("InvalidateCache: %v", err)
+	}
+	if got := listNames(t, mux, "s/docs"); got != "a.txt,b.txt,c.txt,d.txt" {
+		t.Fatalf("listing after purge: got %s", got)
+	}
+
+	if err := mux.InvalidateCache("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("InvalidateCache on missing mount: %v", err)
+	}
+	if err := mux.InvalidateCache("s", "/docs"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("InvalidateCache on invalid path: %v", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	mux, backend := newCachedMux(t, CacheConfig{MaxEntries: 1})
+
+	fs.ReadFile(mux, "s/docs/a.txt")
+	fs.ReadFile(mux, "s/docs/b.txt")
+	before := backend.calls.Load()
+	fs.ReadFile(mux, "s/docs/b.txt")
+	if backend.calls.Load() != before {
+		t.Fatal("most recent entry was evicted")
+	}
+	fs.ReadFile(mux, "s/docs/a.txt")
+	if backend.calls.Load() == before {
+		t.Fatal("least recent entry was kept beyond MaxEntries")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	mux, backend := newCachedMux(t, CacheConfig{TTL: time.Millisecond})
+
+	fs.ReadFile(mux, "s/docs/a.txt")
+	time.Sleep(5 * time.Millisecond)
+	before := backend.calls.Load()
+	fs.ReadFile(mux, "s/docs/a.txt")
+	if backend.calls.Load() == before {
+		t.Fatal("expired entry served from the cache")
+	}
+}
+
+func TestCacheWrites(t *testing.T) {
+	d := newDirFS(t)
+	writeFile(t, d, "a.txt", "hello")
+	mux := NewMultiFS()
+	if err := mux.Mount("d", d, WithCache(CacheConfig{})); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if got := listNames(t, mux, "d"); got != "a.txt" {
+		t.Fatalf("listing: got %s", got)
+	}
+	if err := mux.Move("d/a.txt", "d/b.txt"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if got := listNames(t, mux, "d"); got != "b.txt" {
+		t.Fatalf("listing after Move: got %s", got)
+	}
+	if _, err := mux.Stat("d/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("moved file still cached: %v", err)
+	}
+
+	// Moving a directory drops what is cached under it
+	if err := d.Mkdir("x", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, d, "x/f", "f")
+	if _, err := mux.Stat("d/x/f"); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if err := mux.Move("d/x", "d/y"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if _, err := mux.Stat("d/x/f"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("file of a moved directory still cached: %v", err)
+	}
+}
+
+func TestCacheCheck(t *testing.T) {
+	mux, _ := newCachedMux(t, CacheConfig{})
+	for i := 0; i < 2; i++ {
+		if err := fstest.TestFS(mux, "s/docs/a.txt", "s/big.bin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// closerFS counts the calls to Close.
+type closerFS struct {
+	fstest.MapFS
+	closes *int
+	err    error
+}
+
+func (c *closerFS) Close() error {
+	*c.closes++
+	return c.err
+}
+
+func TestClose(t *testing.T) {
+	var closes int
+	shared := &closerFS{MapFS: fstest.MapFS{}, closes: &closes}
+	failing := &closerFS{MapFS: fstest.MapFS{}, closes: &closes, err: errors.New("connection reset")}
+
+	mux := NewMultiFS()
+	mux.MountRoot(&closerFS{MapFS: fstest.MapFS{}, closes: &closes})
+	mux.Mount("a", shared)
+	mux.Mount("b", shared, WithReadOnly())
+	mux.Mount("c", failing)
+	mux.Mount("plain", fstest.MapFS{})
+
+	err := mux.Close()
+	if err == nil || !strings.Contains(err.Error(), `"c"`) || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("Close: %v", err)
+	}
+	if closes != 3 {
+		t.Fatalf("Close calls: got %d, want 3", closes)
+	}
+	entries, err := mux.ReadDir(".")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("ReadDir after Close: %v, %v", entries, err)
+	}
+	if _, err := mux.Stat("a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat after Close: %v", err)
+	}
+}
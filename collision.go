@@ -0,0 +1,56 @@
+package multifs
+
+// CollisionPolicy decides what Mount does with an id that is already
+// mounted.
+type CollisionPolicy int
+
+const (
+	// CollisionError fails Mount with an error wrapping fs.ErrExist.
+	CollisionError CollisionPolicy = iota
+	// CollisionReplace mounts the new filesystem in place of the old one,
+	// as Replace does.
+	CollisionReplace
+	// CollisionKeep keeps the filesystem mounted first: Mount succeeds
+	// without doing anything.
+	CollisionKeep
+)
+
+func (p CollisionPolicy) String() string {
+	switch p {
+	case CollisionError:
+		return "error"
+	case CollisionReplace:
+		return "replace"
+	case CollisionKeep:
+		return "keep"
+	}
+	return "unknown"
+}
+
+// WithCollisionPolicy sets the policy of Mount for ids already mounted.
+// The default is CollisionError.
+func WithCollisionPolicy(p CollisionPolicy) Option {
+	return func(o *options) {
+		o.collision = p
+	}
+}
+
+// WithMountCollision overrides the collision policy of the MultiFS for
+// one call to Mount.
+func WithMountCollision(p CollisionPolicy) MountOption {
+	return func(o *mountOptions) {
+		o.collision = &p
+	}
+}
+
+// collisionPolicy returns the policy applying to a Mount call given opts.
+func (m *MultiFS) collisionPolicy(opts []MountOption) CollisionPolicy {
+	var o mountOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.collision != nil {
+		return *o.collision
+	}
+	return m.opts.collision
+}
@@ -0,0 +1,58 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCollisionPolicy(t *testing.T) {
+	first := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("first")}}
+	second := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("second")}}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		mopts   []MountOption
+		wantErr error
+		want    string
+	}{
+		{name: "default", wantErr: fs.ErrExist, want: "first"},
+		{name: "replace", opts: []Option{WithCollisionPolicy(CollisionReplace)}, want: "second"},
+		{name: "keep", opts: []Option{WithCollisionPolicy(CollisionKeep)}, want: "first"},
+		{
+			name:  "per call",
+			opts:  []Option{WithCollisionPolicy(CollisionKeep)},
+			mopts: []MountOption{WithMountCollision(CollisionReplace)},
+			want:  "second",
+		},
+		{
+			name:    "per call error",
+			opts:    []Option{WithCollisionPolicy(CollisionReplace)},
+			mopts:   []MountOption{WithMountCollision(CollisionError)},
+			wantErr: fs.ErrExist,
+			want:    "first",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := NewMultiFS(tt.opts...)
+			if err := mux.Mount("snap", first); err != nil {
+				t.Fatalf("Mount: %v", err)
+			}
+			gen := mux.Generation()
+			err := mux.Mount("snap", second, tt.mopts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second Mount: got %v, want %v", err, tt.wantErr)
+			}
+			data, err := fs.ReadFile(mux, "snap/file.txt")
+			if err != nil || string(data) != tt.want {
+				t.Fatalf("ReadFile: got %q, %v, want %q", data, err, tt.want)
+			}
+			if changed := mux.Generation() != gen; changed != (tt.want == "second") {
+				t.Fatalf("generation changed: %v", changed)
+			}
+		})
+	}
+}
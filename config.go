@@ -0,0 +1,176 @@
+package multifs
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Opener opens the filesystem of a backend type from the parameters of a
+// MountConfig.
+type Opener func(ctx context.Context, params map[string]string) (fs.FS, error)
+
+var registry = struct {
+	sync.RWMutex
+	openers map[string]Opener
+}{openers: make(map[string]Opener)}
+
+// Register makes a backend type available to configurations. It panics if
+// the type is registered twice or opener is nil, and is meant to be called
+// from init functions.
+func Register(typ string, opener Opener) {
+	registry.Lock()
+	defer registry.Unlock()
+	if opener == nil {
+		panic("multifs: Register opener is nil")
+	}
+	if _, dup := registry.openers[typ]; dup {
+		panic("multifs: Register called twice for backend " + typ)
+	}
+	registry.openers[typ] = opener
+}
+
+// Backends returns the registered backend types, sorted.
+func Backends() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Sorted(maps.Keys(registry.openers))
+}
+
+func opener(typ string) (Opener, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	o, ok := registry.openers[typ]
+	return o, ok
+}
+
+// Config describes a layout of mounts, for it to be saved and loaded.
+type Config struct {
+	Mounts []MountConfig `json:"mounts"`
+}
+
+// MountConfig describes a mount by the type of its backend, as registered
+// with Register, and the parameters passed to the backend's Opener.
+type MountConfig struct {
+	// ID is the mount id, or empty for the root mount.
+	ID       string            `json:"id,omitempty"`
+	Type     string            `json:"type"`
+	Params   map[string]string `json:"params,omitempty"`
+	Sub      string            `json:"sub,omitempty"`
+	Subtrees []string          `json:"subtrees,omitempty"`
+	ReadOnly bool              `json:"read_only,omitempty"`
+}
+
+// ErrNotConfigured is returned by SaveConfig for mounts that were not made
+// from a MountConfig, and cannot be described by one.
+var ErrNotConfigured = errors.New("multifs: mount not made from a configuration")
+
+// MountBackend opens the backend described by c and mounts it.
+func (m *MultiFS) MountBackend(ctx context.Context, c MountConfig) error {
+	return m.mountBackend(ctx, c, nil)
+}
+
+// mountBackend mounts the backend described by c with the options it
+// describes, followed by extra, which are not part of the configuration.
+func (m *MultiFS) mountBackend(ctx context.Context, c MountConfig, extra []MountOption) error {
+	open, ok := opener(c.Type)
+	if !ok {
+		return fmt.Errorf("multifs: unknown backend type %q", c.Type)
+	}
+	fsys, err := open(ctx, c.Params)
+	if err != nil {
+		return fmt.Errorf("multifs: opening %s backend: %w", c.Type, err)
+	}
+
+	c.Params = maps.Clone(c.Params)
+	c.Subtrees = slices.Clone(c.Subtrees)
+	opts := []MountOption{func(o *mountOptions) { o.config = &c }}
+	if closer, ok := fsys.(io.Closer); ok {
+		owner := own(closer)
+		defer owner.settle()
+		opts = append(opts, owner.option())
+	}
+	if c.Subtrees != nil {
+		opts = append(opts, WithSubtrees(c.Subtrees...))
+	}
+	if c.ReadOnly {
+		opts = append(opts, WithReadOnly())
+	}
+	opts = append(opts, extra...)
+
+	if c.ID != "" {
+		return m.MountSub(c.ID, fsys, cmp.Or(c.Sub, "."), opts...)
+	}
+	if c.Sub != "" {
+		if fsys, err = fs.Sub(fsys, c.Sub); err != nil {
+			return err
+		}
+	}
+	return m.MountRoot(fsys, opts...)
+}
+
+// LoadConfig reads a configuration in JSON and mounts what it describes on
+// a new MultiFS created with opts.
+func LoadConfig(ctx context.Context, r io.Reader, opts ...Option) (*MultiFS, error) {
+	var c Config
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("multifs: decoding configuration: %w", err)
+	}
+	m := NewMultiFS(opts...)
+	for _, mc := range c.Mounts {
+		if err := m.MountBackend(ctx, mc); err != nil {
+			// Release the backends mounted so far
+			m.Close()
+			return nil, fmt.Errorf("multifs: mounting %q: %w", mc.ID, err)
+		}
+	}
+	return m, nil
+}
+
+// Config returns the configuration of the mounts of m, the root mount
+// first. It fails with ErrNotConfigured if a mount was not made by
+// MountBackend.
+func (m *MultiFS) Config() (Config, error) {
+	t := m.tab.Load()
+	var c Config
+	add := func(mnt *mount) error {
+		if mnt.opts.config == nil {
+			return fmt.Errorf("%w: %q", ErrNotConfigured, mnt.id)
+		}
+		mc := *mnt.opts.config
+		mc.ID = mnt.id
+		c.Mounts = append(c.Mounts, mc)
+		return nil
+	}
+	if t.fallback != nil {
+		if err := add(t.fallback); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, id := range t.ids() {
+		mnt, _ := t.roots.get(id)
+		if err := add(mnt); err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+// SaveConfig writes the configuration of the mounts of m in JSON, for
+// LoadConfig to mount them again.
+func (m *MultiFS) SaveConfig(w io.Writer) error {
+	c, err := m.Config()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
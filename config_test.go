@@ -0,0 +1,146 @@
+package multifs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+func init() {
+	// The files named by the "files" parameter, with their names as
+	// contents.
+	Register("test-files", func(ctx context.Context, params map[string]string) (fs.FS, error) {
+		if params["files"] == "" {
+			return nil, errors.New("no files")
+		}
+		fsys := fstest.MapFS{}
+		for _, name := range strings.Split(params["files"], ",") {
+			fsys[name] = &fstest.MapFile{Data: []byte(name)}
+		}
+		return fsys, nil
+	})
+	Register("test-closing", func(ctx context.Context, params map[string]string) (fs.FS, error) {
+		closes.Add(1)
+		return closingFS{fstest.MapFS{}}, nil
+	})
+}
+
+// closes counts the test-closing backends opened and not closed yet.
+var closes atomic.Int64
+
+type closingFS struct {
+	fstest.MapFS
+}
+
+func (closingFS) Close() error {
+	closes.Add(-1)
+	return nil
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	config := `{"mounts": [
+		{"type": "test-files", "params": {"files": "root.txt"}},
+		{"id": "a", "type": "test-files", "params": {"files": "x/1,x/2,y/3"}, "sub": "x"},
+		{"id": "b", "type": "test-files", "params": {"files": "keep/f,drop/g"}, "subtrees": ["keep"], "read_only": true}
+	]}`
+	ctx := context.Background()
+	m, err := LoadConfig(ctx, strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := fstest.TestFS(m, "root.txt", "a/1", "a/2", "b/keep/f"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("b/drop/g"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("subtrees not applied: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := m.SaveConfig(&buf); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	again, err := LoadConfig(ctx, &buf)
+	if err != nil {
+		t.Fatalf("LoadConfig of saved configuration: %v", err)
+	}
+	want, _ := m.Config()
+	got, err := again.Config()
+	if err != nil || len(got.Mounts) != 3 {
+		t.Fatalf("Config: %+v, %v", got, err)
+	}
+	for i := range got.Mounts {
+		g, w := got.Mounts[i], want.Mounts[i]
+		if g.ID != w.ID || g.Type != w.Type || g.Sub != w.Sub || g.ReadOnly != w.ReadOnly ||
+			g.Params["files"] != w.Params["files"] || !slices.Equal(g.Subtrees, w.Subtrees) {
+			t.Fatalf("mount %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := LoadConfig(ctx, strings.NewReader(`{"mounts": [{"id": "a", "type": "nope"}]}`)); err == nil {
+		t.Fatal("LoadConfig succeeded with an unknown backend type")
+	}
+	if _, err := LoadConfig(ctx, strings.NewReader(`{"mounts": [{"id": "a", "type": "test-files"}]}`)); err == nil {
+		t.Fatal("LoadConfig succeeded with a failing backend")
+	}
+	if !slices.Contains(Backends(), "test-files") {
+		t.Fatalf("Backends: %v", Backends())
+	}
+
+	m := NewMultiFS()
+	m.Mount("plain", fstest.MapFS{})
+	if err := m.SaveConfig(&bytes.Buffer{}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("SaveConfig with a mount made by hand: %v", err)
+	}
+}
+
+func TestConfigClosesBackends(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("taken", fstest.MapFS{})
+	if err := mux.MountURL("taken", "test-closing://"); err == nil {
+		t.Fatal("MountURL over a mount succeeded")
+	}
+	if n := closes.Load(); n != 0 {
+		t.Fatalf("backend of a failed mount left open: %d", n)
+	}
+
+	mux.MountURL("a", "test-closing://")
+	mux.MountURL("a", "test-closing://", WithMountCollision(CollisionReplace))
+	mux.MountURL("a", "test-closing://", WithMountCollision(CollisionKeep))
+	if n := closes.Load(); n != 1 {
+		t.Fatalf("replaced backend left open: %d open", n)
+	}
+	clone := mux.Clone()
+	mux.Unmount("a")
+	if n := closes.Load(); n != 1 {
+		t.Fatalf("backend closed while mounted in a clone: %d open", n)
+	}
+	clone.Unmount("a")
+	if n := closes.Load(); n != 0 {
+		t.Fatalf("unmounted backend left open: %d open", n)
+	}
+
+	config := `{"mounts": [{"id": "a", "type": "test-closing"}, {"id": "b", "type": "nope"}]}`
+	if _, err := LoadConfig(context.Background(), strings.NewReader(config)); err == nil {
+		t.Fatal("LoadConfig succeeded with an unknown backend type")
+	}
+	if n := closes.Load(); n != 0 {
+		t.Fatalf("backend left open by a failed LoadConfig: %d open", n)
+	}
+
+	mux.MountURL("b", "test-closing://")
+	if err := mux.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n := closes.Load(); n != 0 {
+		t.Fatalf("backend left open by Close: %d open", n)
+	}
+}
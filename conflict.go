@@ -0,0 +1,86 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// ErrWriteConflict is returned when writing to a file that was changed by
+// someone else since it was opened, with WithConflictDetection.
+var ErrWriteConflict = errors.New("multifs: file changed since opened")
+
+// WithConflictDetection checks, before every write to a file opened for
+// writing through the mount, that its size and modification time on the
+// backend are still those seen at open or after the last write through
+// the handle. Writes to files changed, replaced or removed in between
+// fail with ErrWriteConflict, so that servers can implement optimistic
+// concurrency. Changes that keep both the size and the modification time,
+// within the backend's resolution, go unnoticed.
+func WithConflictDetection() MountOption {
+	return func(o *mountOptions) {
+		o.conflicts = true
+	}
+}
+
+// conflictFile checks the file it was opened as for changes made by
+// others before writing to it.
+type conflictFile struct {
+	File
+	mnt   *mount
+	name  string
+	bname string
+	// size and mtime are the state of the file as last seen.
+	size  int64
+	mtime time.Time
+}
+
+func (mnt *mount) detectConflicts(name, bname string, f File) (File, error) {
+	cf := &conflictFile{File: f, mnt: mnt, name: name, bname: bname}
+	if err := cf.seen(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return cf, nil
+}
+
+// seen records the current state of the file on the backend.
+func (f *conflictFile) seen() error {
+	info, err := fs.Stat(f.mnt.fsys, f.bname)
+	if err != nil {
+		return err
+	}
+	f.size, f.mtime = info.Size(), info.ModTime()
+	return nil
+}
+
+// check fails with ErrWriteConflict if the file changed since last seen.
+func (f *conflictFile) check(op string) error {
+	info, err := fs.Stat(f.mnt.fsys, f.bname)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return &fs.PathError{Op: op, Path: f.name, Err: err}
+	case info.Size() == f.size && info.ModTime().Equal(f.mtime):
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: f.name, Err: ErrWriteConflict}
+}
+
+func (f *conflictFile) Write(p []byte) (int, error) {
+	if err := f.check("write"); err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(p)
+	f.seen()
+	return n, err
+}
+
+func (f *conflictFile) Truncate(size int64) error {
+	if err := f.check("truncate"); err != nil {
+		return err
+	}
+	err := f.File.Truncate(size)
+	f.seen()
+	return err
+}
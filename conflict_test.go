@@ -0,0 +1,54 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConflictDetection(t *testing.T) {
+	scratch := newDirFS(t)
+	writeFile(t, scratch, "f", "data")
+	mux := NewMultiFS()
+	mux.Mount("s", scratch, WithConflictDetection())
+
+	open := func() File {
+		t.Helper()
+		f, err := mux.OpenFile("s/f", os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+		return f
+	}
+
+	// Writes through the handle are not conflicts
+	f := open()
+	for range 3 {
+		if _, err := io.WriteString(f, "+"); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := f.Truncate(2); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	f.Close()
+
+	f = open()
+	defer f.Close()
+	writeFile(t, scratch, "f", "changed")
+	if _, err := io.WriteString(f, "+"); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("Write after a change: %v, want %v", err, ErrWriteConflict)
+	}
+
+	f2 := open()
+	defer f2.Close()
+	past := time.Now().Add(-time.Hour)
+	if err := scratch.Chtimes("f", past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := f2.Truncate(0); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("Truncate after a change: %v, want %v", err, ErrWriteConflict)
+	}
+}
@@ -0,0 +1,65 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+)
+
+// ContextFS is implemented by filesystems whose Open can be bounded or
+// cancelled through a context, typically network backends. MultiFS passes
+// the caller's context down to mounts implementing it.
+type ContextFS interface {
+	fs.FS
+	OpenContext(ctx context.Context, name string) (fs.File, error)
+}
+
+var _ ContextFS = (*MultiFS)(nil)
+var _ ContextFS = (*View)(nil)
+
+func (m *MultiFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	r, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.open(ctx)
+}
+
+func (m *MultiFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	r, err := m.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.stat(ctx)
+}
+
+func (m *MultiFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	r, err := m.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.readDir(ctx)
+}
+
+func (v *View) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	r, err := v.tab.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.open(ctx)
+}
+
+func (v *View) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	r, err := v.tab.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.stat(ctx)
+}
+
+func (v *View) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	r, err := v.tab.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.readDir(ctx)
+}
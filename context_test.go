@@ -0,0 +1,69 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+type ctxKey struct{}
+
+// recordingFS remembers the context of the last OpenContext call.
+type recordingFS struct {
+	fstest.MapFS
+	last context.Context
+}
+
+func (r *recordingFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	r.last = ctx
+	return r.MapFS.Open(name)
+}
+
+func TestOpenContextIsPassedToMount(t *testing.T) {
+	mux := NewMultiFS()
+	rec := &recordingFS{MapFS: fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}}
+	if err := mux.Mount("one", rec); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "marker")
+	f, err := mux.OpenContext(ctx, "one/file.txt")
+	if err != nil {
+		t.Fatalf("OpenContext: %v", err)
+	}
+	f.Close()
+
+	if rec.last == nil || rec.last.Value(ctxKey{}) != "marker" {
+		t.Fatalf("mount did not receive the caller's context")
+	}
+
+	if _, err := mux.StatContext(ctx, "one/file.txt"); err != nil {
+		t.Fatalf("StatContext: %v", err)
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
+	if err := mux.Mount("one", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	root, err := mux.OpenContext(ctx, ".")
+	if err != nil {
+		t.Fatalf("OpenContext(.): %v", err)
+	}
+	defer root.Close()
+
+	cancel()
+
+	if _, err := root.(fs.ReadDirFile).ReadDir(-1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("root ReadDir after cancel: got %v, want context.Canceled", err)
+	}
+	if _, err := mux.ReadDirContext(ctx, "one"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadDirContext after cancel: got %v, want context.Canceled", err)
+	}
+}
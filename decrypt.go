@@ -0,0 +1,201 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// Chunk locates one of the separately encrypted chunks of a stored file.
+type Chunk struct {
+	// Offset and Length locate the chunk in the stored file.
+	Offset int64
+	Length int64
+	// Size is the size of the chunk once decrypted.
+	Size int64
+}
+
+// Decrypter decrypts files stored as a sequence of separately encrypted
+// chunks, so that they can be read from any offset.
+type Decrypter interface {
+	// Chunks returns the chunks of the stored file name, in plaintext
+	// order, from an index kept by the backend or from f itself, the
+	// stored file, which should be read with ReadAt.
+	Chunks(name string, f fs.File) ([]Chunk, error)
+	// Decrypt returns the plaintext of the i-th chunk of name.
+	Decrypt(name string, i int, ciphertext []byte) ([]byte, error)
+}
+
+// WithDecrypter decrypts the regular files of the mount with d as they are
+// read. Stored files must be io.ReaderAt or io.Seeker; decrypted files are
+// both. Stat and listings report plaintext sizes. Contents are decrypted
+// before any WithTransform applies.
+func WithDecrypter(d Decrypter) MountOption {
+	return func(o *mountOptions) {
+		o.decrypter = d
+	}
+}
+
+// decryptFile wraps f, opened at name, unless it is a directory.
+func (mnt *mount) decryptFile(name string, f fs.File) fs.File {
+	if isDir(f) {
+		return f
+	}
+	return newDecryptedFile(f, mnt.opts.decrypter, name, "decrypt")
+}
+
+// newDecryptedFile returns f read through d, failing with errors reported
+// for op when d fails.
+func newDecryptedFile(f fs.File, d Decrypter, name, op string) fs.File {
+	df := &decryptedFile{File: f, d: d, name: name, op: op, cur: -1}
+	return compose(df, df, df, nil, nil)
+}
+
+// plaintextSize returns the size of the stored file f once decrypted.
+func plaintextSize(d Decrypter, name string, f fs.File) (int64, error) {
+	chunks, err := d.Chunks(name, f)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, c := range chunks {
+		size += c.Size
+	}
+	return size, nil
+}
+
+type decryptedFile struct {
+	fs.File
+	d    Decrypter
+	name string
+	op   string
+
+	mu     sync.Mutex
+	loaded bool
+	chunks []Chunk
+	starts []int64 // plaintext offset of each chunk
+	size   int64
+	off    int64
+	// cur is the index of the chunk held decrypted in plain.
+	cur   int
+	plain []byte
+}
+
+var _ io.ReaderAt = (*decryptedFile)(nil)
+var _ io.Seeker = (*decryptedFile)(nil)
+
+// load reads the chunk index. f.mu must be held.
+func (f *decryptedFile) load() error {
+	if f.loaded {
+		return nil
+	}
+	chunks, err := f.d.Chunks(f.name, f.File)
+	if err != nil {
+		return &fs.PathError{Op: f.op, Path: f.name, Err: err}
+	}
+	f.starts = make([]int64, len(chunks))
+	for i, c := range chunks {
+		f.starts[i] = f.size
+		f.size += c.Size
+	}
+	f.chunks, f.loaded = chunks, true
+	return nil
+}
+
+// chunk returns the plaintext of chunk i. f.mu must be held.
+func (f *decryptedFile) chunk(i int) ([]byte, error) {
+	if i == f.cur {
+		return f.plain, nil
+	}
+	c := f.chunks[i]
+	ciphertext := make([]byte, c.Length)
+	var err error
+	switch r := f.File.(type) {
+	case io.ReaderAt:
+		_, err = r.ReadAt(ciphertext, c.Offset)
+	case io.Seeker:
+		if _, err = r.Seek(c.Offset, io.SeekStart); err == nil {
+			_, err = io.ReadFull(f.File, ciphertext)
+		}
+	default:
+		err = errors.ErrUnsupported
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	plain, err := f.d.Decrypt(f.name, i, ciphertext)
+	if err == nil && int64(len(plain)) != c.Size {
+		err = errors.New("decrypted chunk size mismatch")
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: f.op, Path: f.name, Err: err}
+	}
+	f.cur, f.plain = i, plain
+	return plain, nil
+}
+
+func (f *decryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt(p, off)
+}
+
+// readAt is ReadAt with f.mu held.
+func (f *decryptedFile) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(p) {
+		if off >= f.size {
+			return n, io.EOF
+		}
+		i := sort.Search(len(f.starts), func(i int) bool { return f.starts[i] > off }) - 1
+		plain, err := f.chunk(i)
+		if err != nil {
+			return n, err
+		}
+		m := copy(p[n:], plain[off-f.starts[i]:])
+		n += m
+		off += int64(m)
+	}
+	return n, nil
+}
+
+func (f *decryptedFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt(p, f.off)
+	f.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *decryptedFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		if err := f.load(); err != nil {
+			return 0, err
+		}
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
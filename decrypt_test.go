@@ -0,0 +1,106 @@
+package multifs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// xorDecrypter decrypts chunks of 4 bytes, each stored after a one byte
+// key it is XORed with.
+type xorDecrypter struct{}
+
+func encryptXOR(plain []byte) []byte {
+	var out []byte
+	for i := 0; i < len(plain); i += 4 {
+		key := byte(i + 1)
+		out = append(out, key)
+		for _, b := range plain[i:min(i+4, len(plain))] {
+			out = append(out, b^key)
+		}
+	}
+	return out
+}
+
+func (xorDecrypter) Chunks(name string, f fs.File) ([]Chunk, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	for off := int64(0); off < info.Size(); off += 5 {
+		length := min(5, info.Size()-off)
+		chunks = append(chunks, Chunk{Offset: off, Length: length, Size: length - 1})
+	}
+	return chunks, nil
+}
+
+func (xorDecrypter) Decrypt(name string, i int, ciphertext []byte) ([]byte, error) {
+	plain := make([]byte, len(ciphertext)-1)
+	for j := range plain {
+		plain[j] = ciphertext[j+1] ^ ciphertext[0]
+	}
+	return plain, nil
+}
+
+func TestDecrypter(t *testing.T) {
+	plain := []byte("the quick brown fox jumps over the lazy dog")
+	for _, b := range backendsOf(t, fstest.MapFS{"secret": &fstest.MapFile{Data: encryptXOR(plain)}}) {
+		t.Run(b.name, func(t *testing.T) {
+			mux := NewMultiFS()
+			mux.Mount("vault", b.fsys, WithDecrypter(xorDecrypter{}))
+			testDecrypter(t, mux, plain)
+		})
+	}
+}
+
+func testDecrypter(t *testing.T, mux *MultiFS, plain []byte) {
+
+	if data, err := fs.ReadFile(mux, "vault/secret"); err != nil || !bytes.Equal(data, plain) {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if info, err := mux.Stat("vault/secret"); err != nil || info.Size() != int64(len(plain)) {
+		t.Fatalf("Stat: %v, %v", info, err)
+	}
+
+	f, err := mux.Open("vault/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := make([]byte, 9)
+	if n, err := f.(io.ReaderAt).ReadAt(p, 10); err != nil || string(p[:n]) != "brown fox" {
+		t.Fatalf("ReadAt: %q, %v", p[:n], err)
+	}
+	if _, err := f.(io.Seeker).Seek(-8, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if rest, err := io.ReadAll(f); err != nil || string(rest) != "lazy dog" {
+		t.Fatalf("Read after Seek: %q, %v", rest, err)
+	}
+
+	if err := fstest.TestFS(mux, "vault/secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecrypterTransform(t *testing.T) {
+	text := "compressed then encrypted"
+	gz := Gunzip
+	gz.LogicalSize = true
+	for _, b := range backendsOf(t, fstest.MapFS{"f": &fstest.MapFile{Data: encryptXOR(gzipped(t, text))}}) {
+		t.Run(b.name, func(t *testing.T) {
+			mux := NewMultiFS()
+			mux.Mount("vault", b.fsys, WithDecrypter(xorDecrypter{}), WithTransform(gz))
+
+			if data, err := fs.ReadFile(mux, "vault/f"); err != nil || string(data) != text {
+				t.Fatalf("ReadFile: %q, %v", data, err)
+			}
+			if info, err := mux.Stat("vault/f"); err != nil || info.Size() != int64(len(text)) {
+				t.Fatalf("Stat: %v, %v", info, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package multifs
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+type DedupConfig struct {
+	// Hash computes the content digests. It defaults to SHA-256.
+	Hash func() hash.Hash
+}
+
+// DedupIndex maps file contents, by digest, to the paths holding them
+// across a set of mounts. Paths are full MultiFS paths, id included.
+type DedupIndex struct {
+	mu      sync.RWMutex
+	digests map[string]*dedupEntry
+	paths   map[string]string
+}
+
+type dedupEntry struct {
+	size  int64
+	paths []string
+}
+
+type DedupStats struct {
+	Files       int
+	Contents    int
+	TotalBytes  int64
+	UniqueBytes int64
+}
+
+// BuildDedupIndex hashes every regular file of the given mounts, or of all
+// mounts when none is given.
+func (m *MultiFS) BuildDedupIndex(ctx context.Context, cfg DedupConfig, ids ...string) (*DedupIndex, error) {
+	if cfg.Hash == nil {
+		cfg.Hash = sha256.New
+	}
+	if len(ids) == 0 {
+		for _, info := range m.Mounts() {
+			ids = append(ids, info.ID)
+		}
+	}
+
+	x := &DedupIndex{
+		digests: make(map[string]*dedupEntry),
+		paths:   make(map[string]string),
+	}
+	for _, id := range ids {
+		mnt, ok := m.mount(id)
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		if err := x.add(ctx, mnt, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func (x *DedupIndex) add(ctx context.Context, mnt *mount, cfg *DedupConfig) error {
+	return WalkDir(ctx, mnt, ".", WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		digest, size, err := hashFile(ctx, mnt, name, cfg.Hash())
+		if err != nil {
+			return err
+		}
+
+		full := path.Join(mnt.id, name)
+		x.mu.Lock()
+		e := x.digests[digest]
+		if e == nil {
+			e = &dedupEntry{size: size}
+			x.digests[digest] = e
+		}
+		e.paths = append(e.paths, full)
+		x.paths[full] = digest
+		x.mu.Unlock()
+		return nil
+	})
+}
+
+func hashFile(ctx context.Context, mnt *mount, name string, h hash.Hash) (string, int64, error) {
+	f, err := mnt.open(ctx, name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	size, err := copyBuffer(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(h.Sum(nil)), size, nil
+}
+
+// Paths returns the sorted paths of the files whose content has digest.
+func (x *DedupIndex) Paths(digest []byte) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	e := x.digests[string(digest)]
+	if e == nil {
+		return nil
+	}
+	paths := append([]string(nil), e.paths...)
+	sort.Strings(paths)
+	return paths
+}
+
+// Digest returns the content digest of an indexed path.
+func (x *DedupIndex) Digest(name string) ([]byte, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	digest, ok := x.paths[name]
+	return []byte(digest), ok
+}
+
+// Duplicates returns the other paths holding the same content as name.
+func (x *DedupIndex) Duplicates(name string) []string {
+	digest, ok := x.Digest(name)
+	if !ok {
+		return nil
+	}
+	paths := x.Paths(digest)
+	for i, p := range paths {
+		if p == name {
+			return append(paths[:i], paths[i+1:]...)
+		}
+	}
+	return paths
+}
+
+func (x *DedupIndex) Stats() DedupStats {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var st DedupStats
+	for _, e := range x.digests {
+		st.Contents++
+		st.Files += len(e.paths)
+		st.UniqueBytes += e.size
+		st.TotalBytes += e.size * int64(len(e.paths))
+	}
+	return st
+}
@@ -0,0 +1,64 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDedupIndex(t *testing.T) {
+	snap1 := fstest.MapFS{
+		"a.txt":     &fstest.MapFile{Data: []byte("hello")},
+		"dir/b.txt": &fstest.MapFile{Data: []byte("world!")},
+	}
+	snap2 := fstest.MapFS{
+		"a-copy.txt": &fstest.MapFile{Data: []byte("hello")},
+		"c.txt":      &fstest.MapFile{Data: []byte("unique")},
+	}
+	mux := NewMultiFS()
+	mux.Mount("snap1", snap1)
+	mux.Mount("snap2", snap2)
+
+	x, err := mux.BuildDedupIndex(context.Background(), DedupConfig{})
+	if err != nil {
+		t.Fatalf("BuildDedupIndex: %v", err)
+	}
+
+	digest, ok := x.Digest("snap1/a.txt")
+	if !ok {
+		t.Fatal("snap1/a.txt is not indexed")
+	}
+	if got := strings.Join(x.Paths(digest), ","); got != "snap1/a.txt,snap2/a-copy.txt" {
+		t.Fatalf("Paths: got %s", got)
+	}
+	if got := strings.Join(x.Duplicates("snap2/a-copy.txt"), ","); got != "snap1/a.txt" {
+		t.Fatalf("Duplicates: got %s", got)
+	}
+
+	st := x.Stats()
+	want := DedupStats{Files: 4, Contents: 3, TotalBytes: 22, UniqueBytes: 17}
+	if st != want {
+		t.Fatalf("Stats: got %+v, want %+v", st, want)
+	}
+}
+
+func TestDedupIndexSelectedMounts(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("one", fstest.MapFS{"a": &fstest.MapFile{Data: []byte("x")}})
+	mux.Mount("two", fstest.MapFS{"b": &fstest.MapFile{Data: []byte("x")}})
+
+	x, err := mux.BuildDedupIndex(context.Background(), DedupConfig{}, "two")
+	if err != nil {
+		t.Fatalf("BuildDedupIndex: %v", err)
+	}
+	if _, ok := x.Digest("one/a"); ok {
+		t.Fatal("unselected mount was indexed")
+	}
+
+	if _, err := mux.BuildDedupIndex(context.Background(), DedupConfig{}, "three"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package multifs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strings"
+)
+
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change is a difference between two trees. Old is nil for additions and
+// New for removals.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Old  fs.FileInfo
+	New  fs.FileInfo
+}
+
+type DiffOptions struct {
+	// Hash, when set, compares files of equal size by content rather than
+	// by modification time.
+	Hash func() hash.Hash
+}
+
+// Diff compares the trees of two mounts and yields their differences in
+// lexical path order, as they are found. Every entry below an added or
+// removed directory is reported too; directories present on both sides
+// are never reported as modified. The sequence ends after the first error.
+func (m *MultiFS) Diff(ctx context.Context, idA, idB string, opts DiffOptions) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		a, ok := m.mount(idA)
+		if !ok {
+			yield(Change{}, &fs.PathError{Op: "diff", Path: idA, Err: fs.ErrNotExist})
+			return
+		}
+		b, ok := m.mount(idB)
+		if !ok {
+			yield(Change{}, &fs.PathError{Op: "diff", Path: idB, Err: fs.ErrNotExist})
+			return
+		}
+		d := &differ{ctx: ctx, a: a, b: b, opts: &opts, yield: yield}
+		if err := d.dir("."); err != nil && err != errStopDiff {
+			yield(Change{}, err)
+		}
+	}
+}
+
+// errStopDiff unwinds the comparison once the consumer stops iterating.
+var errStopDiff = errors.New("diff stopped")
+
+type differ struct {
+	ctx   context.Context
+	a, b  *mount
+	opts  *DiffOptions
+	yield func(Change, error) bool
+}
+
+func (d *differ) emit(c Change) error {
+	if !d.yield(c, nil) {
+		return errStopDiff
+	}
+	return nil
+}
+
+// dir compares a directory present on both sides.
+func (d *differ) dir(name string) error {
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	as, err := d.list(d.a, name)
+	if err != nil {
+		return err
+	}
+	bs, err := d.list(d.b, name)
+	if err != nil {
+		return err
+	}
+
+	for len(as) > 0 || len(bs) > 0 {
+		switch {
+		case len(bs) == 0 || (len(as) > 0 && as[0].Name() < bs[0].Name()):
+			err = d.one(Removed, d.a, path.Join(name, as[0].Name()), as[0])
+			as = as[1:]
+		case len(as) == 0 || bs[0].Name() < as[0].Name():
+			err = d.one(Added, d.b, path.Join(name, bs[0].Name()), bs[0])
+			bs = bs[1:]
+		default:
+			err = d.both(path.Join(name, as[0].Name()), as[0], bs[0])
+			as, bs = as[1:], bs[1:]
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) list(mnt *mount, name string) ([]fs.DirEntry, error) {
+	entries, err := mnt.readDir(d.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(x, y fs.DirEntry) int { return strings.Compare(x.Name(), y.Name()) })
+	return entries, nil
+}
+
+// one reports an entry present on a single side, along with everything
+// below it.
+func (d *differ) one(kind ChangeKind, mnt *mount, name string, e fs.DirEntry) error {
+	info, err := e.Info()
+	if err != nil {
+		return err
+	}
+	c := Change{Kind: kind, Path: name}
+	if kind == Added {
+		c.New = info
+	} else {
+		c.Old = info
+	}
+	if err := d.emit(c); err != nil {
+		return err
+	}
+	if !e.IsDir() {
+		return nil
+	}
+	return d.tree(kind, mnt, name)
+}
+
+func (d *differ) tree(kind ChangeKind, mnt *mount, name string) error {
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := d.list(mnt, name)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := d.one(kind, mnt, path.Join(name, e.Name()), e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// both compares an entry present on both sides.
+func (d *differ) both(name string, ea, eb fs.DirEntry) error {
+	if ea.IsDir() && eb.IsDir() {
+		return d.dir(name)
+	}
+
+	a, err := ea.Info()
+	if err != nil {
+		return err
+	}
+	b, err := eb.Info()
+	if err != nil {
+		return err
+	}
+	if ea.IsDir() != eb.IsDir() {
+		// A directory replaced by a file or the other way around: the
+		// entry is modified and the directory's content added or removed.
+		if err := d.emit(Change{Kind: Modified, Path: name, Old: a, New: b}); err != nil {
+			return err
+		}
+		if ea.IsDir() {
+			return d.tree(Removed, d.a, name)
+		}
+		return d.tree(Added, d.b, name)
+	}
+
+	same, err := d.same(name, a, b)
+	if err != nil || same {
+		return err
+	}
+	return d.emit(Change{Kind: Modified, Path: name, Old: a, New: b})
+}
+
+func (d *differ) same(name string, a, b fs.FileInfo) (bool, error) {
+	if a.Size() != b.Size() || a.Mode() != b.Mode() {
+		return false, nil
+	}
+	if d.opts.Hash == nil || !a.Mode().IsRegular() {
+		return a.ModTime().Equal(b.ModTime()), nil
+	}
+
+	ha, err := d.hash(d.a, name)
+	if err != nil {
+		return false, err
+	}
+	hb, err := d.hash(d.b, name)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ha, hb), nil
+}
+
+func (d *differ) hash(mnt *mount, name string) ([]byte, error) {
+	f, err := mnt.open(d.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := d.opts.Hash()
+	if _, err := copyBuffer(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
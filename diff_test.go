@@ -0,0 +1,83 @@
+package multifs
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func diffString(t *testing.T, mux *MultiFS, opts DiffOptions) string {
+	t.Helper()
+	var changes []string
+	for c, err := range mux.Diff(context.Background(), "a", "b", opts) {
+		if err != nil {
+			t.Fatalf("Diff: %v", err)
+		}
+		changes = append(changes, fmt.Sprintf("%s %s", c.Kind, c.Path))
+	}
+	return strings.Join(changes, ",")
+}
+
+func TestDiff(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	a := fstest.MapFS{
+		"same.txt":      &fstest.MapFile{Data: []byte("x"), ModTime: t0},
+		"touched.txt":   &fstest.MapFile{Data: []byte("x"), ModTime: t0},
+		"grown.txt":     &fstest.MapFile{Data: []byte("x"), ModTime: t0},
+		"gone/one.txt":  &fstest.MapFile{ModTime: t0},
+		"kept/two.txt":  &fstest.MapFile{ModTime: t0},
+		"swapped/x.txt": &fstest.MapFile{ModTime: t0},
+	}
+	b := fstest.MapFS{
+		"same.txt":     &fstest.MapFile{Data: []byte("x"), ModTime: t0},
+		"touched.txt":  &fstest.MapFile{Data: []byte("x"), ModTime: t0.Add(time.Hour)},
+		"grown.txt":    &fstest.MapFile{Data: []byte("xy"), ModTime: t0},
+		"kept/two.txt": &fstest.MapFile{ModTime: t0},
+		"new/three":    &fstest.MapFile{ModTime: t0},
+		"swapped":      &fstest.MapFile{ModTime: t0},
+	}
+	mux := NewMultiFS()
+	mux.Mount("a", a)
+	mux.Mount("b", b)
+
+	got := diffString(t, mux, DiffOptions{})
+	want := "removed gone,removed gone/one.txt,modified grown.txt,added new,added new/three," +
+		"modified swapped,removed swapped/x.txt,modified touched.txt"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	// By content, only touching a file is no longer a change.
+	got = diffString(t, mux, DiffOptions{Hash: sha256.New})
+	if strings.Contains(got, "touched.txt") {
+		t.Fatalf("touched.txt reported with content hashing: %s", got)
+	}
+}
+
+func TestDiffStop(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("a", fstest.MapFS{"1": {}, "2": {}, "3": {}})
+	mux.Mount("b", fstest.MapFS{})
+
+	n := 0
+	for _, err := range mux.Diff(context.Background(), "a", "b", DiffOptions{}) {
+		if err != nil {
+			t.Fatalf("Diff: %v", err)
+		}
+		n++
+		break
+	}
+	if n != 1 {
+		t.Fatalf("got %d changes", n)
+	}
+
+	for _, err := range mux.Diff(context.Background(), "a", "missing", DiffOptions{}) {
+		if err == nil {
+			t.Fatal("expected an error for a missing mount")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package multifs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// MountDir mounts the directory osPath of the host under id. Unlike with
+// os.DirFS, accesses cannot leave the directory: symbolic links are only
+// followed as long as they resolve inside it, and fail otherwise. Files
+// can be created, written, removed, linked and have their metadata changed
+// through the mount, but not renamed.
+// The directory is closed once unmounted, from m and its clones, and no
+// longer held by a View.
+func (m *MultiFS) MountDir(id, osPath string, opts ...MountOption) error {
+	fsys, err := openHostDir(osPath)
+	if err != nil {
+		return err
+	}
+	owner := own(fsys)
+	defer owner.settle()
+	return m.Mount(id, fsys, append(opts, owner.option())...)
+}
+
+// hostDir is a directory of the host, accessed through an os.Root.
+type hostDir struct {
+	fs.FS
+	root *os.Root
+}
+
+var _ OpenFileFS = (*hostDir)(nil)
+var _ MkdirFS = (*hostDir)(nil)
+var _ RemoveFS = (*hostDir)(nil)
+var _ ChmodFS = (*hostDir)(nil)
+var _ ChtimesFS = (*hostDir)(nil)
+var _ ChownFS = (*hostDir)(nil)
+var _ SymlinkFS = (*hostDir)(nil)
+var _ LinkFS = (*hostDir)(nil)
+
+func openHostDir(name string) (*hostDir, error) {
+	root, err := os.OpenRoot(name)
+	if err != nil {
+		return nil, err
+	}
+	return &hostDir{FS: root.FS(), root: root}, nil
+}
+
+func (d *hostDir) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := d.root.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (d *hostDir) Mkdir(name string, perm fs.FileMode) error { return d.root.Mkdir(name, perm) }
+func (d *hostDir) Remove(name string) error                  { return d.root.Remove(name) }
+func (d *hostDir) Chmod(name string, mode fs.FileMode) error { return d.root.Chmod(name, mode) }
+func (d *hostDir) Chown(name string, uid, gid int) error     { return d.root.Chown(name, uid, gid) }
+func (d *hostDir) Symlink(oldname, newname string) error     { return d.root.Symlink(oldname, newname) }
+func (d *hostDir) Link(oldname, newname string) error        { return d.root.Link(oldname, newname) }
+func (d *hostDir) ReadLink(name string) (string, error)      { return d.root.Readlink(name) }
+func (d *hostDir) Lstat(name string) (fs.FileInfo, error)    { return d.root.Lstat(name) }
+
+func (d *hostDir) Chtimes(name string, atime, mtime time.Time) error {
+	return d.root.Chtimes(name, atime, mtime)
+}
+
+// Close releases the directory, for MultiFS.Close.
+func (d *hostDir) Close() error { return d.root.Close() }
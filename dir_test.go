@@ -0,0 +1,152 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMountDir(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "inside")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../../../../..", filepath.Join(dir, "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewMultiFS()
+	if err := mux.MountDir("d", dir); err != nil {
+		t.Fatalf("MountDir: %v", err)
+	}
+	if data, err := fs.ReadFile(mux, "d/inside"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile through a link inside: %q, %v", data, err)
+	}
+	for _, name := range []string{"d/escape", "d/up/etc/passwd"} {
+		if data, err := fs.ReadFile(mux, name); err == nil {
+			t.Fatalf("ReadFile %s escaped the directory: %q", name, data)
+		}
+	}
+
+	// The write path is available too
+	mnt, _ := mux.mount("d")
+	f, err := mnt.openFile(context.Background(), "new.txt", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("openFile: %v", err)
+	}
+	io.WriteString(f, "new")
+	f.Close()
+	if data, err := os.ReadFile(filepath.Join(dir, "new.txt")); err != nil || string(data) != "new" {
+		t.Fatalf("written file: %q, %v", data, err)
+	}
+	if _, err := mnt.openFile(context.Background(), "escape", os.O_WRONLY|os.O_TRUNC, 0); err == nil {
+		t.Fatal("openFile wrote through a link escaping the directory")
+	}
+
+	if err := mux.MountDir("missing", filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("MountDir succeeded on a missing directory")
+	}
+}
+
+func TestMountDirClosedOnUnmount(t *testing.T) {
+	mux := NewMultiFS()
+	if err := mux.MountDir("d", t.TempDir()); err != nil {
+		t.Fatalf("MountDir: %v", err)
+	}
+	mnt, _ := mux.mount("d")
+	root := mnt.backend.(*hostDir).root
+	mux.Unmount("d")
+	if _, err := root.Stat("."); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("directory left open after Unmount: %v", err)
+	}
+}
+
+func TestMountDirHeldByView(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mux := NewMultiFS()
+	if err := mux.MountDir("d", dir); err != nil {
+		t.Fatalf("MountDir: %v", err)
+	}
+	mnt, _ := mux.mount("d")
+	root := mnt.backend.(*hostDir).root
+
+	v := mux.Stable()
+	mux.Unmount("d")
+	if data, err := fs.ReadFile(v, "d/a.txt"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile through a View after Unmount: %q, %v", data, err)
+	}
+	v.Release()
+	if _, err := root.Stat("."); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("directory left open after Release: %v", err)
+	}
+}
+
+func TestMountDirMetadata(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "f"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mux := NewMultiFS()
+	if err := mux.MountDir("d", dir); err != nil {
+		t.Fatalf("MountDir: %v", err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := mux.Chmod("d/f", 0o600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	if err := mux.Chtimes("d/f", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := mux.Chown("d/f", os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("Chown: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "f"))
+	if err != nil || info.Mode().Perm() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Stat: %v %v, %v", info.Mode(), info.ModTime(), err)
+	}
+}
+
+func TestMountDirLinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "f"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mux := NewMultiFS()
+	if err := mux.MountDir("d", dir); err != nil {
+		t.Fatalf("MountDir: %v", err)
+	}
+
+	if err := mux.Symlink("f", "d/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "f" {
+		t.Fatalf("Readlink: %q, %v", target, err)
+	}
+	if err := mux.Link("d/f", "d/hard"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	for _, name := range []string{"d/link", "d/hard"} {
+		if data, err := fs.ReadFile(mux, name); err != nil || string(data) != "data" {
+			t.Fatalf("ReadFile %s: %q, %v", name, data, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DUOptions struct {
+	// Progress is called with the size of every directory once computed,
+	// or found in the cache.
+	Progress func(name string, size Size)
+}
+
+// DU totals the files below name, which may be any path of the tree, or
+// the file name itself. The sizes of the directories inside each mount are
+// cached, with the same lifetime as those of ComputeSize: replacing or
+// writing to a mount invalidates them. Computed is when the oldest cached
+// size it is made of was computed.
+func (m *MultiFS) DU(ctx context.Context, name string) (Size, error) {
+	return m.DUWith(ctx, DUOptions{}, name)
+}
+
+// DUWith is DU with options.
+func (m *MultiFS) DUWith(ctx context.Context, opts DUOptions, name string) (Size, error) {
+	r, err := m.resolve("du", name)
+	if err != nil {
+		return Size{}, err
+	}
+	if r.mnt == nil {
+		return m.rootUsage(ctx, opts)
+	}
+	info, err := r.stat(ctx)
+	if err != nil {
+		return Size{}, err
+	}
+	if !info.IsDir() {
+		return fileUsage(info), nil
+	}
+	var report func(string, Size)
+	if opts.Progress != nil {
+		report = func(sub string, size Size) {
+			if r.subpath != "." {
+				sub = strings.TrimPrefix(strings.TrimPrefix(sub, r.subpath), "/")
+			}
+			opts.Progress(path.Join(name, sub), size)
+		}
+	}
+	return r.mnt.dirUsage(ctx, r.subpath, report)
+}
+
+// rootUsage totals the entries of the synthetic root.
+func (m *MultiFS) rootUsage(ctx context.Context, opts DUOptions) (Size, error) {
+	entries, err := m.ReadDirContext(ctx, ".")
+	if err != nil {
+		return Size{}, err
+	}
+	size := Size{Dirs: 1, Computed: time.Now()}
+	for _, e := range entries {
+		sub, err := m.DUWith(ctx, opts, e.Name())
+		if err != nil {
+			return Size{}, err
+		}
+		size.add(sub)
+	}
+	if opts.Progress != nil {
+		opts.Progress(".", size)
+	}
+	return size, nil
+}
+
+func fileUsage(info fs.FileInfo) Size {
+	if !info.Mode().IsRegular() {
+		return Size{Computed: time.Now()}
+	}
+	return Size{Files: 1, Bytes: info.Size(), Computed: time.Now()}
+}
+
+func (s *Size) add(sub Size) {
+	s.Files += sub.Files
+	s.Dirs += sub.Dirs
+	s.Bytes += sub.Bytes
+	if sub.Computed.Before(s.Computed) {
+		s.Computed = sub.Computed
+	}
+}
+
+// sizeCache holds the sizes computed for the directories of a mount. gen
+// is bumped by writes, so that a walk overlapping one does not get cached.
+type sizeCache struct {
+	mu    sync.Mutex
+	sizes map[string]Size
+	gen   int64
+}
+
+func (c *sizeCache) get(name string) (Size, bool, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	size, ok := c.sizes[name]
+	return size, ok, c.gen
+}
+
+func (c *sizeCache) put(name string, size Size, gen int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	if c.sizes == nil {
+		c.sizes = make(map[string]Size)
+	}
+	c.sizes[name] = size
+}
+
+func (c *sizeCache) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sizes = nil
+	c.gen++
+}
+
+// diskUsage totals the files below name, inside the mount, reporting the
+// size of every directory to report if not nil.
+func (mnt *mount) diskUsage(ctx context.Context, name string, report func(string, Size)) (Size, error) {
+	if _, ok, _ := mnt.size.get(name); !ok {
+		info, err := fs.Stat(mnt, name)
+		if err != nil {
+			return Size{}, err
+		}
+		if !info.IsDir() {
+			return fileUsage(info), nil
+		}
+	}
+	return mnt.dirUsage(ctx, name, report)
+}
+
+// dirUsage is diskUsage for a directory.
+func (mnt *mount) dirUsage(ctx context.Context, name string, report func(string, Size)) (Size, error) {
+	size, ok, gen := mnt.size.get(name)
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return Size{}, err
+		}
+		entries, err := readDirContext(ctx, mnt, name)
+		if err != nil {
+			return Size{}, err
+		}
+		size = Size{Dirs: 1, Computed: time.Now()}
+		for _, e := range entries {
+			var sub Size
+			if e.IsDir() {
+				sub, err = mnt.dirUsage(ctx, path.Join(name, e.Name()), report)
+			} else if e.Type().IsRegular() {
+				var info fs.FileInfo
+				if info, err = e.Info(); err == nil {
+					sub = fileUsage(info)
+				}
+			}
+			if err != nil {
+				return Size{}, err
+			}
+			size.add(sub)
+		}
+		mnt.size.put(name, size, gen)
+	}
+	if report != nil {
+		report(name, size)
+	}
+	return size, nil
+}
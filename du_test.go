@@ -0,0 +1,46 @@
+package multifs
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDU(t *testing.T) {
+	ctx := context.Background()
+	backend := fstest.MapFS{
+		"a":       &fstest.MapFile{Data: []byte("12345")},
+		"dir/b":   &fstest.MapFile{Data: []byte("123")},
+		"dir/c/d": &fstest.MapFile{Data: []byte("1")},
+	}
+	mux := NewMultiFS()
+	mux.Mount("s", backend)
+	mux.Mount("t", fstest.MapFS{"e": &fstest.MapFile{Data: []byte("12")}})
+
+	var dirs []string
+	opts := DUOptions{Progress: func(name string, size Size) { dirs = append(dirs, name) }}
+	size, err := mux.DUWith(ctx, opts, "s/dir")
+	if err != nil || size.Files != 2 || size.Dirs != 2 || size.Bytes != 4 {
+		t.Fatalf("DU: %+v, %v", size, err)
+	}
+	if !slices.Equal(dirs, []string{"s/dir/c", "s/dir"}) {
+		t.Fatalf("progress: %v", dirs)
+	}
+	if size, err := mux.DU(ctx, "s/a"); err != nil || size.Files != 1 || size.Bytes != 5 {
+		t.Fatalf("DU on a file: %+v, %v", size, err)
+	}
+	if size, err := mux.DU(ctx, "."); err != nil || size.Files != 4 || size.Dirs != 5 || size.Bytes != 11 {
+		t.Fatalf("DU on the root: %+v, %v", size, err)
+	}
+
+	// Cached until the mount is replaced
+	backend["dir/f"] = &fstest.MapFile{Data: []byte("123")}
+	if size, _ := mux.DU(ctx, "s/dir"); size.Files != 2 {
+		t.Fatalf("DU after backend change: %+v", size)
+	}
+	mux.Replace("s", backend)
+	if size, _ := mux.DU(ctx, "s/dir"); size.Files != 3 || size.Bytes != 7 {
+		t.Fatalf("DU after Replace: %+v", size)
+	}
+}
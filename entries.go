@@ -0,0 +1,46 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+)
+
+// entriesChunk is how many entries Entries reads at once.
+const entriesChunk = 256
+
+// Entries streams the entries of the directory name, reading them from the
+// backend a few at a time rather than all at once as ReadDir does. Entries
+// come in the order the backend lists them, which unlike that of ReadDir
+// may not be sorted. The sequence ends after the first error.
+func (m *MultiFS) Entries(name string) iter.Seq2[fs.DirEntry, error] {
+	return func(yield func(fs.DirEntry, error) bool) {
+		f, err := m.Open(name)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer f.Close()
+		dir, ok := f.(fs.ReadDirFile)
+		if !ok {
+			yield(nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")})
+			return
+		}
+		for {
+			entries, err := dir.ReadDir(entriesChunk)
+			for _, e := range entries {
+				if !yield(e, nil) {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
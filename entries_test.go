@@ -0,0 +1,73 @@
+package multifs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// chunkCountFS records the largest number of entries asked to ReadDir.
+type chunkCountFS struct {
+	fstest.MapFS
+	largest int
+}
+
+func (c *chunkCountFS) Open(name string) (fs.File, error) {
+	f, err := c.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok {
+		return &chunkCountDir{ReadDirFile: dir, fs: c}, nil
+	}
+	return f, nil
+}
+
+type chunkCountDir struct {
+	fs.ReadDirFile
+	fs *chunkCountFS
+}
+
+func (d *chunkCountDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		n = 1 << 30
+	}
+	d.fs.largest = max(d.fs.largest, n)
+	return d.ReadDirFile.ReadDir(n)
+}
+
+func TestEntries(t *testing.T) {
+	backend := &chunkCountFS{MapFS: fstest.MapFS{}}
+	for i := range 1000 {
+		backend.MapFS[fmt.Sprintf("dir/%d", i)] = &fstest.MapFile{}
+	}
+	mux := NewMultiFS()
+	mux.Mount("s", backend)
+
+	seen := make(map[string]bool)
+	for e, err := range mux.Entries("s/dir") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[e.Name()] = true
+	}
+	if len(seen) != 1000 {
+		t.Fatalf("%d entries, want 1000", len(seen))
+	}
+	if backend.largest > entriesChunk {
+		t.Fatalf("backend asked for %d entries at once", backend.largest)
+	}
+
+	for _, err := range mux.Entries("s/dir/0") {
+		if err == nil || err == io.EOF {
+			t.Fatalf("Entries on a file: %v", err)
+		}
+	}
+	for _, err := range mux.Entries("s/missing") {
+		if err == nil {
+			t.Fatalf("Entries on a missing directory succeeded")
+		}
+	}
+}
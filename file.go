@@ -0,0 +1,257 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync/atomic"
+	"time"
+)
+
+// mountFile is what a mount hands out for every file opened on its
+// backend. It accounts for reads, writes and handle lifetime.
+type mountFile struct {
+	fs.File
+	mnt    *mount
+	ctx    context.Context
+	name   string
+	closed atomic.Bool
+	opened time.Time
+	stack  []byte
+	leak   *time.Timer
+}
+
+func (mnt *mount) wrap(ctx context.Context, name string, f fs.File) fs.File {
+	mf := mnt.newFile(ctx, name, f)
+	var readerAt io.ReaderAt
+	if _, ok := f.(io.ReaderAt); ok {
+		readerAt = mountFileReaderAt{mf}
+	}
+	seeker, _ := f.(io.Seeker)
+	writer, _ := f.(io.Writer)
+	var dir readDirer
+	if _, ok := f.(fs.ReadDirFile); ok {
+		dir = mountFileDir{mf}
+	}
+	return compose(mf, seeker, readerAt, writer, dir)
+}
+
+// wrapWritable is wrap for the files opened for writing.
+func (mnt *mount) wrapWritable(ctx context.Context, name string, f File) File {
+	return mountFileWriter{mnt.newFile(ctx, name, f)}
+}
+
+// newFile accounts for f, which admit counted among the open files.
+func (mnt *mount) newFile(ctx context.Context, name string, f fs.File) *mountFile {
+	mnt.usage.opens.Add(1)
+
+	mf := &mountFile{File: f, mnt: mnt, ctx: ctx, name: name, opened: time.Now()}
+	mnt.track(mf)
+	return mf
+}
+
+func (f *mountFile) Read(p []byte) (int, error) {
+	if f.mnt.detached.Load() {
+		return 0, ErrUnmounted
+	}
+	p, err := f.mnt.reserve(p)
+	if err != nil {
+		return 0, err
+	}
+	_, t := f.mnt.begin(f.ctx, "read", f.name)
+	n, err := f.File.Read(f.mnt.throttleRead(p))
+	f.mnt.unreserve(p, n)
+	t.end(int64(n), err)
+	if werr := f.mnt.throttled(f.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+func (f *mountFile) Close() error {
+	if f.closed.CompareAndSwap(false, true) {
+		f.mnt.usage.openFiles.Add(-1)
+		f.mnt.untrack(f)
+		f.mnt.release()
+	}
+	return f.File.Close()
+}
+
+type mountFileWriter struct {
+	*mountFile
+}
+
+func (f mountFileWriter) Write(p []byte) (int, error) {
+	if f.mnt.detached.Load() {
+		return 0, ErrUnmounted
+	}
+	_, t := f.mnt.begin(f.ctx, "write", f.name)
+	n, err := f.File.(io.Writer).Write(p)
+	f.mnt.usage.bytesWritten.Add(int64(n))
+	t.end(int64(n), err)
+	return n, err
+}
+
+func (f mountFileWriter) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f mountFileWriter) Truncate(size int64) error {
+	if f.mnt.detached.Load() {
+		return ErrUnmounted
+	}
+	return f.File.(File).Truncate(size)
+}
+
+type mountFileReaderAt struct {
+	*mountFile
+}
+
+func (f mountFileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if f.mnt.detached.Load() {
+		return 0, ErrUnmounted
+	}
+	p, err := f.mnt.reserve(p)
+	if err != nil {
+		return 0, err
+	}
+	_, t := f.mnt.begin(f.ctx, "read", f.name)
+	var n int
+	for n < len(p) && err == nil {
+		var m int
+		m, err = f.File.(io.ReaderAt).ReadAt(f.mnt.throttleRead(p[n:]), off+int64(n))
+		n += m
+		if werr := f.mnt.throttled(f.ctx, m); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	f.mnt.unreserve(p, n)
+	t.end(int64(n), err)
+	return n, err
+}
+
+type mountFileDir struct {
+	*mountFile
+}
+
+func (f mountFileDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.mnt.detached.Load() {
+		return nil, ErrUnmounted
+	}
+	return f.File.(fs.ReadDirFile).ReadDir(n)
+}
+
+// compose builds a file out of f and whichever optional interfaces are
+// non-nil, so that wrappers do not hide or invent capabilities of the file
+// they wrap.
+func compose(f fs.File, seeker io.Seeker, readerAt io.ReaderAt, writer io.Writer, dir readDirer) fs.File {
+	switch {
+	case seeker != nil && readerAt != nil && writer != nil && dir != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			io.ReaderAt
+			io.Writer
+			readDirer
+		}{f, seeker, readerAt, writer, dir}
+	case readerAt != nil && writer != nil && dir != nil:
+		return struct {
+			fs.File
+			io.ReaderAt
+			io.Writer
+			readDirer
+		}{f, readerAt, writer, dir}
+	case seeker != nil && writer != nil && dir != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			io.Writer
+			readDirer
+		}{f, seeker, writer, dir}
+	case seeker != nil && readerAt != nil && dir != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			io.ReaderAt
+			readDirer
+		}{f, seeker, readerAt, dir}
+	case seeker != nil && readerAt != nil && writer != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			io.ReaderAt
+			io.Writer
+		}{f, seeker, readerAt, writer}
+	case writer != nil && dir != nil:
+		return struct {
+			fs.File
+			io.Writer
+			readDirer
+		}{f, writer, dir}
+	case readerAt != nil && dir != nil:
+		return struct {
+			fs.File
+			io.ReaderAt
+			readDirer
+		}{f, readerAt, dir}
+	case seeker != nil && dir != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			readDirer
+		}{f, seeker, dir}
+	case readerAt != nil && writer != nil:
+		return struct {
+			fs.File
+			io.ReaderAt
+			io.Writer
+		}{f, readerAt, writer}
+	case seeker != nil && writer != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			io.Writer
+		}{f, seeker, writer}
+	case seeker != nil && readerAt != nil:
+		return struct {
+			fs.File
+			io.Seeker
+			io.ReaderAt
+		}{f, seeker, readerAt}
+	case dir != nil:
+		return struct {
+			fs.File
+			readDirer
+		}{f, dir}
+	case writer != nil:
+		return struct {
+			fs.File
+			io.Writer
+		}{f, writer}
+	case readerAt != nil:
+		return struct {
+			fs.File
+			io.ReaderAt
+		}{f, readerAt}
+	case seeker != nil:
+		return struct {
+			fs.File
+			io.Seeker
+		}{f, seeker}
+	}
+	return struct{ fs.File }{f}
+}
+
+// isDir reports whether f is a directory. Implementing fs.ReadDirFile does
+// not tell: *os.File and the files of memfs do for regular files too.
+func isDir(f fs.File) bool {
+	if _, ok := f.(fs.ReadDirFile); !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.IsDir()
+}
+
+type readDirer interface {
+	ReadDir(n int) ([]fs.DirEntry, error)
+}
@@ -0,0 +1,116 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"iter"
+	"path"
+	"time"
+)
+
+// Predicate selects the entries yielded by Find. Predicates needing more
+// than the name and type of an entry get its info from d.Info, and do not
+// match entries whose info cannot be read.
+type Predicate func(name string, d fs.DirEntry) bool
+
+// Result is an entry found by Find, or an error met along the way.
+type Result struct {
+	Path  string
+	Entry fs.DirEntry
+	Err   error
+}
+
+// Find walks the tree below root, root included, and yields the entries
+// matching pred, or every entry if pred is nil, as they are found. Errors
+// reading directories are yielded too, the walk going on if the iteration
+// does.
+func (m *MultiFS) Find(ctx context.Context, root string, pred Predicate) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		err := WalkDir(ctx, m, root, WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				if !yield(Result{Path: name, Err: err}) {
+					return fs.SkipAll
+				}
+			case pred == nil || pred(name, d):
+				if !yield(Result{Path: name, Entry: d}) {
+					return fs.SkipAll
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			yield(Result{Path: root, Err: err})
+		}
+	}
+}
+
+// Where matches the entries matching all of preds.
+func Where(preds ...Predicate) Predicate {
+	return func(name string, d fs.DirEntry) bool {
+		for _, pred := range preds {
+			if !pred(name, d) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the entries matching any of preds.
+func Or(preds ...Predicate) Predicate {
+	return func(name string, d fs.DirEntry) bool {
+		for _, pred := range preds {
+			if pred(name, d) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func Not(pred Predicate) Predicate {
+	return func(name string, d fs.DirEntry) bool { return !pred(name, d) }
+}
+
+// NameGlob matches the entries whose base name matches pattern, in the
+// syntax of path.Match.
+func NameGlob(pattern string) Predicate {
+	return func(name string, d fs.DirEntry) bool {
+		ok, _ := path.Match(pattern, d.Name())
+		return ok
+	}
+}
+
+func IsDir() Predicate {
+	return func(name string, d fs.DirEntry) bool { return d.IsDir() }
+}
+
+func IsRegular() Predicate {
+	return func(name string, d fs.DirEntry) bool { return d.Type().IsRegular() }
+}
+
+// SizeGreater matches the regular files larger than size bytes.
+func SizeGreater(size int64) Predicate {
+	return infoPredicate(func(info fs.FileInfo) bool { return info.Mode().IsRegular() && info.Size() > size })
+}
+
+// SizeLess matches the regular files smaller than size bytes.
+func SizeLess(size int64) Predicate {
+	return infoPredicate(func(info fs.FileInfo) bool { return info.Mode().IsRegular() && info.Size() < size })
+}
+
+func ModifiedAfter(t time.Time) Predicate {
+	return infoPredicate(func(info fs.FileInfo) bool { return info.ModTime().After(t) })
+}
+
+func ModifiedBefore(t time.Time) Predicate {
+	return infoPredicate(func(info fs.FileInfo) bool { return info.ModTime().Before(t) })
+}
+
+func infoPredicate(fn func(fs.FileInfo) bool) Predicate {
+	return func(name string, d fs.DirEntry) bool {
+		info, err := d.Info()
+		return err == nil && fn(info)
+	}
+}
@@ -0,0 +1,57 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mux := NewMultiFS()
+	mux.Mount("s", fstest.MapFS{
+		"app.log":      &fstest.MapFile{Data: make([]byte, 100), ModTime: recent},
+		"small.log":    &fstest.MapFile{Data: make([]byte, 10), ModTime: recent},
+		"old/app.log":  &fstest.MapFile{Data: make([]byte, 100), ModTime: old},
+		"old/data.bin": &fstest.MapFile{Data: make([]byte, 100), ModTime: recent},
+		"logs.log/x":   &fstest.MapFile{},
+	})
+	mux.Mount("t", fstest.MapFS{"b.log": &fstest.MapFile{Data: make([]byte, 100), ModTime: recent}})
+
+	find := func(root string, pred Predicate) []string {
+		var names []string
+		for r := range mux.Find(context.Background(), root, pred) {
+			if r.Err != nil {
+				t.Fatalf("Find: %v", r.Err)
+			}
+			names = append(names, r.Path)
+		}
+		return names
+	}
+
+	got := find(".", Where(NameGlob("*.log"), SizeGreater(50), ModifiedAfter(old.Add(time.Hour))))
+	if want := []string{"s/app.log", "t/b.log"}; !slices.Equal(got, want) {
+		t.Fatalf("Find: %v, want %v", got, want)
+	}
+	got = find("s", Where(NameGlob("*.log"), Not(IsDir()), Or(SizeLess(50), ModifiedBefore(recent))))
+	if want := []string{"s/old/app.log", "s/small.log"}; !slices.Equal(got, want) {
+		t.Fatalf("Find: %v, want %v", got, want)
+	}
+	if got := find("s/old", nil); len(got) != 3 {
+		t.Fatalf("Find: %v", got)
+	}
+
+	for r := range mux.Find(context.Background(), "missing", nil) {
+		if !errors.Is(r.Err, fs.ErrNotExist) {
+			t.Fatalf("Find: %+v", r)
+		}
+	}
+	for range mux.Find(context.Background(), ".", nil) {
+		break
+	}
+}
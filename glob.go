@@ -0,0 +1,151 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strings"
+)
+
+// GlobAll yields the paths matching pattern as they are found. Patterns
+// are those of path.Match, applied to each path element, plus "**" as a
+// whole element matching any number of elements, including none: so
+// "snap*/home/**/*.jpg" matches the JPEG files at any depth below the
+// home directory of the mounts whose id starts with "snap". Only the
+// directories the pattern can match below are listed, and elements
+// without wildcards are looked up rather than listed. Errors reading a
+// directory are yielded with its path, the walk going on if the
+// iteration does; a malformed pattern yields path.ErrBadPattern alone.
+func (m *MultiFS) GlobAll(ctx context.Context, pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		elems, err := globElems(pattern)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		g := &globber{ctx: ctx, m: m, elems: elems, yield: yield}
+		g.walk(".", g.closure([]int{0}))
+	}
+}
+
+// globElems splits pattern into elements, checking their syntax.
+func globElems(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, path.ErrBadPattern
+	}
+	var elems []string
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "**" && len(elems) > 0 && elems[len(elems)-1] == "**" {
+			continue
+		}
+		if _, err := path.Match(elem, ""); err != nil || elem == "" {
+			return nil, path.ErrBadPattern
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+// errStopGlob unwinds the walk once the consumer stops iterating.
+var errStopGlob = errors.New("glob stopped")
+
+// globber matches paths against the pattern elements as the walk goes
+// down, tracking the elements each path can be followed by, so that every
+// path is visited at most once however many ways it matches.
+type globber struct {
+	ctx   context.Context
+	m     *MultiFS
+	elems []string
+	yield func(string, error) bool
+}
+
+func (g *globber) emit(name string, err error) error {
+	if !g.yield(name, err) {
+		return errStopGlob
+	}
+	return nil
+}
+
+// closure adds to states the elements following "**", which can match no
+// element at all.
+func (g *globber) closure(states []int) []int {
+	for i := 0; i < len(states); i++ {
+		if s := states[i]; s < len(g.elems) && g.elems[s] == "**" && !slices.Contains(states, s+1) {
+			states = append(states, s+1)
+		}
+	}
+	return states
+}
+
+// next returns the states following states once base is matched.
+func (g *globber) next(states []int, base string) []int {
+	var next []int
+	for _, s := range states {
+		if s == len(g.elems) {
+			continue
+		}
+		if g.elems[s] == "**" {
+			next = append(next, s)
+		} else if ok, _ := path.Match(g.elems[s], base); ok && !slices.Contains(next, s+1) {
+			next = append(next, s+1)
+		}
+	}
+	return g.closure(next)
+}
+
+// walk yields the paths below dir, a directory reached in states.
+func (g *globber) walk(dir string, states []int) error {
+	if err := g.ctx.Err(); err != nil {
+		return g.emit(dir, err)
+	}
+	entries, err := g.entries(dir, states)
+	if err != nil {
+		return g.emit(dir, err)
+	}
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		next := g.next(states, e.Name())
+		if slices.Contains(next, len(g.elems)) {
+			if err := g.emit(name, nil); err != nil {
+				return err
+			}
+		}
+		if e.IsDir() && slices.ContainsFunc(next, func(s int) bool { return s < len(g.elems) }) {
+			if err := g.walk(name, next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entries returns the entries of dir that states may match: all of them,
+// unless states only expect names without wildcards, which are looked up.
+func (g *globber) entries(dir string, states []int) ([]fs.DirEntry, error) {
+	var names []string
+	for _, s := range states {
+		if s == len(g.elems) {
+			continue
+		}
+		if strings.ContainsAny(g.elems[s], `*?[\`) {
+			return g.m.ReadDirContext(g.ctx, dir)
+		}
+		names = append(names, g.elems[s])
+	}
+	slices.Sort(names)
+	var entries []fs.DirEntry
+	for _, base := range slices.Compact(names) {
+		info, err := g.m.StatContext(g.ctx, path.Join(dir, base))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(renamedInfo{FileInfo: info, name: base}))
+	}
+	return entries, nil
+}
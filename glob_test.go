@@ -0,0 +1,70 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func globAll(t *testing.T, m *MultiFS, pattern string) []string {
+	t.Helper()
+	var names []string
+	for name, err := range m.GlobAll(context.Background(), pattern) {
+		if err != nil {
+			t.Fatalf("GlobAll(%q): %v", pattern, err)
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestGlobAll(t *testing.T) {
+	snap := fstest.MapFS{
+		"home/a.jpg":       &fstest.MapFile{},
+		"home/b.png":       &fstest.MapFile{},
+		"home/x/c.jpg":     &fstest.MapFile{},
+		"home/x/y/d.jpg":   &fstest.MapFile{},
+		"home/x/x/x/e.txt": &fstest.MapFile{},
+		"etc/f.jpg":        &fstest.MapFile{},
+	}
+	mux := NewMultiFS()
+	mux.Mount("snap1", snap)
+	mux.Mount("snap2", fstest.MapFS{"home/g.jpg": &fstest.MapFile{}})
+	mux.Mount("other", snap)
+
+	for _, tt := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"snap*/home/**/*.jpg", []string{
+			"snap1/home/a.jpg", "snap1/home/x/c.jpg", "snap1/home/x/y/d.jpg", "snap2/home/g.jpg",
+		}},
+		{"snap1/**", []string{
+			"snap1", "snap1/etc", "snap1/etc/f.jpg", "snap1/home", "snap1/home/a.jpg", "snap1/home/b.png",
+			"snap1/home/x", "snap1/home/x/c.jpg", "snap1/home/x/x", "snap1/home/x/x/x",
+			"snap1/home/x/x/x/e.txt", "snap1/home/x/y", "snap1/home/x/y/d.jpg",
+		}},
+		// Paths matching several ways are yielded once
+		{"snap1/**/x/**/*.txt", []string{"snap1/home/x/x/x/e.txt"}},
+		{"*/etc", []string{"other/etc", "snap1/etc"}},
+		{"snap1/home/missing/**", nil},
+	} {
+		if got := globAll(t, mux, tt.pattern); !slices.Equal(got, tt.want) {
+			t.Errorf("GlobAll(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+
+	for range mux.GlobAll(context.Background(), "**") {
+		break
+	}
+
+	for _, err := range mux.GlobAll(context.Background(), "snap1/[") {
+		if !errors.Is(err, path.ErrBadPattern) {
+			t.Fatalf("GlobAll: %v, want %v", err, path.ErrBadPattern)
+		}
+	}
+}
@@ -1,3 +1,17 @@
 module github.com/PlakarKorp/go-multifs
 
-go 1.24
+go 1.25.0
+
+require (
+	github.com/go-git/go-billy/v5 v5.8.0
+	github.com/pkg/sftp v1.13.10
+	github.com/spf13/afero v1.15.0
+	golang.org/x/crypto v0.48.0
+	golang.org/x/net v0.50.0
+	golang.org/x/text v0.34.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+)
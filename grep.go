@@ -0,0 +1,192 @@
+package multifs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"iter"
+	"regexp"
+	"sync"
+)
+
+// GrepMatch is a line matching the pattern searched by Grep.
+type GrepMatch struct {
+	Path string
+	// Line is the line number, from 1, and Offset the position of the
+	// match in the file.
+	Line   int
+	Offset int64
+	// Text is the line, without its line feed, or the window of a long
+	// line the match was found in. It is empty for binary files, for which
+	// only the first match is reported.
+	Text   string
+	Binary bool
+}
+
+type GrepOptions struct {
+	// Concurrency is the number of files searched in parallel, 4 by
+	// default.
+	Concurrency int
+}
+
+// binarySniff is how much of a file is checked for NUL bytes to tell
+// whether it is binary.
+const binarySniff = 8000
+
+// Lines longer than grepWindow, as binary files may have, are searched in
+// windows of that size overlapping by grepOverlap, so that searching a
+// file takes bounded memory.
+const (
+	grepWindow  = 64 << 10
+	grepOverlap = 4 << 10
+)
+
+// Grep searches the contents of the files below roots, the whole tree if
+// none is given, for pattern; literal strings can be searched for with
+// regexp.QuoteMeta. Matches are yielded as they are found: those of a file
+// in order, but files are searched concurrently. Errors opening or reading
+// a file are yielded with its path, the search going on if the iteration
+// does. Files are binary when NUL bytes show up early in them.
+func (m *MultiFS) Grep(ctx context.Context, pattern *regexp.Regexp, roots ...string) iter.Seq2[GrepMatch, error] {
+	return m.GrepWith(ctx, GrepOptions{}, pattern, roots...)
+}
+
+// GrepWith is Grep with options.
+func (m *MultiFS) GrepWith(ctx context.Context, opts GrepOptions, pattern *regexp.Regexp, roots ...string) iter.Seq2[GrepMatch, error] {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	return func(yield func(GrepMatch, error) bool) {
+		gctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		g := &grepper{ctx: gctx, m: m, pattern: pattern, results: make(chan grepResult)}
+
+		paths := make(chan string)
+		var wg sync.WaitGroup
+		for range opts.Concurrency {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for name := range paths {
+					g.file(name)
+				}
+			}()
+		}
+		go func() {
+			for _, root := range roots {
+				err := WalkDir(gctx, m, root, WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+					if err != nil {
+						g.send(GrepMatch{Path: name}, err)
+						return nil
+					}
+					if !d.Type().IsRegular() {
+						return nil
+					}
+					select {
+					case paths <- name:
+						return nil
+					case <-gctx.Done():
+						return gctx.Err()
+					}
+				})
+				if err != nil {
+					break
+				}
+			}
+			close(paths)
+			wg.Wait()
+			close(g.results)
+		}()
+
+		for r := range g.results {
+			if !yield(r.match, r.err) {
+				cancel()
+				for range g.results {
+				}
+				return
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			yield(GrepMatch{}, err)
+		}
+	}
+}
+
+type grepResult struct {
+	match GrepMatch
+	err   error
+}
+
+type grepper struct {
+	ctx     context.Context
+	m       *MultiFS
+	pattern *regexp.Regexp
+	results chan grepResult
+}
+
+// send reports a result, unless the search is over.
+func (g *grepper) send(match GrepMatch, err error) bool {
+	select {
+	case g.results <- grepResult{match, err}:
+		return true
+	case <-g.ctx.Done():
+		return false
+	}
+}
+
+func (g *grepper) file(name string) {
+	f, err := g.m.OpenContext(g.ctx, name)
+	if err != nil {
+		g.send(GrepMatch{Path: name}, err)
+		return
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, grepWindow)
+	head, _ := r.Peek(binarySniff)
+	binary := bytes.IndexByte(head, 0) >= 0
+	var (
+		off     int64
+		window  []byte // the current line, or its end for long ones
+		matched bool   // whether the current line matched already
+	)
+	for lineno := 1; g.ctx.Err() == nil; {
+		seg, err := r.ReadSlice('\n')
+		off += int64(len(seg))
+		if len(seg) > 0 && !matched {
+			window = append(window, seg...)
+			if loc := g.pattern.FindIndex(window); loc != nil {
+				start := off - int64(len(window))
+				match := GrepMatch{Path: name, Line: lineno, Offset: start + int64(loc[0]), Binary: binary}
+				if !binary {
+					match.Text = string(bytes.TrimSuffix(window, []byte("\n")))
+				}
+				if !g.send(match, nil) || binary {
+					return
+				}
+				matched = true
+			}
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			// The line goes on: only its end is kept to be searched again
+			// along with what follows.
+			if len(window) > grepOverlap {
+				window = window[:copy(window, window[len(window)-grepOverlap:])]
+			}
+			continue
+		case err == io.EOF:
+			return
+		case err != nil:
+			g.send(GrepMatch{Path: name}, err)
+			return
+		}
+		lineno++
+		window, matched = window[:0], false
+	}
+}
@@ -0,0 +1,78 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGrep(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("snap1", fstest.MapFS{
+		"a.txt":   &fstest.MapFile{Data: []byte("one\nneedle two\nthree\n")},
+		"b.bin":   &fstest.MapFile{Data: []byte("\x00\x01needle\nneedle")},
+		"dir/c":   &fstest.MapFile{Data: []byte("no match")},
+		"dir/d.c": &fstest.MapFile{Data: []byte("x\ny needle")},
+	})
+	mux.Mount("snap2", fstest.MapFS{"e": &fstest.MapFile{Data: []byte("needle")}})
+
+	var got []string
+	for m, err := range mux.Grep(context.Background(), regexp.MustCompile("needle"), "snap1", "snap2/e") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d:%d:%s", m.Path, m.Line, m.Offset, m.Text))
+		if m.Binary != (m.Path == "snap1/b.bin") {
+			t.Errorf("%s: binary %v", m.Path, m.Binary)
+		}
+	}
+	slices.Sort(got)
+	want := []string{
+		"snap1/a.txt:2:4:needle two",
+		"snap1/b.bin:1:2:",
+		"snap1/dir/d.c:2:4:y needle",
+		"snap2/e:1:0:needle",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Grep: %q, want %q", got, want)
+	}
+
+	var errs int
+	for _, err := range mux.Grep(context.Background(), regexp.MustCompile("x"), "missing") {
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Grep: %v, want %v", err, fs.ErrNotExist)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Fatalf("%d errors, want 1", errs)
+	}
+
+	for range mux.Grep(context.Background(), regexp.MustCompile("needle")) {
+		break
+	}
+}
+
+func TestGrepLongLines(t *testing.T) {
+	image := make([]byte, 1<<20)
+	copy(image[grepWindow-3:], "needle") // across two windows
+	copy(image[900<<10:], "\nneedle")
+	mux := NewMultiFS()
+	mux.Mount("s", fstest.MapFS{"disk.img": &fstest.MapFile{Data: image}})
+
+	var got []string
+	for m, err := range mux.Grep(context.Background(), regexp.MustCompile("needle"), "s") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d:%d:%v", m.Line, m.Offset, m.Binary))
+	}
+	if want := fmt.Sprintf("1:%d:true", grepWindow-3); !slices.Equal(got, []string{want}) {
+		t.Fatalf("Grep: %q, want %q", got, want)
+	}
+}
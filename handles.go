@@ -0,0 +1,78 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// Handle describes a file open through a mount.
+type Handle struct {
+	// Path is the path of the file inside the mount.
+	Path   string
+	Opened time.Time
+	// Stack is the stack trace of the Open call, recorded when leak
+	// reporting is enabled.
+	Stack string
+}
+
+// WithLeakReport records the stack trace of every Open, and logs it at
+// warn level for files still open after age, through the logger set with
+// WithLogger or else the default logger.
+func WithLeakReport(age time.Duration) Option {
+	return func(o *options) {
+		o.leakAge = age
+	}
+}
+
+// OpenHandles returns the files open through the mount under id, oldest
+// first.
+func (m *MultiFS) OpenHandles(id string) ([]Handle, error) {
+	mnt, ok := m.mount(id)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	var handles []Handle
+	mnt.handles.Range(func(k, _ any) bool {
+		f := k.(*mountFile)
+		handles = append(handles, Handle{Path: f.name, Opened: f.opened, Stack: string(f.stack)})
+		return true
+	})
+	slices.SortFunc(handles, func(a, b Handle) int { return a.Opened.Compare(b.Opened) })
+	return handles, nil
+}
+
+func (mnt *mount) track(f *mountFile) {
+	mnt.handles.Store(f, struct{}{})
+	if mnt.metrics == nil || mnt.metrics.leakAge <= 0 {
+		return
+	}
+	f.stack = debug.Stack()
+	f.leak = time.AfterFunc(mnt.metrics.leakAge, func() { mnt.reportLeak(f) })
+}
+
+func (mnt *mount) untrack(f *mountFile) {
+	mnt.handles.Delete(f)
+	if f.leak != nil {
+		f.leak.Stop()
+	}
+}
+
+func (mnt *mount) reportLeak(f *mountFile) {
+	if f.closed.Load() {
+		return
+	}
+	l := mnt.metrics.logger
+	if l == nil {
+		l = slog.Default()
+	}
+	l.LogAttrs(context.Background(), slog.LevelWarn, "file left open",
+		slog.String("id", mnt.id),
+		slog.String("path", f.name),
+		slog.Duration("age", time.Since(f.opened)),
+		slog.String("stack", string(f.stack)),
+	)
+}
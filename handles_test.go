@@ -0,0 +1,82 @@
+package multifs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestOpenHandles(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"a": &fstest.MapFile{}, "b": &fstest.MapFile{}})
+
+	a, _ := mux.Open("snap/a")
+	b, _ := mux.Open("snap/b")
+	defer b.Close()
+
+	handles, err := mux.OpenHandles("snap")
+	if err != nil || len(handles) != 2 || handles[0].Path != "a" || handles[1].Path != "b" {
+		t.Fatalf("OpenHandles: %+v, %v", handles, err)
+	}
+	if handles[0].Stack != "" {
+		t.Fatal("stack recorded without leak reporting")
+	}
+	a.Close()
+	a.Close()
+	if handles, _ := mux.OpenHandles("snap"); len(handles) != 1 || handles[0].Path != "b" {
+		t.Fatalf("OpenHandles after Close: %+v", handles)
+	}
+	if _, err := mux.OpenHandles("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("OpenHandles on missing mount: %v", err)
+	}
+}
+
+func TestLeakReport(t *testing.T) {
+	var buf syncBuffer
+	mux := NewMultiFS(WithLeakReport(time.Millisecond), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	mux.Mount("snap", fstest.MapFS{"leaked": &fstest.MapFile{}, "closed": &fstest.MapFile{}})
+
+	f, _ := mux.Open("snap/closed")
+	f.Close()
+	leaked, _ := mux.Open("snap/leaked")
+	defer leaked.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "file left open") {
+		if time.Now().After(deadline) {
+			t.Fatal("leak not reported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "path=leaked") || !strings.Contains(out, "TestLeakReport") {
+		t.Fatalf("leak report: %s", out)
+	}
+	if strings.Contains(out, "path=closed") {
+		t.Fatalf("closed file reported: %s", out)
+	}
+}
@@ -0,0 +1,110 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Health is the outcome of the last health check of a mount.
+type Health struct {
+	// Err is why the mount could not be reached, nil if it could.
+	Err     error
+	Checked time.Time
+}
+
+// WithHealthCheck checks every mount in the background at the given
+// interval, the outcome being reported by Mounts.
+func WithHealthCheck(every time.Duration) Option {
+	return func(o *options) {
+		o.healthEvery = every
+	}
+}
+
+// HealthCheck checks whether each mount can be reached, by opening its root
+// and reading the first entry from it, and returns the errors of the
+// mounts that cannot, by id. Mounts are checked concurrently, within
+// their timeout for OpStat if any. The backends are accessed directly,
+// bypassing caches.
+func (m *MultiFS) HealthCheck(ctx context.Context) map[string]error {
+	t := m.tab.Load()
+	roots := make(map[string]*mount, t.roots.len())
+	for id, mnt := range t.roots.all {
+		roots[id] = mnt
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+	)
+	for id, mnt := range roots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := mnt.checkHealth(ctx); err != nil {
+				mu.Lock()
+				errs[id] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// checkHealth probes the mount and records the outcome.
+func (mnt *mount) checkHealth(ctx context.Context) error {
+	_, err := timed(mnt, ctx, OpStat, ".", func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, mnt.probe(ctx)
+	}, nil)
+	if ctx.Err() == nil {
+		mnt.health.Store(&Health{Err: err, Checked: time.Now()})
+	}
+	return err
+}
+
+func (mnt *mount) probe(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	bname, err := mnt.backendName("stat", ".")
+	if err != nil {
+		return err
+	}
+	var f fs.File
+	if cfs, ok := mnt.fsys.(ContextFS); ok {
+		f, err = cfs.OpenContext(ctx, bname)
+	} else {
+		f, err = mnt.fsys.Open(bname)
+	}
+	if err != nil {
+		return mnt.record("stat", ".", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		if dir, ok := f.(fs.ReadDirFile); ok {
+			if _, err = dir.ReadDir(1); err == io.EOF {
+				err = nil
+			}
+		}
+	}
+	return mnt.record("stat", ".", err)
+}
+
+// monitorHealth checks the mount at every tick until it is unmounted.
+func (mnt *mount) monitorHealth(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		mnt.checkHealth(mnt.ctx)
+		select {
+		case <-ticker.C:
+		case <-mnt.ctx.Done():
+			return
+		}
+	}
+}
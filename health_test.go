@@ -0,0 +1,49 @@
+package multifs
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("ok", fstest.MapFS{"f": &fstest.MapFile{}})
+	mux.Mount("down", failingFS{})
+
+	for _, info := range mux.Mounts() {
+		if info.Health != nil {
+			t.Fatalf("%s: health %+v before any check", info.ID, info.Health)
+		}
+	}
+	errs := mux.HealthCheck(context.Background())
+	if len(errs) != 1 || errs["down"] == nil {
+		t.Fatalf("HealthCheck: %v", errs)
+	}
+	for _, info := range mux.Mounts() {
+		if info.Health == nil || info.Health.Checked.IsZero() || (info.Health.Err == nil) != (info.ID == "ok") {
+			t.Fatalf("%s: health %+v", info.ID, info.Health)
+		}
+	}
+}
+
+func TestHealthCheckInBackground(t *testing.T) {
+	mux := NewMultiFS(WithHealthCheck(10 * time.Millisecond))
+	defer mux.Close()
+	mux.Mount("down", failingFS{})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if infos := mux.Mounts(); infos[0].Health != nil {
+			if infos[0].Health.Err == nil {
+				t.Fatalf("failing mount reported healthy")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("mount not checked in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,158 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// NameIndex is an in-memory index of the paths of a set of mounts, kept up
+// to date as they are mounted, replaced and unmounted: removals apply
+// immediately, new mounts are indexed in the background.
+type NameIndex struct {
+	m   *MultiFS
+	ids map[string]bool // nil when indexing every mount
+	wg  sync.WaitGroup
+
+	mu      sync.RWMutex
+	current map[string]*mount
+	paths   map[string][]string
+}
+
+// BuildIndex indexes the paths of the given mounts, or of every mount,
+// including those mounted later, when none is given.
+func (m *MultiFS) BuildIndex(ctx context.Context, ids ...string) (*NameIndex, error) {
+	x := &NameIndex{
+		m:       m,
+		current: make(map[string]*mount),
+		paths:   make(map[string][]string),
+	}
+
+	m.mu.Lock()
+	if len(ids) > 0 {
+		x.ids = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			id, mnt, ok := m.tab.Load().lookup(id)
+			if !ok {
+				m.mu.Unlock()
+				return nil, fs.ErrNotExist
+			}
+			x.ids[id] = true
+			x.current[id] = mnt
+		}
+	} else {
+		for id, mnt := range m.tab.Load().roots.all {
+			x.current[id] = mnt
+		}
+	}
+	m.indexes = append(m.indexes, x)
+	mounts := make([]*mount, 0, len(x.current))
+	for _, mnt := range x.current {
+		mounts = append(mounts, mnt)
+	}
+	m.mu.Unlock()
+
+	for _, mnt := range mounts {
+		if err := x.index(ctx, mnt); err != nil {
+			x.Close()
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// attached and detached are called by MultiFS with m.mu held.
+func (x *NameIndex) attached(mnt *mount) {
+	if x.ids != nil && !x.ids[mnt.id] {
+		return
+	}
+	x.mu.Lock()
+	x.current[mnt.id] = mnt
+	delete(x.paths, mnt.id)
+	x.mu.Unlock()
+
+	x.wg.Add(1)
+	go func() {
+		defer x.wg.Done()
+		x.index(mnt.ctx, mnt)
+	}()
+}
+
+func (x *NameIndex) detached(id string) {
+	x.mu.Lock()
+	delete(x.current, id)
+	delete(x.paths, id)
+	x.mu.Unlock()
+}
+
+// index walks mnt and stores its paths, unless it was replaced or unmounted
+// in the meantime.
+func (x *NameIndex) index(ctx context.Context, mnt *mount) error {
+	var paths []string
+	err := WalkDir(ctx, mnt, ".", WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." {
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.current[mnt.id] == mnt {
+		x.paths[mnt.id] = paths
+	}
+	return nil
+}
+
+// Wait blocks until mounts being indexed in the background are done.
+func (x *NameIndex) Wait() {
+	x.wg.Wait()
+}
+
+// Close stops keeping the index up to date.
+func (x *NameIndex) Close() {
+	x.m.mu.Lock()
+	defer x.m.mu.Unlock()
+	x.m.indexes = slices.DeleteFunc(x.m.indexes, func(other *NameIndex) bool { return other == x })
+}
+
+// Search returns the sorted paths, id included, matching query. A query
+// holding glob metacharacters is matched with path.Match against whole
+// paths and base names; any other query is a case-insensitive substring.
+func (x *NameIndex) Search(query string) []string {
+	match := func(name string) bool {
+		return strings.Contains(strings.ToLower(name), strings.ToLower(query))
+	}
+	if strings.ContainsAny(query, `*?[\`) {
+		match = func(name string) bool {
+			if ok, _ := path.Match(query, name); ok {
+				return true
+			}
+			ok, _ := path.Match(query, path.Base(name))
+			return ok
+		}
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var out []string
+	for id, paths := range x.paths {
+		for _, p := range paths {
+			if full := id + "/" + p; match(full) {
+				out = append(out, full)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
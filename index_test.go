@@ -0,0 +1,75 @@
+package multifs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNameIndexSearch(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("snap1", fstest.MapFS{
+		"docs/Report.pdf": &fstest.MapFile{},
+		"src/main.go":     &fstest.MapFile{},
+	})
+	mux.Mount("snap2", fstest.MapFS{"src/util.go": &fstest.MapFile{}})
+
+	x, err := mux.BuildIndex(context.Background())
+	if err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	defer x.Close()
+
+	for _, tc := range []struct{ query, want string }{
+		{"report", "snap1/docs/Report.pdf"},
+		{"*.go", "snap1/src/main.go,snap2/src/util.go"},
+		{"snap2/src/*", "snap2/src/util.go"},
+		{"missing", ""},
+	} {
+		if got := strings.Join(x.Search(tc.query), ","); got != tc.want {
+			t.Errorf("Search(%q): got %s, want %s", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestNameIndexFollowsMounts(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("one", fstest.MapFS{"a.go": &fstest.MapFile{}})
+
+	x, err := mux.BuildIndex(context.Background())
+	if err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	defer x.Close()
+
+	mux.Mount("two", fstest.MapFS{"b.go": &fstest.MapFile{}})
+	mux.Replace("one", fstest.MapFS{"c.go": &fstest.MapFile{}})
+	x.Wait()
+	if got := strings.Join(x.Search("*.go"), ","); got != "one/c.go,two/b.go" {
+		t.Fatalf("after Mount and Replace: got %s", got)
+	}
+
+	mux.Unmount("two")
+	if got := strings.Join(x.Search("*.go"), ","); got != "one/c.go" {
+		t.Fatalf("after Unmount: got %s", got)
+	}
+}
+
+func TestNameIndexSelectedMounts(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("one", fstest.MapFS{"a.go": &fstest.MapFile{}})
+	mux.Mount("two", fstest.MapFS{"b.go": &fstest.MapFile{}})
+
+	x, err := mux.BuildIndex(context.Background(), "two")
+	if err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	x.Close()
+
+	mux.Mount("three", fstest.MapFS{"c.go": &fstest.MapFile{}})
+	x.Wait()
+	if got := strings.Join(x.Search("*.go"), ","); got != "two/b.go" {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -0,0 +1,111 @@
+package multifs
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+)
+
+// ErrCorrupted is returned when the data read from a mount does not match
+// the digests provided with WithIntegrity.
+var ErrCorrupted = errors.New("multifs: data corrupted")
+
+// Digests are the expected digests of a file.
+type Digests struct {
+	Hash func() hash.Hash
+	// ChunkSize, when positive, splits the file in chunks of that size,
+	// the last one possibly shorter, with one sum each. Otherwise Sums
+	// holds a single sum, of the whole file.
+	ChunkSize int64
+	Sums      [][]byte
+}
+
+// IntegrityProvider supplies the digests files are checked against.
+type IntegrityProvider interface {
+	// Digests returns the expected digests of name, or nil if name is not
+	// to be checked.
+	Digests(name string) (*Digests, error)
+}
+
+// WithIntegrity checks the data of the regular files of the mount against
+// the digests supplied by p as it is read, failing reads with ErrCorrupted
+// on a mismatch. Files with chunk digests are read a chunk at a time, which
+// is checked before any of its data is returned; they can be read at any
+// offset. Files with a single digest are only found corrupted once read to
+// the end, in place of io.EOF, and can only be read sequentially.
+func WithIntegrity(p IntegrityProvider) MountOption {
+	return func(o *mountOptions) {
+		o.integrity = p
+	}
+}
+
+// verifyFile wraps f, opened at name, unless it is a directory or has no
+// digests.
+func (mnt *mount) verifyFile(name string, f fs.File) (fs.File, error) {
+	if isDir(f) {
+		return f, nil
+	}
+	d, err := mnt.opts.integrity.Digests(name)
+	switch {
+	case err != nil:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	case d == nil:
+		return f, nil
+	case d.ChunkSize > 0:
+		return newDecryptedFile(f, chunkVerifier{d}, name, "read"), nil
+	case len(d.Sums) != 1:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("whole file digests need a single sum")}
+	}
+	return compose(&verifiedFile{File: f, name: name, sum: d.Sums[0], h: d.Hash()}, nil, nil, nil, nil), nil
+}
+
+// chunkVerifier checks chunks of a file, passing them on unchanged.
+type chunkVerifier struct {
+	d *Digests
+}
+
+func (v chunkVerifier) Chunks(name string, f fs.File) ([]Chunk, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size, cs := info.Size(), v.d.ChunkSize
+	if (size+cs-1)/cs != int64(len(v.d.Sums)) {
+		return nil, ErrCorrupted
+	}
+	chunks := make([]Chunk, len(v.d.Sums))
+	for i := range chunks {
+		off := int64(i) * cs
+		length := min(cs, size-off)
+		chunks[i] = Chunk{Offset: off, Length: length, Size: length}
+	}
+	return chunks, nil
+}
+
+func (v chunkVerifier) Decrypt(name string, i int, data []byte) ([]byte, error) {
+	h := v.d.Hash()
+	h.Write(data)
+	if !bytes.Equal(h.Sum(nil), v.d.Sums[i]) {
+		return nil, ErrCorrupted
+	}
+	return data, nil
+}
+
+// verifiedFile checks the digest of a file read sequentially.
+type verifiedFile struct {
+	fs.File
+	name string
+	sum  []byte
+	h    hash.Hash
+}
+
+func (f *verifiedFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(f.h.Sum(nil), f.sum) {
+		err = &fs.PathError{Op: "read", Path: f.name, Err: ErrCorrupted}
+	}
+	return n, err
+}
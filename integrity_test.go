@@ -0,0 +1,84 @@
+package multifs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// digestMap supplies digests computed from reference contents.
+type digestMap map[string]*Digests
+
+func (m digestMap) Digests(name string) (*Digests, error) { return m[name], nil }
+
+func digestsOf(data string, chunkSize int) *Digests {
+	d := &Digests{Hash: sha256.New, ChunkSize: int64(chunkSize)}
+	if chunkSize == 0 {
+		chunkSize = len(data)
+	}
+	for i := 0; i < len(data); i += chunkSize {
+		sum := sha256.Sum256([]byte(data[i:min(i+chunkSize, len(data))]))
+		d.Sums = append(d.Sums, sum[:])
+	}
+	return d
+}
+
+func TestIntegrity(t *testing.T) {
+	const good = "0123456789abcdefghij"
+	backend := fstest.MapFS{
+		"whole":   &fstest.MapFile{Data: []byte(good)},
+		"chunked": &fstest.MapFile{Data: []byte(good)},
+		"bad":     &fstest.MapFile{Data: []byte("0123456789abcdeXghij")},
+		"badall":  &fstest.MapFile{Data: []byte("0123456789abcdeXghij")},
+		"short":   &fstest.MapFile{Data: []byte("0123")},
+		"free":    &fstest.MapFile{Data: []byte("anything")},
+	}
+	digests := digestMap{
+		"whole":   digestsOf(good, 0),
+		"chunked": digestsOf(good, 8),
+		"bad":     digestsOf(good, 8),
+		"badall":  digestsOf(good, 0),
+		"short":   digestsOf(good, 8),
+	}
+	for _, b := range backendsOf(t, backend) {
+		t.Run(b.name, func(t *testing.T) {
+			mux := NewMultiFS()
+			mux.Mount("s", b.fsys, WithIntegrity(digests))
+			testIntegrity(t, mux, good)
+		})
+	}
+}
+
+func testIntegrity(t *testing.T, mux *MultiFS, good string) {
+
+	for _, name := range []string{"whole", "chunked"} {
+		if data, err := fs.ReadFile(mux, "s/"+name); err != nil || string(data) != good {
+			t.Fatalf("ReadFile %s: %q, %v", name, data, err)
+		}
+	}
+	if data, err := fs.ReadFile(mux, "s/free"); err != nil || string(data) != "anything" {
+		t.Fatalf("ReadFile free: %q, %v", data, err)
+	}
+	for _, name := range []string{"bad", "badall", "short"} {
+		if _, err := fs.ReadFile(mux, "s/"+name); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("ReadFile %s: %v", name, err)
+		}
+	}
+
+	// Chunks are checked before their data is returned
+	f, err := mux.Open("s/bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := make([]byte, 4)
+	if n, err := f.(io.ReaderAt).ReadAt(p, 2); err != nil || string(p[:n]) != "2345" {
+		t.Fatalf("ReadAt in a good chunk: %q, %v", p[:n], err)
+	}
+	if n, err := f.(io.ReaderAt).ReadAt(p, 8); n != 0 || !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("ReadAt in a corrupted chunk: %q, %v", p[:n], err)
+	}
+}
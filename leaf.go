@@ -0,0 +1,67 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// MountFile mounts the contents of r, size bytes long, as a regular file
+// listed by the root under name. info, when not nil, provides the mode,
+// modification time and Sys of the file; it is read-only otherwise.
+func (m *MultiFS) MountFile(name string, r io.ReaderAt, size int64, info fs.FileInfo, opts ...MountOption) error {
+	if r == nil {
+		return errors.New("multifs: reader is nil")
+	}
+	leaf := &leafFS{r: r, info: fileInfo{name: name, size: size, mode: 0o444, modTime: time.Now()}}
+	if info != nil {
+		leaf.info = fileInfo{name: name, size: size, mode: info.Mode() &^ fs.ModeType, modTime: info.ModTime(), sys: info.Sys()}
+	}
+	return m.Mount(name, leaf, opts...)
+}
+
+// leafFS is a filesystem made of a single file, at its root.
+type leafFS struct {
+	r    io.ReaderAt
+	info fileInfo
+}
+
+func (l *leafFS) Open(name string) (fs.File, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &leafFile{SectionReader: io.NewSectionReader(l.r, 0, l.info.size), info: l.info}, nil
+}
+
+// leaf returns the file mounted by MountFile, if mnt is one.
+func (mnt *mount) leaf() (*leafFS, bool) {
+	l, ok := mnt.backend.(*leafFS)
+	return l, ok
+}
+
+type leafFile struct {
+	*io.SectionReader
+	info fileInfo
+}
+
+var _ io.ReaderAt = (*leafFile)(nil)
+var _ io.Seeker = (*leafFile)(nil)
+
+func (f *leafFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *leafFile) Close() error               { return nil }
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     any
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() any           { return i.sys }
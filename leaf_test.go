@@ -0,0 +1,49 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMountFile(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"a": &fstest.MapFile{Data: []byte("a")}})
+	src := fstest.MapFS{"f": &fstest.MapFile{Mode: 0o640, ModTime: mtime}}
+	finfo, _ := src.Stat("f")
+	if err := mux.MountFile("report.txt", strings.NewReader("the report"), 10, finfo); err != nil {
+		t.Fatalf("MountFile: %v", err)
+	}
+	if err := mux.MountFile("blob", strings.NewReader("0123456789"), 4, nil); err != nil {
+		t.Fatalf("MountFile: %v", err)
+	}
+
+	if err := fstest.TestFS(mux, "report.txt", "blob", "snap/a"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(mux, "report.txt"); err != nil || string(data) != "the report" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if data, err := fs.ReadFile(mux, "blob"); err != nil || string(data) != "0123" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	entries, _ := mux.ReadDir(".")
+	if got := listNames(t, mux, "."); got != "blob,report.txt,snap" {
+		t.Fatalf("listing: got %s", got)
+	}
+	entryInfo, err := entries[1].Info()
+	if err != nil || entries[1].IsDir() || entryInfo.Mode() != 0o640 || !entryInfo.ModTime().Equal(mtime) || entryInfo.Size() != 10 {
+		t.Fatalf("entry info: %v, %v", entryInfo, err)
+	}
+	if info, _ := mux.Stat("blob"); info.Mode() != 0o444 || info.Size() != 4 {
+		t.Fatalf("Stat: %v %d", info.Mode(), info.Size())
+	}
+	if _, err := mux.Open("report.txt/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open below a file: %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrCrossMount is the error of links between different mounts.
+var ErrCrossMount = errors.New("multifs: link across mounts")
+
+// SymlinkFS and LinkFS are implemented by filesystems that can create
+// symbolic and hard links, with the semantics of os.Symlink and os.Link.
+type SymlinkFS interface {
+	fs.FS
+	Symlink(oldname, newname string) error
+}
+
+type LinkFS interface {
+	fs.FS
+	Link(oldname, newname string) error
+}
+
+// WithCrossMountSymlinks lets Symlink create links whose target is outside
+// the mount of the link.
+func WithCrossMountSymlinks() Option {
+	return func(o *options) {
+		o.crossLinks = true
+	}
+}
+
+// readLinkFS is implemented by filesystems exposing symbolic links; it has
+// the shape of fs.ReadLinkFS.
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// ReadLink returns the target of a symbolic link inside a mount.
+func (m *MultiFS) ReadLink(name string) (string, error) {
+	return m.ReadLinkContext(context.Background(), name)
+}
+
+func (m *MultiFS) ReadLinkContext(ctx context.Context, name string) (string, error) {
+	r, err := m.resolve("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if r.mnt == nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return r.mnt.readLink(ctx, r.subpath)
+}
+
+// Lstat is Stat without following a final symbolic link, on mounts able
+// to tell links apart.
+func (m *MultiFS) Lstat(name string) (fs.FileInfo, error) {
+	r, err := m.resolve("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	if r.mnt == nil || r.subpath == "." {
+		return r.stat(context.Background())
+	}
+	return r.mnt.lstat(context.Background(), r.subpath)
+}
+
+func (mnt *mount) readLink(ctx context.Context, name string) (string, error) {
+	if err := mnt.check(ctx, OpStat, name); err != nil {
+		return "", err
+	}
+	rl, ok := mnt.fsys.(readLinkFS)
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	bname, err := mnt.backendName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := rl.ReadLink(bname)
+	return target, mnt.record("readlink", name, err)
+}
+
+func (mnt *mount) lstat(ctx context.Context, name string) (fs.FileInfo, error) {
+	rl, ok := mnt.fsys.(readLinkFS)
+	if !ok {
+		return mnt.stat(ctx, name)
+	}
+	if err := mnt.check(ctx, OpStat, name); err != nil {
+		return nil, err
+	}
+	bname, err := mnt.backendName("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := rl.Lstat(bname)
+	if err != nil {
+		return nil, mnt.record("lstat", name, err)
+	}
+	return mnt.enrich(name, mnt.mountInfo(name, info)), nil
+}
+
+// Symlink creates newname as a symbolic link to oldname inside a mount,
+// whose filesystem must implement SymlinkFS. oldname is passed as is to
+// the filesystem, and must be a relative path staying inside the mount,
+// unless WithCrossMountSymlinks is set: the filesystem would not resolve
+// it against the tree of MultiFS anyway. Targets inside the mount must be
+// visible through it, and may be opened as far as its access functions
+// are concerned.
+func (m *MultiFS) Symlink(oldname, newname string) error {
+	return m.SymlinkContext(context.Background(), oldname, newname)
+}
+
+func (m *MultiFS) SymlinkContext(ctx context.Context, oldname, newname string) error {
+	r, err := m.resolveLink("symlink", oldname, newname)
+	if err != nil {
+		return err
+	}
+	target := path.Join(path.Dir(r.subpath), oldname)
+	if path.IsAbs(oldname) || target == ".." || strings.HasPrefix(target, "../") {
+		if !m.opts.crossLinks {
+			return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrCrossMount}
+		}
+	} else if err := r.mnt.check(ctx, OpOpen, target); err != nil {
+		// The link would expose what the mount hides or denies
+		var pe *fs.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return r.write(ctx, OpSymlink, func() error { return r.mnt.symlink(oldname, r.subpath) })
+}
+
+// Link creates newname as a hard link to oldname, which must be in the
+// same mount, whose filesystem must implement LinkFS.
+func (m *MultiFS) Link(oldname, newname string) error {
+	return m.LinkContext(context.Background(), oldname, newname)
+}
+
+func (m *MultiFS) LinkContext(ctx context.Context, oldname, newname string) error {
+	to, err := m.resolveLink("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	from, err := m.resolve("link", oldname)
+	if err != nil {
+		return err
+	}
+	if from.mnt != to.mnt {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: ErrCrossMount}
+	}
+	if err := from.mnt.check(ctx, OpOpen, from.subpath); err != nil {
+		return err
+	}
+	return to.write(ctx, OpLink, func() error { return to.mnt.link(from.subpath, to.subpath) })
+}
+
+// resolveLink resolves newname, the link op creates to oldname.
+func (m *MultiFS) resolveLink(op, oldname, newname string) (resolved, error) {
+	r, err := m.resolveWrite(op, newname)
+	if err != nil {
+		var pe *fs.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return resolved{}, &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	if r.mnt == nil || (r.id != "" && r.subpath == ".") {
+		return resolved{}, &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	return r, nil
+}
+
+func (mnt *mount) symlink(oldname, newname string) error {
+	w, ok := mnt.fsys.(SymlinkFS)
+	if !ok {
+		return mnt.unsupported("symlink", newname)
+	}
+	bname, err := mnt.backendName("symlink", newname)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(newname)
+	return mnt.record("symlink", newname, w.Symlink(oldname, bname))
+}
+
+func (mnt *mount) link(oldname, newname string) error {
+	w, ok := mnt.fsys.(LinkFS)
+	if !ok {
+		return mnt.unsupported("link", newname)
+	}
+	oldb, err := mnt.backendName("link", oldname)
+	if err != nil {
+		return err
+	}
+	newb, err := mnt.backendName("link", newname)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(newname)
+	return mnt.record("link", newname, w.Link(oldb, newb))
+}
@@ -0,0 +1,85 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSymlinkAndLink(t *testing.T) {
+	scratch := newDirFS(t)
+	other := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch)
+	mux.Mount("other", other)
+	mux.Mount("ro", fstest.MapFS{"f": &fstest.MapFile{}})
+	mux.MkdirAll("scratch/dir", 0o755)
+	writeFile(t, scratch, "dir/f", "data")
+
+	if err := mux.Symlink("f", "scratch/dir/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := os.Readlink(scratch.path("dir/link")); err != nil || target != "f" {
+		t.Fatalf("Readlink: %q, %v", target, err)
+	}
+	if err := mux.Link("scratch/dir/f", "scratch/hard"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if data, err := fs.ReadFile(mux, "scratch/hard"); err != nil || string(data) != "data" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	for _, tt := range []struct {
+		oldname, newname string
+		want             error
+	}{
+		{"../../other/f", "scratch/dir/escape", ErrCrossMount},
+		{"/etc/passwd", "scratch/abs", ErrCrossMount},
+		{"f", "scratch", fs.ErrExist},
+		{"f", "new", fs.ErrPermission},
+		{"f", "ro/link", errors.ErrUnsupported},
+	} {
+		if err := mux.Symlink(tt.oldname, tt.newname); !errors.Is(err, tt.want) {
+			t.Errorf("Symlink(%q, %q): %v, want %v", tt.oldname, tt.newname, err, tt.want)
+		}
+	}
+	if err := mux.Link("scratch/dir/f", "other/f"); !errors.Is(err, ErrCrossMount) {
+		t.Errorf("Link across mounts: %v", err)
+	}
+
+	mux = NewMultiFS(WithCrossMountSymlinks())
+	mux.Mount("scratch", scratch)
+	if err := mux.Symlink("../other/f", "scratch/escape"); err != nil {
+		t.Fatalf("Symlink across mounts: %v", err)
+	}
+}
+
+func TestSymlinkTargetChecked(t *testing.T) {
+	scratch := newDirFS(t)
+	os.Mkdir(scratch.path("pub"), 0o755)
+	os.Mkdir(scratch.path("private"), 0o755)
+	writeFile(t, scratch, "private/key", "key")
+	writeFile(t, scratch, "pub/secret", "secret")
+
+	deny := func(ctx context.Context, op Op, name string) error {
+		if name == "pub/secret" {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch, WithSubtrees("pub"), WithAccessFunc(deny))
+
+	if err := mux.Symlink("../private/key", "scratch/pub/key"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Symlink to a pruned subtree: %v", err)
+	}
+	if err := mux.Symlink("secret", "scratch/pub/secret-link"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Symlink to a denied file: %v", err)
+	}
+	if err := mux.Symlink("missing", "scratch/pub/dangling"); err != nil {
+		t.Errorf("Symlink to an allowed path: %v", err)
+	}
+}
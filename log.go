@@ -0,0 +1,68 @@
+package multifs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WithLogger makes MultiFS log through l: changes to the mount table at
+// info level, failures of mounted filesystems and slow operations at warn
+// level, and expected errors such as missing files at debug level.
+// Nothing is logged by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithSlowThreshold sets the duration above which a delegated operation is
+// logged as slow. The default is one second.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.slow = d
+		}
+	}
+}
+
+func (m *MultiFS) logTable(msg, id string) {
+	if l := m.metrics.logger; l != nil {
+		l.LogAttrs(context.Background(), slog.LevelInfo, msg, slog.String("id", id))
+	}
+}
+
+func (mnt *mount) logOp(op, name string, start time.Time, err error) {
+	if mnt.metrics == nil || mnt.metrics.logger == nil {
+		return
+	}
+	l := mnt.metrics.logger
+	elapsed := time.Since(start)
+
+	level, msg := slog.LevelDebug, ""
+	switch classify(err) {
+	case ErrorNone:
+		if elapsed < mnt.metrics.slow {
+			return
+		}
+		level, msg = slog.LevelWarn, "slow operation"
+	case ErrorOther, ErrorTimeout:
+		level, msg = slog.LevelWarn, "operation failed"
+	default:
+		msg = "operation failed"
+	}
+	if !l.Enabled(context.Background(), level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.String("id", mnt.id),
+		slog.String("path", name),
+		slog.Duration("elapsed", elapsed),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.LogAttrs(context.Background(), level, msg, attrs...)
+}
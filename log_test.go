@@ -0,0 +1,66 @@
+package multifs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// failingFS fails every open with a backend error.
+type failingFS struct{}
+
+func (failingFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("connection reset")}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "elapsed" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	mux := NewMultiFS(WithLogger(logger))
+
+	mux.Mount("ok", fstest.MapFS{"a.txt": &fstest.MapFile{}})
+	mux.Mount("broken", failingFS{})
+	mux.Open("broken/a.txt")
+	mux.Open("ok/missing")
+	mux.Open("ok/a.txt")
+	mux.Unmount("ok")
+
+	want := strings.Join([]string{
+		`level=INFO msg=mounted id=ok`,
+		`level=INFO msg=mounted id=broken`,
+		`level=WARN msg="operation failed" op=open id=broken path=a.txt error="open a.txt: connection reset"`,
+		`level=INFO msg=unmounted id=ok`,
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLoggerSlow(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	mux := NewMultiFS(WithLogger(logger), WithSlowThreshold(time.Nanosecond))
+
+	slow := func(ctx context.Context, name string) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+	mux.Mount("m", fstest.MapFS{"a.txt": &fstest.MapFile{}}, WithBeforeOpen(slow))
+	mux.Open("m/a.txt")
+	if !strings.Contains(buf.String(), `msg="slow operation" op=open id=m path=a.txt`) {
+		t.Fatalf("slow open not logged:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,483 @@
+// Package memfs provides a writable in-memory filesystem, for scratch
+// mounts and overlay upper layers.
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+	errClosed   = errors.New("file already closed")
+)
+
+// FS is a filesystem held in memory. It is safe for concurrent use; files
+// opened on it see the writes made through other handles.
+type FS struct {
+	mu   sync.RWMutex
+	root *node
+}
+
+var _ multifs.WritableFS = (*FS)(nil)
+var _ multifs.ChmodFS = (*FS)(nil)
+var _ multifs.ChtimesFS = (*FS)(nil)
+var _ multifs.ChownFS = (*FS)(nil)
+var _ fs.StatFS = (*FS)(nil)
+var _ fs.ReadDirFS = (*FS)(nil)
+
+type node struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	owner    *Owner // replaced, never modified, by Chown
+	data     []byte
+	children map[string]*node // nil for files
+}
+
+// Owner is the owner set by Chown, returned by the Sys method of the
+// FileInfo of the files having one.
+type Owner struct {
+	UID, GID int
+}
+
+func (n *node) info() fs.FileInfo {
+	return fileInfo{name: n.name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime, owner: n.owner}
+}
+
+// New returns an empty filesystem.
+func New() *FS {
+	return &FS{root: newDir(".", 0o755)}
+}
+
+func newDir(name string, perm fs.FileMode) *node {
+	return &node{name: name, mode: fs.ModeDir | perm.Perm(), modTime: time.Now(), children: make(map[string]*node)}
+}
+
+// lookup returns the node at name. f.mu must be held.
+func (f *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n := f.root
+	if name == "." {
+		return n, nil
+	}
+	for elem := range strings.SplitSeq(name, "/") {
+		if n.children == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: errNotDir}
+		}
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// parent returns the directory holding name and the base name of name.
+// f.mu must be held.
+func (f *FS) parent(op, name string) (*node, string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	dir, err := f.lookup(op, path.Dir(name))
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: errors.Unwrap(err)}
+	}
+	if dir.children == nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return dir, path.Base(name), nil
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return n.entries(), nil
+}
+
+// entries lists a directory, sorted by name. f.mu must be held.
+func (n *node) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info()))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries
+}
+
+// OpenFile opens name with the os.O_* flags in flag, creating it with perm
+// if needed. Directories can only be opened for reading.
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (multifs.File, error) {
+	writing := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	if writing {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+	} else {
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+	}
+
+	n, err := f.lookup("open", name)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		dir, base, err := f.parent("open", name)
+		if err != nil {
+			return nil, err
+		}
+		n = &node{name: base, mode: perm.Perm(), modTime: time.Now()}
+		dir.children[base] = n
+		dir.modTime = n.modTime
+	default:
+		return nil, err
+	}
+
+	if n.children != nil {
+		if writing {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+		}
+		return &file{fsys: f, n: n, name: name, entries: n.entries()}, nil
+	}
+	if flag&os.O_TRUNC != 0 && n.data != nil {
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &file{fsys: f, n: n, name: name, flag: flag}, nil
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir, base, err := f.parent("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if _, ok := dir.children[base]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	n := newDir(base, perm)
+	dir.children[base] = n
+	dir.modTime = n.modTime
+	return nil
+}
+
+// Remove removes a file or an empty directory.
+func (f *FS) Remove(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir, base, err := f.parent("remove", name)
+	if err != nil {
+		return err
+	}
+	n, ok := dir.children[base]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if len(n.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(dir.children, base)
+	dir.modTime = time.Now()
+	return nil
+}
+
+// Rename moves oldname to newname, replacing a file or an empty directory
+// found there as os.Rename does.
+func (f *FS) Rename(oldname, newname string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	odir, obase, err := f.parent("rename", oldname)
+	if err != nil {
+		return err
+	}
+	n, ok := odir.children[obase]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	ndir, nbase, err := f.parent("rename", newname)
+	if err != nil {
+		return err
+	}
+	if n.children != nil && (newname == oldname || strings.HasPrefix(newname, oldname+"/")) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	if old, ok := ndir.children[nbase]; ok && old != n {
+		switch {
+		case old.children != nil && n.children == nil:
+			return &fs.PathError{Op: "rename", Path: newname, Err: errIsDir}
+		case old.children == nil && n.children != nil:
+			return &fs.PathError{Op: "rename", Path: newname, Err: errNotDir}
+		case len(old.children) > 0:
+			return &fs.PathError{Op: "rename", Path: newname, Err: errNotEmpty}
+		}
+	}
+
+	now := time.Now()
+	delete(odir.children, obase)
+	n.name = nbase
+	ndir.children[nbase] = n
+	odir.modTime, ndir.modTime = now, now
+	return nil
+}
+
+// Chmod changes the permission bits of name, along with its setuid, setgid
+// and sticky bits.
+func (f *FS) Chmod(name string, mode fs.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	const bits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+	n.mode = n.mode&^bits | mode&bits
+	return nil
+}
+
+// Chtimes changes the modification time of name, unless mtime is the zero
+// time. Access times are not kept.
+func (f *FS) Chtimes(name string, atime, mtime time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	if !mtime.IsZero() {
+		n.modTime = mtime
+	}
+	return nil
+}
+
+// Chown records the owner of name. An id of -1 leaves it unchanged.
+func (f *FS) Chown(name string, uid, gid int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("chown", name)
+	if err != nil {
+		return err
+	}
+	var owner Owner
+	if n.owner != nil {
+		owner = *n.owner
+	}
+	if uid != -1 {
+		owner.UID = uid
+	}
+	if gid != -1 {
+		owner.GID = gid
+	}
+	n.owner = &owner
+	return nil
+}
+
+// file is a handle on a node. Its offset is its own; the data is shared.
+type file struct {
+	fsys    *FS
+	n       *node
+	name    string
+	flag    int
+	off     int64
+	closed  bool
+	entries []fs.DirEntry // for directories, as listed when opened
+}
+
+var _ multifs.File = (*file)(nil)
+var _ fs.ReadDirFile = (*file)(nil)
+var _ io.ReaderAt = (*file)(nil)
+
+func (h *file) check(op string) error {
+	if h.closed {
+		return &fs.PathError{Op: op, Path: h.name, Err: errClosed}
+	}
+	return nil
+}
+
+func (h *file) Stat() (fs.FileInfo, error) {
+	if err := h.check("stat"); err != nil {
+		return nil, err
+	}
+	h.fsys.mu.RLock()
+	defer h.fsys.mu.RUnlock()
+	return h.n.info(), nil
+}
+
+func (h *file) Read(p []byte) (int, error) {
+	n, err := h.ReadAt(p, h.off)
+	h.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (h *file) ReadAt(p []byte, off int64) (int, error) {
+	if err := h.check("read"); err != nil {
+		return 0, err
+	}
+	if h.n.children != nil {
+		return 0, &fs.PathError{Op: "read", Path: h.name, Err: errIsDir}
+	}
+	if h.flag&os.O_WRONLY != 0 {
+		return 0, &fs.PathError{Op: "read", Path: h.name, Err: fs.ErrPermission}
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: h.name, Err: fs.ErrInvalid}
+	}
+	h.fsys.mu.RLock()
+	defer h.fsys.mu.RUnlock()
+	if off >= int64(len(h.n.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.n.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (h *file) Write(p []byte) (int, error) {
+	if err := h.check("write"); err != nil {
+		return 0, err
+	}
+	if h.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &fs.PathError{Op: "write", Path: h.name, Err: fs.ErrPermission}
+	}
+	h.fsys.mu.Lock()
+	defer h.fsys.mu.Unlock()
+	if h.flag&os.O_APPEND != 0 {
+		h.off = int64(len(h.n.data))
+	}
+	end := h.off + int64(len(p))
+	if end > int64(len(h.n.data)) {
+		h.n.data = slices.Grow(h.n.data, int(end)-len(h.n.data))[:end]
+	}
+	copy(h.n.data[h.off:], p)
+	h.off = end
+	h.n.modTime = time.Now()
+	return len(p), nil
+}
+
+func (h *file) Seek(offset int64, whence int) (int64, error) {
+	if err := h.check("seek"); err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += h.off
+	case io.SeekEnd:
+		h.fsys.mu.RLock()
+		offset += int64(len(h.n.data))
+		h.fsys.mu.RUnlock()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: h.name, Err: fs.ErrInvalid}
+	}
+	h.off = offset
+	return offset, nil
+}
+
+func (h *file) Truncate(size int64) error {
+	if err := h.check("truncate"); err != nil {
+		return err
+	}
+	if h.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &fs.PathError{Op: "truncate", Path: h.name, Err: fs.ErrPermission}
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: h.name, Err: fs.ErrInvalid}
+	}
+	h.fsys.mu.Lock()
+	defer h.fsys.mu.Unlock()
+	if size <= int64(len(h.n.data)) {
+		h.n.data = h.n.data[:size:size]
+	} else {
+		h.n.data = append(h.n.data, make([]byte, int(size)-len(h.n.data))...)
+	}
+	h.n.modTime = time.Now()
+	return nil
+}
+
+func (h *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	if err := h.check("readdir"); err != nil {
+		return nil, err
+	}
+	if h.n.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: h.name, Err: errNotDir}
+	}
+	if len(h.entries) == 0 && n > 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(h.entries) {
+		n = len(h.entries)
+	}
+	entries := h.entries[:n]
+	h.entries = h.entries[n:]
+	return entries, nil
+}
+
+func (h *file) Close() error {
+	if err := h.check("close"); err != nil {
+		return err
+	}
+	h.closed = true
+	return nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	owner   *Owner
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+
+func (i fileInfo) Sys() any {
+	if i.owner == nil {
+		return nil
+	}
+	return i.owner
+}
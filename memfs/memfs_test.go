@@ -0,0 +1,183 @@
+package memfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+func writeFile(t *testing.T, fsys *FS, name, data string) {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile %s: %v", name, err)
+	}
+	if _, err := io.WriteString(f, data); err != nil {
+		t.Fatalf("Write %s: %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close %s: %v", name, err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := New()
+	if err := fsys.Mkdir("dir", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	writeFile(t, fsys, "dir/a", "hello")
+	writeFile(t, fsys, "b", "world")
+	if err := fstest.TestFS(fsys, "dir/a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(fsys, "dir/a"); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	fsys := New()
+	writeFile(t, fsys, "f", "hello world")
+
+	f, err := fsys.OpenFile("f", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Seek(6, io.SeekStart)
+	io.WriteString(f, "there")
+	f.Truncate(8)
+	f.Close()
+	if data, _ := fs.ReadFile(fsys, "f"); string(data) != "hello th" {
+		t.Fatalf("after overwrite and truncate: %q", data)
+	}
+
+	f, _ = fsys.OpenFile("f", os.O_WRONLY|os.O_APPEND, 0)
+	io.WriteString(f, "!")
+	f.Close()
+	if data, _ := fs.ReadFile(fsys, "f"); string(data) != "hello th!" {
+		t.Fatalf("after append: %q", data)
+	}
+
+	if _, err := fsys.OpenFile("f", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("O_EXCL on existing file: %v", err)
+	}
+	if _, err := fsys.OpenFile("missing/f", os.O_WRONLY|os.O_CREATE, 0o644); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("create in missing directory: %v", err)
+	}
+	r, _ := fsys.Open("f")
+	if _, err := r.(io.Writer).Write([]byte("x")); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("write on read-only handle: %v", err)
+	}
+}
+
+func TestRemoveRename(t *testing.T) {
+	fsys := New()
+	fsys.Mkdir("d", 0o755)
+	writeFile(t, fsys, "d/a", "a")
+
+	if err := fsys.Remove("d"); err == nil {
+		t.Fatal("removed a non-empty directory")
+	}
+	if err := fsys.Rename("d", "d/sub"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("rename into itself: %v", err)
+	}
+	if err := fsys.Rename("d", "e"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "e/a"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile after Rename: %q, %v", data, err)
+	}
+	writeFile(t, fsys, "b", "b")
+	if err := fsys.Rename("b", "e/a"); err != nil {
+		t.Fatalf("Rename over a file: %v", err)
+	}
+	if data, _ := fs.ReadFile(fsys, "e/a"); string(data) != "b" {
+		t.Fatalf("replaced file: %q", data)
+	}
+	if err := fsys.Remove("e/a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := fsys.Remove("e"); err != nil {
+		t.Fatalf("Remove empty directory: %v", err)
+	}
+	if entries, _ := fsys.ReadDir("."); len(entries) != 0 {
+		t.Fatalf("entries left: %v", entries)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	fsys := New()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("f%d", i)
+			for range 100 {
+				writeFile(t, fsys, name, name)
+				fs.ReadFile(fsys, name)
+				fs.ReadDir(fsys, ".")
+			}
+		}()
+	}
+	wg.Wait()
+	if entries, _ := fsys.ReadDir("."); len(entries) != 8 {
+		t.Fatalf("entries: got %d, want 8", len(entries))
+	}
+}
+
+func TestMount(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	lower := fstest.MapFS{"a": &fstest.MapFile{Data: []byte("lower")}}
+	mux.Mount("scratch", New())
+	mux.Mount("snap", multifs.NewOverlayFS(lower, New()))
+
+	if err := mux.Move("snap/a", "scratch/a"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if data, err := fs.ReadFile(mux, "scratch/a"); err != nil || string(data) != "lower" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if _, err := mux.Stat("snap/a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("moved file left in overlay: %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	fsys := New()
+	mux.Mount("scratch", fsys)
+	writeFile(t, fsys, "f", "data")
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := mux.Chmod("scratch/f", 0o600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	if err := mux.Chtimes("scratch/f", time.Time{}, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := mux.Chown("scratch/f", 1000, 100); err != nil {
+		t.Fatalf("Chown: %v", err)
+	}
+	if err := mux.Chown("scratch/f", -1, 50); err != nil {
+		t.Fatalf("Chown: %v", err)
+	}
+	info, err := mux.Stat("scratch/f")
+	if err != nil || info.Mode() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Stat: %v %v, %v", info.Mode(), info.ModTime(), err)
+	}
+	if owner, ok := info.Sys().(*Owner); !ok || *owner != (Owner{UID: 1000, GID: 50}) {
+		t.Fatalf("owner: %#v", info.Sys())
+	}
+	if err := mux.Chmod("scratch/missing", 0o600); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Chmod on a missing file: %v", err)
+	}
+}
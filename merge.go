@@ -0,0 +1,259 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrConflict is returned by FailOnConflict.
+var ErrConflict = errors.New("multifs: conflicting entries")
+
+// Candidate is one of the entries found under the same path in several
+// mounts of a MergeFS.
+type Candidate struct {
+	ID   string
+	Info fs.FileInfo
+}
+
+// ConflictFunc picks which of candidates, given in priority order, serves
+// name, by returning its index. It is only called when name is found in
+// several mounts and not as a directory in all of them, as directories
+// are merged. A non-nil error fails the operation on name.
+type ConflictFunc func(name string, candidates []Candidate) (int, error)
+
+// FirstWins serves the candidate of the mount with the highest priority.
+func FirstWins(name string, candidates []Candidate) (int, error) { return 0, nil }
+
+// NewestWins serves the candidate modified last, the one with the highest
+// priority among equals.
+func NewestWins(name string, candidates []Candidate) (int, error) {
+	newest := 0
+	for i, c := range candidates {
+		if c.Info.ModTime().After(candidates[newest].Info.ModTime()) {
+			newest = i
+		}
+	}
+	return newest, nil
+}
+
+// FailOnConflict fails with ErrConflict.
+func FailOnConflict(name string, candidates []Candidate) (int, error) {
+	return 0, ErrConflict
+}
+
+type MergeOptions struct {
+	// Conflict resolves the paths found in several mounts, FirstWins by
+	// default.
+	Conflict ConflictFunc
+}
+
+// MergeFS is a single tree assembled from mounts of a MultiFS, without
+// their ids. Directories list the union of their entries in every mount
+// where they are directories; other paths are served from one mount,
+// chosen by the ConflictFunc of the MergeFS when found in several.
+type MergeFS struct {
+	m        *MultiFS
+	ids      []string
+	conflict ConflictFunc
+}
+
+var _ fs.StatFS = (*MergeFS)(nil)
+var _ fs.ReadDirFS = (*MergeFS)(nil)
+
+// Merge returns the union of the mounts ids, the first one having the
+// highest priority, or of every mount in root listing order when no id is
+// given. The union follows the mount table: mounts are looked up on every
+// call, and ids not mounted are skipped.
+func (m *MultiFS) Merge(ids ...string) *MergeFS {
+	return m.MergeWith(MergeOptions{}, ids...)
+}
+
+// MergeWith is Merge with options.
+func (m *MultiFS) MergeWith(opts MergeOptions, ids ...string) *MergeFS {
+	return &MergeFS{m: m, ids: slices.Clone(ids), conflict: opts.Conflict}
+}
+
+// layers returns the mounts merged, in priority order.
+func (u *MergeFS) layers() ([]*mount, *options) {
+	t := u.m.tab.Load()
+	if len(u.ids) == 0 {
+		mounts := make([]*mount, 0, t.roots.len())
+		for _, id := range t.ids() {
+			mnt, _ := t.roots.get(id)
+			mounts = append(mounts, mnt)
+		}
+		return mounts, t.opts
+	}
+	mounts := make([]*mount, 0, len(u.ids))
+	for _, id := range u.ids {
+		if _, mnt, ok := t.lookup(id); ok {
+			mounts = append(mounts, mnt)
+		}
+	}
+	return mounts, t.opts
+}
+
+// resolve picks among candidates, which are not all directories.
+func (u *MergeFS) resolve(op, name string, candidates []Candidate) (int, error) {
+	if u.conflict == nil {
+		return 0, nil
+	}
+	i, err := u.conflict(name, candidates)
+	if err == nil && (i < 0 || i >= len(candidates)) {
+		err = errors.New("conflict resolved to no candidate")
+	}
+	if err != nil {
+		return 0, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return i, nil
+}
+
+// match is a path found in the mounts: the mount serving it, its info, and
+// when a directory, the mounts holding it as a directory, in priority
+// order, the serving one first.
+type match struct {
+	mnt  *mount
+	info fs.FileInfo
+	dirs []*mount
+}
+
+func (u *MergeFS) lookup(ctx context.Context, op, name string) (match, *options, error) {
+	if !fs.ValidPath(name) {
+		return match{}, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	layers, opts := u.layers()
+	var found []*mount
+	var candidates []Candidate
+	for _, mnt := range layers {
+		info, err := resolved{subpath: name, mnt: mnt, opts: opts}.stat(ctx)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return match{}, nil, err
+		}
+		found = append(found, mnt)
+		candidates = append(candidates, Candidate{ID: mnt.id, Info: info})
+		if u.conflict == nil && !info.IsDir() {
+			// Nothing below can win
+			break
+		}
+	}
+	if len(found) == 0 {
+		return match{}, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	pick := 0
+	if len(candidates) > 1 && slices.ContainsFunc(candidates, func(c Candidate) bool { return !c.Info.IsDir() }) {
+		var err error
+		if pick, err = u.resolve(op, name, candidates); err != nil {
+			return match{}, nil, err
+		}
+	}
+	m := match{mnt: found[pick], info: mergedInfo(name, candidates[pick].Info)}
+	if m.info.IsDir() {
+		m.dirs = []*mount{found[pick]}
+		for i, mnt := range found {
+			if i != pick && candidates[i].Info.IsDir() {
+				m.dirs = append(m.dirs, mnt)
+			}
+		}
+	}
+	return m, opts, nil
+}
+
+func (u *MergeFS) Open(name string) (fs.File, error) {
+	return u.OpenContext(context.Background(), name)
+}
+
+func (u *MergeFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	m, opts, err := u.lookup(ctx, "open", name)
+	if err != nil {
+		return nil, err
+	}
+	if m.dirs == nil {
+		return resolved{subpath: name, mnt: m.mnt, opts: opts}.open(ctx)
+	}
+	entries, err := u.readDir(ctx, name, m.dirs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &staticDir{info: m.info, entries: entries}, nil
+}
+
+// readDir lists the union of the directory name in dirs.
+func (u *MergeFS) readDir(ctx context.Context, name string, dirs []*mount, opts *options) ([]fs.DirEntry, error) {
+	var names []string
+	byName := make(map[string][]fs.DirEntry)
+	ids := make(map[string][]string)
+	for _, mnt := range dirs {
+		entries, err := resolved{subpath: name, mnt: mnt, opts: opts}.readDir(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if _, ok := byName[e.Name()]; !ok {
+				names = append(names, e.Name())
+			}
+			byName[e.Name()] = append(byName[e.Name()], e)
+			ids[e.Name()] = append(ids[e.Name()], mnt.id)
+		}
+	}
+
+	slices.SortFunc(names, strings.Compare)
+	notDir := func(e fs.DirEntry) bool { return !e.IsDir() }
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, base := range names {
+		found := byName[base]
+		if len(found) == 1 || u.conflict == nil || !slices.ContainsFunc(found, notDir) {
+			entries = append(entries, found[0])
+			continue
+		}
+		candidates := make([]Candidate, len(found))
+		for i, e := range found {
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			candidates[i] = Candidate{ID: ids[base][i], Info: info}
+		}
+		pick, err := u.resolve("readdir", path.Join(name, base), candidates)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found[pick])
+	}
+	return entries, nil
+}
+
+func (u *MergeFS) Stat(name string) (fs.FileInfo, error) {
+	m, _, err := u.lookup(context.Background(), "stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return m.info, nil
+}
+
+func (u *MergeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m, opts, err := u.lookup(context.Background(), "readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if m.dirs == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return u.readDir(context.Background(), name, m.dirs, opts)
+}
+
+// mergedInfo names the root of the union ".", whatever name the mount
+// it comes from gives to its own root.
+func mergedInfo(name string, info fs.FileInfo) fs.FileInfo {
+	if name == "." {
+		return renamedInfo{FileInfo: info, name: "."}
+	}
+	return info
+}
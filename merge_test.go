@@ -0,0 +1,128 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newMergeMux() *MultiFS {
+	mux := NewMultiFS()
+	mux.Mount("base", fstest.MapFS{
+		"etc/hosts":  &fstest.MapFile{Data: []byte("base hosts")},
+		"etc/passwd": &fstest.MapFile{Data: []byte("base passwd")},
+		"bin":        &fstest.MapFile{Data: []byte("base bin")},
+	})
+	mux.Mount("patch", fstest.MapFS{
+		"etc/hosts": &fstest.MapFile{Data: []byte("patched hosts")},
+		"bin/sh":    &fstest.MapFile{Data: []byte("sh")},
+		"var/log":   &fstest.MapFile{Data: []byte("log")},
+	})
+	return mux
+}
+
+func TestMerge(t *testing.T) {
+	mux := newMergeMux()
+	u := mux.Merge("patch", "base")
+
+	if err := fstest.TestFS(u, "etc/hosts", "etc/passwd", "bin/sh", "var/log"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile(u, "etc/hosts"); string(data) != "patched hosts" {
+		t.Fatalf("etc/hosts: %q", data)
+	}
+	if got := listNames(t, u, "etc"); got != "hosts,passwd" {
+		t.Fatalf("etc: %s", got)
+	}
+	if got := listNames(t, u, "."); got != "bin,etc,var" {
+		t.Fatalf("root: %s", got)
+	}
+	// bin is a directory in the mount with the highest priority
+	if info, err := u.Stat("bin"); err != nil || !info.IsDir() {
+		t.Fatalf("Stat(bin): %v, %v", info, err)
+	}
+	if _, err := u.Open("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open(missing): %v", err)
+	}
+}
+
+func TestMergeAll(t *testing.T) {
+	mux := newMergeMux()
+	u := mux.Merge()
+
+	// Without ids, mounts are merged in root listing order
+	if data, _ := fs.ReadFile(u, "etc/hosts"); string(data) != "base hosts" {
+		t.Fatalf("etc/hosts: %q", data)
+	}
+	if data, _ := fs.ReadFile(u, "bin"); string(data) != "base bin" {
+		t.Fatalf("bin: %q", data)
+	}
+
+	mux.Unmount("base")
+	if data, _ := fs.ReadFile(u, "etc/hosts"); string(data) != "patched hosts" {
+		t.Fatalf("etc/hosts after Unmount: %q", data)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	old, recent := time.Unix(1000, 0), time.Unix(2000, 0)
+	mux := NewMultiFS()
+	mux.Mount("a", fstest.MapFS{
+		"f":     &fstest.MapFile{Data: []byte("a"), ModTime: old},
+		"d/x":   &fstest.MapFile{Data: []byte("a"), ModTime: recent},
+		"only":  &fstest.MapFile{Data: []byte("a")},
+		"mixed": &fstest.MapFile{Data: []byte("a"), ModTime: old},
+	})
+	mux.Mount("b", fstest.MapFS{
+		"f":       &fstest.MapFile{Data: []byte("b"), ModTime: recent},
+		"d/x":     &fstest.MapFile{Data: []byte("b"), ModTime: old},
+		"mixed":   &fstest.MapFile{Mode: fs.ModeDir, ModTime: recent},
+		"mixed/y": &fstest.MapFile{Data: []byte("b")},
+	})
+
+	newest := mux.MergeWith(MergeOptions{Conflict: NewestWins}, "a", "b")
+	if data, _ := fs.ReadFile(newest, "f"); string(data) != "b" {
+		t.Fatalf("NewestWins f: %q", data)
+	}
+	if data, _ := fs.ReadFile(newest, "d/x"); string(data) != "a" {
+		t.Fatalf("NewestWins d/x: %q", data)
+	}
+	if info, err := newest.Stat("mixed"); err != nil || !info.IsDir() {
+		t.Fatalf("NewestWins mixed: %v, %v", info, err)
+	}
+	entries, err := newest.ReadDir(".")
+	if err != nil || len(entries) != 4 || entries[2].Name() != "mixed" || !entries[2].IsDir() {
+		t.Fatalf("NewestWins listing: %v, %v", entries, err)
+	}
+	if err := fstest.TestFS(newest, "f", "d/x", "only", "mixed/y"); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := mux.MergeWith(MergeOptions{Conflict: FailOnConflict}, "a", "b")
+	if _, err := strict.Open("f"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("FailOnConflict f: %v", err)
+	}
+	if _, err := strict.ReadDir("."); !errors.Is(err, ErrConflict) {
+		t.Fatalf("FailOnConflict listing: %v", err)
+	}
+	if _, err := strict.ReadDir("d"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("FailOnConflict d: %v", err)
+	}
+	if data, err := fs.ReadFile(strict, "only"); err != nil || string(data) != "a" {
+		t.Fatalf("FailOnConflict only: %q, %v", data, err)
+	}
+
+	var got []Candidate
+	custom := mux.MergeWith(MergeOptions{Conflict: func(name string, candidates []Candidate) (int, error) {
+		got = candidates
+		return len(candidates) - 1, nil
+	}}, "a", "b")
+	if data, _ := fs.ReadFile(custom, "f"); string(data) != "b" {
+		t.Fatalf("custom f: %q", data)
+	}
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "b" || !got[1].Info.ModTime().Equal(recent) {
+		t.Fatalf("candidates: %+v", got)
+	}
+}
@@ -0,0 +1,166 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorClass sorts the errors of delegated operations into a few classes
+// suitable as metric labels.
+type ErrorClass int
+
+const (
+	ErrorNone ErrorClass = iota
+	ErrorNotExist
+	ErrorPermission
+	ErrorCanceled
+	ErrorTimeout
+	ErrorQuota
+	ErrorOther
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorNone:
+		return "none"
+	case ErrorNotExist:
+		return "not_exist"
+	case ErrorPermission:
+		return "permission"
+	case ErrorCanceled:
+		return "canceled"
+	case ErrorTimeout:
+		return "timeout"
+	case ErrorQuota:
+		return "quota"
+	case ErrorOther:
+		return "other"
+	}
+	return "unknown"
+}
+
+func classify(err error) ErrorClass {
+	switch {
+	case err == nil, err == io.EOF:
+		return ErrorNone
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorNotExist
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorPermission
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, ErrQuotaExceeded):
+		return ErrorQuota
+	}
+	return ErrorOther
+}
+
+// Observation describes one operation delegated to a mount: "open",
+// "stat" and "readdir" through MultiFS, and "read" on the files it
+// returned.
+type Observation struct {
+	ID      string
+	Op      string
+	Latency time.Duration
+	Bytes   int64
+	Err     ErrorClass
+}
+
+// WithMetrics registers a function called after every delegated
+// operation, for export to a metrics system. It is called synchronously
+// and must be cheap.
+func WithMetrics(fn func(Observation)) Option {
+	return func(o *options) {
+		o.metrics = append(o.metrics, fn)
+	}
+}
+
+// Stats are counters of the operations delegated to mounts since the
+// MultiFS was created.
+type Stats struct {
+	Opens        int64
+	Stats        int64
+	ReadDirs     int64
+	Reads        int64
+	BytesRead    int64
+	Writes       int64
+	BytesWritten int64
+	Errors       int64
+}
+
+// metrics holds the instrumentation shared by the mounts of a MultiFS.
+type metrics struct {
+	hooks       []func(Observation)
+	tracer      Tracer
+	logger      *slog.Logger
+	slow        time.Duration
+	leakAge     time.Duration
+	limiter     *limiter
+	healthEvery time.Duration
+	opens       atomic.Int64
+	stats       atomic.Int64
+	readDirs    atomic.Int64
+	reads       atomic.Int64
+	bytesRead   atomic.Int64
+	writes      atomic.Int64
+	written     atomic.Int64
+	errors      atomic.Int64
+}
+
+func (m *MultiFS) Stats() Stats {
+	return Stats{
+		Opens:        m.metrics.opens.Load(),
+		Stats:        m.metrics.stats.Load(),
+		ReadDirs:     m.metrics.readDirs.Load(),
+		Reads:        m.metrics.reads.Load(),
+		BytesRead:    m.metrics.bytesRead.Load(),
+		Writes:       m.metrics.writes.Load(),
+		BytesWritten: m.metrics.written.Load(),
+		Errors:       m.metrics.errors.Load(),
+	}
+}
+
+func (mnt *mount) observe(op string, start time.Time, bytes int64, err error) {
+	class := classify(err)
+	if class != ErrorNone {
+		mnt.usage.errors.Add(1)
+	}
+	mnt.usage.lastAccess.Store(time.Now().UnixNano())
+
+	mt := mnt.metrics
+	if mt == nil {
+		return
+	}
+	switch op {
+	case "open":
+		mt.opens.Add(1)
+	case "stat":
+		mt.stats.Add(1)
+	case "readdir":
+		mt.readDirs.Add(1)
+	case "read":
+		mt.reads.Add(1)
+		mt.bytesRead.Add(bytes)
+	case "write":
+		mt.writes.Add(1)
+		mt.written.Add(bytes)
+	}
+	if class != ErrorNone {
+		mt.errors.Add(1)
+	}
+	if len(mt.hooks) == 0 {
+		return
+	}
+
+	obs := Observation{ID: mnt.id, Op: op, Latency: time.Since(start), Bytes: bytes, Err: class}
+	for _, hook := range mt.hooks {
+		hook(obs)
+	}
+}
@@ -0,0 +1,47 @@
+package multifs
+
+import (
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMetrics(t *testing.T) {
+	var mu sync.Mutex
+	var observed []Observation
+	mux := NewMultiFS(WithMetrics(func(o Observation) {
+		mu.Lock()
+		observed = append(observed, o)
+		mu.Unlock()
+	}))
+	mux.Mount("m", fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("hello")}})
+
+	if _, err := fs.ReadFile(mux, "m/a.txt"); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	fs.ReadDir(mux, "m")
+	fs.Stat(mux, "m/missing")
+	fs.ReadDir(mux, ".")
+
+	st := mux.Stats()
+	if st.Opens != 1 || st.ReadDirs != 1 || st.Stats != 1 || st.BytesRead != 5 || st.Errors != 1 {
+		t.Fatalf("Stats: got %+v", st)
+	}
+
+	var sawMissing, sawRead bool
+	for _, o := range observed {
+		if o.ID != "m" {
+			t.Fatalf("observation for id %q", o.ID)
+		}
+		if o.Op == "stat" && o.Err == ErrorNotExist {
+			sawMissing = true
+		}
+		if o.Op == "read" && o.Bytes == 5 {
+			sawRead = true
+		}
+	}
+	if !sawMissing || !sawRead {
+		t.Fatalf("missing observations: %+v", observed)
+	}
+}
@@ -0,0 +1,130 @@
+package multifs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// WithMappedReads serves the regular files of at least minSize bytes, 1 MiB
+// if minSize is not positive, from memory mappings, for mounts of local
+// directories such as MountDir. Random accesses, like those to disk
+// images, then cost no system call. Files the backend does not return as
+// *os.File, and platforms without mmap, are read as usual.
+//
+// A mapped file truncated by another process reads as an error past its
+// new end.
+func WithMappedReads(minSize int64) MountOption {
+	return func(o *mountOptions) {
+		if minSize <= 0 {
+			minSize = 1 << 20
+		}
+		o.mmapMin = minSize
+	}
+}
+
+// mapFile maps f when it is a local file of at least min bytes, and
+// otherwise returns it as is.
+func mapFile(f fs.File, min int64) fs.File {
+	osf, ok := f.(*os.File)
+	if !ok {
+		return f
+	}
+	info, err := osf.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() < min || int64(int(info.Size())) != info.Size() {
+		return f
+	}
+	data, err := mmap(osf, int(info.Size()))
+	if err != nil {
+		return f
+	}
+	return &mappedFile{file: osf, info: info, data: data}
+}
+
+// mappedFile reads a file from its memory mapping.
+type mappedFile struct {
+	file *os.File
+	info fs.FileInfo
+
+	mu   sync.RWMutex
+	data []byte // nil once closed
+
+	posMu sync.Mutex
+	pos   int64
+}
+
+var _ io.ReaderAt = (*mappedFile)(nil)
+var _ io.Seeker = (*mappedFile)(nil)
+
+func (f *mappedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *mappedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.file.Name(), Err: fs.ErrInvalid}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.data == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.file.Name(), Err: fs.ErrClosed}
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+
+	// Pages past the end of a file truncated since it was mapped fault
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			n, err = 0, &fs.PathError{Op: "read", Path: f.file.Name(), Err: fmt.Errorf("file truncated while mapped")}
+		}
+	}()
+	n = copy(p, f.data[off:])
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *mappedFile) Read(p []byte) (int, error) {
+	f.posMu.Lock()
+	defer f.posMu.Unlock()
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *mappedFile) Seek(offset int64, whence int) (int64, error) {
+	f.posMu.Lock()
+	defer f.posMu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.file.Name(), Err: fs.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *mappedFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.data == nil {
+		return &fs.PathError{Op: "close", Path: f.file.Name(), Err: fs.ErrClosed}
+	}
+	err := munmap(f.data)
+	f.data = nil
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
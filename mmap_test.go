@@ -0,0 +1,86 @@
+package multifs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMappedReads(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<12)
+	os.WriteFile(filepath.Join(dir, "disk.img"), data, 0o644)
+	os.WriteFile(filepath.Join(dir, "small"), []byte("small"), 0o644)
+
+	// Files of MountDir mounts are mapped
+	host, err := openHostDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	f, _ := host.Open("disk.img")
+	mf, ok := mapFile(f, 1).(*mappedFile)
+	if !ok {
+		f.Close()
+		t.Skip("mmap is not available")
+	}
+	mf.Close()
+
+	mux := NewMultiFS()
+	if err := mux.MountDir("local", dir, WithMappedReads(1<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := mux.ReadFile("local/small"); err != nil || string(got) != "small" {
+		t.Fatalf("unmapped file: %q, %v", got, err)
+	}
+
+	file, err := mux.Open("local/disk.img")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra, ok := file.(io.ReaderAt)
+	if !ok {
+		t.Fatal("mapped file is not an io.ReaderAt")
+	}
+	buf := make([]byte, 16)
+	if n, err := ra.ReadAt(buf, int64(len(data)-8)); n != 8 || err != io.EOF || !bytes.Equal(buf[:n], data[len(data)-8:]) {
+		t.Fatalf("ReadAt: %d %v %q", n, err, buf[:n])
+	}
+	file.(io.Seeker).Seek(100, io.SeekStart)
+	if got, err := io.ReadAll(file); err != nil || !bytes.Equal(got, data[100:]) {
+		t.Fatalf("read after Seek: %d bytes, %v", len(got), err)
+	}
+	if info, err := file.Stat(); err != nil || info.Size() != int64(len(data)) {
+		t.Fatalf("Stat: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := ra.ReadAt(buf, 0); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("ReadAt after Close: %v", err)
+	}
+}
+
+func TestMappedReadsTruncated(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(name, bytes.Repeat([]byte("x"), 1<<16), 0o644)
+	f, _ := os.Open(name)
+	mf, ok := mapFile(f, 1).(*mappedFile)
+	if !ok {
+		f.Close()
+		t.Skip("mmap is not available")
+	}
+	defer mf.Close()
+
+	if err := os.Truncate(name, 0); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	if _, err := mf.ReadAt(buf, 1<<15); err == nil {
+		t.Fatal("read past the end of a truncated file succeeded")
+	}
+}
@@ -0,0 +1,641 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type MountOption func(*mountOptions)
+
+type mountOptions struct {
+	subtrees    map[string]struct{}
+	subtreeDirs map[string]struct{} // directories above nested subtrees
+	beforeOpen  []func(ctx context.Context, name string) error
+	afterOpen   []func(ctx context.Context, name string, f fs.File) (fs.File, error)
+	foldCase    bool
+	readOnly    bool
+	access      []AccessFunc
+	quota       Quota
+	encoding    NameEncoding
+	statFuncs   []func(name string, info fs.FileInfo) fs.FileInfo
+	cache       *CacheConfig
+	readAhead   *ReadAhead
+	collision   *CollisionPolicy
+	prefix      string
+	rewrite     *pathRewrite
+	transform   *Transform
+	decrypter   Decrypter
+	integrity   IntegrityProvider
+	rateLimit   *rateLimit
+	retry       *RetryPolicy
+	timeouts    map[Op]time.Duration
+	replicas    []fs.FS
+	removeGuard *RemoveGuard
+	mmapMin     int64
+	conflicts   bool
+	config      *MountConfig
+	// owned is set for the filesystems opened by MultiFS itself, which
+	// it closes once they are no longer mounted.
+	owned *ownedFS
+}
+
+// WithSubtrees restricts a mount to the given entries of its filesystem,
+// top-level or nested such as "home/alice". Everything else is pruned from
+// listings and cannot be opened. The directories above a nested entry can
+// be read, but list nothing else.
+func WithSubtrees(names ...string) MountOption {
+	return func(o *mountOptions) {
+		if o.subtrees == nil {
+			o.subtrees = make(map[string]struct{}, len(names))
+			o.subtreeDirs = make(map[string]struct{})
+		}
+		for _, name := range names {
+			name = path.Clean(strings.Trim(name, "/"))
+			o.subtrees[name] = struct{}{}
+			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+				o.subtreeDirs[dir] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithBeforeOpen registers a hook called with the path inside the mount
+// before it is opened, for reading or by OpenFile for writing. Returning
+// an error vetoes the open.
+func WithBeforeOpen(hook func(ctx context.Context, name string) error) MountOption {
+	return func(o *mountOptions) {
+		o.beforeOpen = append(o.beforeOpen, hook)
+	}
+}
+
+// WithAfterOpen registers a hook called with every successfully opened file.
+// It may return the file as is, wrap it, or fail the open, in which case the
+// file is closed. Files opened for writing are passed as a File, and only
+// stay writable if the hook returns one.
+func WithAfterOpen(hook func(ctx context.Context, name string, f fs.File) (fs.File, error)) MountOption {
+	return func(o *mountOptions) {
+		o.afterOpen = append(o.afterOpen, hook)
+	}
+}
+
+// WithCaseInsensitivePaths makes lookups inside the mount fall back to a
+// case-insensitive match when the exact path does not exist, for backends
+// that cannot do it themselves. An exact match always wins; otherwise the
+// first matching entry in directory order is used.
+func WithCaseInsensitivePaths() MountOption {
+	return func(o *mountOptions) {
+		o.foldCase = true
+	}
+}
+
+// mount is a filesystem attached to the table under an id. Its context is
+// cancelled once it is unmounted, stopping any background work on it.
+type mount struct {
+	id      string
+	fsys    fs.FS
+	backend fs.FS // fsys as mounted, before any wrapping
+	opts    mountOptions
+	mounted time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	lastErr atomic.Pointer[MountError]
+	health  atomic.Pointer[Health]
+	usage   usage
+	metrics *metrics
+	cache   *cache
+	limiter *limiter
+	// replicas is set when fsys fails over to replicas.
+	replicas *replicaFS
+
+	// sessions counts the open Sessions; it only grows with the table's
+	// lock held for reading, and is checked with it held for writing.
+	sessions atomic.Int64
+
+	// detached is set once forcibly unmounted; releaseCh is closed when
+	// a file or session is closed, for unmounts waiting on them.
+	detached  atomic.Bool
+	releaseMu sync.Mutex
+	releaseCh chan struct{}
+
+	// handles holds the files open on the mount.
+	handles sync.Map
+
+	size          sizeCache
+	confirmations confirmations
+	logical       logicalSizes
+}
+
+// MountError records a failure reported by a mounted filesystem.
+type MountError struct {
+	Op   string
+	Path string
+	Err  error
+	Time time.Time
+}
+
+func (e *MountError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *MountError) Unwrap() error { return e.Err }
+
+// record keeps err as the mount's last error if it denotes a failure of the
+// backend rather than an expected outcome such as a missing file.
+func (mnt *mount) record(op, name string, err error) error {
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist),
+		errors.Is(err, fs.ErrExist),
+		errors.Is(err, fs.ErrPermission),
+		errors.Is(err, fs.ErrInvalid),
+		errors.Is(err, context.Canceled):
+	default:
+		mnt.lastErr.Store(&MountError{Op: op, Path: name, Err: err, Time: time.Now()})
+	}
+	return err
+}
+
+func newMount(id string, fsys fs.FS, opts []MountOption, metrics *metrics) *mount {
+	mnt := &mount{
+		id:      id,
+		fsys:    fsys,
+		backend: fsys,
+		mounted: time.Now(),
+		metrics: metrics,
+	}
+	for _, opt := range opts {
+		opt(&mnt.opts)
+	}
+	if mnt.opts.owned != nil {
+		mnt.opts.owned.refs.Add(1)
+	}
+	if len(mnt.opts.replicas) > 0 {
+		mnt.replicas = newReplicaFS(fsys, mnt.opts.replicas)
+		mnt.fsys = mnt.replicas
+	}
+	if mnt.opts.readOnly {
+		mnt.fsys = readOnly(mnt.fsys)
+	}
+	mnt.start()
+	return mnt
+}
+
+// clone returns a mount of the same filesystem with the same options,
+// for another table.
+func (mnt *mount) clone(metrics *metrics) *mount {
+	c := &mount{
+		id:       mnt.id,
+		fsys:     mnt.fsys,
+		backend:  mnt.backend,
+		opts:     mnt.opts,
+		mounted:  mnt.mounted,
+		metrics:  metrics,
+		replicas: mnt.replicas,
+	}
+	if c.opts.owned != nil {
+		c.opts.owned.refs.Add(1)
+	}
+	c.start()
+	return c
+}
+
+// ownedFS counts the mounts of a filesystem owned by MultiFS, across
+// clones, to close it with c once no longer mounted.
+type ownedFS struct {
+	c      io.Closer
+	refs   atomic.Int64
+	closed atomic.Bool
+}
+
+// own returns the owner of a filesystem to be mounted with its option.
+func own(c io.Closer) *ownedFS {
+	return &ownedFS{c: c}
+}
+
+func (o *ownedFS) option() MountOption {
+	return func(opts *mountOptions) {
+		opts.owned = o
+	}
+}
+
+// settle closes the filesystem if it did not get mounted, the mount
+// having failed or kept another filesystem.
+func (o *ownedFS) settle() {
+	if o.refs.Load() == 0 {
+		o.close()
+	}
+}
+
+// acquire takes a reference on the filesystem unless it is no longer
+// mounted anywhere.
+func (o *ownedFS) acquire() bool {
+	for {
+		n := o.refs.Load()
+		if n == 0 {
+			return false
+		}
+		if o.refs.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// release drops a reference, closing the filesystem with the last one.
+func (o *ownedFS) release() {
+	if o.refs.Add(-1) == 0 {
+		o.close()
+	}
+}
+
+func (o *ownedFS) close() {
+	if o.closed.CompareAndSwap(false, true) {
+		o.c.Close()
+	}
+}
+
+// stop ends the mount once removed from its table, closing its
+// filesystem if MultiFS owns it and no other table nor View holds it.
+func (mnt *mount) stop() {
+	mnt.cancel()
+	if o := mnt.opts.owned; o != nil {
+		o.release()
+	}
+}
+
+// start sets up the state of a new mount.
+func (mnt *mount) start() {
+	if mnt.opts.cache != nil {
+		mnt.cache = newCache(*mnt.opts.cache)
+	}
+	mnt.limiter = newLimiter(mnt.opts.rateLimit)
+	mnt.ctx, mnt.cancel = context.WithCancel(context.Background())
+	if mnt.metrics != nil && mnt.metrics.healthEvery > 0 {
+		go mnt.monitorHealth(mnt.metrics.healthEvery)
+	}
+}
+
+// Open and OpenContext expose the mount, with its options applied, as a
+// filesystem of its own.
+func (mnt *mount) Open(name string) (fs.File, error) {
+	return mnt.OpenContext(context.Background(), name)
+}
+
+func (mnt *mount) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return mnt.open(ctx, name)
+}
+
+// check reports whether name is visible through the mount at all and may
+// be accessed by op.
+func (mnt *mount) check(ctx context.Context, op Op, name string) error {
+	if mnt.opts.subtrees != nil && !mnt.inSubtrees(op, name) {
+		return &fs.PathError{Op: op.String(), Path: name, Err: fs.ErrNotExist}
+	}
+	for _, access := range mnt.opts.access {
+		if err := access(ctx, op, name); err != nil {
+			return &fs.PathError{Op: op.String(), Path: name, Err: err}
+		}
+	}
+	return nil
+}
+
+func (mnt *mount) inSubtrees(op Op, name string) bool {
+	if name == "." {
+		return true
+	}
+	// Directories above a subtree are only traversed, never changed
+	if (op == OpOpen || op == OpStat || op == OpReadDir) && mnt.hasName(mnt.opts.subtreeDirs, name) {
+		return true
+	}
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if mnt.hasName(mnt.opts.subtrees, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasName reports whether set holds name, ignoring case if the mount
+// folds it.
+func (mnt *mount) hasName(set map[string]struct{}, name string) bool {
+	if _, ok := set[name]; ok {
+		return true
+	}
+	if mnt.opts.foldCase {
+		for s := range set {
+			if strings.EqualFold(s, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backendOpen opens name on the mounted filesystem, retrying with the
+// case-folded path when enabled. The folded path is checked again since
+// it is the one actually accessed.
+func (mnt *mount) backendOpen(ctx context.Context, op Op, name string) (fs.File, error) {
+	f, err := mnt.rawOpen(ctx, name)
+	if err != nil && mnt.opts.foldCase && errors.Is(err, fs.ErrNotExist) {
+		if folded, ok := mnt.fold(ctx, name); ok {
+			if err := mnt.check(ctx, op, folded); err != nil {
+				return nil, err
+			}
+			f, err = mnt.rawOpen(ctx, folded)
+		}
+	}
+	return f, mnt.record("open", name, err)
+}
+
+// rawOpen opens name on the backend, translating it to and from the
+// backend's naming if needed.
+func (mnt *mount) rawOpen(ctx context.Context, name string) (fs.File, error) {
+	bname, err := mnt.backendName("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, cached := mnt.cache.open(bname)
+	if !cached {
+		open := func(ctx context.Context) (fs.File, error) {
+			if cfs, ok := mnt.fsys.(ContextFS); ok {
+				return cfs.OpenContext(ctx, bname)
+			}
+			return mnt.fsys.Open(bname)
+		}
+		err = mnt.retry(ctx, func() (err error) {
+			f, err = open(ctx)
+			return err
+		})
+		if err == nil && mnt.opts.mmapMin > 0 {
+			f = mapFile(f, mnt.opts.mmapMin)
+		}
+		if err == nil && mnt.opts.retry != nil {
+			// Reopens happen long after the call that opened f
+			fctx := outliving(ctx)
+			f = mnt.retryingFile(fctx, f, func() (fs.File, error) { return open(fctx) })
+		}
+		if err == nil && mnt.opts.readAhead != nil {
+			f = mnt.readAhead(f)
+		}
+		if err == nil && mnt.cache != nil {
+			f = mnt.cache.record(bname, f)
+		}
+	}
+	if err == nil && mnt.cache != nil && mnt.opts.readAhead != nil && mnt.opts.readAhead.Siblings > 0 {
+		if !isDir(f) {
+			go mnt.prefetch(bname)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mnt.opts.encoding != nil {
+		f = renameFile(f, mnt.opts.encoding.Decode)
+	}
+	if mnt.opts.rewrite != nil {
+		f = mnt.unrewriteFile(name, f)
+	}
+	return f, nil
+}
+
+func (mnt *mount) rawStat(ctx context.Context, name string) (fs.FileInfo, error) {
+	bname, err := mnt.backendName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, cached := mnt.cache.stat(bname)
+	if !cached {
+		err = mnt.retry(ctx, func() (err error) {
+			info, err = fs.Stat(mnt.fsys, bname)
+			return err
+		})
+		if err == nil && mnt.cache != nil {
+			mnt.cache.storeStat(bname, info)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mnt.mountInfo(name, info), nil
+}
+
+// fold resolves name component by component, matching each one
+// case-insensitively against its parent's listing.
+func (mnt *mount) fold(ctx context.Context, name string) (string, bool) {
+	dir := "."
+	for _, elem := range strings.Split(name, "/") {
+		f, err := mnt.rawOpen(ctx, dir)
+		if err != nil {
+			return "", false
+		}
+		rd, ok := f.(fs.ReadDirFile)
+		if !ok {
+			f.Close()
+			return "", false
+		}
+		entries, err := rd.ReadDir(-1)
+		f.Close()
+		if err != nil {
+			return "", false
+		}
+
+		match := ""
+		for _, e := range entries {
+			if e.Name() == elem {
+				match = elem
+				break
+			}
+			if match == "" && strings.EqualFold(e.Name(), elem) {
+				match = e.Name()
+			}
+		}
+		if match == "" {
+			return "", false
+		}
+		dir = path.Join(dir, match)
+	}
+	return dir, true
+}
+
+func (mnt *mount) open(ctx context.Context, name string) (fs.File, error) {
+	return timed(mnt, ctx, OpOpen, name, func(ctx context.Context) (fs.File, error) {
+		return mnt.openAs(ctx, OpOpen, name)
+	}, func(f fs.File) { f.Close() })
+}
+
+// openAs opens name on behalf of op, applying the mount's checks, hooks
+// and listing filters.
+func (mnt *mount) openAs(ctx context.Context, op Op, name string) (fs.File, error) {
+	if err := mnt.check(ctx, op, name); err != nil {
+		return nil, err
+	}
+	for _, hook := range mnt.opts.beforeOpen {
+		if err := hook(ctx, name); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	// Stats open files only to bound the call: they are not accounted as
+	// opens.
+	if op != OpStat {
+		if err := mnt.admit(name); err != nil {
+			return nil, err
+		}
+	}
+	f, err := mnt.backendOpen(ctx, op, name)
+	if err != nil {
+		if op != OpStat {
+			mnt.dismiss()
+		}
+		return nil, err
+	}
+	// The file outlives a call bounded by a timeout
+	fctx := outliving(ctx)
+	if op != OpStat {
+		f = mnt.wrap(fctx, name, f)
+	}
+	if mnt.opts.integrity != nil {
+		verified, err := mnt.verifyFile(name, f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f = verified
+	}
+
+	if mnt.opts.subtrees != nil || mnt.opts.access != nil {
+		f = filterDir(f, func(e fs.DirEntry) bool {
+			return mnt.visible(fctx, path.Join(name, e.Name()))
+		})
+	}
+	if mnt.opts.decrypter != nil {
+		f = mnt.decryptFile(name, f)
+	}
+	if mnt.opts.transform != nil {
+		f = mnt.transformFile(name, f)
+	}
+	if mnt.opts.statFuncs != nil || mnt.measures() {
+		f = mnt.enrichFile(name, f)
+	}
+	for _, hook := range mnt.opts.afterOpen {
+		wrapped, err := hook(ctx, name, f)
+		if err != nil {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		f = wrapped
+	}
+	return f, nil
+}
+
+// visible reports whether name shows up in its parent's listing.
+func (mnt *mount) visible(ctx context.Context, name string) bool {
+	return mnt.check(ctx, OpStat, name) == nil
+}
+
+func (mnt *mount) stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	return timed(mnt, ctx, OpStat, name, func(ctx context.Context) (fs.FileInfo, error) {
+		return mnt.untimedStat(ctx, name)
+	}, nil)
+}
+
+func (mnt *mount) untimedStat(ctx context.Context, name string) (fs.FileInfo, error) {
+	if err := mnt.check(ctx, OpStat, name); err != nil {
+		return nil, err
+	}
+
+	// A context-aware backend is opened so the context can bound the call,
+	// otherwise the backend's own Stat is preferred when it has one.
+	if _, ok := mnt.fsys.(ContextFS); ok || mnt.opts.foldCase {
+		f, err := mnt.openAs(ctx, OpStat, name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.Stat()
+	}
+	info, err := mnt.rawStat(ctx, name)
+	if err != nil {
+		return nil, mnt.record("stat", name, err)
+	}
+	return mnt.enrich(name, info), nil
+}
+
+func (mnt *mount) readDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return timed(mnt, ctx, OpReadDir, name, func(ctx context.Context) ([]fs.DirEntry, error) {
+		return mnt.untimedReadDir(ctx, name)
+	}, nil)
+}
+
+func (mnt *mount) untimedReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	f, err := mnt.openAs(ctx, OpReadDir, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	// Directories list their entries in no particular order, ReadDir
+	// sorts them
+	entries, err := dir.ReadDir(-1)
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, err
+}
+
+// filterDir wraps a directory so that ReadDir only returns the entries
+// accepted by keep. Files that are not directories are returned as is.
+func filterDir(f fs.File, keep func(fs.DirEntry) bool) fs.File {
+	if !isDir(f) {
+		return f
+	}
+	return &filteredDir{ReadDirFile: f.(fs.ReadDirFile), keep: keep}
+}
+
+type filteredDir struct {
+	fs.ReadDirFile
+	keep func(fs.DirEntry) bool
+}
+
+func (d *filteredDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries, err := d.ReadDirFile.ReadDir(n)
+		return d.filter(entries), err
+	}
+
+	var out []fs.DirEntry
+	for len(out) < n {
+		entries, err := d.ReadDirFile.ReadDir(n - len(out))
+		out = append(out, d.filter(entries)...)
+		if err != nil {
+			if err == io.EOF && len(out) > 0 {
+				err = nil
+			}
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (d *filteredDir) filter(entries []fs.DirEntry) []fs.DirEntry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if d.keep(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
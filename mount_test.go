@@ -0,0 +1,318 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountWithSubtrees(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{
+		"etc/passwd":      &fstest.MapFile{Data: []byte("root")},
+		"home/user/a.txt": &fstest.MapFile{Data: []byte("a")},
+		"usr/bin/ls":      &fstest.MapFile{Data: []byte("elf")},
+		"var/log/syslog":  &fstest.MapFile{Data: []byte("log")},
+	}
+	if err := mux.Mount("snap", fs1, WithSubtrees("home", "/etc/")); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	entries, err := fs.ReadDir(mux, "snap")
+	if err != nil {
+		t.Fatalf("ReadDir snap: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "etc" || names[1] != "home" {
+		t.Fatalf("unexpected mount root listing: %v", names)
+	}
+
+	if _, err := fs.ReadFile(mux, "snap/home/user/a.txt"); err != nil {
+		t.Fatalf("ReadFile inside kept subtree: %v", err)
+	}
+	if _, err := fs.ReadFile(mux, "snap/usr/bin/ls"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist outside kept subtrees, got %v", err)
+	}
+
+	var walked []string
+	err = fs.WalkDir(mux, "snap", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	if len(walked) != 2 {
+		t.Fatalf("walk visited unexpected files: %v", walked)
+	}
+}
+
+func TestMountWithNestedSubtrees(t *testing.T) {
+	d := newDirFS(t)
+	for _, dir := range []string{"home", "home/alice", "home/bob"} {
+		if err := d.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, d, "home/alice/a.txt", "a")
+	writeFile(t, d, "home/bob/b.txt", "b")
+	writeFile(t, d, "home/motd", "hi")
+
+	mux := NewMultiFS()
+	if err := mux.Mount("snap", d, WithSubtrees("home/alice/")); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if got := listNames(t, mux, "snap"); got != "home" {
+		t.Fatalf("mount root listing: %s", got)
+	}
+	if got := listNames(t, mux, "snap/home"); got != "alice" {
+		t.Fatalf("home listing: %s", got)
+	}
+	if _, err := fs.ReadFile(mux, "snap/home/alice/a.txt"); err != nil {
+		t.Fatalf("ReadFile inside the subtree: %v", err)
+	}
+	for _, name := range []string{"snap/home/bob/b.txt", "snap/home/motd"} {
+		if _, err := fs.ReadFile(mux, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("ReadFile(%s): expected ErrNotExist, got %v", name, err)
+		}
+	}
+
+	// The directories above the subtree cannot be changed
+	if err := mux.Mkdir("snap/home/carol", 0o755); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Mkdir next to the subtree: expected ErrNotExist, got %v", err)
+	}
+	if err := mux.Remove("snap/home"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Remove above the subtree: expected ErrNotExist, got %v", err)
+	}
+	if err := mux.Mkdir("snap/home/alice/docs", 0o755); err != nil {
+		t.Fatalf("Mkdir inside the subtree: %v", err)
+	}
+}
+
+func TestFilteredDirPagination(t *testing.T) {
+	fs1 := fstest.MapFS{
+		"a": &fstest.MapFile{}, "b": &fstest.MapFile{}, "c": &fstest.MapFile{},
+		"d": &fstest.MapFile{}, "e": &fstest.MapFile{},
+	}
+	f, err := fs1.Open(".")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	dir := filterDir(f, func(e fs.DirEntry) bool { return e.Name() != "b" && e.Name() != "c" }).(fs.ReadDirFile)
+
+	var names []string
+	for {
+		entries, err := dir.ReadDir(2)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if err != nil {
+			break
+		}
+		if len(entries) == 0 {
+			t.Fatalf("ReadDir(2) returned no entries and no error")
+		}
+	}
+	if len(names) != 3 || names[0] != "a" || names[1] != "d" || names[2] != "e" {
+		t.Fatalf("unexpected filtered listing: %v", names)
+	}
+}
+
+type countingFile struct {
+	fs.File
+	reads *int
+}
+
+func (c countingFile) Read(p []byte) (int, error) {
+	*c.reads++
+	return c.File.Read(p)
+}
+
+func TestOpenHooks(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{
+		"public.txt":  &fstest.MapFile{Data: []byte("public")},
+		"secret.txt":  &fstest.MapFile{Data: []byte("secret")},
+		"dir/new.txt": &fstest.MapFile{Data: []byte("new")},
+	}
+
+	var reads int
+	errVeto := errors.New("vetoed")
+	err := mux.Mount("one", fs1,
+		WithBeforeOpen(func(ctx context.Context, name string) error {
+			if name == "secret.txt" {
+				return errVeto
+			}
+			return nil
+		}),
+		WithAfterOpen(func(ctx context.Context, name string, f fs.File) (fs.File, error) {
+			return countingFile{File: f, reads: &reads}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if _, err := mux.Open("one/secret.txt"); !errors.Is(err, errVeto) {
+		t.Fatalf("expected veto error, got %v", err)
+	}
+
+	data, err := fs.ReadFile(mux, "one/public.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "public" {
+		t.Fatalf("unexpected data: %q", data)
+	}
+	if reads == 0 {
+		t.Fatalf("after-open wrapper was not used for reads")
+	}
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{
+		"Documents/Report.TXT": &fstest.MapFile{Data: []byte("report")},
+		"Documents/report.txt": &fstest.MapFile{Data: []byte("exact")},
+	}
+	if err := mux.Mount("win", fs1, WithCaseInsensitivePaths()); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	data, err := fs.ReadFile(mux, "win/documents/REPORT.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "report" {
+		t.Fatalf("unexpected data: %q", data)
+	}
+
+	// An exact match wins over a folded one
+	data, err = fs.ReadFile(mux, "win/documents/report.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "exact" {
+		t.Fatalf("unexpected data: %q", data)
+	}
+
+	if _, err := mux.Stat("win/DOCUMENTS"); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if _, err := mux.Stat("win/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestMountsReportLastError(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := brokenReadFS{fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}}
+	if err := mux.Mount("flaky", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if err := mux.Mount("fine", fstest.MapFS{}); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	// Missing files are not failures
+	if _, err := mux.Open("flaky/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+	if infos := mux.Mounts(); infos[1].LastError != nil {
+		t.Fatalf("unexpected last error: %v", infos[1].LastError)
+	}
+
+	if _, err := mux.Open("flaky/a.txt"); err == nil {
+		t.Fatalf("expected open to fail")
+	}
+
+	infos := mux.Mounts()
+	if len(infos) != 2 || infos[0].ID != "fine" || infos[1].ID != "flaky" {
+		t.Fatalf("unexpected mounts: %+v", infos)
+	}
+	if infos[0].LastError != nil {
+		t.Fatalf("healthy mount has a last error: %v", infos[0].LastError)
+	}
+	last := infos[1].LastError
+	if last == nil {
+		t.Fatalf("failing mount has no last error")
+	}
+	if last.Op != "open" || last.Path != "a.txt" || last.Time.IsZero() {
+		t.Fatalf("unexpected last error: %+v", last)
+	}
+}
+
+// plainFS hides every method of an fs.FS but Open.
+type plainFS struct {
+	fsys fs.FS
+}
+
+func (p plainFS) Open(name string) (fs.File, error) { return p.fsys.Open(name) }
+
+func TestMountSub(t *testing.T) {
+	backend := fstest.MapFS{
+		"snapshots/1/etc/hosts": &fstest.MapFile{Data: []byte("hosts")},
+		"snapshots/1/readme":    &fstest.MapFile{Data: []byte("readme")},
+		"snapshots/2/other":     &fstest.MapFile{},
+	}
+
+	mux := NewMultiFS()
+	if err := mux.MountSub("sub", backend, "snapshots/1"); err != nil {
+		t.Fatalf("MountSub with SubFS: %v", err)
+	}
+	if err := mux.MountSub("plain", plainFS{backend}, "snapshots/1"); err != nil {
+		t.Fatalf("MountSub: %v", err)
+	}
+	if err := fstest.TestFS(mux, "sub/etc/hosts", "sub/readme", "plain/etc/hosts", "plain/readme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mux.Stat("plain/other"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat outside the subtree: %v", err)
+	}
+	if err := mux.MountSub("bad", backend, "../x"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("MountSub with invalid dir: %v", err)
+	}
+}
+
+func TestMountKeepsCapabilities(t *testing.T) {
+	allow := func(context.Context, Op, string) error { return nil }
+	for _, b := range backendsOf(t, fstest.MapFS{"d/f": &fstest.MapFile{Data: []byte("0123456789")}}) {
+		mux := NewMultiFS()
+		mux.Mount("plain", b.fsys)
+		mux.Mount("access", b.fsys, WithAccessFunc(allow))
+		for _, id := range []string{"plain", "access"} {
+			f, err := mux.Open(id + "/d/f")
+			if err != nil {
+				t.Fatalf("%s: Open: %v", b.name, err)
+			}
+			ra, ok1 := f.(io.ReaderAt)
+			_, ok2 := f.(io.Seeker)
+			if !ok1 || !ok2 {
+				t.Fatalf("%s/%s: ReaderAt %v, Seeker %v", b.name, id, ok1, ok2)
+			}
+			p := make([]byte, 4)
+			if n, err := ra.ReadAt(p, 3); err != nil || string(p[:n]) != "3456" {
+				t.Fatalf("%s/%s: ReadAt: %q, %v", b.name, id, p[:n], err)
+			}
+			f.Close()
+
+			if entries, err := fs.ReadDir(mux, id+"/d"); err != nil || len(entries) != 1 {
+				t.Fatalf("%s/%s: ReadDir: %v, %v", b.name, id, entries, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package multifs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// Move renames src to dst. Within a mount it uses the filesystem's own
+// Rename when it has one. Otherwise, and across mounts, files are copied to
+// dst then removed from src one at a time: each file ends up either moved
+// or left untouched, although a directory may end up partially moved.
+// Existing files at dst are replaced, unless the filesystem of dst cannot
+// rename: the move then fails with fs.ErrExist rather than risk losing
+// them. Symbolic links are moved as links, which fails with
+// errors.ErrUnsupported if the filesystem of dst cannot create them.
+func (m *MultiFS) Move(src, dst string) error {
+	return m.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext is Move with a context. Both src and dst are checked as
+// OpRename; when copying, every path created under dst is also checked as
+// OpMkdir, OpCreate or OpSymlink.
+func (m *MultiFS) MoveContext(ctx context.Context, src, dst string) error {
+	from, err := m.resolve("rename", src)
+	if err != nil {
+		return err
+	}
+	to, err := m.resolve("rename", dst)
+	if err != nil {
+		return err
+	}
+	// Mounts themselves are moved by remounting, not by Move.
+	if from.mnt == nil || (from.id != "" && from.subpath == ".") {
+		return &fs.PathError{Op: "move", Path: src, Err: fs.ErrInvalid}
+	}
+	if to.mnt == nil || (to.id != "" && to.subpath == ".") {
+		return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrInvalid}
+	}
+
+	if err := to.mnt.check(ctx, OpRename, to.subpath); err != nil {
+		return err
+	}
+	return from.write(ctx, OpRename, func() error {
+		if from.mnt == to.mnt {
+			err := from.mnt.rename(from.subpath, to.subpath)
+			if !errors.Is(err, errors.ErrUnsupported) {
+				return err
+			}
+			// Copying a directory into itself would never end
+			if strings.HasPrefix(to.subpath+"/", from.subpath+"/") {
+				return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrInvalid}
+			}
+		}
+		info, err := from.mnt.lstat(ctx, from.subpath)
+		if err != nil {
+			return err
+		}
+		return moveAcross(ctx, from.mnt, from.subpath, to.mnt, to.subpath, info)
+	})
+}
+
+// moveAcross moves src to dst by copying, each path created at dst being
+// checked against the access functions of its mount.
+func moveAcross(ctx context.Context, from *mount, src string, to *mount, dst string, info fs.FileInfo) error {
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return moveLink(ctx, from, src, to, dst)
+	case !info.IsDir():
+		return moveFile(ctx, from, src, to, dst, info)
+	}
+
+	if err := to.check(ctx, OpMkdir, dst); err != nil {
+		return err
+	}
+	if err := to.mkdir(dst, info.Mode().Perm()); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if info, err := to.stat(ctx, dst); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+	}
+
+	entries, err := from.readDir(ctx, src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		err = moveAcross(ctx, from, path.Join(src, e.Name()), to, path.Join(dst, e.Name()), info)
+		if err != nil {
+			return err
+		}
+	}
+	return from.remove(src)
+}
+
+// moveFile copies a single file then removes the source, undoing the copy
+// if the source cannot be removed. When the destination can rename, the
+// copy is written under a temporary name first and only renamed over dst
+// once the source is gone, so that dst never holds a partial file and
+// keeps its former content if the move fails. Otherwise the copy is
+// written to dst directly, which must not exist.
+func moveFile(ctx context.Context, from *mount, src string, to *mount, dst string, info fs.FileInfo) error {
+	if _, ok := to.fsys.(OpenFileFS); !ok {
+		return to.unsupported("open", dst)
+	}
+	if _, ok := from.fsys.(RemoveFS); !ok {
+		return from.unsupported("remove", src)
+	}
+	if err := to.check(ctx, OpCreate, dst); err != nil {
+		return err
+	}
+
+	if _, ok := to.fsys.(RenameFS); !ok {
+		// Without a temporary name, overwriting dst would lose it if the
+		// copy failed halfway.
+		if _, err := to.stat(ctx, dst); err == nil {
+			return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+		}
+		if err := copyFile(ctx, from, src, to, dst, info); err != nil {
+			if !errors.Is(err, fs.ErrExist) {
+				to.remove(dst)
+			}
+			return err
+		}
+		if err := from.remove(src); err != nil {
+			to.remove(dst)
+			return err
+		}
+		return nil
+	}
+
+	tmp := path.Join(path.Dir(dst), ".multifs-move-"+path.Base(dst)+"-"+tempSuffix())
+	if err := copyFile(ctx, from, src, to, tmp, info); err != nil {
+		if !errors.Is(err, fs.ErrExist) {
+			to.remove(tmp)
+		}
+		return err
+	}
+	if err := from.remove(src); err != nil {
+		to.remove(tmp)
+		return err
+	}
+	// The source is gone: on failure the copy is left under its temporary
+	// name rather than lost.
+	if err := to.rename(tmp, dst); err != nil {
+		return fmt.Errorf("%w (content kept as %s)", err, tmp)
+	}
+	return nil
+}
+
+// moveLink recreates the symbolic link src as dst, with the same target,
+// then removes src. Links are never followed: their targets are left
+// where they are.
+func moveLink(ctx context.Context, from *mount, src string, to *mount, dst string) error {
+	if _, ok := to.fsys.(SymlinkFS); !ok {
+		return to.unsupported("symlink", dst)
+	}
+	target, err := from.readLink(ctx, src)
+	if err != nil {
+		return err
+	}
+	if err := to.check(ctx, OpSymlink, dst); err != nil {
+		return err
+	}
+	if err := to.symlink(target, dst); err != nil {
+		return err
+	}
+	if err := from.remove(src); err != nil {
+		to.remove(dst)
+		return err
+	}
+	return nil
+}
+
+// tempSuffix returns a random suffix for temporary names, so that
+// concurrent moves to the same destination do not share their copy.
+func tempSuffix() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// copyFile copies src to dst, which must not exist, keeping the holes of
+// sparse files, as reported by the source or found as runs of zeros.
+func copyFile(ctx context.Context, from *mount, src string, to *mount, dst string, info fs.FileInfo) error {
+	extents, known, err := from.extents(ctx, src)
+	if err != nil {
+		return err
+	}
+	r, err := from.open(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := to.openFile(ctx, dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if known {
+		err = copyExtents(w, r, extents, info.Size())
+	} else {
+		err = copySparse(w, r)
+	}
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
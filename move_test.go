@@ -0,0 +1,237 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// noRemoveFS can be written to but not removed from.
+type noRemoveFS struct {
+	dirFS
+}
+
+func (noRemoveFS) Remove(string) error { return fs.ErrPermission }
+
+func TestMoveWithinMount(t *testing.T) {
+	d := newDirFS(t)
+	writeFile(t, d, "a.txt", "hello")
+	m := NewMultiFS()
+	if err := m.Mount("d", d); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if err := m.Move("d/a.txt", "d/b.txt"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if got := listNames(t, m, "d"); got != "b.txt" {
+		t.Fatalf("listing: got %s", got)
+	}
+}
+
+func TestMoveAcrossMounts(t *testing.T) {
+	staging, dest := newDirFS(t), newDirFS(t)
+	if err := staging.Mkdir("out", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, staging, "out/a.txt", "a")
+	writeFile(t, staging, "out/b.txt", "b")
+
+	m := NewMultiFS()
+	m.Mount("staging", staging)
+	m.Mount("dest", dest)
+
+	if err := m.Move("staging/out", "dest/promoted"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if got := listNames(t, m, "staging"); got != "" {
+		t.Fatalf("staging: got %s", got)
+	}
+	data, err := fs.ReadFile(m, "dest/promoted/b.txt")
+	if err != nil || string(data) != "b" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+}
+
+func TestMoveAcrossMountsUndo(t *testing.T) {
+	src, dest := noRemoveFS{newDirFS(t)}, newDirFS(t)
+	writeFile(t, src, "a.txt", "a")
+
+	m := NewMultiFS()
+	m.Mount("src", src)
+	m.Mount("dest", dest)
+
+	if err := m.Move("src/a.txt", "dest/a.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission, got %v", err)
+	}
+	if got := listNames(t, m, "dest"); got != "" {
+		t.Fatalf("copy was not undone: %s", got)
+	}
+	if got := listNames(t, m, "src"); got != "a.txt" {
+		t.Fatalf("source: got %s", got)
+	}
+}
+
+func TestMoveReadOnly(t *testing.T) {
+	m := NewMultiFS()
+	m.Mount("ro", newDirFS(t), WithReadOnly())
+	m.Mount("src", fstest.MapFS{"a.txt": &fstest.MapFile{}})
+
+	if err := m.Move("src/a.txt", "ro/a.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission, got %v", err)
+	}
+	if err := m.Move("src", "ro/src"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid moving a mount, got %v", err)
+	}
+}
+
+// noRenameFS is a dirFS that cannot rename.
+type noRenameFS struct {
+	fs.FS
+	d dirFS
+}
+
+func (f noRenameFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return f.d.OpenFile(name, flag, perm)
+}
+func (f noRenameFS) Mkdir(name string, perm fs.FileMode) error { return f.d.Mkdir(name, perm) }
+func (f noRenameFS) Remove(name string) error                  { return f.d.Remove(name) }
+
+func TestMoveWithinMountWithoutRename(t *testing.T) {
+	d := newDirFS(t)
+	writeFile(t, d, "a.txt", "hello")
+	var trail []string
+	m := NewMultiFS(WithAudit(AuditFunc(func(rec AuditRecord) {
+		trail = append(trail, rec.Op+" "+rec.Path)
+	}), nil))
+	m.Mount("d", noRenameFS{d.FS, d})
+
+	if err := m.Move("d/a.txt", "d/b.txt"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if got := listNames(t, m, "d"); got != "b.txt" {
+		t.Fatalf("listing: got %s", got)
+	}
+	if got := strings.Join(trail, ","); got != "rename d/a.txt" {
+		t.Fatalf("audit: got %q", got)
+	}
+}
+
+func TestMoveKeepsDestination(t *testing.T) {
+	src, dest := noRemoveFS{newDirFS(t)}, newDirFS(t)
+	writeFile(t, src, "a.txt", "new")
+	writeFile(t, dest, "a.txt", "old")
+
+	m := NewMultiFS()
+	m.Mount("src", src)
+	m.Mount("dest", dest)
+
+	if err := m.Move("src/a.txt", "dest/a.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission, got %v", err)
+	}
+	data, err := fs.ReadFile(m, "dest/a.txt")
+	if err != nil || string(data) != "old" {
+		t.Fatalf("destination: %q, %v", data, err)
+	}
+	if got := listNames(t, m, "dest"); got != "a.txt" {
+		t.Fatalf("listing: got %s", got)
+	}
+}
+
+func TestMoveWithoutRenameKeepsDestination(t *testing.T) {
+	src, dest := newDirFS(t), newDirFS(t)
+	writeFile(t, src, "a.txt", "new")
+	writeFile(t, dest, "a.txt", "old")
+
+	m := NewMultiFS()
+	m.Mount("src", src)
+	m.Mount("dest", noRenameFS{dest.FS, dest})
+
+	if err := m.Move("src/a.txt", "dest/a.txt"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected ErrExist, got %v", err)
+	}
+	data, err := fs.ReadFile(m, "dest/a.txt")
+	if err != nil || string(data) != "old" {
+		t.Fatalf("destination: %q, %v", data, err)
+	}
+	if got := listNames(t, m, "src"); got != "a.txt" {
+		t.Fatalf("source: got %s", got)
+	}
+}
+
+func TestMoveTemporaryName(t *testing.T) {
+	src, dest := newDirFS(t), newDirFS(t)
+	writeFile(t, src, "a.txt", "new")
+	// Left over by an earlier move that could not rename its copy
+	writeFile(t, dest, ".multifs-move-a.txt", "stale")
+
+	m := NewMultiFS()
+	m.Mount("src", src)
+	m.Mount("dest", dest)
+
+	if err := m.Move("src/a.txt", "dest/a.txt"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if got := listNames(t, m, "dest"); got != ".multifs-move-a.txt,a.txt" {
+		t.Fatalf("listing: got %s", got)
+	}
+	data, err := fs.ReadFile(m, "dest/a.txt")
+	if err != nil || string(data) != "new" {
+		t.Fatalf("destination: %q, %v", data, err)
+	}
+}
+
+func TestMoveAcrossMountsChecksEveryPath(t *testing.T) {
+	staging, dest := newDirFS(t), newDirFS(t)
+	staging.Mkdir("out", 0o755)
+	writeFile(t, staging, "out/a.txt", "a")
+	writeFile(t, staging, "out/secret", "s")
+
+	m := NewMultiFS()
+	m.Mount("staging", staging)
+	m.Mount("dest", dest, WithAccessFunc(func(_ context.Context, op Op, name string) error {
+		if op == OpCreate && path.Base(name) == "secret" {
+			return fs.ErrPermission
+		}
+		return nil
+	}))
+
+	if err := m.Move("staging/out", "dest/promoted"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected ErrPermission, got %v", err)
+	}
+	if got := listNames(t, m, "dest/promoted"); got != "a.txt" {
+		t.Fatalf("destination: got %s", got)
+	}
+	if got := listNames(t, m, "staging/out"); got != "secret" {
+		t.Fatalf("source: got %s", got)
+	}
+}
+
+func TestMoveAcrossMountsSymlinks(t *testing.T) {
+	staging, dest := newDirFS(t), newDirFS(t)
+	staging.Mkdir("out", 0o755)
+	writeFile(t, staging, "out/a.txt", "a")
+	staging.Symlink("a.txt", "out/link")
+
+	m := NewMultiFS()
+	m.Mount("staging", staging)
+	m.Mount("norename", noRenameFS{dest.FS, dest})
+	m.Mount("dest", dest)
+
+	if err := m.Move("staging/out/link", "norename/link"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if err := m.Move("staging/out", "dest/promoted"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if target, err := m.ReadLink("dest/promoted/link"); err != nil || target != "a.txt" {
+		t.Fatalf("ReadLink: %q, %v", target, err)
+	}
+	if got := listNames(t, m, "staging"); got != "" {
+		t.Fatalf("staging: got %s", got)
+	}
+}
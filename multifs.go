@@ -1,27 +1,77 @@
 package multifs
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"iter"
 	"path"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// MultiFS publishes its mount table through tab, so that resolving paths
+// takes no lock: mu serializes the changes, which are made on a copy of
+// the table, next, published by unlock.
 type MultiFS struct {
-	mu    sync.RWMutex
-	roots map[string]fs.FS
+	mu       sync.RWMutex
+	opts     *options
+	tab      atomic.Pointer[table]
+	next     *table
+	retired  []*mount
+	indexes  []*NameIndex
+	watchers []*watcher
+	metrics  metrics
 }
 
-func NewMultiFS() *MultiFS {
-	return &MultiFS{
-		roots: make(map[string]fs.FS),
+func NewMultiFS(opts ...Option) *MultiFS {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
 	}
+	return newMultiFS(o)
 }
 
-func (m *MultiFS) Mount(id string, f fs.FS) error {
+func newMultiFS(o *options) *MultiFS {
+	m := &MultiFS{opts: o}
+	m.tab.Store(newTable(o))
+	m.metrics.hooks = o.metrics
+	m.metrics.tracer = o.tracer
+	m.metrics.logger = o.logger
+	m.metrics.slow = o.slow
+	m.metrics.leakAge = o.leakAge
+	m.metrics.limiter = newLimiter(o.rateLimit)
+	m.metrics.healthEvery = o.healthEvery
+	return m
+}
+
+// Clone returns a MultiFS with the options and mounts of m, which evolve
+// independently from then on. The mounts keep their options but start
+// with fresh usage, errors and caches; name indexes and watches are not
+// carried over.
+func (m *MultiFS) Clone() *MultiFS {
+	c := newMultiFS(m.opts)
+	t, ct := m.tab.Load(), c.tab.Load()
+	for id, mnt := range t.roots.all {
+		ct.roots = ct.roots.with(id, mnt.clone(&c.metrics))
+		if c.opts.fold {
+			ct.folded = ct.folded.with(strings.ToLower(id), id)
+		}
+	}
+	if t.fallback != nil {
+		ct.fallback = t.fallback.clone(&c.metrics)
+	}
+	return c
+}
+
+func (m *MultiFS) Mount(id string, f fs.FS, opts ...MountOption) error {
 	id = strings.Trim(id, "/")
 	if id == "" || strings.Contains(id, "/") {
 		return errors.New("multifs: ids must be non-empty single path components")
@@ -31,157 +81,771 @@ func (m *MultiFS) Mount(id string, f fs.FS) error {
 	}
 
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	defer m.unlock()
 
-	m.roots[id] = f
+	t := m.current()
+	if m.opts.fold {
+		if other, ok := t.folded.get(strings.ToLower(id)); ok && other != id {
+			return fmt.Errorf("multifs: id %q collides with %q: %w", id, other, fs.ErrExist)
+		}
+	}
+	if _, ok := t.roots.get(id); ok {
+		switch m.collisionPolicy(opts) {
+		case CollisionKeep:
+			return nil
+		case CollisionError:
+			return fmt.Errorf("multifs: id %q is already mounted: %w", id, fs.ErrExist)
+		}
+	}
+
+	m.attach(id, f, opts)
 	return nil
 }
 
-func (m *MultiFS) Unmount(id string) error {
+// Replace atomically swaps the filesystem mounted under id, so that
+// concurrent readers never see the id missing. Files already opened keep
+// reading from the previous filesystem; opens that follow use the new one.
+func (m *MultiFS) Replace(id string, f fs.FS, opts ...MountOption) error {
+	if f == nil {
+		return errors.New("multifs: fs is nil")
+	}
+
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	defer m.unlock()
 
-	if _, ok := m.roots[id]; !ok {
+	id, _, ok := m.current().lookup(id)
+	if !ok {
 		return fs.ErrNotExist
 	}
-	delete(m.roots, id)
+	m.attach(id, f, opts)
 	return nil
 }
 
-func (m *MultiFS) getRoot(id string) (fs.FS, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	f, ok := m.roots[id]
-	return f, ok
+// MountSub mounts the subtree dir of f under id. It uses the Sub method of
+// f when f implements fs.SubFS, and otherwise has the mount prefix the
+// paths it passes to f rather than wrapping f.
+func (m *MultiFS) MountSub(id string, f fs.FS, dir string, opts ...MountOption) error {
+	if !fs.ValidPath(dir) {
+		return &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return m.Mount(id, f, opts...)
+	}
+	if sfs, ok := f.(fs.SubFS); ok {
+		sub, err := sfs.Sub(dir)
+		if err != nil {
+			return err
+		}
+		return m.Mount(id, sub, opts...)
+	}
+	return m.Mount(id, f, append(slices.Clip(opts), func(o *mountOptions) { o.prefix = dir })...)
 }
 
-func (m *MultiFS) idsSnapshot() []string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	names := make([]string, 0, len(m.roots))
-	for k := range m.roots {
-		names = append(names, k)
+// attach mounts f under id, replacing whatever was there. m.mu must be held
+// for writing.
+func (m *MultiFS) attach(id string, f fs.FS, opts []MountOption) {
+	t := m.writable()
+	if old, ok := t.roots.get(id); ok {
+		m.retire(old)
+		m.logTable("replaced", id)
+	} else {
+		m.logTable("mounted", id)
+	}
+	mnt := newMount(id, f, opts, &m.metrics)
+	t.roots = t.roots.with(id, mnt)
+	if m.opts.fold {
+		t.folded = t.folded.with(strings.ToLower(id), id)
+	}
+	if m.opts.verify != nil {
+		go m.verifyInBackground(mnt, *m.opts.verify)
+	}
+	for _, x := range m.indexes {
+		x.attached(mnt)
+	}
+	for _, w := range m.watchers {
+		w.attached(mnt)
 	}
-	return names
 }
 
-func (m *MultiFS) split(name string) (id, subpath string, err error) {
-	name = path.Clean(name)
-	name = strings.TrimPrefix(name, "./")
+// MountRoot mounts f underneath the synthetic root: paths whose first
+// element is not a mounted id are resolved against it, and its top-level
+// entries are listed next to the mount ids. Mount ids shadow entries of
+// the same name.
+func (m *MultiFS) MountRoot(f fs.FS, opts ...MountOption) error {
+	if f == nil {
+		return errors.New("multifs: fs is nil")
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
 
-	if name == "" || name == "." {
-		return "", ".", nil
+	t := m.writable()
+	if t.fallback != nil {
+		m.retire(t.fallback)
 	}
-	if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
-		return "", "", fs.ErrNotExist
+	t.fallback = newMount("", f, opts, &m.metrics)
+	m.logTable("root mounted", "")
+	return nil
+}
+
+func (m *MultiFS) UnmountRoot() error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.current().fallback == nil {
+		return fs.ErrNotExist
 	}
+	t := m.writable()
+	m.retire(t.fallback)
+	t.fallback = nil
+	m.logTable("root unmounted", "")
+	return nil
+}
 
-	parts := strings.SplitN(name, "/", 2)
-	id = parts[0]
+// Unmount removes the mount under id. It fails with ErrMountBusy while
+// sessions are open on it; files still open keep reading from it. See
+// UnmountContext for stricter behaviors.
+func (m *MultiFS) Unmount(id string) error {
+	m.mu.Lock()
+	defer m.unlock()
 
-	_, ok := m.getRoot(id)
+	id, mnt, ok := m.current().lookup(id)
 	if !ok {
-		return "", "", fs.ErrNotExist
+		return fs.ErrNotExist
+	}
+	if mnt.sessions.Load() > 0 {
+		return ErrMountBusy
 	}
+	m.detach(id, mnt)
+	return nil
+}
+
+// Close unmounts everything, the root mount included, and closes the
+// mounted filesystems implementing io.Closer, returning their errors
+// joined. Filesystems shared with a clone of m are closed too, except
+// those opened by MountDir and MountURL, which are closed with their last
+// mount or View.
+func (m *MultiFS) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var mounts []*mount
+	t := m.tab.Load()
+	for _, id := range t.ids() {
+		mnt, _ := t.roots.get(id)
+		mounts = append(mounts, mnt)
+		m.detach(id, mnt)
+	}
+	if fallback := t.fallback; fallback != nil {
+		mounts = append(mounts, fallback)
+		m.writable().fallback = nil
+		m.retire(fallback)
+		m.logTable("root unmounted", "")
+	}
+	m.publish()
+
+	var errs []error
+	closed := make(map[any]bool)
+	for _, mnt := range mounts {
+		c, ok := mnt.backend.(io.Closer)
+		if !ok || mnt.opts.owned != nil {
+			// Owned filesystems were closed by unmounting them
+			continue
+		}
+		// The same filesystem may be mounted more than once
+		if reflect.TypeOf(c).Comparable() {
+			if closed[c] {
+				continue
+			}
+			closed[c] = true
+		}
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("multifs: closing %q: %w", mnt.id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// detach removes mnt, mounted under id, from the table. m.mu must be held
+// for writing.
+func (m *MultiFS) detach(id string, mnt *mount) {
+	t := m.writable()
+	t.roots = t.roots.without(id)
+	if m.opts.fold {
+		t.folded = t.folded.without(strings.ToLower(id))
+	}
+	m.retire(mnt)
+	m.logTable("unmounted", id)
+	for _, x := range m.indexes {
+		x.detached(id)
+	}
+	for _, w := range m.watchers {
+		w.detached(id)
+	}
+}
+
+type MountInfo struct {
+	ID string
+	FS fs.FS
+
+	// LastError is the most recent failure reported by the filesystem, or
+	// nil if it never failed.
+	LastError *MountError
+	// Health is the outcome of the last health check, nil if the mount
+	// was never checked.
+	Health *Health
+	// Replicas holds the health of the filesystem and its replicas, as of
+	// their last use, for mounts with replicas.
+	Replicas []*Health
+}
+
+// Mounts returns the mounted filesystems in root listing order.
+func (m *MultiFS) Mounts() []MountInfo {
+	t := m.tab.Load()
+	ids := t.ids()
+	infos := make([]MountInfo, 0, len(ids))
+	for _, id := range ids {
+		mnt, _ := t.roots.get(id)
+		infos = append(infos, mnt.info(id))
+	}
+	return infos
+}
+
+func (mnt *mount) info(id string) MountInfo {
+	info := MountInfo{
+		ID:        id,
+		FS:        mnt.fsys,
+		LastError: mnt.lastErr.Load(),
+		Health:    mnt.health.Load(),
+	}
+	if mnt.replicas != nil {
+		info.Replicas = mnt.replicas.health()
+	}
+	return info
+}
+
+// All yields the mount ids in root listing order along with their
+// filesystems, with the mount options applied. It runs over the mount table
+// as it is when the iteration starts, so calls to Mount and Unmount made
+// meanwhile do not affect it. The root mount is not included.
+func (m *MultiFS) All() iter.Seq2[string, fs.FS] {
+	return func(yield func(string, fs.FS) bool) {
+		v := m.Stable()
+		defer v.Release()
+		v.tab.all(yield)
+	}
+}
+
+// writable returns the table that the mutation under way may modify, a
+// copy of the published one until unlock publishes it, and bumps the
+// generation. m.mu must be held for writing.
+func (m *MultiFS) writable() *table {
+	if m.next == nil {
+		m.next = m.tab.Load().clone()
+	}
+	m.next.gen++
+	m.next.listing.Store(nil)
+	return m.next
+}
+
+// current returns the table as modified so far by the mutation under way.
+// m.mu must be held for writing.
+func (m *MultiFS) current() *table {
+	if m.next != nil {
+		return m.next
+	}
+	return m.tab.Load()
+}
+
+// retire stops mnt, removed from the table, once the change is published:
+// until then, readers may still resolve paths to it. m.mu must be held for
+// writing.
+func (m *MultiFS) retire(mnt *mount) {
+	m.retired = append(m.retired, mnt)
+}
+
+// publish makes the changes to the table visible to readers, then stops
+// the mounts they removed. m.mu must be held for writing.
+func (m *MultiFS) publish() {
+	if m.next != nil {
+		m.tab.Store(m.next)
+		m.next = nil
+	}
+	for _, mnt := range m.retired {
+		mnt.stop()
+	}
+	m.retired = nil
+}
+
+// unlock publishes the changes to the table and releases m.mu.
+func (m *MultiFS) unlock() {
+	m.publish()
+	m.mu.Unlock()
+}
+
+// Generation returns a counter incremented by every change to the mount
+// table, for caches of the namespace to tell when they are stale.
+func (m *MultiFS) Generation() uint64 {
+	return m.tab.Load().gen
+}
+
+func (m *MultiFS) resolve(op, name string) (resolved, error) {
+	return m.tab.Load().resolve(op, name)
+}
+
+func (m *MultiFS) Open(name string) (fs.File, error) {
+	return m.OpenContext(context.Background(), name)
+}
+
+// Stable returns a read-only view of the mount table as it is now. Later
+// calls to Mount and Unmount do not affect the view, so a caller serving a
+// single request sees a consistent namespace throughout. The filesystems
+// opened by MountDir and MountURL stay open as long as a view mounts them,
+// until it is released or garbage collected.
+func (m *MultiFS) Stable() *View {
+	for {
+		v := &View{tab: m.tab.Load()}
+		owned := v.tab.list().owned
+		if len(owned) == 0 {
+			return v
+		}
+		v.held = &heldFS{owned: make([]*ownedFS, 0, len(owned))}
+		for _, o := range owned {
+			if !o.acquire() {
+				break
+			}
+			v.held.owned = append(v.held.owned, o)
+		}
+		if len(v.held.owned) == len(owned) {
+			runtime.AddCleanup(v, (*heldFS).release, v.held)
+			return v
+		}
+		// A filesystem was closed by unmounting it, which a newer table
+		// already reflects
+		v.held.release()
+	}
+}
+
+// Freeze returns the mount table as it is now as an immutable filesystem.
+// It is the View returned by Stable, for callers that only need an fs.FS.
+func (m *MultiFS) Freeze() fs.FS {
+	return m.Stable()
+}
+
+type View struct {
+	tab  *table
+	held *heldFS
+}
+
+// heldFS holds the owned filesystems of a View, until released.
+type heldFS struct {
+	owned    []*ownedFS
+	released atomic.Bool
+}
+
+func (h *heldFS) release() {
+	if h.released.CompareAndSwap(false, true) {
+		for _, o := range h.owned {
+			o.release()
+		}
+	}
+}
+
+var _ fs.StatFS = (*View)(nil)
+var _ fs.ReadDirFS = (*View)(nil)
+
+func (v *View) Generation() uint64 { return v.tab.gen }
 
-	if len(parts) == 1 {
+// Release lets the filesystems opened by MountDir and MountURL and since
+// unmounted be closed without waiting for the view to be garbage
+// collected. The view must not be used afterwards.
+func (v *View) Release() {
+	if v.held != nil {
+		v.held.release()
+	}
+}
+
+// All yields the mounts of the view, as MultiFS.All does.
+func (v *View) All() iter.Seq2[string, fs.FS] { return v.tab.all }
+
+func (v *View) Open(name string) (fs.File, error) {
+	return v.OpenContext(context.Background(), name)
+}
+
+func (v *View) Stat(name string) (fs.FileInfo, error) {
+	return v.StatContext(context.Background(), name)
+}
+
+func (v *View) ReadDir(name string) ([]fs.DirEntry, error) {
+	return v.ReadDirContext(context.Background(), name)
+}
+
+// table is a generation of the mount table. Once published it is never
+// modified again; the next mutation works on a copy instead, which shares
+// the maps of mounts with it.
+type table struct {
+	gen      uint64
+	opts     *options
+	roots    pmap[*mount]
+	folded   pmap[string] // lower-cased ids, with WithCaseInsensitive
+	fallback *mount
+	listing  atomic.Pointer[listing]
+}
+
+func newTable(opts *options) *table {
+	return &table{opts: opts}
+}
+
+func (t *table) clone() *table {
+	return &table{
+		gen:      t.gen,
+		opts:     t.opts,
+		roots:    t.roots,
+		folded:   t.folded,
+		fallback: t.fallback,
+	}
+}
+
+// listing is the root directory content for a generation of the table.
+// Entries share one backing array so that listing the root does not
+// allocate per mount. owned lists the filesystems MultiFS opened for the
+// mounts of the generation, the root mount included.
+type listing struct {
+	ids     []string
+	entries []dirEntry
+	owned   []*ownedFS
+}
+
+// list returns the mount ids in root listing order. The result is cached
+// until the next mutation and must not be modified.
+func (t *table) list() *listing {
+	if l := t.listing.Load(); l != nil {
+		return l
+	}
+	ids := make([]string, 0, t.roots.len())
+	for k := range t.roots.all {
+		ids = append(ids, k)
+	}
+	slices.SortFunc(ids, t.opts.compare)
+
+	l := &listing{ids: ids, entries: make([]dirEntry, len(ids))}
+	for i, id := range ids {
+		mnt, _ := t.roots.get(id)
+		l.entries[i] = dirEntry{name: id, info: &t.opts.dir}
+		if _, leaf := mnt.leaf(); leaf || !t.opts.synthetic {
+			l.entries[i].mnt = mnt
+		}
+		if o := mnt.opts.owned; o != nil {
+			l.owned = append(l.owned, o)
+		}
+	}
+	if t.fallback != nil && t.fallback.opts.owned != nil {
+		l.owned = append(l.owned, t.fallback.opts.owned)
+	}
+	t.listing.Store(l)
+	return l
+}
+
+func (t *table) ids() []string { return t.list().ids }
+
+func (t *table) all(yield func(string, fs.FS) bool) {
+	for _, id := range t.ids() {
+		mnt, _ := t.roots.get(id)
+		if !yield(id, mnt) {
+			return
+		}
+	}
+}
+
+// lookup finds the mount for id, honoring case folding when enabled, and
+// returns it along with the id it was mounted under.
+func (t *table) lookup(id string) (string, *mount, bool) {
+	if mnt, ok := t.roots.get(id); ok {
+		return id, mnt, true
+	}
+	if t.opts.fold {
+		if id, ok := t.folded.get(strings.ToLower(id)); ok {
+			mnt, _ := t.roots.get(id)
+			return id, mnt, true
+		}
+	}
+	return "", nil, false
+}
+
+// mount returns the mount for id.
+func (m *MultiFS) mount(id string) (*mount, bool) {
+	_, mnt, ok := m.tab.Load().lookup(id)
+	return mnt, ok
+}
+
+// split separates the first element of name, the mount id, from the path
+// inside the mount. The synthetic root has an empty id. Names must be valid
+// in the sense of fs.ValidPath.
+func split(name string) (id, subpath string, err error) {
+	if !fs.ValidPath(name) {
+		return "", "", fs.ErrInvalid
+	}
+	if name == "." {
+		return "", ".", nil
+	}
+
+	id, subpath, ok := strings.Cut(name, "/")
+	if !ok {
 		subpath = "."
-	} else {
-		subpath = parts[1]
 	}
 	return id, subpath, nil
 }
 
-func (m *MultiFS) Open(name string) (fs.File, error) {
-	id, subpath, err := m.split(name)
+// resolved is the outcome of a lookup in the mount table. A nil mnt
+// designates the synthetic root, listing ids.
+type resolved struct {
+	id       string
+	subpath  string
+	mnt      *mount
+	list     *listing
+	fallback *mount
+	opts     *options
+}
+
+func (t *table) resolve(op, name string) (resolved, error) {
+	first, subpath, err := split(name)
 	if err != nil {
-		return nil, err
+		return resolved{}, &fs.PathError{Op: op, Path: name, Err: err}
 	}
-	if id == "" {
-		return newRootDir(m.idsSnapshot()), nil
+	if first == "" {
+		return resolved{subpath: ".", list: t.list(), fallback: t.fallback, opts: t.opts}, nil
 	}
 
-	subfs, ok := m.getRoot(id)
+	id, mnt, ok := t.lookup(first)
 	if !ok {
-		return nil, fs.ErrNotExist
+		if t.fallback == nil {
+			return resolved{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		// Unknown ids fall through to the root mount, which sees the
+		// whole path.
+		return resolved{subpath: path.Join(first, subpath), mnt: t.fallback, opts: t.opts}, nil
+	}
+	if subpath != "." {
+		if inner, ok := t.flattens(mnt); ok {
+			return resolveNested(inner, op, name, subpath)
+		}
+	}
+	return resolved{id: id, subpath: subpath, mnt: mnt, opts: t.opts}, nil
+}
+
+func (r resolved) root(ctx context.Context) *rootDir {
+	return newRootDir(ctx, r.list, r.fallback, r.opts)
+}
+
+func (r resolved) open(ctx context.Context) (fs.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	return subfs.Open(subpath)
+	if r.mnt == nil {
+		return r.root(ctx), nil
+	}
+	ctx, t := r.mnt.begin(ctx, "open", r.subpath)
+	f, err := r.mnt.open(ctx, r.subpath)
+	t.end(0, err)
+	if err != nil {
+		return nil, err
+	}
+	r.audit(ctx, "open")
+	if r.id != "" && r.subpath == "." {
+		f = namedRoot(f, r.id)
+	}
+	if r.opts.seekable {
+		f = seekable(f)
+	}
+	return f, nil
+}
+
+func (r resolved) readDir(ctx context.Context) ([]fs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.mnt == nil {
+		return r.root(ctx).ReadDir(-1)
+	}
+	ctx, t := r.mnt.begin(ctx, "readdir", r.subpath)
+	entries, err := r.mnt.readDir(ctx, r.subpath)
+	t.end(0, err)
+	return entries, err
 }
 
+func (r resolved) stat(ctx context.Context) (fs.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.mnt == nil {
+		return r.opts.dir, nil
+	}
+	ctx, t := r.mnt.begin(ctx, "stat", r.subpath)
+	info, err := r.mnt.stat(ctx, r.subpath)
+	t.end(0, err)
+	if err != nil {
+		return nil, err
+	}
+	if r.id != "" && r.subpath == "." {
+		// The mount root is known by its id here, whatever name the
+		// underlying filesystem gives to its own root.
+		info = renamedInfo{FileInfo: info, name: r.id}
+	}
+	return info, nil
+}
+
+// rootDir lists the synthetic root: the mount ids of a generation of the
+// table, merged with the entries of the root mount if any. Mount ids are
+// read straight from the generation's listing, so that opening and paging
+// through the root does not copy it.
 type rootDir struct {
-	names []string
-	pos   int
+	ctx      context.Context
+	list     *listing
+	fallback *mount
+	info     *dirInfo
+	compare  func(a, b string) int
+	pos      int
+
+	// under holds the entries of the root mount not shadowed by a mount
+	// id, sorted, once loaded.
+	under  []fs.DirEntry
+	loaded bool
+	upos   int
 }
 
-func newRootDir(names []string) *rootDir {
-	return &rootDir{names: names}
+func newRootDir(ctx context.Context, list *listing, fallback *mount, opts *options) *rootDir {
+	return &rootDir{
+		ctx:      ctx,
+		list:     list,
+		fallback: fallback,
+		info:     &opts.dir,
+		compare:  opts.compare,
+	}
 }
 
 var _ fs.File = (*rootDir)(nil)
 var _ fs.ReadDirFile = (*rootDir)(nil)
 
-func (d *rootDir) Stat() (fs.FileInfo, error) { return dirInfo{name: "."}, nil }
+func (d *rootDir) Stat() (fs.FileInfo, error) { return *d.info, nil }
 func (d *rootDir) Read([]byte) (int, error)   { return 0, io.EOF }
 func (d *rootDir) Close() error               { return nil }
 
 func (d *rootDir) ReadDir(n int) ([]fs.DirEntry, error) {
-	if d.pos >= len(d.names) && n > 0 {
+	if err := d.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if d.fallback != nil && !d.loaded {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := d.list.ids
+	remaining := len(ids) - d.pos + len(d.under) - d.upos
+	if remaining == 0 && n > 0 {
 		return nil, io.EOF
 	}
-	if n <= 0 || n > len(d.names)-d.pos {
-		n = len(d.names) - d.pos
+	if n <= 0 || n > remaining {
+		n = remaining
 	}
 
+	// Both sides are sorted: merge them as they are consumed
 	entries := make([]fs.DirEntry, 0, n)
-	for ; n > 0 && d.pos < len(d.names); n-- {
-		entries = append(entries, dirEntry{name: d.names[d.pos]})
-		d.pos++
+	for len(entries) < n {
+		if d.pos < len(ids) && (d.upos == len(d.under) || d.compare(ids[d.pos], d.under[d.upos].Name()) <= 0) {
+			entries = append(entries, &d.list.entries[d.pos])
+			d.pos++
+		} else {
+			entries = append(entries, d.under[d.upos])
+			d.upos++
+		}
 	}
 	return entries, nil
 }
 
+// load lists the root mount, dropping the entries shadowed by mount ids.
+func (d *rootDir) load() error {
+	under, err := d.fallback.readDir(d.ctx, ".")
+	if err != nil {
+		return err
+	}
+	d.under = slices.DeleteFunc(under, func(e fs.DirEntry) bool {
+		i, found := slices.BinarySearchFunc(d.list.ids, e.Name(), d.compare)
+		return found && d.list.ids[i] == e.Name()
+	})
+	slices.SortFunc(d.under, func(a, b fs.DirEntry) int {
+		return d.compare(a.Name(), b.Name())
+	})
+	d.loaded = true
+	return nil
+}
+
 type dirInfo struct {
-	name string
+	name    string
+	perm    fs.FileMode
+	modTime time.Time
+	sys     any
 }
 
 func (i dirInfo) Name() string       { return i.name }
 func (i dirInfo) Size() int64        { return 0 }
-func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
-func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | i.perm }
+func (i dirInfo) ModTime() time.Time { return i.modTime }
 func (i dirInfo) IsDir() bool        { return true }
-func (i dirInfo) Sys() any           { return nil }
+func (i dirInfo) Sys() any           { return i.sys }
 
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string { return i.name }
+
+// dirEntry is a mount id listed by the synthetic root. Its info is that
+// of the root of the mount, or shares the attributes of the synthetic
+// root when mnt is nil. Mounts made by MountFile are listed as files.
 type dirEntry struct {
 	name string
+	info *dirInfo
+	mnt  *mount
+}
+
+func (e dirEntry) Name() string { return e.name }
+func (e dirEntry) IsDir() bool  { return e.Type().IsDir() }
+
+func (e dirEntry) Type() fs.FileMode {
+	if e.mnt != nil {
+		if l, ok := e.mnt.leaf(); ok {
+			return l.info.mode.Type()
+		}
+	}
+	return fs.ModeDir
 }
 
-func (e dirEntry) Name() string               { return e.name }
-func (e dirEntry) IsDir() bool                { return true }
-func (e dirEntry) Type() fs.FileMode          { return fs.ModeDir }
-func (e dirEntry) Info() (fs.FileInfo, error) { return dirInfo{name: e.name}, nil }
+func (e dirEntry) Info() (fs.FileInfo, error) {
+	info := *e.info
+	info.name = e.name
+	if e.mnt == nil {
+		return info, nil
+	}
+	if root, err := e.mnt.stat(context.Background(), "."); err == nil {
+		return renamedInfo{FileInfo: root, name: e.name}, nil
+	}
+	if l, ok := e.mnt.leaf(); ok {
+		return l.info, nil
+	}
+	// The root of the mount cannot be stat'ed: report when it was mounted
+	info.modTime = e.mnt.mounted
+	return info, nil
+}
 
 var _ fs.StatFS = (*MultiFS)(nil)
 var _ fs.ReadDirFS = (*MultiFS)(nil)
 
 func (m *MultiFS) Stat(name string) (fs.FileInfo, error) {
-	f, err := m.Open(name)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	return f.Stat()
+	return m.StatContext(context.Background(), name)
 }
 
 func (m *MultiFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	f, err := m.Open(name)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	dir, ok := f.(fs.ReadDirFile)
-	if !ok {
-		return nil, errors.New("not a directory")
-	}
-	return dir.ReadDir(-1)
+	return m.ReadDirContext(context.Background(), name)
 }
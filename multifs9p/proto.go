@@ -0,0 +1,193 @@
+package multifs9p
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Message types of 9P2000.L; each reply is its request's type plus one.
+const (
+	tlerror      = 6
+	tstatfs      = 8
+	tlopen       = 12
+	tlcreate     = 14
+	tsymlink     = 16
+	tmknod       = 18
+	trename      = 20
+	treadlink    = 22
+	tgetattr     = 24
+	tsetattr     = 26
+	txattrwalk   = 30
+	txattrcreate = 32
+	treaddir     = 40
+	tfsync       = 50
+	tlock        = 52
+	tgetlock     = 54
+	tlink        = 70
+	tmkdir       = 72
+	trenameat    = 74
+	tunlinkat    = 76
+	tversion     = 100
+	tauth        = 102
+	tattach      = 104
+	tflush       = 108
+	twalk        = 110
+	tread        = 116
+	twrite       = 118
+	tclunk       = 120
+	tremove      = 122
+)
+
+const (
+	noFid = 0xffffffff
+
+	// headerSize is the size of a message header: size[4] type[1] tag[2].
+	headerSize = 7
+	// ioHeaderSize is the largest header of a message carrying data,
+	// the difference between msize and the iounit of open files.
+	ioHeaderSize = 24
+)
+
+// Linux error numbers, which 9P2000.L uses whatever the server runs on.
+const (
+	enoent     = 2
+	eintr      = 4
+	eio        = 5
+	ebadf      = 9
+	eacces     = 13
+	eexist     = 17
+	enotdir    = 20
+	eisdir     = 21
+	einval     = 22
+	erofs      = 30
+	enosys     = 38
+	enodata    = 61
+	eopnotsupp = 95
+)
+
+// Qid types.
+const (
+	qtDir     = 0x80
+	qtSymlink = 0x02
+	qtFile    = 0x00
+)
+
+type qid struct {
+	typ     uint8
+	version uint32
+	path    uint64
+}
+
+var errMalformed = errors.New("malformed 9P message")
+
+// decoder reads the fields of a message body, recording the first error
+// so that callers only check once at the end.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = errMalformed
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) u64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) str() string {
+	return string(d.next(int(d.u16())))
+}
+
+func (d *decoder) qid() qid {
+	return qid{typ: d.u8(), version: d.u32(), path: d.u64()}
+}
+
+// encoder builds a message, leaving room for its header.
+type encoder struct {
+	buf []byte
+}
+
+func newEncoder(typ uint8, tag uint16) *encoder {
+	e := &encoder{buf: make([]byte, 4, 64)}
+	e.u8(typ)
+	e.u16(tag)
+	return e
+}
+
+func (e *encoder) u8(v uint8)   { e.buf = append(e.buf, v) }
+func (e *encoder) u16(v uint16) { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *encoder) u32(v uint32) { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *encoder) u64(v uint64) { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+
+func (e *encoder) str(s string) {
+	e.u16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) qid(q qid) {
+	e.u8(q.typ)
+	e.u32(q.version)
+	e.u64(q.path)
+}
+
+// bytes returns the message with its size filled in.
+func (e *encoder) bytes() []byte {
+	binary.LittleEndian.PutUint32(e.buf, uint32(len(e.buf)))
+	return e.buf
+}
+
+// readMsg reads a message no larger than msize and returns its type, tag
+// and body.
+func readMsg(r io.Reader, msize uint32) (uint8, uint16, []byte, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[:4])
+	if size < headerSize || size > msize {
+		return 0, 0, nil, errMalformed
+	}
+	body := make([]byte, size-headerSize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 0, nil, err
+	}
+	return hdr[4], binary.LittleEndian.Uint16(hdr[5:]), body, nil
+}
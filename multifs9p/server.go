@@ -0,0 +1,764 @@
+// Package multifs9p serves a MultiFS over 9P2000.L, so that it can be
+// mounted natively by Linux's v9fs, from WSL or through QEMU's virtfs,
+// where FUSE is not available. The export is read-only.
+package multifs9p
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net"
+	"path"
+	"strings"
+	"sync"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+// maxMsize bounds the message size negotiated with clients.
+const maxMsize = 1 << 20
+
+// Serve accepts connections on l and serves mux on each of them, until
+// Accept fails.
+func Serve(l net.Listener, mux *multifs.MultiFS) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go ServeConn(c, mux)
+	}
+}
+
+// ServeConn serves mux on a single connection, such as a virtio channel,
+// until the client goes away. The connection is closed on return.
+//
+// Clients can attach to a subtree by naming it as the attach name, as in
+// mount -t 9p -o aname=/snap.
+func ServeConn(rwc io.ReadWriteCloser, mux *multifs.MultiFS) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &conn{
+		mux:     mux,
+		rwc:     rwc,
+		ctx:     ctx,
+		msize:   maxMsize,
+		fids:    make(map[uint32]*fid),
+		pending: make(map[uint16]*request),
+	}
+	defer func() {
+		cancel()
+		c.wg.Wait()
+		c.clunkAll()
+		rwc.Close()
+	}()
+	return c.serve()
+}
+
+type conn struct {
+	mux *multifs.MultiFS
+	rwc io.ReadWriteCloser
+	// ctx lives as long as the connection and bounds open files, which
+	// outlive the request opening them.
+	ctx   context.Context
+	msize uint32
+
+	wmu sync.Mutex
+	wg  sync.WaitGroup
+
+	mu      sync.Mutex
+	fids    map[uint32]*fid
+	pending map[uint16]*request
+}
+
+// request is a request being served, which Tflush can cancel and wait for.
+type request struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// fid is a path the client walked to, which it may then open.
+type fid struct {
+	// root is the attach point, which ".." does not go above.
+	root string
+	name string
+
+	mu      sync.Mutex
+	f       fs.File
+	dir     bool
+	pos     int64
+	entries []fs.DirEntry
+
+	// xattr holds the attribute, or the list of attribute names, a
+	// Txattrwalk read; such fids are read as if opened.
+	xattr     []byte
+	xattrWalk bool
+}
+
+func (c *conn) serve() error {
+	for {
+		typ, tag, body, err := readMsg(c.rwc, c.msize)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		// Tversion resets the session, once every request is answered
+		if typ == tversion {
+			c.wg.Wait()
+			c.write(c.version(tag, body))
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(c.ctx)
+		req := &request{cancel: cancel, done: make(chan struct{})}
+		c.mu.Lock()
+		c.pending[tag] = req
+		c.mu.Unlock()
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.write(c.handle(ctx, typ, tag, &decoder{buf: body}))
+			c.mu.Lock()
+			if c.pending[tag] == req {
+				delete(c.pending, tag)
+			}
+			c.mu.Unlock()
+			cancel()
+			close(req.done)
+		}()
+	}
+}
+
+func (c *conn) write(e *encoder) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.rwc.Write(e.bytes())
+}
+
+func (c *conn) handle(ctx context.Context, typ uint8, tag uint16, d *decoder) *encoder {
+	switch typ {
+	case tattach:
+		return c.attach(tag, d)
+	case tauth:
+		return rlerror(tag, enosys)
+	case tflush:
+		return c.flush(tag, d)
+	case twalk:
+		return c.walk(tag, d)
+	case tlopen:
+		return c.lopen(tag, d)
+	case tread:
+		return c.read(tag, d)
+	case treaddir:
+		return c.readdir(ctx, tag, d)
+	case tgetattr:
+		return c.getattr(tag, d)
+	case treadlink:
+		return c.readlink(ctx, tag, d)
+	case tstatfs:
+		return c.statfs(tag, d)
+	case tclunk:
+		return c.clunk(tag, d)
+	case tfsync:
+		return newEncoder(tfsync+1, tag)
+	case txattrwalk:
+		return c.xattrwalk(tag, d)
+	case tremove:
+		// Tremove clunks the fid even when it fails
+		c.clunk(tag, d)
+		return rlerror(tag, erofs)
+	case tlcreate, tsymlink, tmknod, trename, tsetattr, txattrcreate,
+		tlink, tmkdir, trenameat, tunlinkat, twrite:
+		return rlerror(tag, erofs)
+	}
+	return rlerror(tag, enosys)
+}
+
+func (c *conn) version(tag uint16, body []byte) *encoder {
+	d := &decoder{buf: body}
+	msize, version := d.u32(), d.str()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	c.clunkAll()
+
+	if msize > maxMsize {
+		msize = maxMsize
+	}
+	if msize < 4096 || !strings.HasPrefix(version, "9P2000.L") {
+		version = "unknown"
+	} else {
+		c.msize = msize
+		version = "9P2000.L"
+	}
+	e := newEncoder(tversion+1, tag)
+	e.u32(c.msize)
+	e.str(version)
+	return e
+}
+
+func (c *conn) attach(tag uint16, d *decoder) *encoder {
+	fidn, _, _, aname := d.u32(), d.u32(), d.str(), d.str()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	root := fsName(aname)
+	info, err := c.mux.Lstat(root)
+	if err != nil {
+		return rerror(tag, err)
+	}
+	if !info.IsDir() {
+		return rlerror(tag, enotdir)
+	}
+	if !c.addFid(fidn, &fid{root: root, name: root}) {
+		return rlerror(tag, ebadf)
+	}
+	e := newEncoder(tattach+1, tag)
+	e.qid(qidOf(root, info.Mode()))
+	return e
+}
+
+func (c *conn) flush(tag uint16, d *decoder) *encoder {
+	oldtag := d.u16()
+	c.mu.Lock()
+	req := c.pending[oldtag]
+	c.mu.Unlock()
+	if req != nil && oldtag != tag {
+		req.cancel()
+		<-req.done
+	}
+	return newEncoder(tflush+1, tag)
+}
+
+func (c *conn) walk(tag uint16, d *decoder) *encoder {
+	fidn, newfidn, n := d.u32(), d.u32(), d.u16()
+	elems := make([]string, 0, n)
+	for range n {
+		elems = append(elems, d.str())
+	}
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil || f.opened() {
+		return rlerror(tag, ebadf)
+	}
+	if newfidn != fidn && c.fid(newfidn) != nil {
+		return rlerror(tag, ebadf)
+	}
+
+	name := f.name
+	qids := make([]qid, 0, len(elems))
+	for i, elem := range elems {
+		next := name
+		switch {
+		case elem == "" || strings.Contains(elem, "/"):
+			return rlerror(tag, einval)
+		case elem == "..":
+			if name != f.root {
+				next = path.Dir(name)
+			}
+		case elem != ".":
+			next = path.Join(name, elem)
+		}
+		info, err := c.mux.Lstat(next)
+		if err != nil {
+			// A partial walk succeeds, without creating newfid
+			if i == 0 {
+				return rerror(tag, err)
+			}
+			break
+		}
+		qids = append(qids, qidOf(next, info.Mode()))
+		name = next
+	}
+	if len(qids) == len(elems) {
+		c.setFid(newfidn, &fid{root: f.root, name: name})
+	}
+
+	e := newEncoder(twalk+1, tag)
+	e.u16(uint16(len(qids)))
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return e
+}
+
+// Open flags of Tlopen, as on Linux.
+const (
+	oAccMode = 0o3
+	oTrunc   = 0o1000
+)
+
+func (c *conn) lopen(tag uint16, d *decoder) *encoder {
+	fidn, flags := d.u32(), d.u32()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil {
+		return rlerror(tag, ebadf)
+	}
+	if flags&oAccMode != 0 || flags&oTrunc != 0 {
+		return rlerror(tag, erofs)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f != nil || f.xattrWalk {
+		return rlerror(tag, ebadf)
+	}
+	file, err := c.mux.OpenContext(c.ctx, f.name)
+	if err != nil {
+		return rerror(tag, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return rerror(tag, err)
+	}
+	f.f, f.dir = file, info.IsDir()
+
+	e := newEncoder(tlopen+1, tag)
+	e.qid(qidOf(f.name, info.Mode()))
+	e.u32(c.msize - ioHeaderSize)
+	return e
+}
+
+func (c *conn) read(tag uint16, d *decoder) *encoder {
+	fidn, offset, count := d.u32(), d.u64(), d.u32()
+	if d.err != nil || int64(offset) < 0 {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil {
+		return rlerror(tag, ebadf)
+	}
+	if limit := c.msize - headerSize - 4; count > limit {
+		count = limit
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.xattrWalk:
+		return readXattr(tag, f.xattr, offset, count)
+	case f.f == nil:
+		return rlerror(tag, ebadf)
+	case f.dir:
+		return rlerror(tag, eisdir)
+	}
+	buf := make([]byte, count)
+	n, err := f.readAt(buf, int64(offset), func() (fs.File, error) {
+		return c.mux.OpenContext(c.ctx, f.name)
+	})
+	if err != nil && err != io.EOF {
+		return rerror(tag, err)
+	}
+
+	e := newEncoder(tread+1, tag)
+	e.u32(uint32(n))
+	e.buf = append(e.buf, buf[:n]...)
+	return e
+}
+
+func readXattr(tag uint16, data []byte, offset uint64, count uint32) *encoder {
+	data = data[min(offset, uint64(len(data))):]
+	data = data[:min(uint64(count), uint64(len(data)))]
+	e := newEncoder(tread+1, tag)
+	e.u32(uint32(len(data)))
+	e.buf = append(e.buf, data...)
+	return e
+}
+
+// readAt reads at off natively when the file is an io.ReaderAt, by seeking
+// when it can, and otherwise by reading forward, reopening the file to go
+// back. Clients mostly read sequentially, which costs nothing extra.
+func (f *fid) readAt(p []byte, off int64, reopen func() (fs.File, error)) (int, error) {
+	if ra, ok := f.f.(io.ReaderAt); ok {
+		n, err := ra.ReadAt(p, off)
+		if errors.Is(err, fs.ErrInvalid) {
+			// Some implementations reject offsets past the end
+			if info, serr := f.f.Stat(); serr == nil && off >= info.Size() {
+				err = io.EOF
+			}
+		}
+		return n, err
+	}
+
+	if off != f.pos {
+		if s, ok := f.f.(io.Seeker); ok {
+			pos, err := s.Seek(off, io.SeekStart)
+			f.pos = pos
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			if off < f.pos {
+				file, err := reopen()
+				if err != nil {
+					return 0, err
+				}
+				f.f.Close()
+				f.f, f.pos = file, 0
+			}
+			n, err := io.CopyN(io.Discard, f.f, off-f.pos)
+			f.pos += n
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := io.ReadFull(f.f, p)
+	f.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (c *conn) readdir(ctx context.Context, tag uint16, d *decoder) *encoder {
+	fidn, offset, count := d.u32(), d.u64(), d.u32()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil {
+		return rlerror(tag, ebadf)
+	}
+	if limit := c.msize - headerSize - 4; count > limit {
+		count = limit
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.f == nil:
+		return rlerror(tag, ebadf)
+	case !f.dir:
+		return rlerror(tag, enotdir)
+	}
+	// Offsets are positions in a listing taken when the client starts over
+	if offset == 0 || f.entries == nil {
+		entries, err := c.mux.ReadDirContext(ctx, f.name)
+		if err != nil {
+			return rerror(tag, err)
+		}
+		f.entries = entries
+	}
+
+	e := newEncoder(treaddir+1, tag)
+	e.u32(0)
+	start := len(e.buf)
+	// Listings start with "." and "..", as on a local filesystem
+	for i := offset; i < uint64(len(f.entries))+2; i++ {
+		name, elem, mode := f.name, ".", fs.ModeDir
+		switch i {
+		case 0:
+		case 1:
+			elem = ".."
+			if name != f.root {
+				name = path.Dir(name)
+			}
+		default:
+			entry := f.entries[i-2]
+			elem, mode = entry.Name(), entry.Type()
+			name = path.Join(f.name, elem)
+		}
+		if len(e.buf)-start+13+8+1+2+len(elem) > int(count) {
+			break
+		}
+		e.qid(qidOf(name, mode))
+		e.u64(i + 1)
+		e.u8(uint8(unixMode(mode) >> 12))
+		e.str(elem)
+	}
+	binary.LittleEndian.PutUint32(e.buf[start-4:], uint32(len(e.buf)-start))
+	return e
+}
+
+// Attributes returned by Tgetattr: mode, nlink, uid, gid, rdev, atime,
+// mtime, ctime, ino, size and blocks.
+const getattrBasic = 0x7ff
+
+func (c *conn) getattr(tag uint16, d *decoder) *encoder {
+	fidn := d.u32()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil {
+		return rlerror(tag, ebadf)
+	}
+	info, err := c.mux.Lstat(f.name)
+	if err != nil {
+		return rerror(tag, err)
+	}
+
+	mtime := info.ModTime()
+	sec, nsec := uint64(0), uint64(0)
+	if !mtime.IsZero() {
+		sec, nsec = uint64(mtime.Unix()), uint64(mtime.Nanosecond())
+	}
+	size := uint64(0)
+	if info.Mode().IsRegular() || info.Mode()&fs.ModeSymlink != 0 {
+		size = uint64(info.Size())
+	}
+	nlink := uint64(1)
+	if info.IsDir() {
+		nlink = 2
+	}
+
+	e := newEncoder(tgetattr+1, tag)
+	e.u64(getattrBasic)
+	e.qid(qidOf(f.name, info.Mode()))
+	e.u32(unixMode(info.Mode()))
+	e.u32(0) // uid
+	e.u32(0) // gid
+	e.u64(nlink)
+	e.u64(0) // rdev
+	e.u64(size)
+	e.u64(4096)               // blksize
+	e.u64((size + 511) / 512) // blocks
+	for range 3 {
+		// atime, mtime and ctime
+		e.u64(sec)
+		e.u64(nsec)
+	}
+	e.u64(0) // btime
+	e.u64(0)
+	e.u64(0) // gen
+	e.u64(0) // data_version
+	return e
+}
+
+// xattrwalk reads the attribute name, or the NUL-terminated list of
+// attribute names when name is empty, for newfid to be read from.
+func (c *conn) xattrwalk(tag uint16, d *decoder) *encoder {
+	fidn, newfidn, name := d.u32(), d.u32(), d.str()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil || f.opened() {
+		return rlerror(tag, ebadf)
+	}
+	if newfidn != fidn && c.fid(newfidn) != nil {
+		return rlerror(tag, ebadf)
+	}
+
+	var data []byte
+	if name == "" {
+		attrs, err := c.mux.ListXattr(f.name)
+		if err != nil {
+			return rerror(tag, err)
+		}
+		for _, attr := range attrs {
+			data = append(append(data, attr...), 0)
+		}
+	} else {
+		value, err := c.mux.GetXattr(f.name, name)
+		if err != nil {
+			return rerror(tag, err)
+		}
+		data = value
+	}
+	c.setFid(newfidn, &fid{root: f.root, name: f.name, xattr: data, xattrWalk: true})
+
+	e := newEncoder(txattrwalk+1, tag)
+	e.u64(uint64(len(data)))
+	return e
+}
+
+func (c *conn) readlink(ctx context.Context, tag uint16, d *decoder) *encoder {
+	fidn := d.u32()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	f := c.fid(fidn)
+	if f == nil {
+		return rlerror(tag, ebadf)
+	}
+	target, err := c.mux.ReadLinkContext(ctx, f.name)
+	if err != nil {
+		return rerror(tag, err)
+	}
+	e := newEncoder(treadlink+1, tag)
+	e.str(target)
+	return e
+}
+
+// v9fsMagic is the filesystem type Linux reports for 9P mounts.
+const v9fsMagic = 0x01021997
+
+func (c *conn) statfs(tag uint16, d *decoder) *encoder {
+	if f := c.fid(d.u32()); f == nil || d.err != nil {
+		return rlerror(tag, ebadf)
+	}
+	e := newEncoder(tstatfs+1, tag)
+	e.u32(v9fsMagic)
+	e.u32(4096) // bsize
+	for range 6 {
+		// blocks, bfree, bavail, files, ffree and fsid are unknown
+		e.u64(0)
+	}
+	e.u32(255) // namelen
+	return e
+}
+
+func (c *conn) clunk(tag uint16, d *decoder) *encoder {
+	fidn := d.u32()
+	if d.err != nil {
+		return rlerror(tag, einval)
+	}
+	c.mu.Lock()
+	f := c.fids[fidn]
+	delete(c.fids, fidn)
+	c.mu.Unlock()
+	if f == nil {
+		return rlerror(tag, ebadf)
+	}
+	f.close()
+	return newEncoder(tclunk+1, tag)
+}
+
+func (c *conn) clunkAll() {
+	c.mu.Lock()
+	fids := c.fids
+	c.fids = make(map[uint32]*fid)
+	c.mu.Unlock()
+	for _, f := range fids {
+		f.close()
+	}
+}
+
+func (c *conn) fid(n uint32) *fid {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fids[n]
+}
+
+// addFid registers f under n, unless n is in use.
+func (c *conn) addFid(n uint32, f *fid) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.fids[n]; ok || n == noFid {
+		return false
+	}
+	c.fids[n] = f
+	return true
+}
+
+// setFid registers f under n, replacing the fid a walk started from.
+func (c *conn) setFid(n uint32, f *fid) {
+	c.mu.Lock()
+	old := c.fids[n]
+	c.fids[n] = f
+	c.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+}
+
+func (f *fid) opened() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f != nil || f.xattrWalk
+}
+
+func (f *fid) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f != nil {
+		f.f.Close()
+		f.f = nil
+	}
+}
+
+// fsName maps a 9P path, rooted at "/", to a MultiFS path.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// qidOf derives a qid from the MultiFS path, so that it is the same for a
+// given mount id and path across walks, connections and remounts.
+func qidOf(name string, mode fs.FileMode) qid {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	q := qid{typ: qtFile, path: h.Sum64()}
+	switch {
+	case mode.IsDir():
+		q.typ = qtDir
+	case mode&fs.ModeSymlink != 0:
+		q.typ = qtSymlink
+	}
+	return q
+}
+
+// unixMode converts mode to Linux mode bits.
+func unixMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	switch {
+	case mode.IsDir():
+		m |= 0o040000
+	case mode&fs.ModeSymlink != 0:
+		m |= 0o120000
+	case mode&fs.ModeNamedPipe != 0:
+		m |= 0o010000
+	case mode&fs.ModeSocket != 0:
+		m |= 0o140000
+	case mode&fs.ModeCharDevice != 0:
+		m |= 0o020000
+	case mode&fs.ModeDevice != 0:
+		m |= 0o060000
+	default:
+		m |= 0o100000
+	}
+	if mode&fs.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
+
+func rlerror(tag uint16, errno uint32) *encoder {
+	e := newEncoder(tlerror+1, tag)
+	e.u32(errno)
+	return e
+}
+
+// rerror replies with the Linux error number closest to err.
+func rerror(tag uint16, err error) *encoder {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return rlerror(tag, enoent)
+	case errors.Is(err, fs.ErrPermission):
+		return rlerror(tag, eacces)
+	case errors.Is(err, fs.ErrExist):
+		return rlerror(tag, eexist)
+	case errors.Is(err, fs.ErrInvalid):
+		return rlerror(tag, einval)
+	case errors.Is(err, multifs.ErrNoXattr):
+		return rlerror(tag, enodata)
+	case errors.Is(err, errors.ErrUnsupported):
+		return rlerror(tag, eopnotsupp)
+	case errors.Is(err, context.Canceled):
+		return rlerror(tag, eintr)
+	}
+	return rlerror(tag, eio)
+}
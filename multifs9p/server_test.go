@@ -0,0 +1,337 @@
+package multifs9p
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+// client sends one request at a time and returns the body of its reply.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func newClient(t *testing.T, mux *multifs.MultiFS) *client {
+	t.Helper()
+	cc, sc := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- ServeConn(sc, mux) }()
+	t.Cleanup(func() {
+		cc.Close()
+		if err := <-done; err != nil {
+			t.Errorf("ServeConn: %v", err)
+		}
+	})
+
+	c := &client{t: t, conn: cc}
+	d := c.rpc(tversion, func(e *encoder) {
+		e.u32(8192)
+		e.str("9P2000.L")
+	})
+	if msize, version := d.u32(), d.str(); msize != 8192 || version != "9P2000.L" {
+		t.Fatalf("Rversion: %d %q", msize, version)
+	}
+	return c
+}
+
+// call returns the reply to a request, or the error number of Rlerror.
+func (c *client) call(typ uint8, build func(e *encoder)) (*decoder, uint32) {
+	c.t.Helper()
+	e := newEncoder(typ, 1)
+	build(e)
+	if _, err := c.conn.Write(e.bytes()); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+	rtyp, tag, body, err := readMsg(c.conn, maxMsize)
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	d := &decoder{buf: body}
+	if rtyp == tlerror+1 {
+		return nil, d.u32()
+	}
+	if rtyp != typ+1 || tag != 1 {
+		c.t.Fatalf("reply to %d: type %d tag %d", typ, rtyp, tag)
+	}
+	return d, 0
+}
+
+func (c *client) rpc(typ uint8, build func(e *encoder)) *decoder {
+	c.t.Helper()
+	d, errno := c.call(typ, build)
+	if errno != 0 {
+		c.t.Fatalf("request %d: errno %d", typ, errno)
+	}
+	return d
+}
+
+func (c *client) walk(fid, newfid uint32, elems ...string) (int, uint32) {
+	c.t.Helper()
+	d, errno := c.call(twalk, func(e *encoder) {
+		e.u32(fid)
+		e.u32(newfid)
+		e.u16(uint16(len(elems)))
+		for _, elem := range elems {
+			e.str(elem)
+		}
+	})
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(d.u16()), 0
+}
+
+func (c *client) open(fid uint32) {
+	c.t.Helper()
+	c.rpc(tlopen, func(e *encoder) {
+		e.u32(fid)
+		e.u32(0)
+	})
+}
+
+func (c *client) read(fid uint32, off uint64, count uint32) string {
+	c.t.Helper()
+	d := c.rpc(tread, func(e *encoder) {
+		e.u32(fid)
+		e.u64(off)
+		e.u32(count)
+	})
+	return string(d.next(int(d.u32())))
+}
+
+func (c *client) readdir(fid uint32) []string {
+	c.t.Helper()
+	var names []string
+	off := uint64(0)
+	for {
+		d := c.rpc(treaddir, func(e *encoder) {
+			e.u32(fid)
+			e.u64(off)
+			e.u32(64)
+		})
+		data := &decoder{buf: d.next(int(d.u32()))}
+		if len(data.buf) == 0 {
+			return names
+		}
+		for len(data.buf) > 0 && data.err == nil {
+			data.qid()
+			off = data.u64()
+			data.u8()
+			names = append(names, data.str())
+		}
+	}
+}
+
+func newTestMux(t *testing.T) *multifs.MultiFS {
+	mux := multifs.NewMultiFS()
+	if err := mux.Mount("snap", fstest.MapFS{
+		"docs/a.txt": &fstest.MapFile{Data: []byte("hello 9p"), Mode: 0o644},
+		"docs/link":  &fstest.MapFile{Data: []byte("a.txt"), Mode: fs.ModeSymlink | 0o777},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Mount("other", fstest.MapFS{}); err != nil {
+		t.Fatal(err)
+	}
+	return mux
+}
+
+func attach(c *client, fid uint32, aname string) uint32 {
+	_, errno := c.call(tattach, func(e *encoder) {
+		e.u32(fid)
+		e.u32(noFid)
+		e.str("user")
+		e.str(aname)
+		e.u32(0)
+	})
+	return errno
+}
+
+func TestServe(t *testing.T) {
+	c := newClient(t, newTestMux(t))
+	if errno := attach(c, 0, ""); errno != 0 {
+		t.Fatalf("attach: errno %d", errno)
+	}
+
+	if n, errno := c.walk(0, 1, "snap", "docs", "a.txt"); n != 3 || errno != 0 {
+		t.Fatalf("walk: %d qids, errno %d", n, errno)
+	}
+	d := c.rpc(tgetattr, func(e *encoder) {
+		e.u32(1)
+		e.u64(getattrBasic)
+	})
+	d.u64()
+	d.qid()
+	mode := d.u32()
+	d.next(4 + 4 + 8 + 8)
+	if size := d.u64(); mode != 0o100644 || size != 8 {
+		t.Fatalf("getattr: mode %o size %d", mode, size)
+	}
+
+	c.open(1)
+	if got := c.read(1, 0, 5); got != "hello" {
+		t.Fatalf("read: %q", got)
+	}
+	if got := c.read(1, 6, 100); got != "9p" {
+		t.Fatalf("read at offset: %q", got)
+	}
+	if got := c.read(1, 100, 10); got != "" {
+		t.Fatalf("read past end: %q", got)
+	}
+
+	c.walk(0, 2)
+	c.open(2)
+	if got := c.readdir(2); len(got) != 4 || got[2] != "other" || got[3] != "snap" {
+		t.Fatalf("root listing: %q", got)
+	}
+
+	c.walk(0, 3, "snap", "docs", "link")
+	d = c.rpc(treadlink, func(e *encoder) { e.u32(3) })
+	if target := d.str(); target != "a.txt" {
+		t.Fatalf("readlink: %q", target)
+	}
+
+	if _, errno := c.walk(0, 4, "missing"); errno != enoent {
+		t.Fatalf("walk to missing: errno %d", errno)
+	}
+	if n, _ := c.walk(0, 4, "snap", "missing"); n != 1 {
+		t.Fatalf("partial walk: %d qids", n)
+	}
+	if _, errno := c.call(tgetattr, func(e *encoder) {
+		e.u32(4)
+		e.u64(getattrBasic)
+	}); errno != ebadf {
+		t.Fatalf("partial walk created its fid: errno %d", errno)
+	}
+
+	c.rpc(tclunk, func(e *encoder) { e.u32(1) })
+	if _, errno := c.call(tread, func(e *encoder) {
+		e.u32(1)
+		e.u64(0)
+		e.u32(10)
+	}); errno != ebadf {
+		t.Fatalf("read after clunk: errno %d", errno)
+	}
+}
+
+func TestServeReadOnly(t *testing.T) {
+	c := newClient(t, newTestMux(t))
+	attach(c, 0, "")
+	c.walk(0, 1, "snap", "docs", "a.txt")
+
+	if _, errno := c.call(tlopen, func(e *encoder) {
+		e.u32(1)
+		e.u32(uint32(os.O_RDWR))
+	}); errno != erofs {
+		t.Fatalf("lopen for writing: errno %d", errno)
+	}
+	if _, errno := c.call(tmkdir, func(e *encoder) {
+		e.u32(0)
+		e.str("new")
+		e.u32(0o755)
+		e.u32(0)
+	}); errno != erofs {
+		t.Fatalf("mkdir: errno %d", errno)
+	}
+}
+
+func TestServeAttachName(t *testing.T) {
+	c := newClient(t, newTestMux(t))
+	if errno := attach(c, 0, "/snap"); errno != 0 {
+		t.Fatalf("attach: errno %d", errno)
+	}
+	// ".." stops at the attach point
+	if n, errno := c.walk(0, 1, "..", "docs", "a.txt"); n != 3 || errno != 0 {
+		t.Fatalf("walk above the attach point: %d qids, errno %d", n, errno)
+	}
+	if errno := attach(c, 2, "snap/docs/a.txt"); errno != enotdir {
+		t.Fatalf("attach to a file: errno %d", errno)
+	}
+
+	// Qids only depend on the path
+	d1 := c.rpc(twalk, func(e *encoder) {
+		e.u32(0)
+		e.u32(3)
+		e.u16(1)
+		e.str("docs")
+	})
+	d2 := c.rpc(twalk, func(e *encoder) {
+		e.u32(1)
+		e.u32(4)
+		e.u16(1)
+		e.str("..")
+	})
+	d1.u16()
+	d2.u16()
+	if q1, q2 := d1.qid(), d2.qid(); q1 != q2 {
+		t.Fatalf("qids differ: %v %v", q1, q2)
+	}
+}
+
+// xattrFS is a MapFS whose files all carry the same extended attributes.
+type xattrFS struct {
+	fstest.MapFS
+}
+
+func (xattrFS) ListXattr(name string) ([]string, error) {
+	return []string{"user.a", "user.b"}, nil
+}
+
+func (xattrFS) GetXattr(name, attr string) ([]byte, error) {
+	if attr != "user.a" {
+		return nil, multifs.ErrNoXattr
+	}
+	return []byte("value"), nil
+}
+
+func TestServeXattr(t *testing.T) {
+	mux := newTestMux(t)
+	if err := mux.Mount("x", xattrFS{fstest.MapFS{"f": &fstest.MapFile{}}}); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t, mux)
+	attach(c, 0, "")
+	c.walk(0, 1, "x", "f")
+
+	xattrwalk := func(newfid uint32, name string) (uint64, uint32) {
+		d, errno := c.call(txattrwalk, func(e *encoder) {
+			e.u32(1)
+			e.u32(newfid)
+			e.str(name)
+		})
+		if errno != 0 {
+			return 0, errno
+		}
+		return d.u64(), 0
+	}
+	if size, errno := xattrwalk(2, ""); errno != 0 || size != 14 {
+		t.Fatalf("xattrwalk for the list: size %d, errno %d", size, errno)
+	}
+	if got := c.read(2, 0, 64); got != "user.a\x00user.b\x00" {
+		t.Fatalf("list: %q", got)
+	}
+	if size, errno := xattrwalk(3, "user.a"); errno != 0 || size != 5 {
+		t.Fatalf("xattrwalk: size %d, errno %d", size, errno)
+	}
+	if got := c.read(3, 2, 64); got != "lue" {
+		t.Fatalf("value at offset 2: %q", got)
+	}
+	if _, errno := xattrwalk(4, "user.b"); errno != enodata {
+		t.Fatalf("xattrwalk of an unset attribute: errno %d", errno)
+	}
+
+	// Mounts without extended attributes do not support them
+	c.walk(0, 5, "snap", "docs", "a.txt")
+	if _, errno := c.call(txattrwalk, func(e *encoder) {
+		e.u32(5)
+		e.u32(6)
+		e.str("")
+	}); errno != eopnotsupp {
+		t.Fatalf("xattrwalk without XattrFS: errno %d", errno)
+	}
+}
@@ -2,12 +2,42 @@ package multifs
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
+type backend struct {
+	name string
+	fsys fs.FS
+}
+
+// backendsOf returns files both as a MapFS and written to an os.DirFS,
+// whose regular files also implement fs.ReadDirFile: layers must not take
+// them for directories.
+func backendsOf(t *testing.T, files fstest.MapFS) []backend {
+	t.Helper()
+	dir := t.TempDir()
+	for name, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, f.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return []backend{{"MapFS", files}, {"DirFS", os.DirFS(dir)}}
+}
+
 func TestMountAndOpen(t *testing.T) {
 	mux := NewMultiFS()
 
@@ -179,16 +209,39 @@ func TestInvalidPaths(t *testing.T) {
 		"/absolute",
 		"../outside",
 		"one/../escape",
+		"one/./file.txt",
+		"one//file.txt",
+		"one/file.txt/",
+		"./one",
+		"",
 	}
 
 	for _, name := range tests {
 		_, err := mux.Open(name)
-		if err == nil {
-			t.Fatalf("Open(%q): expected error, got nil", name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Fatalf("Open(%q): expected ErrInvalid, got %v", name, err)
 		}
 	}
 }
 
+func TestOpenMountRootName(t *testing.T) {
+	mux := NewMultiFS()
+	if err := mux.Mount("one", fstest.MapFS{"file.txt": &fstest.MapFile{}}); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	f, err := mux.Open("one")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Name() != "one" {
+		t.Fatalf("Stat of mount root: %v, %v", info, err)
+	}
+	if got := listNames(t, mux, "one"); got != "file.txt" {
+		t.Fatalf("listing of mount root: %s", got)
+	}
+}
+
 func TestNonExistentIDOrFile(t *testing.T) {
 	mux := NewMultiFS()
 	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
@@ -225,3 +278,616 @@ func TestRootOpenAsDir(t *testing.T) {
 		t.Fatalf("root did not implement fs.ReadDirFile")
 	}
 }
+
+func TestStableViewIgnoresLaterMounts(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
+	if err := mux.Mount("one", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	view := mux.Stable()
+
+	if err := mux.Mount("two", fs1); err != nil {
+		t.Fatalf("Mount two: %v", err)
+	}
+	if err := mux.Unmount("one"); err != nil {
+		t.Fatalf("Unmount one: %v", err)
+	}
+
+	// The view still sees the table as it was when taken
+	if _, err := fs.ReadFile(view, "one/file.txt"); err != nil {
+		t.Fatalf("ReadFile one/file.txt through view: %v", err)
+	}
+	if _, err := view.Open("two"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist for id mounted after view, got %v", err)
+	}
+
+	// The live mux moved on
+	if _, err := fs.ReadFile(mux, "one/file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist after unmount, got %v", err)
+	}
+	if got := mux.Stable().Generation(); got <= view.Generation() {
+		t.Fatalf("generation did not advance: view %d, now %d", view.Generation(), got)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
+	mux.Mount("one", fs1)
+
+	frozen := mux.Freeze()
+	mux.Unmount("one")
+	mux.Mount("two", fs1)
+
+	if err := fstest.TestFS(frozen, "one/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(frozen, "two"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("frozen view sees a later mount: %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	mux := NewMultiFS(WithCaseInsensitive())
+	fs1 := fstest.MapFS{
+		"file.txt":   &fstest.MapFile{Data: []byte("x")},
+		"hidden.txt": &fstest.MapFile{},
+	}
+	mux.MountRoot(fstest.MapFS{"root.txt": &fstest.MapFile{}})
+	mux.Mount("One", fs1, WithSubtrees("file.txt"))
+	mux.Mount("two", fs1)
+
+	c := mux.Clone()
+	mux.Unmount("two")
+	c.Mount("three", fs1)
+
+	if err := fstest.TestFS(c, "One/file.txt", "two/file.txt", "three/file.txt", "root.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mux.Stat("three"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("mount on the clone seen by the original: %v", err)
+	}
+	if _, err := c.Stat("one"); err != nil {
+		t.Fatalf("clone lost case folding: %v", err)
+	}
+
+	// Mount options come along, usage does not
+	if _, err := c.Stat("One/hidden.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("subtrees not cloned: %v", err)
+	}
+	if usage, _ := mux.Usage("One"); usage.Opens != 0 {
+		t.Fatalf("clone usage accounted on the original: %+v", usage)
+	}
+}
+
+func TestAll(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
+	mux.MountRoot(fs1)
+	for _, id := range []string{"b", "a", "c"} {
+		if err := mux.Mount(id, fs1); err != nil {
+			t.Fatalf("Mount %s: %v", id, err)
+		}
+	}
+
+	var ids []string
+	for id, fsys := range mux.All() {
+		// Changes made while iterating are not seen
+		mux.Unmount("c")
+		mux.Mount("d", fs1)
+
+		if _, err := fs.ReadFile(fsys, "file.txt"); err != nil {
+			t.Fatalf("ReadFile through %s: %v", id, err)
+		}
+		ids = append(ids, id)
+	}
+	if got := strings.Join(ids, ","); got != "a,b,c" {
+		t.Fatalf("All: got %s, want a,b,c", got)
+	}
+
+	ids = ids[:0]
+	for id := range mux.All() {
+		ids = append(ids, id)
+		break
+	}
+	if got := strings.Join(ids, ","); got != "a" {
+		t.Fatalf("All after break: got %s, want a", got)
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"a.txt": &fstest.MapFile{}}
+
+	gen := mux.Generation()
+	step := func(what string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+		if got := mux.Generation(); got <= gen {
+			t.Fatalf("%s: generation did not advance from %d", what, gen)
+		}
+		gen = mux.Generation()
+	}
+	step("Mount", mux.Mount("one", fs1))
+	step("Replace", mux.Replace("one", fs1))
+	step("MountRoot", mux.MountRoot(fs1))
+	step("UnmountRoot", mux.UnmountRoot())
+	step("Unmount", mux.Unmount("one"))
+
+	// Reads and views leave it alone.
+	mux.Stable()
+	fs.ReadDir(mux, ".")
+	if got := mux.Generation(); got != gen {
+		t.Fatalf("generation changed without a mutation: %d, was %d", got, gen)
+	}
+}
+
+func TestRootReadDirIsSorted(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}
+
+	for _, id := range []string{"charlie", "alpha", "delta", "bravo"} {
+		if err := mux.Mount(id, fs1); err != nil {
+			t.Fatalf("Mount %s: %v", id, err)
+		}
+	}
+
+	entries, err := fs.ReadDir(mux, ".")
+	if err != nil {
+		t.Fatalf("ReadDir root: %v", err)
+	}
+
+	want := []string{"alpha", "bravo", "charlie", "delta"}
+	for i, e := range entries {
+		if e.Name() != want[i] {
+			t.Errorf("root entry[%d]: got %q, want %q", i, e.Name(), want[i])
+		}
+	}
+}
+
+func TestRootReadDirCustomOrder(t *testing.T) {
+	reverse := func(a, b string) int { return strings.Compare(b, a) }
+	mux := NewMultiFS(WithRootOrder(reverse))
+	fs1 := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}
+
+	for _, id := range []string{"a", "c", "b"} {
+		if err := mux.Mount(id, fs1); err != nil {
+			t.Fatalf("Mount %s: %v", id, err)
+		}
+	}
+
+	f, err := mux.Open(".")
+	if err != nil {
+		t.Fatalf("Open(.): %v", err)
+	}
+	defer f.Close()
+
+	// Paginated reads keep the order across calls
+	var names []string
+	for {
+		entries, err := f.(fs.ReadDirFile).ReadDir(2)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if err != nil {
+			break
+		}
+	}
+	if got := strings.Join(names, ","); got != "c,b,a" {
+		t.Fatalf("root order: got %s, want c,b,a", got)
+	}
+}
+
+func TestStatMountRootDelegates(t *testing.T) {
+	mux := NewMultiFS()
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	fs1 := fstest.MapFS{
+		".":        &fstest.MapFile{Mode: fs.ModeDir | 0o750, ModTime: mtime, Sys: "root-sys"},
+		"file.txt": &fstest.MapFile{Data: []byte("x")},
+	}
+	if err := mux.Mount("one", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	info, err := mux.Stat("one")
+	if err != nil {
+		t.Fatalf("Stat(one): %v", err)
+	}
+	if info.Name() != "one" {
+		t.Errorf("Name: got %q, want %q", info.Name(), "one")
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0o750 {
+		t.Errorf("Mode: got %v, want dir with 0750", info.Mode())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("ModTime: got %v, want %v", info.ModTime(), mtime)
+	}
+	if info.Sys() != "root-sys" {
+		t.Errorf("Sys: got %v, want %q", info.Sys(), "root-sys")
+	}
+}
+
+func TestCaseInsensitiveIDs(t *testing.T) {
+	mux := NewMultiFS(WithCaseInsensitive())
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
+	if err := mux.Mount("Snap", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if _, err := fs.ReadFile(mux, "SNAP/file.txt"); err != nil {
+		t.Fatalf("ReadFile with different case: %v", err)
+	}
+	info, err := mux.Stat("snap")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Name() != "Snap" {
+		t.Fatalf("Stat.Name: got %q, want the mounted id %q", info.Name(), "Snap")
+	}
+
+	// Ids differing only by case collide
+	if err := mux.Mount("sNaP", fs1); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected ErrExist for colliding id, got %v", err)
+	}
+
+	if err := mux.Unmount("SNAP"); err != nil {
+		t.Fatalf("Unmount with different case: %v", err)
+	}
+	if _, err := mux.Open("snap"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist after unmount, got %v", err)
+	}
+
+	// Case-sensitive by default
+	strict := NewMultiFS()
+	if err := strict.Mount("Snap", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if _, err := strict.Open("snap"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist without WithCaseInsensitive, got %v", err)
+	}
+}
+
+func TestSyntheticDirInfo(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mux := NewMultiFS(WithSyntheticDir(SyntheticDir{
+		Name:    "exports",
+		Perm:    0o755,
+		ModTime: mtime,
+		Sys:     "owner",
+	}))
+	if err := mux.Mount("one", fstest.MapFS{}); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	info, err := mux.Stat(".")
+	if err != nil {
+		t.Fatalf("Stat(.): %v", err)
+	}
+	if info.Name() != "exports" || info.Mode() != fs.ModeDir|0o755 || !info.ModTime().Equal(mtime) || info.Sys() != "owner" {
+		t.Fatalf("unexpected root info: %s %v %v %v", info.Name(), info.Mode(), info.ModTime(), info.Sys())
+	}
+
+	entries, err := mux.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir(.): %v", err)
+	}
+	info, err = entries[0].Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Name() != "one" || info.Mode() != fs.ModeDir|0o755 || info.Sys() != "owner" {
+		t.Fatalf("unexpected mount entry info: %s %v %v", info.Name(), info.Mode(), info.Sys())
+	}
+
+	mux = NewMultiFS(WithSyntheticDir(SyntheticDir{Perm: 0}))
+	if info, err := mux.Stat("."); err != nil || info.Mode() != fs.ModeDir {
+		t.Fatalf("mode 000: %v, %v", info, err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	mux := NewMultiFS()
+	oldFS := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("old")}}
+	newFS := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("new")}}
+
+	if err := mux.Replace("snap", newFS); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist replacing an unknown id, got %v", err)
+	}
+	if err := mux.Mount("snap", oldFS); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	f, err := mux.Open("snap/file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	if err := mux.Replace("snap", newFS); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+
+	// Files opened before the swap keep reading from the old fs
+	data, err := io.ReadAll(f)
+	if err != nil || string(data) != "old" {
+		t.Fatalf("read from file opened before Replace: %q, %v", data, err)
+	}
+	data, err = fs.ReadFile(mux, "snap/file.txt")
+	if err != nil || string(data) != "new" {
+		t.Fatalf("read after Replace: %q, %v", data, err)
+	}
+}
+
+func TestReplaceHasNoGap(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("x")}}
+	if err := mux.Mount("snap", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if err := mux.Replace("snap", fs1); err != nil {
+				t.Errorf("Replace: %v", err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if _, err := mux.Stat("snap/file.txt"); err != nil {
+			t.Fatalf("Stat during Replace: %v", err)
+		}
+	}
+}
+
+func TestMountRootFallthrough(t *testing.T) {
+	mux := NewMultiFS()
+	base := fstest.MapFS{
+		"etc/hosts":  &fstest.MapFile{Data: []byte("base hosts")},
+		"snap/x.txt": &fstest.MapFile{Data: []byte("shadowed")},
+		"readme":     &fstest.MapFile{Data: []byte("readme")},
+	}
+	overlay := fstest.MapFS{"x.txt": &fstest.MapFile{Data: []byte("from snap")}}
+
+	if err := mux.MountRoot(base); err != nil {
+		t.Fatalf("MountRoot: %v", err)
+	}
+	if err := mux.Mount("snap", overlay); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	data, err := fs.ReadFile(mux, "etc/hosts")
+	if err != nil || string(data) != "base hosts" {
+		t.Fatalf("ReadFile through root mount: %q, %v", data, err)
+	}
+	data, err = fs.ReadFile(mux, "snap/x.txt")
+	if err != nil || string(data) != "from snap" {
+		t.Fatalf("explicit mount does not shadow root mount: %q, %v", data, err)
+	}
+
+	entries, err := mux.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir(.): %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "etc,readme,snap" {
+		t.Fatalf("root listing: got %s, want etc,readme,snap", got)
+	}
+
+	if err := mux.UnmountRoot(); err != nil {
+		t.Fatalf("UnmountRoot: %v", err)
+	}
+	if _, err := mux.Open("etc/hosts"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist after UnmountRoot, got %v", err)
+	}
+	if err := mux.UnmountRoot(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist unmounting missing root, got %v", err)
+	}
+}
+
+func TestRootReadDirPagedMerge(t *testing.T) {
+	mux := NewMultiFS()
+	mux.MountRoot(fstest.MapFS{
+		"a":   &fstest.MapFile{},
+		"c/x": &fstest.MapFile{},
+		"e":   &fstest.MapFile{},
+		"f":   &fstest.MapFile{},
+	})
+	for _, id := range []string{"b", "c", "d", "g"} {
+		mux.Mount(id, fstest.MapFS{})
+	}
+
+	f, err := mux.Open(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dir := f.(fs.ReadDirFile)
+
+	var pages []string
+	for {
+		entries, err := dir.ReadDir(3)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		pages = append(pages, strings.Join(names, ","))
+	}
+	if got := strings.Join(pages, "|"); got != "a,b,c|d,e,f|g" {
+		t.Fatalf("root pages: got %s, want a,b,c|d,e,f|g", got)
+	}
+}
+
+func benchmarkRootReadDir(b *testing.B, mounts int) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{}
+	for i := 0; i < mounts; i++ {
+		if err := mux.Mount(fmt.Sprintf("snap-%06d", i), fs1); err != nil {
+			b.Fatalf("Mount: %v", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entries, err := mux.ReadDir(".")
+		if err != nil || len(entries) != mounts {
+			b.Fatalf("ReadDir: %d entries, %v", len(entries), err)
+		}
+	}
+}
+
+func BenchmarkRootReadDir100(b *testing.B) { benchmarkRootReadDir(b, 100) }
+func BenchmarkRootReadDir50k(b *testing.B) { benchmarkRootReadDir(b, 50000) }
+
+func benchmarkRootReadDirPage(b *testing.B, mounts int) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{}
+	for i := 0; i < mounts; i++ {
+		if err := mux.Mount(fmt.Sprintf("snap-%06d", i), fs1); err != nil {
+			b.Fatalf("Mount: %v", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := mux.Open(".")
+		if err != nil {
+			b.Fatalf("Open: %v", err)
+		}
+		entries, err := f.(fs.ReadDirFile).ReadDir(64)
+		if err != nil || len(entries) != 64 {
+			b.Fatalf("ReadDir: %d entries, %v", len(entries), err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkRootReadDirPage100(b *testing.B) { benchmarkRootReadDirPage(b, 100) }
+func BenchmarkRootReadDirPage50k(b *testing.B) { benchmarkRootReadDirPage(b, 50000) }
+
+func benchmarkOpen(b *testing.B, mounts int) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"dir/file.txt": &fstest.MapFile{Data: []byte("x")}}
+	for i := 0; i < mounts; i++ {
+		if err := mux.Mount(fmt.Sprintf("snap-%06d", i), fs1); err != nil {
+			b.Fatalf("Mount: %v", err)
+		}
+	}
+	name := fmt.Sprintf("snap-%06d/dir/file.txt", mounts/2)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f, err := mux.Open(name)
+			if err != nil {
+				b.Fatalf("Open: %v", err)
+			}
+			f.Close()
+		}
+	})
+}
+
+func BenchmarkOpen100(b *testing.B) { benchmarkOpen(b, 100) }
+func BenchmarkOpen50k(b *testing.B) { benchmarkOpen(b, 50000) }
+
+// benchmarkOpenMounting opens files in parallel while the mount table keeps
+// changing: opens load the published table without waiting for Mount and
+// Unmount.
+func benchmarkOpenMounting(b *testing.B, mounts int) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"dir/file.txt": &fstest.MapFile{Data: []byte("x")}}
+	for i := 0; i < mounts; i++ {
+		if err := mux.Mount(fmt.Sprintf("snap-%06d", i), fs1); err != nil {
+			b.Fatalf("Mount: %v", err)
+		}
+	}
+	name := fmt.Sprintf("snap-%06d/dir/file.txt", mounts/2)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			id := fmt.Sprintf("extra-%d", i%16)
+			mux.Mount(id, fs1)
+			mux.Unmount(id)
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f, err := mux.Open(name)
+			if err != nil {
+				b.Fatalf("Open: %v", err)
+			}
+			f.Close()
+		}
+	})
+}
+
+func BenchmarkOpenMounting100(b *testing.B) { benchmarkOpenMounting(b, 100) }
+func BenchmarkOpenMounting50k(b *testing.B) { benchmarkOpenMounting(b, 50000) }
+
+func TestMountEntryInfo(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{".": &fstest.MapFile{Mode: fs.ModeDir | 0o750, ModTime: mtime, Sys: "owner"}})
+	before := time.Now()
+	mux.Mount("broken", failingFS{})
+
+	entries, err := mux.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir(.): %v", err)
+	}
+	info, err := entries[1].Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Name() != "snap" || info.Mode() != fs.ModeDir|0o750 || !info.ModTime().Equal(mtime) || info.Sys() != "owner" {
+		t.Fatalf("unexpected mount entry info: %s %v %v %v", info.Name(), info.Mode(), info.ModTime(), info.Sys())
+	}
+
+	// Mounts whose root cannot be stat'ed report when they were mounted
+	info, err = entries[0].Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Name() != "broken" || info.Mode() != fs.ModeDir|0o555 || info.ModTime().Before(before) {
+		t.Fatalf("unexpected mount entry info: %s %v %v", info.Name(), info.Mode(), info.ModTime())
+	}
+}
@@ -0,0 +1,179 @@
+package multifsafero
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/spf13/afero"
+)
+
+// Filesystem exposes mux as an afero.Fs. Paths are slash-separated and
+// relative to the synthetic root, with or without a leading slash. Writes
+// are delegated to the mounts by the write methods of MultiFS, and fail as
+// they do: with fs.ErrPermission on the synthetic root, and an error
+// wrapping errors.ErrUnsupported on mounts that cannot perform them.
+func Filesystem(mux *multifs.MultiFS) afero.Fs {
+	return &aferoFS{FromIOFS: afero.FromIOFS{FS: mux}, mux: mux}
+}
+
+var errNotDir = errors.New("not a directory")
+
+// aferoFS is afero's own io/fs adapter, with paths made relative and the
+// links support of MultiFS.
+type aferoFS struct {
+	afero.FromIOFS
+	mux *multifs.MultiFS
+}
+
+var _ afero.Symlinker = (*aferoFS)(nil)
+
+// fsName maps an afero path to a MultiFS path.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (a *aferoFS) Name() string { return "multifs" }
+
+func (a *aferoFS) Open(name string) (afero.File, error) {
+	return a.FromIOFS.Open(fsName(name))
+}
+
+// OpenFile opens files for writing with MultiFS.OpenFile, and others as
+// Open does.
+func (a *aferoFS) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return a.Open(name)
+	}
+	f, err := a.mux.OpenFile(fsName(name), flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: name}, nil
+}
+
+func (a *aferoFS) Create(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (a *aferoFS) Mkdir(name string, perm os.FileMode) error {
+	return a.mux.Mkdir(fsName(name), perm)
+}
+
+func (a *aferoFS) MkdirAll(name string, perm os.FileMode) error {
+	return a.mux.MkdirAll(fsName(name), perm)
+}
+
+func (a *aferoFS) Remove(name string) error {
+	return a.mux.Remove(fsName(name))
+}
+
+func (a *aferoFS) RemoveAll(name string) error {
+	return a.mux.RemoveAll(fsName(name))
+}
+
+// Rename is MultiFS.Move, which also moves files across mounts.
+func (a *aferoFS) Rename(oldname, newname string) error {
+	return a.mux.Move(fsName(oldname), fsName(newname))
+}
+
+func (a *aferoFS) Chmod(name string, mode os.FileMode) error {
+	return a.mux.Chmod(fsName(name), mode)
+}
+
+func (a *aferoFS) Chtimes(name string, atime, mtime time.Time) error {
+	return a.mux.Chtimes(fsName(name), atime, mtime)
+}
+
+func (a *aferoFS) Chown(name string, uid, gid int) error {
+	return a.mux.Chown(fsName(name), uid, gid)
+}
+
+func (a *aferoFS) Stat(name string) (os.FileInfo, error) {
+	return a.mux.Stat(fsName(name))
+}
+
+func (a *aferoFS) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	info, err := a.mux.Lstat(fsName(name))
+	return info, true, err
+}
+
+func (a *aferoFS) ReadlinkIfPossible(name string) (string, error) {
+	return a.mux.ReadLink(fsName(name))
+}
+
+// SymlinkIfPossible passes oldname as is to MultiFS.Symlink.
+func (a *aferoFS) SymlinkIfPossible(oldname, newname string) error {
+	return a.mux.Symlink(oldname, fsName(newname))
+}
+
+// file is a file opened for writing, which MultiFS hands out without
+// ReadAt nor WriteAt: they seek to the offset then back, and are not safe
+// for concurrent use.
+type file struct {
+	multifs.File
+	name string
+}
+
+var _ afero.File = (*file)(nil)
+
+func (f *file) Name() string { return f.name }
+func (f *file) Sync() error  { return nil }
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	err := f.at(off, func() (err error) {
+		n, err = io.ReadFull(f.File, p)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return err
+	})
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	n := 0
+	err := f.at(off, func() (err error) {
+		n, err = f.File.Write(p)
+		return err
+	})
+	return n, err
+}
+
+// at runs fn at offset off, then seeks back.
+func (f *file) at(off int64, fn func() error) error {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	err = fn()
+	if _, serr := f.Seek(pos, io.SeekStart); serr != nil && err == nil {
+		err = serr
+	}
+	return err
+}
+
+func (f *file) Readdir(int) ([]os.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errNotDir}
+}
+
+func (f *file) Readdirnames(int) ([]string, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errNotDir}
+}
@@ -0,0 +1,123 @@
+package multifsafero
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/PlakarKorp/go-multifs/memfs"
+	"github.com/spf13/afero"
+)
+
+func TestFilesystem(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"docs/a.txt": &fstest.MapFile{Data: []byte("hello afero")},
+		"docs/link":  &fstest.MapFile{Data: []byte("a.txt"), Mode: fs.ModeSymlink | 0o777},
+	})
+	mux.Mount("other", fstest.MapFS{})
+	afs := Filesystem(mux)
+
+	infos, err := afero.ReadDir(afs, "/")
+	if err != nil || len(infos) != 2 || infos[0].Name() != "other" || infos[1].Name() != "snap" {
+		t.Fatalf("ReadDir /: %v, %v", infos, err)
+	}
+	data, err := afero.ReadFile(afs, "/snap/docs/a.txt")
+	if err != nil || string(data) != "hello afero" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if ok, err := afero.DirExists(afs, "snap/docs"); !ok || err != nil {
+		t.Fatalf("DirExists: %v, %v", ok, err)
+	}
+
+	lr := afs.(afero.Symlinker)
+	if info, _, err := lr.LstatIfPossible("/snap/docs/link"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("LstatIfPossible: %v, %v", info, err)
+	}
+	if target, err := lr.ReadlinkIfPossible("/snap/docs/link"); err != nil || target != "a.txt" {
+		t.Fatalf("ReadlinkIfPossible: %q, %v", target, err)
+	}
+
+	if _, err := afs.Create("/snap/new"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Create on a read-only mount: %v", err)
+	}
+	if err := afs.Remove("/snap/docs/a.txt"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Remove on a read-only mount: %v", err)
+	}
+	if err := afs.Mkdir("/new", 0o755); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Mkdir in the synthetic root: %v", err)
+	}
+}
+
+func TestFilesystemWrite(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("scratch", memfs.New())
+	mux.Mount("host", Wrap(afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())))
+	afs := Filesystem(mux)
+
+	if err := afs.MkdirAll("/scratch/logs/old", 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := afero.WriteFile(afs, "/scratch/logs/app.log", []byte("line 1\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	f, err := afs.OpenFile("/scratch/logs/app.log", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("LINE"), 0); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+	buf := make([]byte, 4)
+	if n, err := f.ReadAt(buf, 5); n != 2 || err != io.EOF || string(buf[:n]) != "1\n" {
+		t.Fatalf("ReadAt: %d %q, %v", n, buf[:n], err)
+	}
+	f.Seek(0, io.SeekEnd)
+	if _, err := f.WriteString("line 2\n"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	f.Close()
+	if data, err := afero.ReadFile(afs, "/scratch/logs/app.log"); err != nil || string(data) != "LINE 1\nline 2\n" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := afs.Chmod("/scratch/logs/app.log", 0o600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	if err := afs.Chtimes("/scratch/logs/app.log", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := afs.Chown("/scratch/logs/app.log", 1000, 1000); err != nil {
+		t.Fatalf("Chown: %v", err)
+	}
+	if info, err := afs.Stat("/scratch/logs/app.log"); err != nil || info.Mode() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Stat: %v, %v", info, err)
+	}
+
+	// Renames move files across mounts
+	if err := afs.Rename("/scratch/logs/app.log", "/host/app.log"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := afs.(afero.Linker).SymlinkIfPossible("app.log", "/host/current"); err != nil {
+		t.Fatalf("SymlinkIfPossible: %v", err)
+	}
+	if data, err := afero.ReadFile(afs, "/host/current"); err != nil || string(data) != "LINE 1\nline 2\n" {
+		t.Fatalf("ReadFile through the link: %q, %v", data, err)
+	}
+
+	if err := afs.Remove("/scratch/logs"); err == nil {
+		t.Fatal("Remove of a directory not empty succeeded")
+	}
+	if err := afs.RemoveAll("/scratch/logs"); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if ok, _ := afero.Exists(afs, "/scratch/logs"); ok {
+		t.Fatal("RemoveAll left the directory")
+	}
+}
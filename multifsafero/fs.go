@@ -0,0 +1,166 @@
+// Package multifsafero adapts between afero and MultiFS: Wrap makes an
+// afero.Fs mountable, and Filesystem exposes a MultiFS as an afero.Fs.
+package multifsafero
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/spf13/afero"
+)
+
+// FS is an afero.Fs seen as a multifs.WritableFS. Symbolic links are
+// exposed, and can be created, when the afero filesystem supports them.
+type FS struct {
+	iofs afero.IOFS
+}
+
+var _ multifs.WritableFS = (*FS)(nil)
+var _ multifs.ChmodFS = (*FS)(nil)
+var _ multifs.ChtimesFS = (*FS)(nil)
+var _ multifs.ChownFS = (*FS)(nil)
+var _ multifs.SymlinkFS = (*FS)(nil)
+var _ fs.StatFS = (*FS)(nil)
+var _ fs.ReadDirFS = (*FS)(nil)
+
+// Wrap returns a as a filesystem that can be mounted on a MultiFS.
+func Wrap(a afero.Fs) *FS {
+	return &FS{iofs: afero.NewIOFS(a)}
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	file, err := f.iofs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err == nil && !info.IsDir() {
+		return &regularFile{File: file.(afero.File)}, nil
+	}
+	return file, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Stat(name)
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.ReadDir(name)
+}
+
+func (f *FS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	lr, ok := f.iofs.Fs.(afero.LinkReader)
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return lr.ReadlinkIfPossible(name)
+}
+
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	if ls, ok := f.iofs.Fs.(afero.Lstater); ok {
+		info, _, err := ls.LstatIfPossible(name)
+		return info, err
+	}
+	return f.iofs.Stat(name)
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (multifs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := f.iofs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &regularFile{File: file}, nil
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Mkdir(name, perm)
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Remove(name)
+}
+
+func (f *FS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	if !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Rename(oldname, newname)
+}
+
+func (f *FS) Chmod(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Chmod(name, mode)
+}
+
+func (f *FS) Chtimes(name string, atime, mtime time.Time) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Chtimes(name, atime, mtime)
+}
+
+func (f *FS) Chown(name string, uid, gid int) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.iofs.Chown(name, uid, gid)
+}
+
+// Symlink creates newname on afero filesystems implementing afero.Linker,
+// which may rewrite oldname: BasePathFs makes it absolute.
+func (f *FS) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	err := afero.ErrNoSymlink
+	if l, ok := f.iofs.Fs.(afero.Linker); ok {
+		err = l.SymlinkIfPossible(oldname, newname)
+	}
+	if errors.Is(err, afero.ErrNoSymlink) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+	}
+	return err
+}
+
+// regularFile fixes the ReadAt of some afero files, such as the ones of
+// MemMapFs, which return short reads at end of file without io.EOF.
+type regularFile struct {
+	afero.File
+}
+
+func (f *regularFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
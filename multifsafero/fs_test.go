@@ -0,0 +1,116 @@
+package multifsafero
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/spf13/afero"
+)
+
+func TestWrap(t *testing.T) {
+	mem := afero.NewMemMapFs()
+	afero.WriteFile(mem, "docs/a.txt", []byte("hello afero"), 0o644)
+	afero.WriteFile(mem, "docs/b.txt", []byte("bee"), 0o644)
+
+	fsys := Wrap(mem)
+	if err := fstest.TestFS(fsys, "docs/a.txt", "docs/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.ReadLink("docs/a.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("ReadLink without links support: %v", err)
+	}
+}
+
+func TestWrapWrite(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	fsys := Wrap(afero.NewMemMapFs())
+	if err := mux.Mount("scratch", fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsys.Mkdir("logs", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	f, err := fsys.OpenFile("logs/app.log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	io.WriteString(f, "line 1\n")
+	f.Close()
+
+	if err := fsys.Rename("logs/app.log", "logs/app.log.1"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	data, err := fs.ReadFile(mux, "scratch/logs/app.log.1")
+	if err != nil || string(data) != "line 1\n" {
+		t.Fatalf("ReadFile through MultiFS: %q, %v", data, err)
+	}
+	if err := fsys.Remove("logs/app.log.1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := mux.Stat("scratch/logs/app.log.1"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("removed file still there: %v", err)
+	}
+	if err := fsys.Remove("/logs"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Remove invalid path: %v", err)
+	}
+}
+
+func TestWrapMetadata(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	fsys := Wrap(afero.NewMemMapFs())
+	mux.Mount("scratch", fsys)
+	f, err := mux.OpenFile("scratch/f", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	f.Close()
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := mux.Chmod("scratch/f", 0o600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	if err := mux.Chtimes("scratch/f", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := mux.Chown("scratch/f", os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("Chown: %v", err)
+	}
+	info, err := mux.Stat("scratch/f")
+	if err != nil || info.Mode().Perm() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Stat: %v %v, %v", info.Mode(), info.ModTime(), err)
+	}
+}
+
+func TestWrapSymlink(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("os", Wrap(afero.NewBasePathFs(afero.NewOsFs(), t.TempDir())))
+	mux.Mount("mem", Wrap(afero.NewMemMapFs()))
+	for _, id := range []string{"os", "mem"} {
+		f, err := mux.OpenFile(id+"/f", os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+		io.WriteString(f, "data")
+		f.Close()
+	}
+
+	if err := mux.Symlink("f", "os/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if data, err := fs.ReadFile(mux, "os/link"); err != nil || string(data) != "data" {
+		t.Fatalf("ReadFile through the link: %q, %v", data, err)
+	}
+	if info, err := mux.Lstat("os/link"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Lstat: %v, %v", info, err)
+	}
+	if err := mux.Symlink("f", "mem/link"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Symlink without links support: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package multifsbilly
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// Filesystem exposes mux as a read-only billy.Filesystem. Paths are
+// slash-separated and relative to the synthetic root, with or without a
+// leading slash. Writes fail with billy.ErrReadOnly.
+func Filesystem(mux *multifs.MultiFS) billy.Filesystem {
+	return &billyFS{mux: mux}
+}
+
+type billyFS struct {
+	mux *multifs.MultiFS
+}
+
+var _ billy.Capable = (*billyFS)(nil)
+
+// fsName maps a billy path to a MultiFS path.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func readOnly(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: billy.ErrReadOnly}
+}
+
+func (b *billyFS) Create(name string) (billy.File, error) {
+	return nil, readOnly("open", name)
+}
+
+func (b *billyFS) Open(name string) (billy.File, error) {
+	return b.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (b *billyFS) OpenFile(name string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnly("open", name)
+	}
+	f, err := b.mux.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &billyFile{File: f, name: name}, nil
+}
+
+func (b *billyFS) Stat(name string) (os.FileInfo, error) {
+	return b.mux.Stat(fsName(name))
+}
+
+func (b *billyFS) Lstat(name string) (os.FileInfo, error) {
+	return b.mux.Lstat(fsName(name))
+}
+
+func (b *billyFS) Readlink(name string) (string, error) {
+	return b.mux.ReadLink(fsName(name))
+}
+
+func (b *billyFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := b.mux.ReadDir(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (b *billyFS) Rename(oldname, newname string) error { return readOnly("rename", oldname) }
+func (b *billyFS) Remove(name string) error             { return readOnly("remove", name) }
+func (b *billyFS) Symlink(target, link string) error    { return readOnly("symlink", link) }
+
+func (b *billyFS) MkdirAll(name string, perm os.FileMode) error {
+	return readOnly("mkdir", name)
+}
+
+func (b *billyFS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, readOnly("open", path.Join(dir, prefix))
+}
+
+func (b *billyFS) Join(elem ...string) string { return path.Join(elem...) }
+
+func (b *billyFS) Chroot(dir string) (billy.Filesystem, error) {
+	return chroot.New(b, dir), nil
+}
+
+func (b *billyFS) Root() string { return "/" }
+
+func (b *billyFS) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability
+}
+
+// billyFile is a file of the MultiFS as a billy.File. Seek and ReadAt are
+// passed through when the file supports them; ReadAt is otherwise
+// emulated on seekable files.
+type billyFile struct {
+	fs.File
+	name string
+}
+
+func (f *billyFile) Name() string { return f.name }
+
+func (f *billyFile) Seek(offset int64, whence int) (int64, error) {
+	s, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.ErrUnsupported}
+	}
+	return s.Seek(offset, whence)
+}
+
+func (f *billyFile) ReadAt(p []byte, off int64) (int, error) {
+	if ra, ok := f.File.(io.ReaderAt); ok {
+		n, err := ra.ReadAt(p, off)
+		if errors.Is(err, fs.ErrInvalid) {
+			// Some implementations reject offsets past the end
+			if info, serr := f.Stat(); serr == nil && off >= info.Size() {
+				err = io.EOF
+			}
+		}
+		return n, err
+	}
+
+	s, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: errors.ErrUnsupported}
+	}
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f.File, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if _, serr := s.Seek(pos, io.SeekStart); serr != nil && err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+func (f *billyFile) Write([]byte) (int, error) { return 0, readOnly("write", f.name) }
+func (f *billyFile) Truncate(int64) error      { return readOnly("truncate", f.name) }
+func (f *billyFile) Lock() error               { return nil }
+func (f *billyFile) Unlock() error             { return nil }
@@ -0,0 +1,63 @@
+package multifsbilly
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestFilesystem(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"repo/HEAD":   &fstest.MapFile{Data: []byte("ref: refs/heads/main\n")},
+		"repo/config": &fstest.MapFile{Data: []byte("[core]\n")},
+	})
+	mux.Mount("other", fstest.MapFS{})
+	bfs := Filesystem(mux)
+
+	infos, err := bfs.ReadDir("/")
+	if err != nil || len(infos) != 2 || infos[0].Name() != "other" || infos[1].Name() != "snap" {
+		t.Fatalf("ReadDir /: %v, %v", infos, err)
+	}
+
+	data, err := util.ReadFile(bfs, "/snap/repo/HEAD")
+	if err != nil || string(data) != "ref: refs/heads/main\n" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	repo, err := bfs.Chroot("snap/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := repo.Open("HEAD")
+	if err != nil {
+		t.Fatalf("Open in chroot: %v", err)
+	}
+	defer f.Close()
+	buf := make([]byte, 4)
+	if n, err := f.ReadAt(buf, 5); err != nil || string(buf[:n]) != "refs" {
+		t.Fatalf("ReadAt: %q, %v", buf[:n], err)
+	}
+	if n, err := f.ReadAt(buf, 100); n != 0 || err != io.EOF {
+		t.Fatalf("ReadAt past end: %d, %v", n, err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, billy.ErrReadOnly) {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, err := bfs.Create("snap/new"); !errors.Is(err, billy.ErrReadOnly) {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := bfs.MkdirAll("snap/dir", 0o755); !errors.Is(err, billy.ErrReadOnly) {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if _, err := bfs.Stat("snap/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat missing: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+// Package multifsbilly adapts between go-billy filesystems and MultiFS:
+// Wrap makes a billy filesystem mountable, and Filesystem exposes a MultiFS
+// to billy consumers such as go-git.
+package multifsbilly
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/polyfill"
+)
+
+// FS is a billy filesystem seen as a multifs.WritableFS. Symbolic links are
+// exposed, and can be created, when the billy filesystem supports them.
+type FS struct {
+	b billy.Filesystem
+}
+
+var _ multifs.WritableFS = (*FS)(nil)
+var _ multifs.SymlinkFS = (*FS)(nil)
+var _ fs.StatFS = (*FS)(nil)
+var _ fs.ReadDirFS = (*FS)(nil)
+
+// Wrap returns b as a filesystem that can be mounted on a MultiFS.
+func Wrap(b billy.Basic) *FS {
+	return &FS{b: polyfill.New(b)}
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.b.Stat(name)
+	if err != nil {
+		return nil, convert("open", name, err)
+	}
+	if info.IsDir() {
+		return &dir{fsys: f, name: name, info: info}, nil
+	}
+	bf, err := f.b.Open(name)
+	if err != nil {
+		return nil, convert("open", name, err)
+	}
+	return &file{File: bf, fsys: f, name: name}, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.b.Stat(name)
+	return info, convert("stat", name, err)
+}
+
+// ReadDir returns the entries of name sorted by name, whatever order the
+// billy filesystem lists them in.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := f.b.ReadDir(name)
+	if err != nil {
+		return nil, convert("readdir", name, err)
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (f *FS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := f.b.Readlink(name)
+	if errors.Is(err, billy.ErrNotSupported) {
+		// Without links support, nothing is a link
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return target, convert("readlink", name, err)
+}
+
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.b.Lstat(name)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return f.Stat(name)
+	}
+	return info, convert("lstat", name, err)
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (multifs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	bf, err := f.b.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, convert("open", name, err)
+	}
+	return &file{File: bf, fsys: f, name: name}, nil
+}
+
+// Mkdir creates name, whose parent must exist. billy only has MkdirAll,
+// so the checks are made beforehand and can race with other writers.
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.b.Lstat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	parent, err := f.b.Stat(path.Dir(name))
+	switch {
+	case err != nil:
+		return convert("mkdir", name, err)
+	case !parent.IsDir():
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return convert("mkdir", name, f.b.MkdirAll(name, perm))
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return convert("remove", name, f.b.Remove(name))
+}
+
+func (f *FS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	if !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	return convert("rename", oldname, f.b.Rename(oldname, newname))
+}
+
+func (f *FS) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: fs.ErrInvalid}
+	}
+	return convert("symlink", newname, f.b.Symlink(oldname, newname))
+}
+
+// convert maps billy's own errors to the fs ones MultiFS understands.
+func convert(op, name string, err error) error {
+	switch {
+	case errors.Is(err, billy.ErrNotSupported):
+		return &fs.PathError{Op: op, Path: name, Err: errors.ErrUnsupported}
+	case errors.Is(err, billy.ErrReadOnly):
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return err
+}
+
+// file is a billy file with the Stat method of fs.File. It is stated on
+// demand since it may have been written to.
+type file struct {
+	billy.File
+	fsys *FS
+	name string
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.fsys.Stat(f.name)
+}
+
+// dir is an open directory, listed on the first call to ReadDir.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
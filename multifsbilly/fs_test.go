@@ -0,0 +1,118 @@
+package multifsbilly
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestWrap(t *testing.T) {
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "docs/b.txt", []byte("bee"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(mem, "docs/a.txt", []byte("hello billy"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Symlink("a.txt", "docs/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	// memfs makes up modification times on every Stat, which fstest.TestFS
+	// rejects, so the checks are spelled out
+	fsys := Wrap(mem)
+	data, err := fs.ReadFile(fsys, "docs/a.txt")
+	if err != nil || string(data) != "hello billy" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	var names []string
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		names = append(names, name)
+		return err
+	})
+	if got := strings.Join(names, ","); err != nil || got != ".,docs,docs/a.txt,docs/b.txt,docs/link" {
+		t.Fatalf("WalkDir: %s, %v", got, err)
+	}
+	if _, err := fsys.Open("docs/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open missing: %v", err)
+	}
+	if _, err := fsys.Open("/docs"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Open invalid: %v", err)
+	}
+	if target, err := fsys.ReadLink("docs/link"); err != nil || target != "a.txt" {
+		t.Fatalf("ReadLink: %q, %v", target, err)
+	}
+	if info, err := fsys.Lstat("docs/link"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Lstat: %v, %v", info, err)
+	}
+}
+
+func TestWrapWrite(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	fsys := Wrap(memfs.New())
+	if err := mux.Mount("scratch", fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsys.Mkdir("logs", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := fsys.Mkdir("logs", 0o755); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Mkdir existing: %v", err)
+	}
+	if err := fsys.Mkdir("missing/logs", 0o755); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Mkdir without parent: %v", err)
+	}
+
+	f, err := fsys.OpenFile("logs/app.log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	io.WriteString(f, "line 1\n")
+	if info, err := f.Stat(); err != nil || info.Size() != 7 {
+		t.Fatalf("Stat of written file: %v, %v", info, err)
+	}
+	f.Close()
+
+	if err := fsys.Rename("logs/app.log", "logs/app.log.1"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	data, err := fs.ReadFile(mux, "scratch/logs/app.log.1")
+	if err != nil || string(data) != "line 1\n" {
+		t.Fatalf("ReadFile through MultiFS: %q, %v", data, err)
+	}
+	if err := fsys.Remove("logs/app.log.1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := mux.Stat("scratch/logs/app.log.1"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("removed file still there: %v", err)
+	}
+}
+
+func TestWrapSymlink(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("scratch", Wrap(memfs.New()))
+	f, err := mux.OpenFile("scratch/f", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	io.WriteString(f, "data")
+	f.Close()
+
+	if err := mux.Symlink("f", "scratch/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := mux.ReadLink("scratch/link"); err != nil || target != "f" {
+		t.Fatalf("ReadLink: %q, %v", target, err)
+	}
+	if data, err := fs.ReadFile(mux, "scratch/link"); err != nil || string(data) != "data" {
+		t.Fatalf("ReadFile through the link: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,217 @@
+// Package multifshttp serves a MultiFS over HTTP, with directory listings
+// that understand its synthetic root.
+package multifshttp
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+type Options struct {
+	// NoListing answers requests for directories with 403 Forbidden
+	// instead of a listing.
+	NoListing bool
+}
+
+// Handler serves the files of mux. Directories are listed as HTML, or as
+// JSON when requested with "?format=json" or an Accept header preferring
+// application/json. Paths are cleaned and never escape the namespace.
+func Handler(mux *multifs.MultiFS, opts Options) http.Handler {
+	return &handler{mux: mux, opts: opts}
+}
+
+type handler struct {
+	mux  *multifs.MultiFS
+	opts Options
+}
+
+// Entry is a directory entry in a JSON listing.
+type Entry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+
+	f, err := h.mux.OpenContext(r.Context(), name)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+
+	if !info.IsDir() {
+		serveFile(w, r, name, f, info)
+		return
+	}
+	if name != "." && !strings.HasSuffix(r.URL.Path, "/") {
+		target := url.PathEscape(path.Base(r.URL.Path)) + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+	if h.opts.NoListing {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	h.serveDir(w, r, name, f, info)
+}
+
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, fs.ErrInvalid):
+		http.Error(w, "invalid path", http.StatusBadRequest)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, name string, f fs.File, info fs.FileInfo) {
+	// ServeContent handles ranges and conditional requests when the file
+	// can seek; others are streamed whole.
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, name, info.ModTime(), rs)
+		return
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	if !info.ModTime().IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, f)
+}
+
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+func (h *handler) serveDir(w http.ResponseWriter, r *http.Request, name string, f fs.File, info fs.FileInfo) {
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	list, err := dir.ReadDir(-1)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+
+	entries := make([]Entry, 0, len(list))
+	for _, e := range list {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, Entry{
+			Name:    e.Name(),
+			Dir:     e.IsDir(),
+			Size:    info.Size(),
+			Mode:    info.Mode().String(),
+			ModTime: info.ModTime(),
+		})
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Name, b.Name) })
+
+	if !info.ModTime().IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Vary", "Accept")
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			json.NewEncoder(w).Encode(entries)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	title := "/"
+	if name != "." {
+		title += name
+	}
+	links := make([]link, len(entries))
+	for i, e := range entries {
+		links[i] = link{Entry: e, Href: url.PathEscape(e.Name)}
+	}
+	listingTemplate.Execute(w, struct {
+		Path    string
+		Root    bool
+		Entries []link
+	}{title, name == ".", links})
+}
+
+// link is an entry of an HTML listing, with its name escaped for use in a
+// relative URL: names may hold '#', '?' or '%'.
+type link struct {
+	Entry
+	Href string
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!doctype html>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+<h1>Index of {{.Path}}</h1>
+<table>
+{{- if not .Root}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="./{{.Href}}{{if .Dir}}/{{end}}">{{.Name}}{{if .Dir}}/{{end}}</a></td><td>{{if not .Dir}}{{.Size}}{{end}}</td><td>{{if not .ModTime.IsZero}}{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{- end}}
+</table>
+`))
@@ -0,0 +1,160 @@
+package multifshttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+func newServer(t *testing.T, opts Options) *httptest.Server {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mux := multifs.NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"index.html":  &fstest.MapFile{Data: []byte("<p>hi</p>"), ModTime: mtime},
+		"docs/a.json": &fstest.MapFile{Data: []byte(`{}`), ModTime: mtime},
+		"<b>.txt":     &fstest.MapFile{Data: []byte("escaped")},
+	})
+	srv := httptest.NewServer(Handler(mux, opts))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string, header ...string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestHandlerFiles(t *testing.T) {
+	srv := newServer(t, Options{})
+
+	resp, body := get(t, srv.URL+"/snap/docs/a.json")
+	if resp.StatusCode != http.StatusOK || body != "{}" {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Fatalf("Last-Modified: got %q", lm)
+	}
+
+	if resp, _ := get(t, srv.URL+"/snap/missing"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing file: got %d", resp.StatusCode)
+	}
+	if resp, _ := get(t, srv.URL+"/snap/../../etc/passwd"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("escaping path: got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerListings(t *testing.T) {
+	srv := newServer(t, Options{})
+
+	resp, body := get(t, srv.URL+"/")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `<a href="./snap/">snap/</a>`) {
+		t.Fatalf("root listing: %d\n%s", resp.StatusCode, body)
+	}
+	if strings.Contains(body, `href="../"`) {
+		t.Fatalf("root listing links to its parent:\n%s", body)
+	}
+	if !strings.Contains(body, "<title>Index of /</title>") {
+		t.Fatalf("root listing title:\n%s", body)
+	}
+
+	_, body = get(t, srv.URL+"/snap/")
+	if !strings.Contains(body, "&lt;b&gt;.txt") || strings.Contains(body, "<b>.txt") {
+		t.Fatalf("names are not escaped:\n%s", body)
+	}
+
+	resp, body = get(t, srv.URL+"/snap/", "Accept", "application/json")
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+	var entries []Entry
+	if err := json.Unmarshal([]byte(body), &entries); err != nil {
+		t.Fatalf("Unmarshal: %v\n%s", err, body)
+	}
+	if len(entries) != 3 || entries[1].Name != "docs" || !entries[1].Dir {
+		t.Fatalf("entries: %+v", entries)
+	}
+
+	// Directories are redirected to their canonical, slashed URL.
+	resp, _ = get(t, srv.URL+"/snap/docs?format=json")
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.String() != srv.URL+"/snap/docs/?format=json" {
+		t.Fatalf("redirect: %d %s", resp.StatusCode, resp.Request.URL)
+	}
+}
+
+func TestHandlerNoListing(t *testing.T) {
+	srv := newServer(t, Options{NoListing: true})
+	if resp, _ := get(t, srv.URL+"/snap/"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerListingTitle(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount(".config", fstest.MapFS{"a": &fstest.MapFile{}})
+	srv := httptest.NewServer(Handler(mux, Options{}))
+	defer srv.Close()
+
+	if _, body := get(t, srv.URL+"/.config/"); !strings.Contains(body, "<title>Index of /.config</title>") {
+		t.Fatalf("listing title:\n%s", body)
+	}
+}
+
+func TestHandlerListingLinks(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"a#b":      &fstest.MapFile{Data: []byte("hash")},
+		"50%":      &fstest.MapFile{Data: []byte("percent")},
+		"why?":     &fstest.MapFile{Data: []byte("question")},
+		"d#1/file": &fstest.MapFile{Data: []byte("nested")},
+		"e?2/file": &fstest.MapFile{Data: []byte("nested")},
+		"f%3/file": &fstest.MapFile{Data: []byte("nested")},
+	})
+	srv := httptest.NewServer(Handler(mux, Options{}))
+	defer srv.Close()
+
+	_, body := get(t, srv.URL+"/snap/")
+	hrefs := regexp.MustCompile(`href="\./([^"]*)"`).FindAllStringSubmatch(body, -1)
+	want := map[string]string{"a%23b": "hash", "50%25": "percent", "why%3F": "question", "d%231/": "", "e%3F2/": "", "f%253/": ""}
+	if len(hrefs) != len(want) {
+		t.Fatalf("links: %q\n%s", hrefs, body)
+	}
+	for _, href := range hrefs {
+		data, ok := want[href[1]]
+		if !ok {
+			t.Fatalf("unexpected link %q\n%s", href[1], body)
+		}
+		resp, got := get(t, srv.URL+"/snap/"+href[1])
+		if resp.StatusCode != http.StatusOK || (data != "" && got != data) {
+			t.Fatalf("following %q: %d %q", href[1], resp.StatusCode, got)
+		}
+	}
+
+	// Directories are redirected to their escaped name
+	for _, dir := range []string{"d%231", "e%3F2", "f%253"} {
+		resp, _ := get(t, srv.URL+"/snap/"+dir)
+		if resp.StatusCode != http.StatusOK || resp.Request.URL.String() != srv.URL+"/snap/"+dir+"/" {
+			t.Fatalf("redirect of %q: %d %s", dir, resp.StatusCode, resp.Request.URL)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package multifssftp serves a MultiFS through github.com/pkg/sftp's
+// request server, so that sftp tooling can browse mounted filesystems.
+// ServeConn runs it as the sftp subsystem of an SSH server, which
+// authenticates users by public key.
+package multifssftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/pkg/sftp"
+)
+
+// Handlers returns read-only sftp request handlers for mux, to pass to
+// sftp.NewRequestServer. Writes and other commands are denied.
+func Handlers(mux *multifs.MultiFS) sftp.Handlers {
+	return handlers(mux, "")
+}
+
+// handlers returns the handlers of a connection authenticated as who, if
+// not empty.
+func handlers(mux *multifs.MultiFS, who string) sftp.Handlers {
+	h := &handler{mux: mux, who: who}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+type handler struct {
+	mux *multifs.MultiFS
+	who string
+}
+
+var _ sftp.LstatFileLister = (*handler)(nil)
+var _ sftp.ReadlinkFileLister = (*handler)(nil)
+
+// fsName maps an sftp path, rooted at "/", to a MultiFS path.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// context returns ctx carrying the identity of the connection.
+func (h *handler) context(ctx context.Context) context.Context {
+	if h.who != "" {
+		ctx = multifs.WithIdentity(ctx, h.who)
+	}
+	return ctx
+}
+
+func (h *handler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	name := fsName(r.Filepath)
+	ctx := h.context(r.Context())
+	f, err := h.mux.OpenContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	return newReaderAt(f, func() (fs.File, error) { return h.mux.OpenContext(ctx, name) }), nil
+}
+
+func (h *handler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return nil, &fs.PathError{Op: "open", Path: r.Filepath, Err: fs.ErrPermission}
+}
+
+func (h *handler) Filecmd(r *sftp.Request) error {
+	return &fs.PathError{Op: strings.ToLower(r.Method), Path: r.Filepath, Err: fs.ErrPermission}
+}
+
+func (h *handler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	name := fsName(r.Filepath)
+	ctx := h.context(r.Context())
+	switch r.Method {
+	case "List":
+		entries, err := h.mux.ReadDirContext(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		infos := make(listerAt, 0, len(entries))
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	case "Stat":
+		info, err := h.mux.StatContext(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+	return nil, &fs.PathError{Op: strings.ToLower(r.Method), Path: r.Filepath, Err: errors.ErrUnsupported}
+}
+
+func (h *handler) Lstat(r *sftp.Request) (sftp.ListerAt, error) {
+	info, err := h.mux.Lstat(fsName(r.Filepath))
+	if err != nil {
+		return nil, err
+	}
+	return listerAt{info}, nil
+}
+
+func (h *handler) Readlink(name string) (string, error) {
+	return h.mux.ReadLinkContext(h.context(context.Background()), fsName(name))
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(out []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(out, l[offset:])
+	if n < len(out) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readerAt serves the concurrent, possibly out of order ReadAt calls of
+// sftp clients from any file: natively when it is an io.ReaderAt, by
+// seeking when it can, and otherwise by reading forward, reopening the
+// file to go back.
+type readerAt struct {
+	mu     sync.Mutex
+	f      fs.File
+	reopen func() (fs.File, error)
+	pos    int64
+}
+
+func newReaderAt(f fs.File, reopen func() (fs.File, error)) io.ReaderAt {
+	if ra, ok := f.(io.ReaderAt); ok {
+		return struct {
+			io.ReaderAt
+			io.Closer
+		}{ra, f}
+	}
+	return &readerAt{f: f, reopen: reopen}
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.seek(off); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.f, p)
+	r.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *readerAt) seek(off int64) error {
+	if off == r.pos {
+		return nil
+	}
+	if s, ok := r.f.(io.Seeker); ok {
+		pos, err := s.Seek(off, io.SeekStart)
+		r.pos = pos
+		return err
+	}
+	if off < r.pos {
+		f, err := r.reopen()
+		if err != nil {
+			return err
+		}
+		r.f.Close()
+		r.f, r.pos = f, 0
+	}
+	n, err := io.CopyN(io.Discard, r.f, off-r.pos)
+	r.pos += n
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+func (r *readerAt) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
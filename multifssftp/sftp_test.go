@@ -0,0 +1,74 @@
+package multifssftp
+
+import (
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/pkg/sftp"
+)
+
+func newClient(t *testing.T, mux *multifs.MultiFS) *sftp.Client {
+	t.Helper()
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	server := sftp.NewRequestServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{sr, sw}, Handlers(mux))
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(cr, cw)
+	if err != nil {
+		t.Fatalf("NewClientPipe: %v", err)
+	}
+	// The server goes first so the client's reader sees the pipe close
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return client
+}
+
+func TestSFTP(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"docs/a.txt": &fstest.MapFile{Data: []byte("hello sftp")},
+		"docs/link":  &fstest.MapFile{Data: []byte("a.txt"), Mode: os.ModeSymlink | 0o777},
+	})
+	mux.Mount("other", fstest.MapFS{})
+	client := newClient(t, mux)
+
+	infos, err := client.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir /: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name() != "other" || !infos[1].IsDir() {
+		t.Fatalf("root listing: %v", infos)
+	}
+
+	f, err := client.Open("/snap/docs/a.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "hello sftp" {
+		t.Fatalf("read: %q, %v", data, err)
+	}
+
+	if info, err := client.Lstat("/snap/docs/link"); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Lstat: %v, %v", info, err)
+	}
+	if target, err := client.ReadLink("/snap/docs/link"); err != nil || target != "a.txt" {
+		t.Fatalf("ReadLink: %q, %v", target, err)
+	}
+	if _, err := client.Stat("/snap/missing"); !os.IsNotExist(err) {
+		t.Fatalf("Stat missing: %v", err)
+	}
+	if _, err := client.Create("/snap/new.txt"); err == nil {
+		t.Fatal("Create succeeded on a read-only server")
+	}
+}
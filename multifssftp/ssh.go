@@ -0,0 +1,90 @@
+package multifssftp
+
+import (
+	"net"
+	"sync"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// PublicKeyAuth authenticates the users of an SSH server by public key. It
+// returns the identity their requests are served under, or an error to
+// reject the key.
+type PublicKeyAuth func(conn ssh.ConnMetadata, key ssh.PublicKey) (who string, err error)
+
+// identityExtension is the permission extension recording the identity of
+// an SSH connection.
+const identityExtension = "multifs-identity"
+
+// ServerConfig returns the configuration of an SSH server authenticating
+// its users with auth, for ServeConn. Host keys remain to be added with
+// its AddHostKey method.
+func ServerConfig(auth PublicKeyAuth) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			who, err := auth(conn, key)
+			if err != nil {
+				return nil, err
+			}
+			return &ssh.Permissions{Extensions: map[string]string{identityExtension: who}}, nil
+		},
+	}
+}
+
+// ServeConn runs an SSH server with config on c, serving the read-only
+// sftp subsystem of mux until the client disconnects. The requests of a
+// user authenticated through ServerConfig carry the identity returned by
+// its PublicKeyAuth, for the access functions and audit of mux to find
+// with multifs.Identity.
+func ServeConn(mux *multifs.MultiFS, c net.Conn, config *ssh.ServerConfig) error {
+	conn, chans, reqs, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	var who string
+	if conn.Permissions != nil {
+		who = conn.Permissions.Extensions[identityExtension]
+	}
+	var wg sync.WaitGroup
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveSession(ch, requests, handlers(mux, who))
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// serveSession serves sftp on a session channel once the client asks for
+// the subsystem. Other requests, shells and commands, are refused.
+func serveSession(ch ssh.Channel, requests <-chan *ssh.Request, h sftp.Handlers) {
+	defer ch.Close()
+	for req := range requests {
+		// The payload of a subsystem request is its length-prefixed name
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		server := sftp.NewRequestServer(ch, h)
+		server.Serve()
+		server.Close()
+		return
+	}
+}
@@ -0,0 +1,132 @@
+package multifssftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// dial connects to an SSH server serving mux with config, authenticating
+// with key.
+func dial(t *testing.T, mux *multifs.MultiFS, config *ssh.ServerConfig, key ssh.Signer) (*sftp.Client, error) {
+	t.Helper()
+	// Both ends of an SSH connection write first: net.Pipe would deadlock
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if sc, err := l.Accept(); err == nil {
+			ServeConn(mux, sc, config)
+			sc.Close()
+		}
+	}()
+	cc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cc.Close()
+		<-done
+	})
+
+	conn, chans, reqs, err := ssh.NewClientConn(cc, "pipe", &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(ssh.NewClient(conn, chans, reqs))
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, nil
+}
+
+func TestServeConn(t *testing.T) {
+	alice, mallory := newSigner(t), newSigner(t)
+	config := ServerConfig(func(conn ssh.ConnMetadata, key ssh.PublicKey) (string, error) {
+		if bytes.Equal(key.Marshal(), alice.PublicKey().Marshal()) {
+			return "alice", nil
+		}
+		return "", errors.New("unknown key")
+	})
+	config.AddHostKey(newSigner(t))
+
+	var (
+		mu  sync.Mutex
+		who []string
+	)
+	mux := multifs.NewMultiFS(multifs.WithAudit(multifs.AuditFunc(func(rec multifs.AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		who = append(who, rec.Who)
+	}), multifs.Identity))
+	onlyAlice := func(ctx context.Context, op multifs.Op, name string) error {
+		if multifs.Identity(ctx) != "alice" {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+	mux.Mount("snap", fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("hello alice")}}, multifs.WithAccessFunc(onlyAlice))
+
+	if _, err := dial(t, mux, config, mallory); err == nil {
+		t.Fatal("unknown key accepted")
+	}
+
+	client, err := dial(t, mux, config, alice)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	f, err := client.Open("/snap/a.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "hello alice" {
+		t.Fatalf("read: %q, %v", data, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(who) == 0 || who[0] != "alice" {
+		t.Fatalf("audit: %q", who)
+	}
+
+	// Without a connection identity, the access function denies reads
+	anonymous := newClient(t, mux)
+	if _, err := anonymous.Open("/snap/a.txt"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("anonymous Open: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package multifstest
+
+import (
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+// Check tests the tree composed on m with fstest.TestFS, expecting to find
+// at least the expected files, named from the synthetic root as in
+// "id/path". It runs on a stable view of m, so mounts changing meanwhile
+// do not produce spurious failures.
+func Check(m *multifs.MultiFS, expected ...string) error {
+	return fstest.TestFS(m.Stable(), expected...)
+}
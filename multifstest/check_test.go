@@ -0,0 +1,75 @@
+package multifstest
+
+import (
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+func TestCheck(t *testing.T) {
+	files := func(names ...string) fstest.MapFS {
+		fsys := fstest.MapFS{}
+		for _, name := range names {
+			fsys[name] = &fstest.MapFile{Data: []byte(name)}
+		}
+		return fsys
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(m *multifs.MultiFS)
+		opts     []multifs.Option
+		expected []string
+	}{
+		{
+			name: "mounts",
+			setup: func(m *multifs.MultiFS) {
+				m.Mount("b", files("x/y.txt", "z"))
+				m.Mount("a", files("f"))
+				m.Mount("empty", fstest.MapFS{})
+			},
+			expected: []string{"a/f", "b/x/y.txt", "b/z"},
+		},
+		{
+			name: "root mount",
+			setup: func(m *multifs.MultiFS) {
+				m.MountRoot(files("root.txt", "dir/q"))
+				m.Mount("a", files("f"))
+			},
+			expected: []string{"a/f", "dir/q", "root.txt"},
+		},
+		{
+			name: "case insensitive",
+			setup: func(m *multifs.MultiFS) {
+				m.Mount("Upper", files("f"))
+			},
+			opts:     []multifs.Option{multifs.WithCaseInsensitive()},
+			expected: []string{"Upper/f"},
+		},
+		{
+			name: "subtrees",
+			setup: func(m *multifs.MultiFS) {
+				m.Mount("s", files("keep/a", "drop/b"), multifs.WithSubtrees("keep"))
+			},
+			expected: []string{"s/keep/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := multifs.NewMultiFS(tt.opts...)
+			tt.setup(m)
+			if err := Check(m, tt.expected...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCheckMissing(t *testing.T) {
+	m := multifs.NewMultiFS()
+	m.Mount("a", fstest.MapFS{"f": &fstest.MapFile{}})
+	if err := Check(m, "a/missing"); err == nil {
+		t.Fatal("Check succeeded with a missing file")
+	}
+}
@@ -0,0 +1,173 @@
+// Package multifstest provides helpers for testing code built on multifs,
+// and multifs itself.
+package multifstest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"testing"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+type StressConfig struct {
+	// Duration is how long the mount table is churned. The default is one
+	// second.
+	Duration time.Duration
+
+	// Readers is the number of goroutines reading concurrently. The
+	// default is 8.
+	Readers int
+
+	// IDs are the mount ids to churn. The default is a, b and c.
+	IDs []string
+
+	// Backends are the filesystems mounted under the ids, at least one.
+	// They should not change during the run.
+	Backends []fs.FS
+}
+
+// Stress mounts, replaces and unmounts cfg.Backends under cfg.IDs on m
+// while other goroutines open, read, list and walk it, and reports through
+// t any broken guarantee:
+//
+//   - no operation panics;
+//   - operations only fail with errors matching fs.ErrNotExist, or
+//     multifs.ErrMountBusy for Unmount;
+//   - a file, once opened, reads to the end and closes without error,
+//     whatever happens to its mount meanwhile.
+func Stress(t testing.TB, m *multifs.MultiFS, cfg StressConfig) {
+	t.Helper()
+	if len(cfg.Backends) == 0 {
+		t.Fatal("multifstest: no backends to mount")
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = time.Second
+	}
+	if cfg.Readers <= 0 {
+		cfg.Readers = 8
+	}
+	if len(cfg.IDs) == 0 {
+		cfg.IDs = []string{"a", "b", "c"}
+	}
+
+	var files []string
+	for _, b := range cfg.Backends {
+		fs.WalkDir(b, ".", func(name string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				files = append(files, name)
+			}
+			return nil
+		})
+	}
+	if len(files) == 0 {
+		t.Fatal("multifstest: backends hold no regular files")
+	}
+
+	s := &stress{t: t, m: m, cfg: &cfg, files: files, done: make(chan struct{})}
+	var wg sync.WaitGroup
+	wg.Add(1 + cfg.Readers)
+	go s.run(&wg, s.churn)
+	for range cfg.Readers {
+		go s.run(&wg, s.read)
+	}
+	time.Sleep(cfg.Duration)
+	close(s.done)
+	wg.Wait()
+}
+
+type stress struct {
+	t     testing.TB
+	m     *multifs.MultiFS
+	cfg   *StressConfig
+	files []string
+	done  chan struct{}
+}
+
+func (s *stress) run(wg *sync.WaitGroup, step func(r *rand.Rand)) {
+	defer wg.Done()
+	defer func() {
+		if v := recover(); v != nil {
+			s.t.Errorf("multifstest: panic: %v\n%s", v, debug.Stack())
+		}
+	}()
+
+	r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		step(r)
+	}
+}
+
+func (s *stress) id(r *rand.Rand) string     { return s.cfg.IDs[r.IntN(len(s.cfg.IDs))] }
+func (s *stress) backend(r *rand.Rand) fs.FS { return s.cfg.Backends[r.IntN(len(s.cfg.Backends))] }
+
+func (s *stress) check(what string, err error, allowed ...error) {
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	for _, a := range allowed {
+		if errors.Is(err, a) {
+			return
+		}
+	}
+	s.t.Errorf("multifstest: %s: unexpected error %v", what, err)
+}
+
+func (s *stress) churn(r *rand.Rand) {
+	id := s.id(r)
+	switch r.IntN(3) {
+	case 0:
+		s.check("Mount "+id, s.m.Mount(id, s.backend(r)), fs.ErrExist)
+	case 1:
+		s.check("Replace "+id, s.m.Replace(id, s.backend(r)))
+	case 2:
+		s.check("Unmount "+id, s.m.Unmount(id), multifs.ErrMountBusy)
+	}
+	if r.IntN(4) == 0 {
+		time.Sleep(time.Duration(r.IntN(100)) * time.Microsecond)
+	}
+}
+
+func (s *stress) read(r *rand.Rand) {
+	name := s.id(r) + "/" + s.files[r.IntN(len(s.files))]
+	switch r.IntN(4) {
+	case 0, 1:
+		f, err := s.m.Open(name)
+		s.check("Open "+name, err)
+		if err != nil {
+			return
+		}
+		// Give the churner a chance to pull the mount from under the file.
+		time.Sleep(time.Duration(r.IntN(50)) * time.Microsecond)
+		if _, err := io.Copy(io.Discard, f); err != nil {
+			s.t.Errorf("multifstest: reading open file %s: %v", name, err)
+		}
+		if err := f.Close(); err != nil {
+			s.t.Errorf("multifstest: closing %s: %v", name, err)
+		}
+	case 2:
+		_, err := fs.ReadDir(s.m, ".")
+		s.check("ReadDir .", err)
+		id := s.id(r)
+		_, err = fs.ReadDir(s.m, id)
+		s.check("ReadDir "+id, err)
+	case 3:
+		id := s.id(r)
+		err := fs.WalkDir(s.m, id, func(name string, d fs.DirEntry, err error) error {
+			s.check(fmt.Sprintf("WalkDir %s at %s", id, name), err)
+			return nil
+		})
+		s.check("WalkDir "+id, err)
+	}
+}
@@ -0,0 +1,27 @@
+package multifstest
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+func TestStress(t *testing.T) {
+	backends := []fs.FS{
+		fstest.MapFS{
+			"a.txt":     &fstest.MapFile{Data: []byte("a")},
+			"dir/b.txt": &fstest.MapFile{Data: []byte("b")},
+		},
+		fstest.MapFS{
+			"dir/c.txt":   &fstest.MapFile{Data: []byte("c")},
+			"dir/d/e.txt": &fstest.MapFile{Data: []byte("e")},
+		},
+	}
+	Stress(t, multifs.NewMultiFS(), StressConfig{
+		Duration: 200 * time.Millisecond,
+		Backends: backends,
+	})
+}
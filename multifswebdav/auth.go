@@ -0,0 +1,34 @@
+package multifswebdav
+
+import (
+	"net/http"
+	"strconv"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+// Credentials authenticates WebDAV users by the user name and password of
+// HTTP basic authentication. It returns the identity their requests are
+// served under, or an error to reject them.
+type Credentials func(r *http.Request, user, password string) (who string, err error)
+
+// WithAuth wraps h, typically returned by Handler, so that every request
+// must authenticate with basic authentication, checked by auth. Requests
+// that do not are answered 401 Unauthorized, with realm. The others carry
+// the identity returned by auth in their context, for the access functions
+// and audit of the MultiFS to find with multifs.Identity.
+func WithAuth(h http.Handler, realm string, auth Credentials) http.Handler {
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if ok {
+			who, err := auth(r, user, password)
+			if err == nil {
+				h.ServeHTTP(w, r.WithContext(multifs.WithIdentity(r.Context(), who)))
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,69 @@
+package multifswebdav
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+func TestWithAuth(t *testing.T) {
+	var who []string
+	mux := multifs.NewMultiFS(multifs.WithAudit(multifs.AuditFunc(func(rec multifs.AuditRecord) {
+		who = append(who, rec.Who)
+	}), multifs.Identity))
+	onlyAlice := func(ctx context.Context, op multifs.Op, name string) error {
+		if multifs.Identity(ctx) != "alice" {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+	mux.Mount("snap", fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("hello")}}, multifs.WithAccessFunc(onlyAlice))
+
+	passwords := map[string]string{"alice": "secret", "bob": "hunter2"}
+	auth := func(r *http.Request, user, password string) (string, error) {
+		if p, ok := passwords[user]; ok && p == password {
+			return user, nil
+		}
+		return "", errors.New("bad credentials")
+	}
+	srv := httptest.NewServer(WithAuth(Handler(mux, ""), "multifs", auth))
+	defer srv.Close()
+
+	get := func(user, password string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/snap/a.txt", nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != `Basic realm="multifs", charset="UTF-8"` {
+			t.Fatalf("challenge: %q", resp.Header.Get("WWW-Authenticate"))
+		}
+		return resp.StatusCode
+	}
+	if code := get("", ""); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous: got %d", code)
+	}
+	if code := get("alice", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: got %d", code)
+	}
+	// webdav reports files that cannot be stat'ed as missing
+	if code := get("bob", "hunter2"); code != http.StatusNotFound {
+		t.Fatalf("bob: got %d", code)
+	}
+	if code := get("alice", "secret"); code != http.StatusOK {
+		t.Fatalf("alice: got %d", code)
+	}
+	if len(who) == 0 || who[len(who)-1] != "alice" {
+		t.Fatalf("audit: %q", who)
+	}
+}
@@ -0,0 +1,248 @@
+// Package multifswebdav serves a MultiFS over WebDAV, so that mounted
+// filesystems can be attached as network drives.
+package multifswebdav
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+	"golang.org/x/net/webdav"
+)
+
+// Handler returns a WebDAV handler for mux, serving it under prefix. Wrap
+// it with WithAuth to authenticate users.
+func Handler(mux *multifs.MultiFS, prefix string) *webdav.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: NewFileSystem(mux),
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// NewFileSystem exposes mux as a read-only webdav.FileSystem. The
+// synthetic root and the mount roots are directories like any other;
+// every write fails with os.ErrPermission.
+func NewFileSystem(mux *multifs.MultiFS) webdav.FileSystem {
+	return &fileSystem{mux: mux}
+}
+
+type fileSystem struct {
+	mux *multifs.MultiFS
+}
+
+// fsName maps a WebDAV name, rooted at "/", to a MultiFS path.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (d *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+}
+
+func (d *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+}
+
+func (d *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+}
+
+func (d *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return d.mux.StatContext(ctx, fsName(name))
+}
+
+func (d *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	name = fsName(name)
+	f, err := d.mux.OpenContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// The file outlives the request that opened it only for the duration
+	// of the WebDAV operation, so reopening it with its context is fine.
+	return &file{
+		File:   f,
+		mux:    d.mux,
+		name:   name,
+		reopen: func() (fs.File, error) { return d.mux.OpenContext(ctx, name) },
+		size:   -1,
+	}, nil
+}
+
+// file adapts an fs.File to webdav.File. Files that cannot seek are read
+// at a logical position, moved by seeking: with ReadAt when they have it,
+// and otherwise by an emulation whose reads catch up by skipping data, or
+// by reopening the file to go back.
+type file struct {
+	fs.File
+	mux    *multifs.MultiFS
+	name   string
+	reopen func() (fs.File, error)
+	pos    int64 // logical position
+	real   int64 // position of the underlying file
+	size   int64 // size of the contents once read to the end, or -1
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	switch r := f.File.(type) {
+	case io.Seeker:
+		return f.File.Read(p)
+	case io.ReaderAt:
+		n, err := r.ReadAt(p, f.pos)
+		f.pos += int64(n)
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+		return n, err
+	}
+	if f.pos != f.real {
+		if err := f.catchUp(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.File.Read(p)
+	f.pos += int64(n)
+	f.real += int64(n)
+	return n, err
+}
+
+func (f *file) catchUp() error {
+	if f.pos < f.real {
+		nf, err := f.reopen()
+		if err != nil {
+			return err
+		}
+		f.File.Close()
+		f.File, f.real = nf, 0
+	}
+	n, err := io.CopyN(io.Discard, f.File, f.pos-f.real)
+	f.real += n
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		size, err := f.end()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	}
+	if offset < 0 {
+		return 0, errors.New("multifswebdav: negative position")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// end returns the size of the contents of a file that cannot seek. Files
+// read sequentially are read to their end for it, once: the size reported
+// by Stat may not be that of the contents read, for transformed files.
+func (f *file) end() (int64, error) {
+	if _, ok := f.File.(io.ReaderAt); ok {
+		info, err := f.File.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	if f.size < 0 {
+		n, err := io.Copy(io.Discard, f.File)
+		f.real += n
+		if err != nil {
+			return 0, err
+		}
+		f.size = f.real
+	}
+	return f.size, nil
+}
+
+func (f *file) Readdir(count int) ([]fs.FileInfo, error) {
+	dir, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Err: errors.New("not a directory")}
+	}
+	entries, err := dir.ReadDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+// XattrNamespace is the XML namespace of the properties under which files
+// expose their extended attributes, with base64-encoded values.
+const XattrNamespace = "urn:x-multifs:xattr"
+
+// DeadProps reports the extended attributes of the file as properties in
+// XattrNamespace.
+func (f *file) DeadProps() (map[xml.Name]webdav.Property, error) {
+	attrs, err := f.mux.ListXattr(f.name)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[xml.Name]webdav.Property, len(attrs))
+	for _, attr := range attrs {
+		value, err := f.mux.GetXattr(f.name, attr)
+		if errors.Is(err, multifs.ErrNoXattr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := xml.Name{Space: XattrNamespace, Local: attr}
+		props[name] = webdav.Property{
+			XMLName:  name,
+			InnerXML: []byte(base64.StdEncoding.EncodeToString(value)),
+		}
+	}
+	return props, nil
+}
+
+// Patch refuses every change. The handler opens files for writing before
+// patching them, which already fails on this read-only filesystem.
+func (f *file) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	pstat := webdav.Propstat{Status: http.StatusForbidden}
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			pstat.Props = append(pstat.Props, webdav.Property{XMLName: prop.XMLName})
+		}
+	}
+	return []webdav.Propstat{pstat}, nil
+}
@@ -0,0 +1,199 @@
+package multifswebdav
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	multifs "github.com/PlakarKorp/go-multifs"
+)
+
+func newMux() *multifs.MultiFS {
+	mux := multifs.NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{
+		"docs/a.txt": &fstest.MapFile{Data: []byte("0123456789")},
+	})
+	return mux
+}
+
+func TestPropfindRoot(t *testing.T) {
+	srv := httptest.NewServer(Handler(newMux(), ""))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("PROPFIND", srv.URL+"/", nil)
+	req.Header.Set("Depth", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PROPFIND: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(string(body), "<D:href>/snap/</D:href>") {
+		t.Fatalf("got %d\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestRangeGet(t *testing.T) {
+	srv := httptest.NewServer(Handler(newMux(), ""))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/snap/docs/a.txt", nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "345" {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fsys := NewFileSystem(newMux())
+	ctx := context.Background()
+
+	if _, err := fsys.OpenFile(ctx, "/snap/new.txt", os.O_CREATE|os.O_WRONLY, 0o644); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("OpenFile for writing: got %v", err)
+	}
+	if err := fsys.Mkdir(ctx, "/snap/dir", 0o755); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Mkdir: got %v", err)
+	}
+	if err := fsys.RemoveAll(ctx, "/snap"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("RemoveAll: got %v", err)
+	}
+}
+
+func TestSeekEmulation(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	streamOnly := func(ctx context.Context, name string, f fs.File) (fs.File, error) {
+		return struct{ fs.File }{f}, nil
+	}
+	mux.Mount("s", fstest.MapFS{"f": &fstest.MapFile{Data: []byte("0123456789")}}, multifs.WithAfterOpen(streamOnly))
+
+	f, err := NewFileSystem(mux).OpenFile(context.Background(), "/s/f", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer f.Close()
+
+	if size, err := f.Seek(0, io.SeekEnd); err != nil || size != 10 {
+		t.Fatalf("Seek to end: %d, %v", size, err)
+	}
+	buf := make([]byte, 3)
+	for _, tc := range []struct {
+		off  int64
+		want string
+	}{{6, "678"}, {2, "234"}, {5, "567"}} {
+		f.Seek(tc.off, io.SeekStart)
+		if _, err := io.ReadFull(f, buf); err != nil || string(buf) != tc.want {
+			t.Fatalf("read at %d: %q, %v", tc.off, buf, err)
+		}
+	}
+}
+
+// xattrFS is a MapFS whose files all carry the attribute user.comment.
+type xattrFS struct {
+	fstest.MapFS
+}
+
+func (xattrFS) ListXattr(name string) ([]string, error) {
+	return []string{"user.comment"}, nil
+}
+
+func (xattrFS) GetXattr(name, attr string) ([]byte, error) {
+	return []byte("hello"), nil
+}
+
+func TestXattrProps(t *testing.T) {
+	mux := newMux()
+	mux.Mount("x", xattrFS{fstest.MapFS{"f": &fstest.MapFile{}}})
+	srv := httptest.NewServer(Handler(mux, ""))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("PROPFIND", srv.URL+"/x/f", nil)
+	req.Header.Set("Depth", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PROPFIND: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), XattrNamespace) || !strings.Contains(string(body), ">aGVsbG8=<") {
+		t.Fatalf("got %d\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestReadAt(t *testing.T) {
+	mux := multifs.NewMultiFS()
+	opens := 0
+	count := func(ctx context.Context, name string) error {
+		opens++
+		return nil
+	}
+	// Files read at an offset, without seeking, are not reopened to go back
+	readerAtOnly := func(ctx context.Context, name string, f fs.File) (fs.File, error) {
+		return struct {
+			fs.File
+			io.ReaderAt
+		}{f, f.(io.ReaderAt)}, nil
+	}
+	mux.Mount("s", fstest.MapFS{"f": &fstest.MapFile{Data: []byte("0123456789")}},
+		multifs.WithBeforeOpen(count), multifs.WithAfterOpen(readerAtOnly))
+
+	f, err := NewFileSystem(mux).OpenFile(context.Background(), "/s/f", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer f.Close()
+
+	if size, err := f.Seek(0, io.SeekEnd); err != nil || size != 10 {
+		t.Fatalf("Seek to end: %d, %v", size, err)
+	}
+	buf := make([]byte, 3)
+	for _, tc := range []struct {
+		off  int64
+		want string
+	}{{6, "678"}, {2, "234"}, {5, "567"}} {
+		f.Seek(tc.off, io.SeekStart)
+		if _, err := io.ReadFull(f, buf); err != nil || string(buf) != tc.want {
+			t.Fatalf("read at %d: %q, %v", tc.off, buf, err)
+		}
+	}
+	if opens != 1 {
+		t.Fatalf("file opened %d times", opens)
+	}
+}
+
+func TestSeekEndTransformed(t *testing.T) {
+	text := strings.Repeat("transformed contents ", 100)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(text))
+	w.Close()
+
+	mux := multifs.NewMultiFS()
+	mux.Mount("s", fstest.MapFS{"f.gz": &fstest.MapFile{Data: gz.Bytes()}}, multifs.WithTransform(multifs.Gunzip))
+	srv := httptest.NewServer(Handler(mux, ""))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/s/f.gz")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != text || resp.ContentLength != int64(len(text)) {
+		t.Fatalf("got %d, %d bytes of %d", resp.StatusCode, len(body), resp.ContentLength)
+	}
+}
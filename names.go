@@ -0,0 +1,179 @@
+package multifs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// NameEncoding translates the file names stored by a backend to UTF-8 and
+// back. Encode reports false for names that cannot be represented in the
+// backend's encoding.
+type NameEncoding interface {
+	Decode(name string) string
+	Encode(name string) (string, bool)
+}
+
+// WithNameEncoding declares the encoding used by the mounted filesystem for
+// its file names. Names are transcoded to UTF-8 in listings and Stat
+// results, and paths are mapped back to the backend's encoding on lookup.
+func WithNameEncoding(enc NameEncoding) MountOption {
+	return func(o *mountOptions) {
+		o.encoding = enc
+	}
+}
+
+// Latin1 is the ISO 8859-1 encoding, common in old Unix backups.
+var Latin1 NameEncoding = latin1{}
+
+type latin1 struct{}
+
+func (latin1) Decode(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+	for i := 0; i < len(name); i++ {
+		b.WriteRune(rune(name[i]))
+	}
+	return b.String()
+}
+
+func (latin1) Encode(name string) (string, bool) {
+	buf := make([]byte, 0, len(name))
+	for _, r := range name {
+		if r > 0xff {
+			return "", false
+		}
+		buf = append(buf, byte(r))
+	}
+	return string(buf), true
+}
+
+// ShiftJIS is the Shift JIS encoding of Japanese, common in archives made
+// on Windows. Bytes that are not valid Shift JIS decode to U+FFFD.
+var ShiftJIS NameEncoding = shiftJIS{}
+
+type shiftJIS struct{}
+
+func (shiftJIS) Decode(name string) string {
+	s, err := japanese.ShiftJIS.NewDecoder().String(name)
+	if err != nil {
+		return name
+	}
+	return s
+}
+
+func (shiftJIS) Encode(name string) (string, bool) {
+	s, err := japanese.ShiftJIS.NewEncoder().String(name)
+	return s, err == nil
+}
+
+// backendName maps a path inside the mount to the name used by the backend.
+func (mnt *mount) backendName(op, name string) (string, error) {
+	bname, err := mnt.rewritePath(op, name)
+	if err != nil {
+		return "", err
+	}
+	if enc := mnt.opts.encoding; enc != nil {
+		var ok bool
+		if bname, ok = enc.Encode(bname); !ok {
+			return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	if mnt.opts.prefix != "" {
+		bname = path.Join(mnt.opts.prefix, bname)
+	}
+	return bname, nil
+}
+
+// mountInfo renames info, stat'ed on the backend for name, as name is
+// known inside the mount.
+func (mnt *mount) mountInfo(name string, info fs.FileInfo) fs.FileInfo {
+	switch {
+	case mnt.opts.rewrite != nil && name != ".":
+		return renamedInfo{FileInfo: info, name: path.Base(name)}
+	case mnt.opts.encoding != nil:
+		return renamedInfo{FileInfo: info, name: mnt.opts.encoding.Decode(info.Name())}
+	}
+	return info
+}
+
+// renameFile wraps f so that the names it reports, through Stat and
+// ReadDir, are passed through rename.
+func renameFile(f fs.File, rename func(string) string) fs.File {
+	rf := renamedFile{File: f, rename: rename}
+	var dir readDirer
+	if d, ok := f.(fs.ReadDirFile); ok {
+		dir = renamedDir{renamedFile: rf, dir: d}
+	}
+	seeker, _ := f.(io.Seeker)
+	readerAt, _ := f.(io.ReaderAt)
+	writer, _ := f.(io.Writer)
+	return compose(rf, seeker, readerAt, writer, dir)
+}
+
+// namedRoot wraps the root directory of a mount so that Stat reports it
+// under id, as the synthetic root lists it.
+func namedRoot(f fs.File, id string) fs.File {
+	seeker, _ := f.(io.Seeker)
+	readerAt, _ := f.(io.ReaderAt)
+	writer, _ := f.(io.Writer)
+	dir, _ := f.(fs.ReadDirFile)
+	return compose(rootFile{File: f, id: id}, seeker, readerAt, writer, dir)
+}
+
+type rootFile struct {
+	fs.File
+	id string
+}
+
+func (f rootFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{FileInfo: info, name: f.id}, nil
+}
+
+type renamedFile struct {
+	fs.File
+	rename func(string) string
+}
+
+func (f renamedFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{FileInfo: info, name: f.rename(info.Name())}, nil
+}
+
+type renamedDir struct {
+	renamedFile
+	dir fs.ReadDirFile
+}
+
+func (d renamedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.dir.ReadDir(n)
+	for i, e := range entries {
+		entries[i] = renamedEntry{DirEntry: e, name: d.rename(e.Name())}
+	}
+	return entries, err
+}
+
+type renamedEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e renamedEntry) Name() string { return e.name }
+
+func (e renamedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{FileInfo: info, name: e.name}, nil
+}
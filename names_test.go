@@ -0,0 +1,113 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// encodedFS is a backend that stores its names in enc. fstest.MapFS
+// cannot be used directly since it rejects paths that are not UTF-8.
+type encodedFS struct {
+	files fstest.MapFS
+	enc   NameEncoding
+}
+
+func (e encodedFS) Open(name string) (fs.File, error) {
+	f, err := e.files.Open(e.enc.Decode(name))
+	if err != nil {
+		return nil, err
+	}
+	return renameFile(f, func(s string) string {
+		enc, _ := e.enc.Encode(s)
+		return enc
+	}), nil
+}
+
+func TestLatin1Names(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := encodedFS{fstest.MapFS{
+		"café/résumé.txt": &fstest.MapFile{Data: []byte("cv")},
+	}, Latin1}
+
+	// Without the encoding, names come out as they are stored
+	if err := mux.Mount("raw", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if entries, err := mux.ReadDir("raw"); err != nil || entries[0].Name() != "caf\xe9" {
+		t.Fatalf("unexpected raw listing: %v, %v", entries, err)
+	}
+
+	if err := mux.Mount("old", fs1, WithNameEncoding(Latin1)); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	entries, err := mux.ReadDir("old")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "café" {
+		t.Fatalf("unexpected listing: %v", entries)
+	}
+
+	data, err := fs.ReadFile(mux, "old/café/résumé.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "cv" {
+		t.Fatalf("unexpected data: %q", data)
+	}
+
+	info, err := mux.Stat("old/café/résumé.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Name() != "résumé.txt" {
+		t.Fatalf("Stat.Name: got %q", info.Name())
+	}
+
+	// Not representable in Latin-1
+	if _, err := mux.Stat("old/日本"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+
+	var walked []string
+	err = fs.WalkDir(mux, "old", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	if len(walked) != 3 || walked[2] != "old/café/résumé.txt" {
+		t.Fatalf("unexpected walk: %v", walked)
+	}
+}
+
+func TestShiftJISNames(t *testing.T) {
+	const name, stored = "日本語.txt", "\x93\xfa\x96\x7b\x8c\xea.txt"
+	if enc, ok := ShiftJIS.Encode(name); !ok || enc != stored {
+		t.Fatalf("Encode: %q, %v", enc, ok)
+	}
+	if dec := ShiftJIS.Decode(stored); dec != name {
+		t.Fatalf("Decode: %q", dec)
+	}
+	if _, ok := ShiftJIS.Encode("🙂"); ok {
+		t.Fatal("Encode of a name outside Shift JIS succeeded")
+	}
+
+	mux := NewMultiFS()
+	fs1 := encodedFS{fstest.MapFS{
+		"資料/" + name: &fstest.MapFile{Data: []byte("data")},
+	}, ShiftJIS}
+	if err := mux.Mount("jp", fs1, WithNameEncoding(ShiftJIS)); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if err := fstest.TestFS(mux, "jp/資料/"+name); err != nil {
+		t.Fatal(err)
+	}
+}
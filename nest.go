@@ -0,0 +1,82 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// A MultiFS may be mounted in another one. Paths below such a mount are
+// resolved directly against the inner table, rather than going through
+// the outer mount and the inner Open in turn, as long as the outer mount
+// has no options and the outer MultiFS neither observes nor changes the
+// files it opens: there is then no behavior to apply on the way. Usage of the outer mount does not account
+// for the accesses resolved this way; the inner mounts do.
+
+// flattens reports whether paths below mnt may be resolved against inner
+// directly.
+func (t *table) flattens(mnt *mount) (*MultiFS, bool) {
+	inner, ok := mnt.fsys.(*MultiFS)
+	if !ok || !mnt.opts.plain() {
+		return nil, false
+	}
+	o := t.opts
+	if o.metrics != nil || o.tracer != nil || o.logger != nil || o.audit != nil || o.seekable ||
+		o.rateLimit != nil {
+		return nil, false
+	}
+	return inner, true
+}
+
+// plain reports whether the options change nothing to the accesses
+// through the mount.
+func (o *mountOptions) plain() bool {
+	return o.subtrees == nil && o.beforeOpen == nil && o.afterOpen == nil &&
+		!o.foldCase && !o.readOnly && o.access == nil && o.quota == (Quota{}) &&
+		o.encoding == nil && o.statFuncs == nil && o.cache == nil && o.readAhead == nil &&
+		o.prefix == "" && o.rewrite == nil && o.transform == nil && o.decrypter == nil &&
+		o.integrity == nil && o.rateLimit == nil && o.retry == nil &&
+		o.timeouts == nil && o.replicas == nil && o.removeGuard == nil &&
+		o.mmapMin == 0
+}
+
+// resolveNested resolves subpath, below the mount root of inner, and
+// reports errors against the whole name.
+func resolveNested(inner *MultiFS, op, name, subpath string) (resolved, error) {
+	r, err := inner.resolve(op, subpath)
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = &fs.PathError{Op: op, Path: name, Err: pe.Err}
+	}
+	return r, err
+}
+
+// Flatten returns the mounts of m in root listing order, with the mounts
+// of any MultiFS mounted in m listed in its place, recursively, under ids
+// made of the path to them. A nested root mount is listed under the id of
+// the MultiFS holding it.
+func (m *MultiFS) Flatten() []MountInfo {
+	var infos []MountInfo
+	v := m.Stable()
+	defer v.Release()
+	v.tab.flatten("", &infos)
+	return infos
+}
+
+func (t *table) flatten(prefix string, infos *[]MountInfo) {
+	for _, id := range t.ids() {
+		mnt, _ := t.roots.get(id)
+		id = path.Join(prefix, id)
+		inner, ok := mnt.fsys.(*MultiFS)
+		if !ok {
+			*infos = append(*infos, mnt.info(id))
+			continue
+		}
+		v := inner.Stable()
+		if v.tab.fallback != nil {
+			*infos = append(*infos, v.tab.fallback.info(id))
+		}
+		v.tab.flatten(id, infos)
+		v.Release()
+	}
+}
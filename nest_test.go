@@ -0,0 +1,102 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newNested(t *testing.T, opts ...MountOption) *MultiFS {
+	t.Helper()
+	inner := NewMultiFS()
+	inner.MountRoot(fstest.MapFS{"root.txt": &fstest.MapFile{Data: []byte("root")}})
+	inner.Mount("a", fstest.MapFS{"f.txt": &fstest.MapFile{Data: []byte("a")}})
+	inner.Mount("b", fstest.MapFS{"g.txt": &fstest.MapFile{Data: []byte("b")}})
+
+	outer := NewMultiFS()
+	if err := outer.Mount("repo", inner, opts...); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	outer.Mount("other", fstest.MapFS{"h.txt": &fstest.MapFile{}})
+	return outer
+}
+
+func TestNestedFlattened(t *testing.T) {
+	outer := newNested(t)
+
+	data, err := fs.ReadFile(outer, "repo/a/f.txt")
+	if err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if usage, _ := outer.Usage("repo"); usage.Opens != 0 {
+		t.Fatalf("outer mount used by a flattened open: %+v", usage)
+	}
+	if _, err := outer.Stat("repo/a/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat missing: %v", err)
+	}
+	_, err = outer.Open("repo/a/../b")
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe.Path != "repo/a/../b" || !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Open invalid: %v", err)
+	}
+	if err := fstest.TestFS(outer, "repo/a/f.txt", "repo/b/g.txt", "repo/root.txt", "other/h.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNestedWithOptions(t *testing.T) {
+	outer := newNested(t, WithQuota(Quota{MaxOpenFiles: 10}))
+
+	data, err := fs.ReadFile(outer, "repo/b/g.txt")
+	if err != nil || string(data) != "b" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if usage, _ := outer.Usage("repo"); usage.Opens != 1 {
+		t.Fatalf("outer mount options bypassed: %+v", usage)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	outer := newNested(t)
+
+	var ids []string
+	for _, info := range outer.Flatten() {
+		ids = append(ids, info.ID)
+	}
+	if got := strings.Join(ids, ","); got != "other,repo,repo/a,repo/b" {
+		t.Fatalf("Flatten: got %s", got)
+	}
+}
+
+func TestNestedSeekable(t *testing.T) {
+	inner := NewMultiFS()
+	inner.Mount("x", streamFS{MapFS: fstest.MapFS{"a": &fstest.MapFile{Data: []byte("a")}}})
+	outer := NewMultiFS(WithSeekableReads())
+	outer.Mount("in", inner)
+
+	f, err := outer.Open("in/x/a")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if _, ok := f.(io.Seeker); !ok {
+		t.Fatal("nested file is not an io.Seeker")
+	}
+}
+
+func TestNestedGlobalRateLimit(t *testing.T) {
+	inner := NewMultiFS()
+	inner.Mount("x", fstest.MapFS{"a": &fstest.MapFile{Data: []byte("a")}})
+	outer := NewMultiFS(WithGlobalRateLimit(1<<20, 1<<20))
+	outer.Mount("in", inner)
+
+	if _, err := fs.ReadFile(outer, "in/x/a"); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if usage, _ := outer.Usage("in"); usage.BytesRead != 1 {
+		t.Fatalf("read bypassed the outer mount and its limit: %+v", usage)
+	}
+}
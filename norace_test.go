@@ -0,0 +1,5 @@
+//go:build !race
+
+package multifs
+
+const raceEnabled = false
@@ -0,0 +1,89 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type Option func(*options)
+
+type options struct {
+	compare      func(a, b string) int
+	verify       *VerifyConfig
+	fold         bool
+	dir          dirInfo
+	pollInterval time.Duration
+	metrics      []func(Observation)
+	tracer       Tracer
+	logger       *slog.Logger
+	slow         time.Duration
+	audit        AuditSink
+	auditWho     func(ctx context.Context) string
+	collision    CollisionPolicy
+	leakAge      time.Duration
+	synthetic    bool
+	rateLimit    *rateLimit
+	healthEvery  time.Duration
+	crossLinks   bool
+	seekable     bool
+}
+
+func defaultOptions() *options {
+	return &options{
+		compare:      strings.Compare,
+		dir:          dirInfo{name: ".", perm: 0o555},
+		pollInterval: 2 * time.Second,
+		slow:         time.Second,
+	}
+}
+
+// WithRootOrder sets the order in which mount ids are listed by the
+// synthetic root directory. The default is lexical order, which is what
+// fs.WalkDir and fstest expect.
+func WithRootOrder(compare func(a, b string) int) Option {
+	return func(o *options) {
+		if compare != nil {
+			o.compare = compare
+		}
+	}
+}
+
+// WithCaseInsensitive makes mount ids resolve regardless of case. Ids that
+// only differ by case cannot be mounted side by side: Mount fails with an
+// error wrapping fs.ErrExist. Paths inside mounts are left to the backend
+// unless the mount is created with WithCaseInsensitivePaths.
+func WithCaseInsensitive() Option {
+	return func(o *options) {
+		o.fold = true
+	}
+}
+
+// SyntheticDir describes the directories MultiFS makes up itself: the root
+// and, as listed by the root, the mount points. Some exports need specific
+// permissions or ownership on them to be usable by non-root clients.
+// Without WithSyntheticDir, mount points are listed with the attributes of
+// the root of their filesystem.
+type SyntheticDir struct {
+	// Name is the name reported by Stat on the root, "." by default.
+	Name string
+	// Perm holds the permission bits, applied as they are: 0 denies all
+	// access. Without WithSyntheticDir, the root has 0555.
+	Perm    fs.FileMode
+	ModTime time.Time
+	Sys     any
+}
+
+func WithSyntheticDir(d SyntheticDir) Option {
+	return func(o *options) {
+		if d.Name != "" {
+			o.dir.name = d.Name
+		}
+		o.dir.perm = d.Perm.Perm()
+		o.dir.modTime = d.ModTime
+		o.dir.sys = d.Sys
+		o.synthetic = true
+	}
+}
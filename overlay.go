@@ -0,0 +1,455 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// whiteoutPrefix marks, in the upper layer, a name deleted from the lower
+// layer. A whiteout keeps hiding the lower entry, and everything below it,
+// even once the name is created again in the upper layer.
+const whiteoutPrefix = ".wh."
+
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+var errNotEmpty = errors.New("directory not empty")
+
+// OverlayFS stacks a writable upper layer on top of a read-only lower one.
+// Reads see the upper layer first; files are copied up from the lower layer
+// on their first write, and deletions of lower entries are recorded as
+// whiteouts in the upper layer.
+type OverlayFS struct {
+	lower fs.FS
+	upper WritableFS
+}
+
+var _ WritableFS = (*OverlayFS)(nil)
+var _ fs.StatFS = (*OverlayFS)(nil)
+var _ fs.ReadDirFS = (*OverlayFS)(nil)
+
+func NewOverlayFS(lower fs.FS, upper WritableFS) *OverlayFS {
+	return &OverlayFS{lower: lower, upper: upper}
+}
+
+func (o *OverlayFS) Open(name string) (fs.File, error) {
+	if err := checkOverlayPath("open", name); err != nil {
+		return nil, err
+	}
+
+	info, err := fs.Stat(o.upper, name)
+	switch {
+	case err == nil && info.IsDir():
+		return o.openDir(name, info)
+	case err == nil:
+		return o.upper.Open(name)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	if o.masked(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.lower.Open(name)
+}
+
+func (o *OverlayFS) Stat(name string) (fs.FileInfo, error) {
+	if err := checkOverlayPath("stat", name); err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(o.upper, name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return info, err
+	}
+	if o.masked(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(o.lower, name)
+}
+
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := o.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return dir.ReadDir(-1)
+}
+
+func (o *OverlayFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := checkOverlayPath("open", name); err != nil {
+		return nil, err
+	}
+	if flag&writeFlags == 0 {
+		// Directories are merged, whichever layer holds them
+		if info, err := fs.Stat(o.upper, name); err == nil && !info.IsDir() {
+			return o.upper.OpenFile(name, flag, perm)
+		}
+		f, err := o.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return readOnlyHandleOf(f, name), nil
+	}
+
+	info, err := o.Stat(name)
+	exists := err == nil
+	switch {
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	case exists && info.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case exists && flag&os.O_EXCL != 0 && flag&os.O_CREATE != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if err := o.copyUpParents(name); err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := o.copyUp(name, info, flag&os.O_TRUNC != 0); err != nil {
+			return nil, err
+		}
+	}
+	return o.upper.OpenFile(name, flag, perm)
+}
+
+func (o *OverlayFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := checkOverlayPath("mkdir", name); err != nil {
+		return err
+	}
+	if _, err := o.Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := o.copyUpParents(name); err != nil {
+		return err
+	}
+	return o.upper.Mkdir(name, perm)
+}
+
+func (o *OverlayFS) Remove(name string) error {
+	if err := checkOverlayPath("remove", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+		}
+	}
+
+	if _, err := fs.Stat(o.upper, name); err == nil {
+		if info.IsDir() {
+			if err := o.dropWhiteouts(name); err != nil {
+				return err
+			}
+		}
+		if err := o.upper.Remove(name); err != nil {
+			return err
+		}
+	}
+	if o.inLower(name) {
+		return o.whiteout(name)
+	}
+	return nil
+}
+
+// Rename moves a file, copying it up first if needed. Directories can only
+// be renamed when they live in the upper layer alone, on both ends.
+func (o *OverlayFS) Rename(oldname, newname string) error {
+	if err := checkOverlayPath("rename", oldname); err != nil {
+		return err
+	}
+	if err := checkOverlayPath("rename", newname); err != nil {
+		return err
+	}
+	info, err := o.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && (o.inLower(oldname) || o.inLower(newname)) {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: errors.ErrUnsupported}
+	}
+	if dst, err := o.Stat(newname); err == nil && dst.IsDir() {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+
+	if err := o.copyUpParents(oldname); err != nil {
+		return err
+	}
+	if err := o.copyUp(oldname, info, false); err != nil {
+		return err
+	}
+	if err := o.copyUpParents(newname); err != nil {
+		return err
+	}
+	if err := o.upper.Rename(oldname, newname); err != nil {
+		return err
+	}
+	if o.inLower(oldname) {
+		return o.whiteout(oldname)
+	}
+	return nil
+}
+
+// masked reports whether name is hidden from the lower layer, by a
+// whiteout on itself or one of its ancestors, or by an ancestor replaced
+// with a file in the upper layer.
+func (o *OverlayFS) masked(name string) bool {
+	if name == "." {
+		return false
+	}
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		p := path.Join(elems[:i+1]...)
+		if _, err := fs.Stat(o.upper, whiteoutName(p)); err == nil {
+			return true
+		}
+		if i < len(elems)-1 {
+			if info, err := fs.Stat(o.upper, p); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o *OverlayFS) inLower(name string) bool {
+	if o.masked(name) {
+		return false
+	}
+	_, err := fs.Stat(o.lower, name)
+	return err == nil
+}
+
+func (o *OverlayFS) openDir(name string, info fs.FileInfo) (fs.File, error) {
+	upper, err := fs.ReadDir(o.upper, name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(upper))
+	entries := make([]fs.DirEntry, 0, len(upper))
+	for _, e := range upper {
+		if strings.HasPrefix(e.Name(), whiteoutPrefix) {
+			seen[strings.TrimPrefix(e.Name(), whiteoutPrefix)] = struct{}{}
+			continue
+		}
+		seen[e.Name()] = struct{}{}
+		entries = append(entries, e)
+	}
+
+	if !o.masked(name) {
+		lower, err := fs.ReadDir(o.lower, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, e := range lower {
+			if _, ok := seen[e.Name()]; !ok {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return &staticDir{info: info, entries: entries}, nil
+}
+
+// copyUpParents makes sure the directories leading to name exist in the
+// upper layer, creating them with the permissions they have below.
+func (o *OverlayFS) copyUpParents(name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	if info, err := fs.Stat(o.upper, dir); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+
+	info, err := o.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+	}
+	if err := o.copyUpParents(dir); err != nil {
+		return err
+	}
+	return o.upper.Mkdir(dir, info.Mode().Perm())
+}
+
+// copyUp copies a lower file into the upper layer unless it already is
+// there. With truncate set, only an empty file is created.
+func (o *OverlayFS) copyUp(name string, info fs.FileInfo, truncate bool) error {
+	if _, err := fs.Stat(o.upper, name); err == nil {
+		return nil
+	}
+	if info.IsDir() {
+		return o.upper.Mkdir(name, info.Mode().Perm())
+	}
+
+	dst, err := o.upper.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if !truncate {
+		src, err := o.lower.Open(name)
+		if err != nil {
+			dst.Close()
+			return err
+		}
+		err = copySparse(dst, src)
+		src.Close()
+		if err != nil {
+			dst.Close()
+			return err
+		}
+	}
+	return dst.Close()
+}
+
+func (o *OverlayFS) whiteout(name string) error {
+	if err := o.copyUpParents(name); err != nil {
+		return err
+	}
+	f, err := o.upper.OpenFile(whiteoutName(name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// dropWhiteouts removes the whiteouts held by an upper directory about to
+// be removed.
+func (o *OverlayFS) dropWhiteouts(dir string) error {
+	entries, err := fs.ReadDir(o.upper, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), whiteoutPrefix) {
+			if err := o.upper.Remove(path.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func whiteoutName(name string) string {
+	return path.Join(path.Dir(name), whiteoutPrefix+path.Base(name))
+}
+
+func checkOverlayPath(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, whiteoutPrefix) {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	return nil
+}
+
+// staticDir is a directory whose entries are known when it is opened.
+type staticDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+func (d *staticDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *staticDir) Close() error               { return nil }
+
+func (d *staticDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *staticDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	left := len(d.entries) - d.pos
+	if n > 0 && left == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > left {
+		n = left
+	}
+	entries := d.entries[d.pos : d.pos+n : d.pos+n]
+	d.pos += n
+	return entries, nil
+}
+
+// readOnlyHandle adapts a file opened for reading to the File interface.
+type readOnlyHandle struct {
+	fs.File
+	name string
+}
+
+// readOnlyHandleOf returns f as a readOnlyHandle that keeps its ReadDir and
+// ReadAt.
+func readOnlyHandleOf(f fs.File, name string) File {
+	h := readOnlyHandle{File: f, name: name}
+	dir, _ := f.(fs.ReadDirFile)
+	readerAt, _ := f.(io.ReaderAt)
+	switch {
+	case dir != nil && readerAt != nil:
+		return struct {
+			readOnlyHandle
+			readDirer
+			io.ReaderAt
+		}{h, dir, readerAt}
+	case dir != nil:
+		return struct {
+			readOnlyHandle
+			readDirer
+		}{h, dir}
+	case readerAt != nil:
+		return struct {
+			readOnlyHandle
+			io.ReaderAt
+		}{h, readerAt}
+	}
+	return h
+}
+
+func (h readOnlyHandle) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: h.name, Err: fs.ErrPermission}
+}
+
+func (h readOnlyHandle) Truncate(int64) error {
+	return &fs.PathError{Op: "truncate", Path: h.name, Err: fs.ErrPermission}
+}
+
+func (h readOnlyHandle) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := h.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, &fs.PathError{Op: "seek", Path: h.name, Err: errors.ErrUnsupported}
+}
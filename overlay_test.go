@@ -0,0 +1,199 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// dirFS is a WritableFS over a directory of the host, for tests.
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func newDirFS(t *testing.T) dirFS {
+	dir := t.TempDir()
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+func (d dirFS) path(name string) string { return filepath.Join(d.dir, filepath.FromSlash(name)) }
+
+func (d dirFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return os.OpenFile(d.path(name), flag, perm)
+}
+
+func (d dirFS) Mkdir(name string, perm fs.FileMode) error { return os.Mkdir(d.path(name), perm) }
+func (d dirFS) Remove(name string) error                  { return os.Remove(d.path(name)) }
+func (d dirFS) Rename(oldname, newname string) error {
+	return os.Rename(d.path(oldname), d.path(newname))
+}
+
+func (d dirFS) Symlink(oldname, newname string) error  { return os.Symlink(oldname, d.path(newname)) }
+func (d dirFS) ReadLink(name string) (string, error)   { return os.Readlink(d.path(name)) }
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(d.path(name)) }
+func (d dirFS) Link(oldname, newname string) error {
+	return os.Link(d.path(oldname), d.path(newname))
+}
+func (d dirFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(d.path(name), mode) }
+func (d dirFS) Chown(name string, uid, gid int) error     { return os.Chown(d.path(name), uid, gid) }
+func (d dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(d.path(name), atime, mtime)
+}
+
+func writeFile(t *testing.T, fsys OpenFileFS, name, data string) {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile %s: %v", name, err)
+	}
+	if _, err := io.WriteString(f, data); err != nil {
+		t.Fatalf("Write %s: %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close %s: %v", name, err)
+	}
+}
+
+func listNames(t *testing.T, fsys fs.FS, name string) string {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		t.Fatalf("ReadDir %s: %v", name, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func newTestOverlay(t *testing.T) (*OverlayFS, dirFS) {
+	lower := fstest.MapFS{
+		"etc/hosts":    &fstest.MapFile{Data: []byte("lower hosts"), Mode: 0o644},
+		"etc/passwd":   &fstest.MapFile{Data: []byte("lower passwd"), Mode: 0o644},
+		"home/u/a.txt": &fstest.MapFile{Data: []byte("a"), Mode: 0o644},
+	}
+	upper := newDirFS(t)
+	return NewOverlayFS(lower, upper), upper
+}
+
+func TestOverlayCopyUp(t *testing.T) {
+	o, upper := newTestOverlay(t)
+
+	f, err := o.OpenFile("etc/hosts", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := io.WriteString(f, " + upper"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	f.Close()
+
+	data, err := fs.ReadFile(o, "etc/hosts")
+	if err != nil || string(data) != "lower hosts + upper" {
+		t.Fatalf("ReadFile after copy-up: %q, %v", data, err)
+	}
+	if _, err := fs.Stat(upper, "etc/hosts"); err != nil {
+		t.Fatalf("file was not copied up: %v", err)
+	}
+	if _, err := fs.Stat(upper, "etc/passwd"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("untouched sibling was copied up: %v", err)
+	}
+
+	writeFile(t, o, "home/u/new.txt", "new")
+	if got := listNames(t, o, "home/u"); got != "a.txt,new.txt" {
+		t.Fatalf("merged listing: got %s", got)
+	}
+
+	// Directories opened for reading are merged too
+	d, err := o.OpenFile("home/u", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer d.Close()
+	dir, ok := d.(fs.ReadDirFile)
+	if !ok {
+		t.Fatal("directory opened for reading cannot ReadDir")
+	}
+	if entries, err := dir.ReadDir(-1); err != nil || len(entries) != 2 {
+		t.Fatalf("ReadDir: %v, %v", entries, err)
+	}
+}
+
+func TestOverlayWhiteouts(t *testing.T) {
+	o, _ := newTestOverlay(t)
+
+	if err := o.Remove("etc/passwd"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := o.Stat("etc/passwd"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist after Remove, got %v", err)
+	}
+	if got := listNames(t, o, "etc"); got != "hosts" {
+		t.Fatalf("listing after Remove: got %s", got)
+	}
+
+	// Removing a non-empty directory fails, an emptied one can go
+	if err := o.Remove("home/u"); err == nil {
+		t.Fatalf("expected error removing non-empty directory")
+	}
+	if err := o.Remove("home/u/a.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := o.Remove("home/u"); err != nil {
+		t.Fatalf("Remove emptied dir: %v", err)
+	}
+	if got := listNames(t, o, "home"); got != "" {
+		t.Fatalf("listing after removing dir: got %q", got)
+	}
+
+	// A directory recreated over a whiteout does not resurrect lower content
+	if err := o.Mkdir("home/u", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if got := listNames(t, o, "home/u"); got != "" {
+		t.Fatalf("recreated dir shows lower content: %q", got)
+	}
+
+	if _, err := o.OpenFile(".wh.x", os.O_CREATE|os.O_WRONLY, 0o644); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for reserved name, got %v", err)
+	}
+}
+
+func TestOverlayRename(t *testing.T) {
+	o, _ := newTestOverlay(t)
+
+	if err := o.Rename("etc/hosts", "etc/hosts.bak"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := o.Stat("etc/hosts"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("old name still visible: %v", err)
+	}
+	data, err := fs.ReadFile(o, "etc/hosts.bak")
+	if err != nil || string(data) != "lower hosts" {
+		t.Fatalf("ReadFile renamed: %q, %v", data, err)
+	}
+
+	if err := o.Rename("home/u", "home/v"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported renaming a lower directory, got %v", err)
+	}
+}
+
+func TestOverlayAsMount(t *testing.T) {
+	o, _ := newTestOverlay(t)
+	mux := NewMultiFS()
+	if err := mux.Mount("work", o); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	writeFile(t, o, "scratch.txt", "scratch")
+	if got := listNames(t, mux, "work"); got != "etc,home,scratch.txt" {
+		t.Fatalf("listing through MultiFS: got %s", got)
+	}
+}
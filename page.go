@@ -0,0 +1,69 @@
+package multifs
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// pageChunk is how many entries ReadDirPage reads at once.
+const pageChunk = 1024
+
+// ReadDirPage returns up to limit entries of the directory name, all of
+// them if limit is not positive, following those of the page cursor comes
+// from: an empty cursor starts from the first entry. nextCursor is empty
+// once the last entry was returned. No handle is kept between pages, so
+// each page reads the whole directory, holding only limit entries at a
+// time; entries added or removed meanwhile may show up or not. Entries are
+// in the order of ReadDir.
+func (m *MultiFS) ReadDirPage(name string, cursor string, limit int) (entries []fs.DirEntry, nextCursor string, err error) {
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, "", &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	compare := strings.Compare
+	if name == "." {
+		compare = m.opts.compare
+	}
+	byName := func(a, b fs.DirEntry) int { return compare(a.Name(), b.Name()) }
+
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, "", &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	// One entry more than a page is kept, to tell whether there is a
+	// next page.
+	for {
+		chunk, err := dir.ReadDir(pageChunk)
+		for _, e := range chunk {
+			if cursor == "" || compare(e.Name(), string(after)) > 0 {
+				entries = append(entries, e)
+			}
+		}
+		if limit > 0 && len(entries) > 2*limit {
+			slices.SortFunc(entries, byName)
+			entries = slices.Delete(entries, limit+1, len(entries))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	slices.SortFunc(entries, byName)
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit:limit]
+		nextCursor = base64.RawURLEncoding.EncodeToString([]byte(entries[limit-1].Name()))
+	}
+	return entries, nextCursor, nil
+}
@@ -0,0 +1,64 @@
+package multifs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func readAllPages(t *testing.T, m *MultiFS, name string, limit int) []string {
+	t.Helper()
+	var names []string
+	cursor := ""
+	for {
+		entries, next, err := m.ReadDirPage(name, cursor, limit)
+		if err != nil {
+			t.Fatalf("ReadDirPage(%q, %q): %v", name, cursor, err)
+		}
+		if len(entries) > limit {
+			t.Fatalf("%d entries, limit %d", len(entries), limit)
+		}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if next == "" {
+			return names
+		}
+		cursor = next
+	}
+}
+
+func TestReadDirPage(t *testing.T) {
+	big := fstest.MapFS{}
+	for i := range 2500 {
+		big[fmt.Sprintf("dir/f%05d", 2500-i)] = &fstest.MapFile{}
+	}
+	reverse := func(a, b string) int { return strings.Compare(b, a) }
+	mux := NewMultiFS(WithRootOrder(reverse))
+	mux.Mount("a", big)
+	mux.Mount("b", big)
+	mux.Mount("c", big)
+
+	var want []string
+	entries, _ := fs.ReadDir(mux, "a/dir")
+	for _, e := range entries {
+		want = append(want, e.Name())
+	}
+	if got := readAllPages(t, mux, "a/dir", 1000); !slices.Equal(got, want) {
+		t.Fatalf("paged listing differs: %d entries, want %d", len(got), len(want))
+	}
+	if got := readAllPages(t, mux, ".", 2); !slices.Equal(got, []string{"c", "b", "a"}) {
+		t.Fatalf("paged root: %v", got)
+	}
+
+	if _, _, err := mux.ReadDirPage("a/dir", "!", 10); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("ReadDirPage with invalid cursor: %v", err)
+	}
+	if _, _, err := mux.ReadDirPage("a/dir/f00001", "", 10); err == nil {
+		t.Fatalf("ReadDirPage on a file succeeded")
+	}
+}
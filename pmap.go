@@ -0,0 +1,206 @@
+package multifs
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"slices"
+)
+
+// pmap is an immutable map from strings to V: a hash array mapped trie,
+// whose updates copy the path to the entry they change and share the rest.
+// Publishing a new generation of the mount table on every change thus
+// costs a few allocations, however many mounts there are. The zero pmap
+// is empty.
+type pmap[V any] struct {
+	root *pnode[V]
+	size int
+}
+
+const (
+	pbits  = 5
+	pdepth = 64 / pbits // levels branching on the hash; deeper ones are lists
+)
+
+var pseed = maphash.MakeSeed()
+
+// pnode holds the slots of one level of the trie, indexed by pbits of the
+// hash through bitmap. Below pdepth, slots are a plain list of the keys
+// sharing a hash.
+type pnode[V any] struct {
+	bitmap uint32
+	slots  []pslot[V]
+}
+
+// pslot holds either an entry or, when sub is set, the next level.
+type pslot[V any] struct {
+	sub *pnode[V]
+	key string
+	val V
+}
+
+func phash(key string) uint64 { return maphash.String(pseed, key) }
+
+// index returns the bit of the slot for h at depth, and its position in
+// the slots of n if taken.
+func (n *pnode[V]) index(h uint64, depth int) (uint32, int) {
+	bit := uint32(1) << (h >> (depth * pbits) & (1<<pbits - 1))
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (m pmap[V]) len() int { return m.size }
+
+func (m pmap[V]) get(key string) (V, bool) {
+	h := phash(key)
+	n := m.root
+	for depth := 0; n != nil; depth++ {
+		if depth == pdepth {
+			for _, s := range n.slots {
+				if s.key == key {
+					return s.val, true
+				}
+			}
+			break
+		}
+		bit, i := n.index(h, depth)
+		if n.bitmap&bit == 0 {
+			break
+		}
+		s := &n.slots[i]
+		if s.sub == nil {
+			if s.key == key {
+				return s.val, true
+			}
+			break
+		}
+		n = s.sub
+	}
+	var zero V
+	return zero, false
+}
+
+// with returns m with key set to val.
+func (m pmap[V]) with(key string, val V) pmap[V] {
+	root, added := m.root.with(phash(key), 0, key, val)
+	if added {
+		m.size++
+	}
+	return pmap[V]{root: root, size: m.size}
+}
+
+// without returns m without key.
+func (m pmap[V]) without(key string) pmap[V] {
+	root, removed := m.root.without(phash(key), 0, key)
+	if !removed {
+		return m
+	}
+	return pmap[V]{root: root, size: m.size - 1}
+}
+
+// all yields the entries of m, in no particular order.
+func (m pmap[V]) all(yield func(string, V) bool) {
+	m.root.all(yield)
+}
+
+func (n *pnode[V]) with(h uint64, depth int, key string, val V) (*pnode[V], bool) {
+	if n == nil {
+		n = &pnode[V]{}
+	}
+	c := &pnode[V]{bitmap: n.bitmap, slots: slices.Clone(n.slots)}
+	if depth == pdepth {
+		for i := range c.slots {
+			if c.slots[i].key == key {
+				c.slots[i].val = val
+				return c, false
+			}
+		}
+		c.slots = append(c.slots, pslot[V]{key: key, val: val})
+		return c, true
+	}
+
+	bit, i := n.index(h, depth)
+	if n.bitmap&bit == 0 {
+		c.bitmap |= bit
+		c.slots = slices.Insert(c.slots, i, pslot[V]{key: key, val: val})
+		return c, true
+	}
+	s := &c.slots[i]
+	switch {
+	case s.sub != nil:
+		sub, added := s.sub.with(h, depth+1, key, val)
+		s.sub = sub
+		return c, added
+	case s.key == key:
+		s.val = val
+		return c, false
+	}
+	// Two keys share the slot: move both one level down
+	sub, _ := (*pnode[V])(nil).with(phash(s.key), depth+1, s.key, s.val)
+	sub, _ = sub.with(h, depth+1, key, val)
+	*s = pslot[V]{sub: sub}
+	return c, true
+}
+
+func (n *pnode[V]) without(h uint64, depth int, key string) (*pnode[V], bool) {
+	if n == nil {
+		return nil, false
+	}
+	if depth == pdepth {
+		for i, s := range n.slots {
+			if s.key == key {
+				if len(n.slots) == 1 {
+					return nil, true
+				}
+				return &pnode[V]{slots: slices.Delete(slices.Clone(n.slots), i, i+1)}, true
+			}
+		}
+		return n, false
+	}
+
+	bit, i := n.index(h, depth)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	s := n.slots[i]
+	var sub *pnode[V]
+	if s.sub == nil {
+		if s.key != key {
+			return n, false
+		}
+	} else {
+		var removed bool
+		if sub, removed = s.sub.without(h, depth+1, key); !removed {
+			return n, false
+		}
+	}
+
+	c := &pnode[V]{bitmap: n.bitmap, slots: slices.Clone(n.slots)}
+	switch {
+	case sub == nil:
+		if c.bitmap &^= bit; c.bitmap == 0 {
+			return nil, true
+		}
+		c.slots = slices.Delete(c.slots, i, i+1)
+	case len(sub.slots) == 1 && sub.slots[0].sub == nil:
+		// An entry left alone below moves back up
+		c.slots[i] = sub.slots[0]
+	default:
+		c.slots[i].sub = sub
+	}
+	return c, true
+}
+
+func (n *pnode[V]) all(yield func(string, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, s := range n.slots {
+		if s.sub != nil {
+			if !s.sub.all(yield) {
+				return false
+			}
+		} else if !yield(s.key, s.val) {
+			return false
+		}
+	}
+	return true
+}
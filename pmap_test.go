@@ -0,0 +1,62 @@
+package multifs
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestPmap(t *testing.T) {
+	var m pmap[int]
+	want := make(map[string]int)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("k%d", rng.IntN(2000))
+		prev, before := m, maps.Clone(want)
+		if rng.IntN(3) == 0 {
+			m = m.without(key)
+			delete(want, key)
+		} else {
+			m = m.with(key, i)
+			want[key] = i
+		}
+		// Updates leave earlier versions untouched
+		if i%1000 == 0 && (!maps.Equal(maps.Collect(prev.all), before) || prev.len() != len(before)) {
+			t.Fatalf("update %d changed the earlier version", i)
+		}
+	}
+
+	if m.len() != len(want) {
+		t.Fatalf("len = %d, want %d", m.len(), len(want))
+	}
+	if got := maps.Collect(m.all); !maps.Equal(got, want) {
+		t.Fatalf("all = %v, want %v", got, want)
+	}
+	for key, val := range want {
+		if got, ok := m.get(key); !ok || got != val {
+			t.Fatalf("get(%q) = %d, %v; want %d", key, got, ok, val)
+		}
+	}
+	if _, ok := m.get("missing"); ok {
+		t.Fatalf("get(missing) found an entry")
+	}
+}
+
+func TestPmapCollisions(t *testing.T) {
+	// Keys sharing their whole hash end up in the lists below pdepth: use
+	// a node built at that depth directly.
+	n, _ := (*pnode[int])(nil).with(0, pdepth, "a", 1)
+	n, _ = n.with(0, pdepth, "b", 2)
+	n, _ = n.with(0, pdepth, "a", 3)
+	if len(n.slots) != 2 || n.slots[0].val != 3 {
+		t.Fatalf("slots = %+v", n.slots)
+	}
+	n, removed := n.without(0, pdepth, "a")
+	if !removed || len(n.slots) != 1 || n.slots[0].key != "b" {
+		t.Fatalf("without: %+v, %v", n, removed)
+	}
+	if n, _ = n.without(0, pdepth, "b"); n != nil {
+		t.Fatalf("without last: %+v", n)
+	}
+}
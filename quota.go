@@ -0,0 +1,105 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+)
+
+var ErrQuotaExceeded = errors.New("multifs: quota exceeded")
+
+// Usage reports what has been consumed through a mount since it was
+// mounted.
+type Usage struct {
+	Opens        int64
+	OpenFiles    int64
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// Quota limits what can be consumed through a mount. Zero fields mean no
+// limit. Operations beyond a limit fail with ErrQuotaExceeded.
+type Quota struct {
+	MaxBytesRead int64
+	MaxOpenFiles int64
+}
+
+type usage struct {
+	opens     atomic.Int64
+	openFiles atomic.Int64
+	bytesRead atomic.Int64
+	// bytesWritten is not limited by quotas.
+	bytesWritten atomic.Int64
+	// errors and lastAccess (in Unix nanoseconds) back MountStats.
+	errors     atomic.Int64
+	lastAccess atomic.Int64
+}
+
+func WithQuota(q Quota) MountOption {
+	return func(o *mountOptions) {
+		o.quota = q
+	}
+}
+
+func (m *MultiFS) Usage(id string) (Usage, error) {
+	mnt, ok := m.mount(id)
+	if !ok {
+		return Usage{}, fs.ErrNotExist
+	}
+	return Usage{
+		Opens:        mnt.usage.opens.Load(),
+		OpenFiles:    mnt.usage.openFiles.Load(),
+		BytesRead:    mnt.usage.bytesRead.Load(),
+		BytesWritten: mnt.usage.bytesWritten.Load(),
+	}, nil
+}
+
+// admit counts one more file open on the mount, if the quota allows it.
+// The file is then either wrapped or given back with dismiss.
+func (mnt *mount) admit(name string) error {
+	max := mnt.opts.quota.MaxOpenFiles
+	for {
+		n := mnt.usage.openFiles.Load()
+		if max > 0 && n >= max {
+			return &fs.PathError{Op: "open", Path: name, Err: ErrQuotaExceeded}
+		}
+		if mnt.usage.openFiles.CompareAndSwap(n, n+1) {
+			return nil
+		}
+	}
+}
+
+// dismiss gives back a file admitted but not opened.
+func (mnt *mount) dismiss() {
+	mnt.usage.openFiles.Add(-1)
+	mnt.release()
+}
+
+// reserve trims p to what is left of the read quota and counts it as
+// read. What is not read is given back with unreserve.
+func (mnt *mount) reserve(p []byte) ([]byte, error) {
+	max := mnt.opts.quota.MaxBytesRead
+	if max <= 0 || len(p) == 0 {
+		mnt.usage.bytesRead.Add(int64(len(p)))
+		return p, nil
+	}
+	for {
+		read := mnt.usage.bytesRead.Load()
+		left := max - read
+		if left <= 0 {
+			return nil, ErrQuotaExceeded
+		}
+		q := p[:min(int64(len(p)), left)]
+		if mnt.usage.bytesRead.CompareAndSwap(read, read+int64(len(q))) {
+			return q, nil
+		}
+	}
+}
+
+// unreserve gives back what was reserved with p but not read, n being
+// the bytes read.
+func (mnt *mount) unreserve(p []byte, n int) {
+	if n < len(p) {
+		mnt.usage.bytesRead.Add(int64(n - len(p)))
+	}
+}
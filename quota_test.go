@@ -0,0 +1,159 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUsageAccounting(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("0123456789")}}
+	if err := mux.Mount("one", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if _, err := fs.ReadFile(mux, "one/file.txt"); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	f, err := mux.Open("one/file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := f.(io.ReaderAt).ReadAt(buf, 2); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+
+	u, err := mux.Usage("one")
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if u.Opens != 2 || u.OpenFiles != 1 || u.BytesRead != 14 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+
+	f.Close()
+	f.Close()
+	if u, _ := mux.Usage("one"); u.OpenFiles != 0 {
+		t.Fatalf("open files after close: got %d, want 0", u.OpenFiles)
+	}
+
+	if _, err := mux.Usage("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist for unknown id, got %v", err)
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("0123456789")}}
+	if err := mux.Mount("tenant", fs1, WithQuota(Quota{MaxBytesRead: 6, MaxOpenFiles: 1})); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	f, err := mux.Open("tenant/file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := mux.Open("tenant/file.txt"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded on second open, got %v", err)
+	}
+
+	data, err := io.ReadAll(f)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded reading past the limit, got %v", err)
+	}
+	if string(data) != "012345" {
+		t.Fatalf("unexpected data before quota: %q", data)
+	}
+}
+
+func TestUsageWrites(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("d", newDirFS(t), WithQuota(Quota{MaxOpenFiles: 1}))
+
+	f, err := mux.OpenFile("d/a.txt", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := io.WriteString(f, "hello"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if handles, _ := mux.OpenHandles("d"); len(handles) != 1 || handles[0].Path != "a.txt" {
+		t.Fatalf("OpenHandles: %+v", handles)
+	}
+	if _, err := mux.OpenFile("d/b.txt", os.O_WRONLY|os.O_CREATE, 0o644); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := mux.UnmountContext(context.Background(), "d", UnmountFail); !errors.Is(err, ErrMountBusy) {
+		t.Fatalf("expected ErrMountBusy, got %v", err)
+	}
+	f.Close()
+
+	if u, _ := mux.Usage("d"); u.Opens != 1 || u.OpenFiles != 0 || u.BytesWritten != 5 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if s := mux.Stats(); s.Writes != 1 || s.BytesWritten != 5 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestQuotaConcurrent(t *testing.T) {
+	data := make([]byte, 1000)
+	mux := NewMultiFS()
+	mux.Mount("one", fstest.MapFS{"f": &fstest.MapFile{Data: data}},
+		WithQuota(Quota{MaxOpenFiles: 3, MaxBytesRead: 2500}))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var files []fs.File
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := mux.Open("one/f")
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			files = append(files, f)
+			mu.Unlock()
+			io.Copy(io.Discard, f)
+		}()
+	}
+	wg.Wait()
+	if len(files) > 3 {
+		t.Fatalf("%d files open, quota is 3", len(files))
+	}
+	if u, _ := mux.Usage("one"); u.BytesRead > 2500 {
+		t.Fatalf("%d bytes read, quota is 2500", u.BytesRead)
+	}
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func TestQuotaStat(t *testing.T) {
+	mux := NewMultiFS()
+	rec := &recordingFS{MapFS: fstest.MapFS{"f": &fstest.MapFile{Data: []byte("x")}}}
+	mux.Mount("one", rec, WithQuota(Quota{MaxOpenFiles: 1}))
+
+	f, err := mux.Open("one/f")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if _, err := mux.Stat("one/f"); err != nil {
+		t.Fatalf("Stat refused by the open files quota: %v", err)
+	}
+	if u, _ := mux.Usage("one"); u.Opens != 1 {
+		t.Fatalf("Stat counted as an open: %+v", u)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package multifs
+
+// The race detector drops pooled buffers at random, which allocation
+// counts cannot account for.
+const raceEnabled = true
@@ -0,0 +1,107 @@
+package multifs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the bytes read from the mount to bytesPerSec, with
+// bursts of up to burst bytes, bytesPerSec when not positive. Reads wait
+// for their share of the bandwidth, and are cut to burst bytes at most.
+func WithRateLimit(bytesPerSec, burst int64) MountOption {
+	return func(o *mountOptions) {
+		o.rateLimit = &rateLimit{bytesPerSec, burst}
+	}
+}
+
+// WithGlobalRateLimit is WithRateLimit for the bytes read from all the
+// mounts together. Reads are subject to both limits on mounts having
+// their own.
+func WithGlobalRateLimit(bytesPerSec, burst int64) Option {
+	return func(o *options) {
+		o.rateLimit = &rateLimit{bytesPerSec, burst}
+	}
+}
+
+type rateLimit struct {
+	bytesPerSec int64
+	burst       int64
+}
+
+// limiter is a token bucket. Takes beyond the available tokens put it in
+// debt, which later takes wait for.
+type limiter struct {
+	rate  float64
+	burst int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(r *rateLimit) *limiter {
+	if r == nil || r.bytesPerSec <= 0 {
+		return nil
+	}
+	burst := r.burst
+	if burst <= 0 {
+		burst = r.bytesPerSec
+	}
+	return &limiter{rate: float64(r.bytesPerSec), burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// take removes n tokens and waits until the bucket is out of debt, or ctx
+// is done.
+func (l *limiter) take(ctx context.Context, n int) error {
+	if l == nil || n == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+	if debt <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(time.Duration(debt / l.rate * float64(time.Second)))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cut trims p to the largest read a take can cover in one burst.
+func (l *limiter) cut(p []byte) []byte {
+	if l != nil && int64(len(p)) > l.burst {
+		return p[:l.burst]
+	}
+	return p
+}
+
+// throttleRead trims p according to the mount's and global rate limits.
+func (mnt *mount) throttleRead(p []byte) []byte {
+	p = mnt.limiter.cut(p)
+	if mnt.metrics != nil {
+		p = mnt.metrics.limiter.cut(p)
+	}
+	return p
+}
+
+// throttled waits for n bytes read to fit in the rate limits.
+func (mnt *mount) throttled(ctx context.Context, n int) error {
+	if err := mnt.limiter.take(ctx, n); err != nil {
+		return err
+	}
+	if mnt.metrics != nil {
+		return mnt.metrics.limiter.take(ctx, n)
+	}
+	return nil
+}
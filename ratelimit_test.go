@@ -0,0 +1,63 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	backend := fstest.MapFS{"f": &fstest.MapFile{Data: make([]byte, 3000)}}
+	for _, mux := range []*MultiFS{
+		NewMultiFS(),
+		NewMultiFS(WithGlobalRateLimit(10000, 1000)),
+	} {
+		var opts []MountOption
+		if mux.metrics.limiter == nil {
+			opts = append(opts, WithRateLimit(10000, 1000))
+		}
+		mux.Mount("s", backend, opts...)
+
+		// 1000 bytes of burst, then 2000 bytes at 10000 bytes per second
+		start := time.Now()
+		if data, err := fs.ReadFile(mux, "s/f"); err != nil || len(data) != 3000 {
+			t.Fatalf("ReadFile: %d, %v", len(data), err)
+		}
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Fatalf("read too fast: %v", elapsed)
+		}
+
+		f, _ := mux.Open("s/f")
+		p := make([]byte, 3000)
+		start = time.Now()
+		if n, err := f.(io.ReaderAt).ReadAt(p, 0); err != nil || n != 3000 {
+			t.Fatalf("ReadAt: %d, %v", n, err)
+		}
+		if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+			t.Fatalf("ReadAt too fast: %v", elapsed)
+		}
+		f.Close()
+	}
+}
+
+func TestRateLimitCancel(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("s", fstest.MapFS{"f": &fstest.MapFile{Data: make([]byte, 3000)}}, WithRateLimit(100, 1000))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	f, err := mux.OpenContext(ctx, "s/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := make([]byte, 1000)
+	f.Read(p)
+	if _, err := f.Read(p); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Read past the deadline: %v", err)
+	}
+}
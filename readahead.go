@@ -0,0 +1,138 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ReadAhead configures WithReadAhead.
+type ReadAhead struct {
+	// Size is the size of the reads issued to the backend, 1 MiB by
+	// default. Smaller reads are served from the data read ahead.
+	Size int
+	// Siblings, when positive, is how many of the files following an
+	// opened file in its directory are read in the background, so that
+	// walks find them ready. Prefetched files go to the mount's cache: it
+	// requires WithCache, and only covers files of listed directories that
+	// fit in the cache.
+	Siblings int
+}
+
+// WithReadAhead makes sequential reads of the mount's files go to the
+// backend in large chunks, and optionally prefetches the next files of a
+// directory, for high-latency backends.
+func WithReadAhead(r ReadAhead) MountOption {
+	return func(o *mountOptions) {
+		if r.Size <= 0 {
+			r.Size = 1 << 20
+		}
+		o.readAhead = &r
+	}
+}
+
+// readAhead wraps a regular file freshly opened on the backend.
+func (mnt *mount) readAhead(f fs.File) fs.File {
+	if isDir(f) {
+		return f
+	}
+	rf := &readAheadFile{File: f, size: mnt.opts.readAhead.Size}
+	var seeker io.Seeker
+	if _, ok := f.(io.Seeker); ok {
+		seeker = rf
+	}
+	readerAt, _ := f.(io.ReaderAt)
+	return compose(rf, seeker, readerAt, nil, nil)
+}
+
+type readAheadFile struct {
+	fs.File
+	size int
+	buf  []byte
+	// ahead holds the data read from the backend and not consumed yet.
+	ahead []byte
+}
+
+func (f *readAheadFile) Read(p []byte) (int, error) {
+	if len(f.ahead) == 0 {
+		if len(p) >= f.size {
+			return f.File.Read(p)
+		}
+		if f.buf == nil {
+			f.buf = make([]byte, f.size)
+		}
+		n, err := f.File.Read(f.buf)
+		f.ahead = f.buf[:n]
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, f.ahead)
+	f.ahead = f.ahead[n:]
+	return n, nil
+}
+
+// Seek drops the data read ahead, which the backend's offset is past.
+func (f *readAheadFile) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekCurrent {
+		offset -= int64(len(f.ahead))
+	}
+	f.ahead = nil
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// prefetch reads into the cache the regular files following bname in the
+// cached listing of its directory, in the order of names as MultiFS lists
+// them rather than the backend's.
+func (mnt *mount) prefetch(bname string) {
+	n := mnt.opts.readAhead.Siblings
+	dir, base := path.Dir(bname), path.Base(bname)
+	entries, ok := mnt.cache.listing(dir)
+	if !ok {
+		return
+	}
+	entries = slices.SortedFunc(slices.Values(entries), func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	i := slices.IndexFunc(entries, func(e fs.DirEntry) bool { return e.Name() == base })
+	if i < 0 {
+		return
+	}
+
+	for _, e := range entries[i+1:] {
+		if n == 0 || mnt.ctx.Err() != nil {
+			return
+		}
+		if !e.Type().IsRegular() {
+			continue
+		}
+		n--
+		mnt.prefetchFile(mnt.ctx, path.Join(dir, e.Name()))
+	}
+}
+
+func (mnt *mount) prefetchFile(ctx context.Context, bname string) {
+	if !mnt.cache.claim(bname) {
+		return
+	}
+	defer mnt.cache.release(bname)
+
+	var f fs.File
+	var err error
+	if cfs, ok := mnt.fsys.(ContextFS); ok {
+		f, err = cfs.OpenContext(ctx, bname)
+	} else {
+		f, err = mnt.fsys.Open(bname)
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() > mnt.cache.cfg.MaxFileSize {
+		return
+	}
+	io.Copy(io.Discard, mnt.cache.record(bname, f))
+}
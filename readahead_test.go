@@ -0,0 +1,173 @@
+package multifs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// readCountFS counts the reads reaching its files.
+type readCountFS struct {
+	fs.FS
+	reads atomic.Int64
+}
+
+func (r *readCountFS) Open(name string) (fs.File, error) {
+	f, err := r.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if isDir(f) {
+		return f, nil
+	}
+	return &readCountFile{File: f, reads: &r.reads}, nil
+}
+
+type readCountFile struct {
+	fs.File
+	reads *atomic.Int64
+}
+
+func (f *readCountFile) Read(p []byte) (int, error) {
+	f.reads.Add(1)
+	return f.File.Read(p)
+}
+
+// ReadDir makes regular files look like directories to a type assertion,
+// as *os.File does.
+func (f *readCountFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (f *readCountFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func TestReadAhead(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, b := range backendsOf(t, fstest.MapFS{"f.bin": &fstest.MapFile{Data: data}}) {
+		t.Run(b.name, func(t *testing.T) {
+			testReadAhead(t, b.fsys, data)
+		})
+	}
+}
+
+func testReadAhead(t *testing.T, fsys fs.FS, data []byte) {
+	backend := &readCountFS{FS: fsys}
+	mux := NewMultiFS()
+	if err := mux.Mount("s", backend, WithReadAhead(ReadAhead{Size: 4096})); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	f, err := mux.Open("s/f.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got bytes.Buffer
+	p := make([]byte, 100)
+	for {
+		n, err := f.Read(p)
+		got.Write(p[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if !bytes.Equal(got.Bytes(), data) {
+		t.Fatal("contents differ")
+	}
+	if n := backend.reads.Load(); n != 4 {
+		t.Fatalf("backend reads: got %d, want 4", n)
+	}
+}
+
+func TestReadAheadSeek(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	mux := NewMultiFS()
+	mux.Mount("s", fstest.MapFS{"f": &fstest.MapFile{Data: data}}, WithReadAhead(ReadAhead{Size: 8}))
+
+	f, err := mux.Open("s/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	seeker := f.(io.Seeker)
+
+	p := make([]byte, 3)
+	f.Read(p)
+	if pos, err := seeker.Seek(0, io.SeekCurrent); err != nil || pos != 3 {
+		t.Fatalf("Seek current: %d, %v", pos, err)
+	}
+	if _, err := seeker.Seek(-2, io.SeekCurrent); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := f.Read(p); string(p[:n]) != "123" {
+		t.Fatalf("Read after relative seek: %q", p[:n])
+	}
+	if _, err := seeker.Seek(15, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if rest, _ := io.ReadAll(f); string(rest) != "fghij" {
+		t.Fatalf("Read after absolute seek: %q", rest)
+	}
+}
+
+func TestReadAheadSiblings(t *testing.T) {
+	files := fstest.MapFS{
+		"d/a":   &fstest.MapFile{Data: []byte("a")},
+		"d/b":   &fstest.MapFile{Data: []byte("b")},
+		"d/c":   &fstest.MapFile{Data: []byte("c")},
+		"d/e/f": &fstest.MapFile{Data: []byte("f")},
+	}
+	for _, b := range backendsOf(t, files) {
+		t.Run(b.name, func(t *testing.T) {
+			testReadAheadSiblings(t, b.fsys)
+		})
+	}
+}
+
+func testReadAheadSiblings(t *testing.T, fsys fs.FS) {
+	backend := &slowFS{FS: fsys}
+	mux := NewMultiFS()
+	err := mux.Mount("s", backend, WithCache(CacheConfig{}), WithReadAhead(ReadAhead{Siblings: 2}))
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	mnt, _ := mux.mount("s")
+
+	listNames(t, mux, "s/d")
+	if _, err := fs.ReadFile(mux, "s/d/a"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, b := mnt.cache.open("d/b")
+		_, c := mnt.cache.open("d/c")
+		if b && c {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("siblings not prefetched")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	before := backend.calls.Load()
+	if data, err := fs.ReadFile(mux, "s/d/b"); err != nil || string(data) != "b" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if backend.calls.Load() != before {
+		t.Fatal("prefetched file read from the backend")
+	}
+}
@@ -0,0 +1,87 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+var _ fs.ReadFileFS = (*MultiFS)(nil)
+
+// ReadFile reads name whole, into a buffer of the size the file reports,
+// which only grows if the file turns out to be larger.
+func (m *MultiFS) ReadFile(name string) ([]byte, error) {
+	return m.ReadFileContext(context.Background(), name)
+}
+
+func (m *MultiFS) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
+	f, err := m.OpenContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readAll(f)
+}
+
+func readAll(f fs.File) ([]byte, error) {
+	size := 0
+	if info, err := f.Stat(); err == nil {
+		if info.IsDir() {
+			return nil, &fs.PathError{Op: "read", Path: info.Name(), Err: errors.New("is a directory")}
+		}
+		if n := info.Size(); n > 0 && int64(int(n)) == n {
+			size = int(n)
+		}
+	}
+
+	data := make([]byte, size)
+	n, err := io.ReadFull(f, data)
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		return data[:n], nil
+	case err != nil && err != io.EOF:
+		return data[:n], err
+	}
+	// The file may have grown since, or not know its size
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+	for {
+		n, err := f.Read(*buf)
+		data = append(data, (*buf)[:n]...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+}
+
+// copyBuffers are the buffers of copyBuffer, sized for the 4 KiB blocks
+// sparse copies work with.
+var copyBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 16*holeBlock)
+		return &buf
+	},
+}
+
+// copyBuffer is io.Copy with a pooled buffer, as bulk reads copy many
+// files in a row. Like io.Copy, it uses the WriteTo method of src or the
+// ReadFrom method of dst instead when they have one.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// copyN is io.CopyN with a pooled buffer.
+func copyN(dst io.Writer, src io.Reader, n int64) (int64, error) {
+	written, err := copyBuffer(dst, io.LimitReader(src, n))
+	if written < n && err == nil {
+		err = io.EOF
+	}
+	return written, err
+}
@@ -0,0 +1,71 @@
+package multifs
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// sizeFS reports a wrong size for its files.
+type sizeFS struct {
+	fstest.MapFS
+	size int64
+}
+
+func (s sizeFS) Open(name string) (fs.File, error) {
+	f, err := s.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return sizeFile{File: f, size: s.size}, nil
+}
+
+type sizeFile struct {
+	fs.File
+	size int64
+}
+
+func (f sizeFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return sizedInfo{FileInfo: info, size: f.size}, nil
+}
+
+func TestReadFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	files := fstest.MapFS{"f": &fstest.MapFile{Data: data}}
+	mux := NewMultiFS()
+	mux.Mount("exact", files)
+	mux.Mount("short", sizeFS{MapFS: files, size: 10})
+	mux.Mount("long", sizeFS{MapFS: files, size: 1 << 20})
+	mux.Mount("unknown", sizeFS{MapFS: files})
+
+	for _, id := range []string{"exact", "short", "long", "unknown"} {
+		got, err := mux.ReadFile(id + "/f")
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%s: %d bytes, %v", id, len(got), err)
+		}
+	}
+	if _, err := mux.ReadFile("exact"); err == nil {
+		t.Fatal("ReadFile of a directory succeeded")
+	}
+	if _, err := mux.ReadFile("exact/missing"); err == nil {
+		t.Fatal("ReadFile of a missing file succeeded")
+	}
+
+	// Files are read without reallocating as they are, even when they do
+	// not know their size
+	if raceEnabled {
+		return
+	}
+	for _, name := range []string{"exact/f", "unknown/f"} {
+		allocs := testing.AllocsPerRun(10, func() { mux.ReadFile(name) })
+		fsAllocs := testing.AllocsPerRun(10, func() { fs.ReadFile(struct{ fs.FS }{mux}, name) })
+		if allocs >= fsAllocs {
+			t.Fatalf("%s: ReadFile: %v allocations, fs.ReadFile: %v", name, allocs, fsAllocs)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+// WithReadOnly hides every interface of the mounted filesystem, and of the
+// files it returns, that is not strictly needed to read it. Code that
+// type-asserts for write support on the mount or its files finds none.
+func WithReadOnly() MountOption {
+	return func(o *mountOptions) {
+		o.readOnly = true
+	}
+}
+
+func readOnly(fsys fs.FS) fs.FS {
+	ro := readOnlyFS{fsys: fsys}
+	if _, ok := fsys.(ContextFS); ok {
+		return readOnlyContextFS{ro}
+	}
+	return ro
+}
+
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+var _ fs.StatFS = readOnlyFS{}
+var _ fs.ReadDirFS = readOnlyFS{}
+var _ fs.ReadFileFS = readOnlyFS{}
+
+func (r readOnlyFS) Open(name string) (fs.File, error) {
+	f, err := r.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyFile(f), nil
+}
+
+func (r readOnlyFS) Stat(name string) (fs.FileInfo, error)      { return fs.Stat(r.fsys, name) }
+func (r readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) { return fs.ReadDir(r.fsys, name) }
+func (r readOnlyFS) ReadFile(name string) ([]byte, error)       { return fs.ReadFile(r.fsys, name) }
+
+type readOnlyContextFS struct {
+	readOnlyFS
+}
+
+func (r readOnlyContextFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	f, err := r.fsys.(ContextFS).OpenContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyFile(f), nil
+}
+
+// readOnlyFile wraps f into a type exposing only the reading side of the
+// optional interfaces f implements.
+func readOnlyFile(f fs.File) fs.File {
+	seeker, _ := f.(io.Seeker)
+	readerAt, _ := f.(io.ReaderAt)
+	dir, _ := f.(fs.ReadDirFile)
+	return compose(f, seeker, readerAt, nil, dir)
+}
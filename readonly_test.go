@@ -0,0 +1,85 @@
+package multifs
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// writableFS pretends to support writes so the tests can check they are
+// hidden by WithReadOnly.
+type writableFS struct {
+	fstest.MapFS
+}
+
+func (w writableFS) Remove(name string) error { return nil }
+
+func (w writableFS) Open(name string) (fs.File, error) {
+	f, err := w.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.(fs.ReadDirFile); ok {
+		return f, nil
+	}
+	return writableFile{f.(seekReaderAtFile)}, nil
+}
+
+type seekReaderAtFile interface {
+	fs.File
+	io.Seeker
+	io.ReaderAt
+}
+
+type writableFile struct {
+	seekReaderAtFile
+}
+
+func (writableFile) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestReadOnlyMount(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := writableFS{fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("hello")}}}
+	if err := mux.Mount("rw", fs1); err != nil {
+		t.Fatalf("Mount rw: %v", err)
+	}
+	if err := mux.Mount("ro", fs1, WithReadOnly()); err != nil {
+		t.Fatalf("Mount ro: %v", err)
+	}
+
+	f, err := mux.Open("rw/file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, ok := f.(io.Writer); !ok {
+		t.Fatalf("test setup: writable mount returned a read-only file")
+	}
+	f.Close()
+
+	f, err = mux.Open("ro/file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if _, ok := f.(io.Writer); ok {
+		t.Fatalf("read-only mount returned a writable file")
+	}
+	if _, ok := f.(io.Seeker); !ok {
+		t.Fatalf("read-only mount hid io.Seeker")
+	}
+	if _, ok := f.(io.ReaderAt); !ok {
+		t.Fatalf("read-only mount hid io.ReaderAt")
+	}
+	data, err := io.ReadAll(f)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("ReadAll: %q, %v", data, err)
+	}
+
+	for _, info := range mux.Mounts() {
+		_, canRemove := info.FS.(interface{ Remove(string) error })
+		if info.ID == "ro" && canRemove {
+			t.Fatalf("read-only mount exposes Remove")
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package multifs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// ErrNeedsConfirmation is wrapped by the errors of removals that need a
+// confirmation token.
+var ErrNeedsConfirmation = errors.New("multifs: removal needs confirmation")
+
+// confirmationTTL is how long a confirmation token remains valid.
+const confirmationTTL = 5 * time.Minute
+
+// RemoveGuard sets the thresholds above which RemoveAll needs to be
+// confirmed. Zero fields mean no threshold.
+type RemoveGuard struct {
+	// MaxEntries is the number of files and directories, MaxBytes the
+	// total size of the regular files, that can be removed at once.
+	MaxEntries int64
+	MaxBytes   int64
+}
+
+// WithRemoveGuard has RemoveAll fail with a *ConfirmationError, rather
+// than remove anything, when removing more than g allows.
+func WithRemoveGuard(g RemoveGuard) MountOption {
+	return func(o *mountOptions) {
+		o.removeGuard = &g
+	}
+}
+
+// ConfirmationError is the error of a removal above the thresholds of its
+// mount: it is performed by RemoveAllConfirmed with Token, within five
+// minutes.
+type ConfirmationError struct {
+	Path  string
+	Size  Size
+	Token string
+}
+
+func (e *ConfirmationError) Error() string {
+	return fmt.Sprintf("multifs: removing %s (%d files, %d directories, %d bytes) needs confirmation",
+		e.Path, e.Size.Files, e.Size.Dirs, e.Size.Bytes)
+}
+
+func (e *ConfirmationError) Unwrap() error { return ErrNeedsConfirmation }
+
+// Remove removes the file or empty directory name inside a mount, whose
+// filesystem must implement RemoveFS. Mounts themselves are removed with
+// Unmount: removing a mount point fails with fs.ErrPermission.
+func (m *MultiFS) Remove(name string) error {
+	return m.RemoveContext(context.Background(), name)
+}
+
+func (m *MultiFS) RemoveContext(ctx context.Context, name string) error {
+	r, err := m.resolveRemove("remove", name)
+	if err != nil {
+		return err
+	}
+	return r.write(ctx, OpRemove, func() error { return r.mnt.remove(r.subpath) })
+}
+
+// RemoveAll removes name and everything below it inside a mount, as
+// Remove does. It succeeds if name does not exist.
+func (m *MultiFS) RemoveAll(name string) error {
+	return m.RemoveAllContext(context.Background(), name)
+}
+
+func (m *MultiFS) RemoveAllContext(ctx context.Context, name string) error {
+	return m.removeAll(ctx, name, "")
+}
+
+// RemoveAllConfirmed is RemoveAll with the token of the ConfirmationError
+// returned for name. Tokens can only be used once.
+func (m *MultiFS) RemoveAllConfirmed(name, token string) error {
+	return m.RemoveAllConfirmedContext(context.Background(), name, token)
+}
+
+func (m *MultiFS) RemoveAllConfirmedContext(ctx context.Context, name, token string) error {
+	if token == "" {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return m.removeAll(ctx, name, token)
+}
+
+func (m *MultiFS) removeAll(ctx context.Context, name, token string) error {
+	r, err := m.resolveRemove("remove", name)
+	if err != nil {
+		return err
+	}
+	if _, err := r.mnt.stat(ctx, r.subpath); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if g := r.mnt.opts.removeGuard; g != nil && !r.mnt.confirmations.use(r.subpath, token) {
+		size, err := r.mnt.diskUsage(ctx, r.subpath, nil)
+		if err != nil {
+			return err
+		}
+		if (g.MaxEntries > 0 && size.Files+size.Dirs > g.MaxEntries) || (g.MaxBytes > 0 && size.Bytes > g.MaxBytes) {
+			return &ConfirmationError{Path: name, Size: size, Token: r.mnt.confirmations.issue(r.subpath)}
+		}
+	}
+	return r.write(ctx, OpRemove, func() error { return removeAll(mountRemover{r.mnt}, r.subpath) })
+}
+
+// resolveRemove resolves name for op, which may not remove the synthetic
+// root nor mount points.
+func (m *MultiFS) resolveRemove(op, name string) (resolved, error) {
+	r, err := m.resolve(op, name)
+	if err != nil {
+		return resolved{}, err
+	}
+	if r.mnt == nil || (r.id != "" && r.subpath == ".") {
+		return resolved{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return r, nil
+}
+
+// mountRemover adapts a mount to RemoveFS.
+type mountRemover struct {
+	*mount
+}
+
+func (d mountRemover) Remove(name string) error { return d.mount.remove(name) }
+
+// confirmations holds the confirmation tokens issued for a mount, by path.
+type confirmations struct {
+	mu     sync.Mutex
+	tokens map[string]confirmation
+}
+
+type confirmation struct {
+	token   string
+	expires time.Time
+}
+
+func (c *confirmations) issue(name string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for name, t := range c.tokens {
+		if now.After(t.expires) {
+			delete(c.tokens, name)
+		}
+	}
+	if c.tokens == nil {
+		c.tokens = make(map[string]confirmation)
+	}
+	c.tokens[name] = confirmation{token: token, expires: now.Add(confirmationTTL)}
+	return token
+}
+
+// use reports whether token confirms the removal of name, consuming it.
+func (c *confirmations) use(name, token string) bool {
+	if token == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[name]
+	if !ok || t.token != token || time.Now().After(t.expires) {
+		return false
+	}
+	delete(c.tokens, name)
+	return true
+}
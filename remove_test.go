@@ -0,0 +1,83 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRemove(t *testing.T) {
+	scratch := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch)
+	mux.Mount("ro", fstest.MapFS{"f": &fstest.MapFile{}})
+
+	mux.MkdirAll("scratch/a/b", 0o755)
+	writeFile(t, scratch, "a/b/f", "data")
+	writeFile(t, scratch, "f", "data")
+
+	if err := mux.Remove("scratch/a"); err == nil {
+		t.Fatalf("Remove of a non-empty directory succeeded")
+	}
+	if err := mux.Remove("scratch/f"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := mux.RemoveAll("scratch/a"); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if _, err := fs.Stat(mux, "scratch/a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat after RemoveAll: %v", err)
+	}
+	if err := mux.RemoveAll("scratch/a"); err != nil {
+		t.Fatalf("RemoveAll of a missing path: %v", err)
+	}
+
+	for name, want := range map[string]error{
+		"scratch": fs.ErrPermission,
+		".":       fs.ErrPermission,
+		"ro/f":    errors.ErrUnsupported,
+	} {
+		if err := mux.Remove(name); !errors.Is(err, want) {
+			t.Errorf("Remove(%q): %v, want %v", name, err, want)
+		}
+		if err := mux.RemoveAll(name); !errors.Is(err, want) {
+			t.Errorf("RemoveAll(%q): %v, want %v", name, err, want)
+		}
+	}
+}
+
+func TestRemoveGuard(t *testing.T) {
+	scratch := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch, WithRemoveGuard(RemoveGuard{MaxEntries: 2}))
+	mux.MkdirAll("scratch/small", 0o755)
+	writeFile(t, scratch, "small/f", "x")
+	mux.MkdirAll("scratch/big", 0o755)
+	for _, name := range []string{"big/a", "big/b", "big/c"} {
+		writeFile(t, scratch, name, "x")
+	}
+
+	if err := mux.RemoveAll("scratch/small"); err != nil {
+		t.Fatalf("RemoveAll below the threshold: %v", err)
+	}
+	err := mux.RemoveAll("scratch/big")
+	var confirm *ConfirmationError
+	if !errors.As(err, &confirm) || !errors.Is(err, ErrNeedsConfirmation) || confirm.Size.Files != 3 {
+		t.Fatalf("RemoveAll above the threshold: %v", err)
+	}
+	if _, err := fs.Stat(mux, "scratch/big/a"); err != nil {
+		t.Fatalf("unconfirmed removal removed files: %v", err)
+	}
+	if err := mux.RemoveAllConfirmed("scratch/big", "bogus"); !errors.Is(err, ErrNeedsConfirmation) {
+		t.Fatalf("RemoveAllConfirmed with a bogus token: %v", err)
+	}
+	err = mux.RemoveAll("scratch/big")
+	errors.As(err, &confirm)
+	if err := mux.RemoveAllConfirmed("scratch/big", confirm.Token); err != nil {
+		t.Fatalf("RemoveAllConfirmed: %v", err)
+	}
+	if _, err := fs.Stat(mux, "scratch/big"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat after RemoveAllConfirmed: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaCooldown is how long a failing replica is only tried once the
+// others failed too.
+const replicaCooldown = 30 * time.Second
+
+// WithReplicas registers copies of the mounted filesystem, tried in order
+// when it fails: opens, stats and reads fail over to the next replica on
+// errors other than the file missing, being denied or the caller giving
+// up. Replicas that just failed are tried last. Their health is reported
+// by Mounts. Mounts with replicas cannot be written to.
+func WithReplicas(replicas ...fs.FS) MountOption {
+	return func(o *mountOptions) {
+		o.replicas = append(o.replicas, replicas...)
+	}
+}
+
+type replica struct {
+	fsys   fs.FS
+	health atomic.Pointer[Health]
+}
+
+// replicaFS is a filesystem served by the first of its replicas that
+// works.
+type replicaFS struct {
+	replicas []*replica
+}
+
+func newReplicaFS(primary fs.FS, others []fs.FS) *replicaFS {
+	r := &replicaFS{replicas: []*replica{{fsys: primary}}}
+	for _, fsys := range others {
+		r.replicas = append(r.replicas, &replica{fsys: fsys})
+	}
+	return r
+}
+
+// failsOver reports whether an operation failing with err is worth
+// trying on another replica.
+func failsOver(err error) bool {
+	c := classify(err)
+	return c == ErrorOther || c == ErrorTimeout
+}
+
+// order returns the replicas in the order they are to be tried, the
+// replicas that failed recently last.
+func (r *replicaFS) order() []*replica {
+	up := make([]*replica, 0, len(r.replicas))
+	var down []*replica
+	for _, rep := range r.replicas {
+		if h := rep.health.Load(); h != nil && h.Err != nil && time.Since(h.Checked) < replicaCooldown {
+			down = append(down, rep)
+		} else {
+			up = append(up, rep)
+		}
+	}
+	return append(up, down...)
+}
+
+func (rep *replica) report(err error) {
+	if err != nil && !failsOver(err) {
+		err = nil
+	}
+	rep.health.Store(&Health{Err: err, Checked: time.Now()})
+}
+
+// do runs fn on each replica in turn until one does not fail over.
+func (r *replicaFS) do(skip *replica, fn func(*replica) error) error {
+	var err error
+	for _, rep := range r.order() {
+		if rep == skip {
+			continue
+		}
+		err = fn(rep)
+		rep.report(err)
+		if !failsOver(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *replicaFS) Open(name string) (fs.File, error) {
+	return r.OpenContext(context.Background(), name)
+}
+
+func (r *replicaFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	f, from, err := r.open(ctx, nil, name)
+	if err != nil {
+		return nil, err
+	}
+	rf := &replicaFile{File: f, fsys: r, ctx: outliving(ctx), name: name, from: from}
+	var seeker io.Seeker
+	if _, ok := f.(io.Seeker); ok {
+		seeker = rf
+	}
+	var readerAt io.ReaderAt
+	if _, ok := f.(io.ReaderAt); ok {
+		readerAt = rf
+	}
+	var dir readDirer
+	if _, ok := f.(fs.ReadDirFile); ok {
+		dir = rf
+	}
+	return compose(rf, seeker, readerAt, nil, dir), nil
+}
+
+// open opens name on the first working replica other than skip.
+func (r *replicaFS) open(ctx context.Context, skip *replica, name string) (f fs.File, from *replica, err error) {
+	err = r.do(skip, func(rep *replica) (err error) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if cfs, ok := rep.fsys.(ContextFS); ok {
+			f, err = cfs.OpenContext(ctx, name)
+		} else {
+			f, err = rep.fsys.Open(name)
+		}
+		from = rep
+		return err
+	})
+	return f, from, err
+}
+
+func (r *replicaFS) Stat(name string) (info fs.FileInfo, err error) {
+	err = r.do(nil, func(rep *replica) (err error) {
+		info, err = fs.Stat(rep.fsys, name)
+		return err
+	})
+	return info, err
+}
+
+// health returns the health of the replicas, nil for those never used.
+func (r *replicaFS) health() []*Health {
+	health := make([]*Health, len(r.replicas))
+	for i, rep := range r.replicas {
+		health[i] = rep.health.Load()
+	}
+	return health
+}
+
+// replicaFile fails over to another replica when reading fails, resuming
+// at the same offset. mu guards the swap of File, which ReadAt calls may
+// be using in parallel.
+type replicaFile struct {
+	fs.File
+	fsys *replicaFS
+	ctx  context.Context
+	name string
+	mu   sync.RWMutex
+	from *replica
+	off  int64
+}
+
+// failOver replaces cur, the file that failed, by the same one on another
+// replica, unless another call already did. f.mu must be held.
+func (f *replicaFile) failOver(cur fs.File) bool {
+	if f.File != cur {
+		return true
+	}
+	nf, from, err := f.fsys.open(f.ctx, f.from, f.name)
+	if err != nil {
+		return false
+	}
+	if f.off > 0 {
+		s, ok := nf.(io.Seeker)
+		if !ok {
+			nf.Close()
+			return false
+		}
+		if _, err := s.Seek(f.off, io.SeekStart); err != nil {
+			nf.Close()
+			return false
+		}
+	}
+	f.File.Close()
+	f.File, f.from = nf, from
+	return true
+}
+
+func (f *replicaFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.File.Read(p)
+	if n == 0 && failsOver(err) && f.failOver(f.File) {
+		n, err = f.File.Read(p)
+	}
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *replicaFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.RLock()
+	cur := f.File
+	n, err := cur.(io.ReaderAt).ReadAt(p, off)
+	f.mu.RUnlock()
+	if !failsOver(err) {
+		return n, err
+	}
+	f.mu.Lock()
+	ok := f.failOver(cur)
+	f.mu.Unlock()
+	if !ok {
+		return n, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.File.(io.ReaderAt).ReadAt(p, off)
+}
+
+func (f *replicaFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	off, err := f.File.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		f.off = off
+	}
+	return off, err
+}
+
+func (f *replicaFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := f.File.(fs.ReadDirFile).ReadDir(n)
+	if n <= 0 && failsOver(err) && f.failOver(f.File) {
+		entries, err = f.File.(fs.ReadDirFile).ReadDir(n)
+	}
+	return entries, err
+}
+
+func (f *replicaFile) Stat() (fs.FileInfo, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.File.Stat()
+}
+
+func (f *replicaFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.Close()
+}
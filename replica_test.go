@@ -0,0 +1,98 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReplicas(t *testing.T) {
+	replica := fstest.MapFS{
+		"f":    &fstest.MapFile{Data: []byte("hello, world")},
+		"only": &fstest.MapFile{Data: []byte("x")},
+	}
+	mux := NewMultiFS()
+	mux.Mount("s", failingFS{}, WithReplicas(replica))
+
+	if data, err := fs.ReadFile(mux, "s/f"); err != nil || string(data) != "hello, world" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if info, err := fs.Stat(mux, "s/f"); err != nil || info.Size() != 12 {
+		t.Fatalf("Stat: %v, %v", info, err)
+	}
+	health := mux.Mounts()[0].Replicas
+	if len(health) != 2 || health[0] == nil || health[0].Err == nil || health[1] == nil || health[1].Err != nil {
+		t.Fatalf("replica health: %+v", health)
+	}
+
+	// Missing files are not looked up on replicas
+	mux.Mount("t", fstest.MapFS{"f": replica["f"]}, WithReplicas(replica))
+	if _, err := mux.Open("t/only"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open: %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestReplicasRead(t *testing.T) {
+	data := fstest.MapFS{"f": &fstest.MapFile{Data: []byte("hello, world")}}
+	primary := &flakyFS{FS: data, breakAt: 5}
+	mux := NewMultiFS()
+	mux.Mount("s", primary, WithReplicas(data))
+
+	f, err := mux.Open("s/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, err := io.ReadAll(f); err != nil || string(got) != "hello, world" {
+		t.Fatalf("ReadAll: %q, %v", got, err)
+	}
+	if primary.opens != 1 {
+		t.Fatalf("%d opens on the primary, want 1", primary.opens)
+	}
+}
+
+// brokenAtFS serves files whose ReadAt always fails.
+type brokenAtFS struct {
+	fs.FS
+}
+
+func (b brokenAtFS) Open(name string) (fs.File, error) {
+	f, err := b.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return brokenAtFile{f}, nil
+}
+
+type brokenAtFile struct {
+	fs.File
+}
+
+func (brokenAtFile) ReadAt([]byte, int64) (int, error) { return 0, errFlaky }
+
+func TestReplicasReadAtConcurrent(t *testing.T) {
+	data := fstest.MapFS{"f": &fstest.MapFile{Data: []byte("hello, world")}}
+	mux := NewMultiFS()
+	mux.Mount("s", brokenAtFS{data}, WithReplicas(data))
+
+	f, err := mux.Open("s/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 5)
+			if n, err := f.(io.ReaderAt).ReadAt(p, int64(i%4)); err != nil || n != 5 {
+				t.Errorf("ReadAt: %d, %v", n, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
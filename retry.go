@@ -0,0 +1,217 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures WithRetry. Zero fields take the defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts made of an operation, 3 by
+	// default.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, 100ms by default. It
+	// doubles with every retry, up to MaxBackoff, 5s by default.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable reports whether an operation failing with err may succeed
+	// if retried. By default, failures of the backend are retried, but
+	// not errors such as fs.ErrNotExist, fs.ErrPermission or context
+	// errors.
+	Retryable func(err error) bool
+}
+
+// WithRetry retries the opens, stats and full listings of the mount's
+// backend failing with retryable errors. Reads failing likewise reopen the
+// file, seeking back to where they were if the file is an io.Seeker.
+func WithRetry(p RetryPolicy) MountOption {
+	return func(o *mountOptions) {
+		if p.MaxAttempts <= 0 {
+			p.MaxAttempts = 3
+		}
+		if p.Backoff <= 0 {
+			p.Backoff = 100 * time.Millisecond
+		}
+		if p.MaxBackoff <= 0 {
+			p.MaxBackoff = 5 * time.Second
+		}
+		if p.Retryable == nil {
+			p.Retryable = func(err error) bool { return classify(err) == ErrorOther }
+		}
+		o.retry = &p
+	}
+}
+
+// wait waits before attempt, the second one being the first retry, after
+// a failure with err. It reports false if the attempt is not to be made.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int, err error) bool {
+	if p == nil || attempt > p.MaxAttempts || !p.Retryable(err) {
+		return false
+	}
+	delay := p.Backoff
+	for i := 2; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	t := time.NewTimer(min(delay, p.MaxBackoff))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retry calls fn until it succeeds or the mount's retry policy gives up.
+func (mnt *mount) retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 2; err != nil && mnt.opts.retry.wait(ctx, attempt, err); attempt++ {
+		err = fn()
+	}
+	return err
+}
+
+// errCannotResume stops the recovery of a read from a file that cannot
+// seek back to where it was.
+var errCannotResume = errors.New("cannot resume read")
+
+// retryingFile recovers from read failures by reopening the file with
+// open. mu guards the swap of File, which ReadAt calls may be using in
+// parallel.
+type retryingFile struct {
+	fs.File
+	ctx    context.Context
+	policy *RetryPolicy
+	open   func() (fs.File, error)
+	mu     sync.RWMutex
+	off    int64
+}
+
+func (mnt *mount) retryingFile(ctx context.Context, f fs.File, open func() (fs.File, error)) fs.File {
+	rf := &retryingFile{File: f, ctx: ctx, policy: mnt.opts.retry, open: open}
+	var seeker io.Seeker
+	if _, ok := f.(io.Seeker); ok {
+		seeker = rf
+	}
+	var readerAt io.ReaderAt
+	if _, ok := f.(io.ReaderAt); ok {
+		readerAt = rf
+	}
+	var dir readDirer
+	if _, ok := f.(fs.ReadDirFile); ok {
+		dir = rf
+	}
+	return compose(rf, seeker, readerAt, nil, dir)
+}
+
+// reopen replaces cur, the file that failed, by a new one at the same
+// offset, unless another call already did. f.mu must be held.
+func (f *retryingFile) reopen(cur fs.File) error {
+	if f.File != cur {
+		return nil
+	}
+	nf, err := f.open()
+	if err != nil {
+		return err
+	}
+	if f.off > 0 {
+		s, ok := nf.(io.Seeker)
+		if !ok {
+			nf.Close()
+			return errCannotResume
+		}
+		if _, err := s.Seek(f.off, io.SeekStart); err != nil {
+			nf.Close()
+			return err
+		}
+	}
+	f.File.Close()
+	f.File = nf
+	return nil
+}
+
+func (f *retryingFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.File.Read(p)
+	f.off += int64(n)
+	if n > 0 && err != io.EOF {
+		// The failure, if any, shows up again on the next read
+		return n, nil
+	}
+	first := err
+	for attempt := 2; err != nil && err != io.EOF && f.policy.wait(f.ctx, attempt, err); attempt++ {
+		if err = f.reopen(f.File); err == errCannotResume {
+			return 0, first
+		}
+		if err == nil {
+			n, err = f.File.Read(p)
+			f.off += int64(n)
+			if n > 0 && err != io.EOF {
+				return n, nil
+			}
+		}
+	}
+	return n, err
+}
+
+func (f *retryingFile) ReadAt(p []byte, off int64) (int, error) {
+	readAt := func() (fs.File, int, error) {
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		n, err := f.File.(io.ReaderAt).ReadAt(p, off)
+		return f.File, n, err
+	}
+	cur, n, err := readAt()
+	for attempt := 2; err != nil && err != io.EOF && f.policy.wait(f.ctx, attempt, err); attempt++ {
+		f.mu.Lock()
+		err = f.reopen(cur)
+		f.mu.Unlock()
+		if err == nil {
+			cur, n, err = readAt()
+		}
+	}
+	return n, err
+}
+
+func (f *retryingFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	off, err := f.File.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		f.off = off
+	}
+	return off, err
+}
+
+// ReadDir retries full listings only: partial ones cannot be resumed.
+func (f *retryingFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := f.File.(fs.ReadDirFile).ReadDir(n)
+	if n > 0 {
+		return entries, err
+	}
+	for attempt := 2; err != nil && f.policy.wait(f.ctx, attempt, err); attempt++ {
+		if err = f.reopen(f.File); err == nil {
+			entries, err = f.File.(fs.ReadDirFile).ReadDir(n)
+		}
+	}
+	return entries, err
+}
+
+func (f *retryingFile) Stat() (fs.FileInfo, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.File.Stat()
+}
+
+func (f *retryingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.Close()
+}
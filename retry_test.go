@@ -0,0 +1,182 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flakyFS fails its first opens, and its first read past breakAt bytes.
+type flakyFS struct {
+	fs.FS
+	mu       sync.Mutex
+	opens    int
+	failOpen int
+	breakAt  int64
+	broken   bool
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	f.mu.Lock()
+	f.opens++
+	fail := f.opens <= f.failOpen
+	f.mu.Unlock()
+	if fail {
+		return nil, errFlaky
+	}
+	file, err := f.FS.Open(name)
+	if err != nil || f.breakAt == 0 {
+		return file, err
+	}
+	return &flakyFile{File: file, fsys: f}, nil
+}
+
+type flakyFile struct {
+	fs.File
+	fsys *flakyFS
+	off  int64
+}
+
+func (f *flakyFile) Read(p []byte) (int, error) {
+	f.fsys.mu.Lock()
+	fail := !f.fsys.broken && f.off >= f.fsys.breakAt
+	f.fsys.broken = f.fsys.broken || fail
+	f.fsys.mu.Unlock()
+	if fail {
+		return 0, errFlaky
+	}
+	n, err := f.File.Read(p)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *flakyFile) Seek(offset int64, whence int) (int64, error) {
+	off, err := f.File.(io.Seeker).Seek(offset, whence)
+	f.off = off
+	return off, err
+}
+
+func TestRetry(t *testing.T) {
+	backend := fstest.MapFS{"f": &fstest.MapFile{Data: []byte("hello, world")}}
+	policy := RetryPolicy{Backoff: time.Millisecond}
+
+	flaky := &flakyFS{FS: backend, failOpen: 2}
+	mux := NewMultiFS()
+	mux.Mount("s", flaky, WithRetry(policy))
+	if data, err := fs.ReadFile(mux, "s/f"); err != nil || string(data) != "hello, world" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	flaky = &flakyFS{FS: backend, failOpen: 3}
+	mux = NewMultiFS()
+	mux.Mount("s", flaky, WithRetry(policy))
+	if _, err := mux.Open("s/f"); !errors.Is(err, errFlaky) {
+		t.Fatalf("Open: %v, want %v", err, errFlaky)
+	}
+	if flaky.opens != 3 {
+		t.Fatalf("%d opens, want 3", flaky.opens)
+	}
+
+	// Missing files are not retried
+	flaky = &flakyFS{FS: backend}
+	mux = NewMultiFS()
+	mux.Mount("s", flaky, WithRetry(policy))
+	if _, err := mux.Open("s/missing"); !errors.Is(err, fs.ErrNotExist) || flaky.opens != 1 {
+		t.Fatalf("Open: %v after %d opens", err, flaky.opens)
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	backend := fstest.MapFS{"f": &fstest.MapFile{Data: []byte("hello, world")}}
+	flaky := &flakyFS{FS: backend, breakAt: 5}
+	mux := NewMultiFS()
+	mux.Mount("s", flaky, WithRetry(RetryPolicy{Backoff: time.Millisecond}))
+
+	f, err := mux.Open("s/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := make([]byte, 5)
+	if n, err := io.ReadFull(f, p); err != nil || string(p[:n]) != "hello" {
+		t.Fatalf("Read: %q, %v", p[:n], err)
+	}
+	if rest, err := io.ReadAll(f); err != nil || string(rest) != ", world" {
+		t.Fatalf("ReadAll: %q, %v", rest, err)
+	}
+	if flaky.opens != 2 {
+		t.Fatalf("%d opens, want 2", flaky.opens)
+	}
+}
+
+// flakyAtFS serves a file whose ReadAt always fails on its first open.
+type flakyAtFS struct {
+	fs.FS
+	mu    sync.Mutex
+	opens int
+}
+
+func (f *flakyAtFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.opens++; f.opens == 1 {
+		return brokenAtFile{file}, nil
+	}
+	return file, nil
+}
+
+func TestRetryReadAtConcurrent(t *testing.T) {
+	backend := fstest.MapFS{"f": &fstest.MapFile{Data: []byte("hello, world")}}
+	mux := NewMultiFS()
+	mux.Mount("s", &flakyAtFS{FS: backend}, WithRetry(RetryPolicy{Backoff: time.Millisecond}))
+
+	f, err := mux.Open("s/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 5)
+			if n, err := f.(io.ReaderAt).ReadAt(p, int64(i%4)); err != nil || n != 5 {
+				t.Errorf("ReadAt: %d, %v", n, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRetryStatCanceled(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("s", failingFS{}, WithRetry(RetryPolicy{Backoff: time.Hour}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	done := make(chan error)
+	go func() {
+		_, err := mux.StatContext(ctx, "s/f")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("StatContext succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StatContext kept retrying after its context was canceled")
+	}
+}
@@ -0,0 +1,64 @@
+package multifs
+
+import (
+	"io/fs"
+	"path"
+)
+
+type pathRewrite struct {
+	rewrite func(name string) (string, error)
+	reverse func(name string) (string, bool)
+}
+
+// WithPathRewrite maps the paths inside the mount to other paths of its
+// filesystem: rewrite is called with every path looked up, and returns
+// the path to access instead, or an error failing the lookup. reverse, if
+// not nil, maps the paths of the filesystem back, so that listings show
+// entries under their names inside the mount; it reports false for
+// paths that cannot be reached through the mount, which are left out.
+// Without reverse, entries are listed under their names in the
+// filesystem.
+func WithPathRewrite(rewrite func(name string) (string, error), reverse func(name string) (string, bool)) MountOption {
+	return func(o *mountOptions) {
+		o.rewrite = &pathRewrite{rewrite: rewrite, reverse: reverse}
+	}
+}
+
+// rewritePath applies the mount's path rewrite to name.
+func (mnt *mount) rewritePath(op, name string) (string, error) {
+	rw := mnt.opts.rewrite
+	if rw == nil {
+		return name, nil
+	}
+	rname, err := rw.rewrite(name)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if !fs.ValidPath(rname) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return rname, nil
+}
+
+// unrewriteFile wraps f, opened at the rewritten path of name, so that it
+// reports its name and the names of its entries as seen through the
+// mount.
+func (mnt *mount) unrewriteFile(name string, f fs.File) fs.File {
+	rw := mnt.opts.rewrite
+	if rw.reverse != nil {
+		if rname, err := rw.rewrite(name); err == nil {
+			f = filterDir(f, func(e fs.DirEntry) bool {
+				mname, ok := rw.reverse(path.Join(rname, e.Name()))
+				return ok && path.Dir(mname) == name
+			})
+			f = renameFile(f, func(base string) string {
+				mname, _ := rw.reverse(path.Join(rname, base))
+				return path.Base(mname)
+			})
+		}
+	}
+	if name == "." {
+		return f
+	}
+	return namedRoot(f, path.Base(name))
+}
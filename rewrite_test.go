@@ -0,0 +1,64 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPathRewrite(t *testing.T) {
+	backend := fstest.MapFS{
+		"vendor/v2/a":     &fstest.MapFile{Data: []byte("old")},
+		"vendor/v3/a":     &fstest.MapFile{Data: []byte("new")},
+		"vendor/v3/sub/b": &fstest.MapFile{Data: []byte("b")},
+		"vendor/conf":     &fstest.MapFile{Data: []byte("conf")},
+	}
+	// The vendor/ prefix is stripped, and current/ maps to v3/
+	rewrite := func(name string) (string, error) {
+		switch {
+		case name == "current", strings.HasPrefix(name, "current/"):
+			return path.Join("vendor/v3", strings.TrimPrefix(name, "current")), nil
+		case strings.HasPrefix(name, "v"):
+			return "", fs.ErrNotExist
+		}
+		return path.Join("vendor", name), nil
+	}
+	reverse := func(name string) (string, bool) {
+		rel, ok := strings.CutPrefix(name, "vendor/")
+		switch {
+		case !ok:
+			return "", false
+		case rel == "v3", strings.HasPrefix(rel, "v3/"):
+			return path.Join("current", strings.TrimPrefix(rel, "v3")), true
+		case strings.HasPrefix(rel, "v"):
+			return "", false
+		}
+		return rel, true
+	}
+
+	mux := NewMultiFS()
+	if err := mux.Mount("s", backend, WithPathRewrite(rewrite, reverse)); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	if data, err := fs.ReadFile(mux, "s/current/a"); err != nil || string(data) != "new" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if got := listNames(t, mux, "s/current"); got != "a,sub" {
+		t.Fatalf("listing: %s", got)
+	}
+	if got := listNames(t, mux, "s"); got != "conf,current" {
+		t.Fatalf("root listing: %s", got)
+	}
+	if info, err := mux.Stat("s/current"); err != nil || info.Name() != "current" {
+		t.Fatalf("Stat: %v, %v", info, err)
+	}
+	if _, err := mux.Open("s/v2/a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open of a path rewritten away: %v", err)
+	}
+	if err := fstest.TestFS(mux, "s/current/a", "s/current/sub/b", "s/conf"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// Sample reads size bytes at each of the given offsets of name, opening it
+// once. Windows are returned in the order of offsets and are short, or
+// empty, past the end of the file. Files that support range reads are read
+// only where asked; others are read sequentially up to the last window.
+func (m *MultiFS) Sample(name string, offsets []int64, size int) ([][]byte, error) {
+	if size < 0 {
+		return nil, &fs.PathError{Op: "sample", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, off := range offsets {
+		if off < 0 {
+			return nil, &fs.PathError{Op: "sample", Path: name, Err: fs.ErrInvalid}
+		}
+	}
+
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { f.Close() }()
+
+	buf := make([]byte, len(offsets)*size)
+	windows := make([][]byte, len(offsets))
+	for i := range windows {
+		windows[i] = buf[i*size : (i+1)*size : (i+1)*size]
+	}
+
+	if ra, ok := f.(io.ReaderAt); ok {
+		for i, off := range offsets {
+			n, err := ra.ReadAt(windows[i], off)
+			if err != nil && err != io.EOF && !pastEnd(f, off, err) {
+				return nil, err
+			}
+			windows[i] = windows[i][:n]
+		}
+		return windows, nil
+	}
+
+	// Without range reads, visit the windows by increasing offset so the
+	// file is read at most once, unless windows overlap and the file
+	// cannot seek back, in which case it is opened again.
+	order := make([]int, len(offsets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return offsets[order[a]] < offsets[order[b]] })
+
+	var pos int64
+	for _, i := range order {
+		off := offsets[i]
+		if off != pos {
+			if pos, err = skipTo(f, pos, off); err != nil {
+				if !errors.Is(err, errors.ErrUnsupported) {
+					return nil, err
+				}
+				f.Close()
+				if f, err = m.Open(name); err != nil {
+					return nil, err
+				}
+				if pos, err = skipTo(f, 0, off); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if pos < off {
+			// End of file reached before the window.
+			windows[i] = windows[i][:0]
+			continue
+		}
+		n, err := io.ReadFull(f, windows[i])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		windows[i] = windows[i][:n]
+		pos += int64(n)
+	}
+	return windows, nil
+}
+
+// pastEnd reports whether err is how f rejects reading at an offset beyond
+// its end, as some ReadAt implementations do instead of returning io.EOF.
+func pastEnd(f fs.File, off int64, err error) bool {
+	if !errors.Is(err, fs.ErrInvalid) {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && off >= info.Size()
+}
+
+// skipTo moves f from pos to off, seeking when possible and reading
+// forward otherwise. It returns the position reached, which is short of off
+// at end of file, and errors.ErrUnsupported if off is behind a file that
+// cannot seek.
+func skipTo(f fs.File, pos, off int64) (int64, error) {
+	if s, ok := f.(io.Seeker); ok {
+		return s.Seek(off, io.SeekStart)
+	}
+	if off < pos {
+		return pos, errors.ErrUnsupported
+	}
+	n, err := io.CopyN(io.Discard, f, off-pos)
+	if err == io.EOF {
+		err = nil
+	}
+	return pos + n, err
+}
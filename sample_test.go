@@ -0,0 +1,46 @@
+package multifs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSample(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	streamOnly := func(ctx context.Context, name string, f fs.File) (fs.File, error) {
+		return struct{ fs.File }{f}, nil
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []MountOption
+	}{
+		{"ranged", nil},
+		{"stream", []MountOption{WithAfterOpen(streamOnly)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := NewMultiFS()
+			mux.Mount("m", fstest.MapFS{"f": &fstest.MapFile{Data: data}}, tc.opts...)
+
+			windows, err := mux.Sample("m/f", []int64{10, 0, 18, 2, 40}, 4)
+			if err != nil {
+				t.Fatalf("Sample: %v", err)
+			}
+			got := fmt.Sprintf("%q", windows)
+			if want := `["abcd" "0123" "ij" "2345" ""]`; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSampleInvalid(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("m", fstest.MapFS{"f": &fstest.MapFile{}})
+	if _, err := mux.Sample("m/f", []int64{-1}, 4); err == nil {
+		t.Fatal("expected an error for a negative offset")
+	}
+}
@@ -0,0 +1,228 @@
+package multifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sync"
+)
+
+// spoolMemory is how much of a file that cannot seek is kept in memory
+// before spooling it to a temporary file.
+const spoolMemory = 1 << 20
+
+// WithSeekableReads makes every file returned by Open, directories aside,
+// implement io.Seeker and io.ReaderAt, as http.ServeContent and archive
+// readers need. Files having one of them get the other derived from it;
+// files having neither are spooled as they are read, in memory then to a
+// temporary file, so that they can be read again.
+func WithSeekableReads() Option {
+	return func(o *options) {
+		o.seekable = true
+	}
+}
+
+func seekable(f fs.File) fs.File {
+	seeker, _ := f.(io.Seeker)
+	readerAt, _ := f.(io.ReaderAt)
+	if seeker != nil && readerAt != nil {
+		return f
+	}
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		return f
+	}
+
+	switch {
+	case readerAt != nil:
+		size := int64(0)
+		if info != nil {
+			size = info.Size()
+		}
+		return sectionFile{File: f, SectionReader: io.NewSectionReader(readerAt, 0, size)}
+	case seeker != nil:
+		return &seekerFile{File: f}
+	}
+	return &spoolFile{File: f}
+}
+
+// sectionFile reads a file through its ReadAt method.
+type sectionFile struct {
+	fs.File
+	*io.SectionReader
+}
+
+func (f sectionFile) Read(p []byte) (int, error) { return f.SectionReader.Read(p) }
+
+// seekerFile implements ReadAt by seeking, then seeking back.
+type seekerFile struct {
+	fs.File
+	mu sync.Mutex
+}
+
+func (f *seekerFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.Read(p)
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f *seekerFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.File.(io.Seeker)
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f.File, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if _, serr := s.Seek(pos, io.SeekStart); serr != nil && err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+// spoolFile keeps what it reads of a file, in memory then in a temporary
+// file, so that it can seek backwards and read at any offset.
+type spoolFile struct {
+	fs.File
+	mu  sync.Mutex
+	mem []byte
+	tmp *os.File
+	n   int64 // bytes spooled
+	eof error // error that ended the file, once read to its end
+	pos int64
+}
+
+// fill spools the file up to end, or to its end.
+func (f *spoolFile) fill(end int64) error {
+	buf := make([]byte, 32<<10)
+	for f.n < end && f.eof == nil {
+		n, err := f.File.Read(buf)
+		if n > 0 {
+			if werr := f.spool(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			f.eof = err
+		}
+	}
+	if f.eof != nil && f.eof != io.EOF {
+		return f.eof
+	}
+	return nil
+}
+
+func (f *spoolFile) spool(p []byte) error {
+	if f.tmp == nil && len(f.mem)+len(p) > spoolMemory {
+		tmp, err := os.CreateTemp("", "multifs-spool-")
+		if err != nil {
+			return err
+		}
+		if _, err := tmp.Write(f.mem); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		f.tmp, f.mem = tmp, nil
+	}
+	if f.tmp != nil {
+		if _, err := f.tmp.WriteAt(p, f.n); err != nil {
+			return err
+		}
+	} else {
+		f.mem = append(f.mem, p...)
+	}
+	f.n += int64(len(p))
+	return nil
+}
+
+func (f *spoolFile) readAt(p []byte, off int64) (int, error) {
+	if err := f.fill(off + int64(len(p))); err != nil {
+		return 0, err
+	}
+	if off >= f.n {
+		return 0, io.EOF
+	}
+	var n int
+	if f.tmp != nil {
+		var err error
+		n, err = f.tmp.ReadAt(p[:min(int64(len(p)), f.n-off)], off)
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+	} else {
+		n = copy(p, f.mem[off:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *spoolFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt(p, off)
+}
+
+func (f *spoolFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt(p, f.pos)
+	f.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *spoolFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		// The size reported by Stat may not be that of the contents read,
+		// for transformed files: the file is spooled to its end instead.
+		if err := f.fill(math.MaxInt64); err != nil {
+			return 0, err
+		}
+		offset += f.n
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Err: fs.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *spoolFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.File.Close()
+	if f.tmp != nil {
+		err = errors.Join(err, f.tmp.Close(), os.Remove(f.tmp.Name()))
+		f.tmp = nil
+	}
+	f.mem = nil
+	return err
+}
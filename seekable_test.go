@@ -0,0 +1,157 @@
+package multifs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// streamFS hides Seek and ReadAt from the files of a MapFS, keeping only
+// those given.
+type streamFS struct {
+	fstest.MapFS
+	seek, readAt bool
+}
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.(fs.ReadDirFile); ok {
+		return f, nil
+	}
+	var seeker io.Seeker
+	if s.seek {
+		seeker = f.(io.Seeker)
+	}
+	var readerAt io.ReaderAt
+	if s.readAt {
+		readerAt = f.(io.ReaderAt)
+	}
+	return compose(f, seeker, readerAt, nil, nil), nil
+}
+
+func TestSeekableReads(t *testing.T) {
+	small := []byte("0123456789")
+	large := bytes.Repeat([]byte("abcdefghijklmnopq"), spoolMemory/16)
+	files := fstest.MapFS{
+		"small": &fstest.MapFile{Data: small},
+		"large": &fstest.MapFile{Data: large},
+	}
+	mux := NewMultiFS(WithSeekableReads())
+	mux.Mount("stream", streamFS{MapFS: files})
+	mux.Mount("seek", streamFS{MapFS: files, seek: true})
+	mux.Mount("readat", streamFS{MapFS: files, readAt: true})
+
+	plain := NewMultiFS()
+	plain.Mount("stream", streamFS{MapFS: files})
+	f, _ := plain.Open("stream/small")
+	if _, ok := f.(io.Seeker); ok {
+		t.Fatal("stream files seek without WithSeekableReads")
+	}
+	f.Close()
+
+	for _, id := range []string{"stream", "seek", "readat"} {
+		for name, data := range map[string][]byte{"small": small, "large": large} {
+			f, err := mux.Open(id + "/" + name)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			s, ok1 := f.(io.Seeker)
+			ra, ok2 := f.(io.ReaderAt)
+			if !ok1 || !ok2 {
+				t.Fatalf("%s/%s: Seeker %v, ReaderAt %v", id, name, ok1, ok2)
+			}
+
+			// Read past the memory spool, then go back
+			buf := make([]byte, 5)
+			off := int64(len(data) - 7)
+			if n, err := ra.ReadAt(buf, off); n != 5 || err != nil || !bytes.Equal(buf, data[off:off+5]) {
+				t.Fatalf("%s/%s: ReadAt: %d %v %q", id, name, n, err, buf)
+			}
+			if n, err := ra.ReadAt(buf, off+4); n != 3 || err != io.EOF {
+				t.Fatalf("%s/%s: ReadAt at the end: %d %v", id, name, n, err)
+			}
+			if pos, err := s.Seek(-3, io.SeekEnd); err != nil || pos != int64(len(data)-3) {
+				t.Fatalf("%s/%s: Seek: %d %v", id, name, pos, err)
+			}
+			if got, _ := io.ReadAll(f); !bytes.Equal(got, data[len(data)-3:]) {
+				t.Fatalf("%s/%s: read after Seek: %q", id, name, got)
+			}
+			s.Seek(2, io.SeekStart)
+			if got, _ := io.ReadAll(f); !bytes.Equal(got, data[2:]) {
+				t.Fatalf("%s/%s: read after seeking back differs", id, name)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		}
+	}
+
+	// Directories are left alone
+	d, err := mux.Open("stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.(fs.ReadDirFile); !ok {
+		t.Fatal("directory lost ReadDir")
+	}
+	d.Close()
+}
+
+func TestSeekableServeContent(t *testing.T) {
+	mux := NewMultiFS(WithSeekableReads())
+	mux.Mount("s", streamFS{MapFS: fstest.MapFS{"f": &fstest.MapFile{Data: []byte("0123456789")}}})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := mux.Open("s/f")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		http.ServeContent(w, r, "f", time.Time{}, f.(io.ReadSeeker))
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "345" {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestSeekableTransformedEnd(t *testing.T) {
+	text := strings.Repeat("0123456789", 1200)
+	mux := NewMultiFS(WithSeekableReads())
+	mux.Mount("z", fstest.MapFS{"a.gz": &fstest.MapFile{Data: gzipped(t, text)}}, WithTransform(Gunzip))
+
+	f, err := mux.Open("z/a.gz")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	s := f.(io.Seeker)
+	if end, err := s.Seek(0, io.SeekEnd); err != nil || end != int64(len(text)) {
+		t.Fatalf("Seek to the end: %d, %v, want %d", end, err, len(text))
+	}
+	if pos, err := s.Seek(-4, io.SeekEnd); err != nil || pos != int64(len(text)-4) {
+		t.Fatalf("Seek before the end: %d, %v", pos, err)
+	}
+	if got, _ := io.ReadAll(f); string(got) != "6789" {
+		t.Fatalf("read after Seek: %q", got)
+	}
+}
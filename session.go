@@ -0,0 +1,131 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+)
+
+// ErrMountBusy is returned when unmounting an id that is still in use.
+var ErrMountBusy = errors.New("multifs: mount is busy")
+
+// Capabilities tells which optional interfaces a mounted filesystem
+// offers, once its mount options are applied.
+type Capabilities struct {
+	Context  bool `json:"context"`
+	Stat     bool `json:"stat"`
+	ReadDir  bool `json:"readdir"`
+	ReadFile bool `json:"readfile"`
+	OpenFile bool `json:"openfile"`
+	Mkdir    bool `json:"mkdir"`
+	Remove   bool `json:"remove"`
+	Rename   bool `json:"rename"`
+	Chmod    bool `json:"chmod"`
+	Chtimes  bool `json:"chtimes"`
+	Chown    bool `json:"chown"`
+	Symlink  bool `json:"symlink"`
+	Link     bool `json:"link"`
+	Xattr    bool `json:"xattr"`
+	Sparse   bool `json:"sparse"`
+	Watch    bool `json:"watch"`
+}
+
+func capabilitiesOf(fsys fs.FS) Capabilities {
+	var c Capabilities
+	_, c.Context = fsys.(ContextFS)
+	_, c.Stat = fsys.(fs.StatFS)
+	_, c.ReadDir = fsys.(fs.ReadDirFS)
+	_, c.ReadFile = fsys.(fs.ReadFileFS)
+	_, c.OpenFile = fsys.(OpenFileFS)
+	_, c.Mkdir = fsys.(MkdirFS)
+	_, c.Remove = fsys.(RemoveFS)
+	_, c.Rename = fsys.(RenameFS)
+	_, c.Chmod = fsys.(ChmodFS)
+	_, c.Chtimes = fsys.(ChtimesFS)
+	_, c.Chown = fsys.(ChownFS)
+	_, c.Symlink = fsys.(SymlinkFS)
+	_, c.Link = fsys.(LinkFS)
+	_, c.Watch = fsys.(WatchableFS)
+
+	// Read-only mounts pass these through whether the filesystem has
+	// them or not
+	switch ro := fsys.(type) {
+	case readOnlyFS:
+		fsys = ro.fsys
+	case readOnlyContextFS:
+		fsys = ro.fsys
+	}
+	_, c.Xattr = fsys.(XattrFS)
+	_, c.Sparse = fsys.(SparseFS)
+	return c
+}
+
+// MountCapabilities is a row of the capability matrix.
+type MountCapabilities struct {
+	ID       string `json:"id"`
+	ReadOnly bool   `json:"readonly"`
+	Capabilities
+}
+
+// CapabilityMatrix returns the capabilities of every mount in root listing
+// order. It marshals to JSON as a list of flat objects, for frontends to
+// tell which actions to offer on each mount.
+func (m *MultiFS) CapabilityMatrix() []MountCapabilities {
+	t := m.tab.Load()
+	ids := t.ids()
+	matrix := make([]MountCapabilities, 0, len(ids))
+	for _, id := range ids {
+		mnt, _ := t.roots.get(id)
+		matrix = append(matrix, MountCapabilities{
+			ID:           id,
+			ReadOnly:     mnt.opts.readOnly,
+			Capabilities: capabilitiesOf(mnt.fsys),
+		})
+	}
+	return matrix
+}
+
+// Session holds on to a mount: until it is closed, the id cannot be
+// unmounted. A Replace does not affect the session either, which keeps
+// using the filesystem it was opened on: like a View, it keeps the
+// filesystems opened by MountDir and MountURL open.
+type Session struct {
+	mnt  *mount
+	once sync.Once
+}
+
+func (m *MultiFS) OpenSession(id string) (*Session, error) {
+	// Unmount checks the sessions with m.mu held for writing, so that it
+	// cannot miss this one
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, mnt, ok := m.tab.Load().lookup(id)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	if o := mnt.opts.owned; o != nil && !o.acquire() {
+		return nil, fs.ErrNotExist
+	}
+	mnt.sessions.Add(1)
+	return &Session{mnt: mnt}, nil
+}
+
+func (s *Session) ID() string { return s.mnt.id }
+
+// FS returns the mounted filesystem, with the mount's options applied.
+// Paths are relative to the mount.
+func (s *Session) FS() fs.FS { return s.mnt }
+
+func (s *Session) Capabilities() Capabilities { return capabilitiesOf(s.mnt.fsys) }
+
+func (s *Session) Close() error {
+	s.once.Do(func() {
+		s.mnt.sessions.Add(-1)
+		s.mnt.release()
+		if o := s.mnt.opts.owned; o != nil {
+			o.release()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,111 @@
+package multifs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSessionPinsMount(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}})
+
+	s, err := mux.OpenSession("snap")
+	if err != nil {
+		t.Fatalf("OpenSession: %v", err)
+	}
+	if err := mux.Unmount("snap"); !errors.Is(err, ErrMountBusy) {
+		t.Fatalf("expected ErrMountBusy, got %v", err)
+	}
+
+	// Replacing the mount does not pull the filesystem from under the
+	// session.
+	if err := mux.Replace("snap", fstest.MapFS{}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if data, err := fs.ReadFile(s.FS(), "a.txt"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile through session: %q, %v", data, err)
+	}
+
+	s.Close()
+	s.Close()
+	if err := mux.Unmount("snap"); err != nil {
+		t.Fatalf("Unmount after Close: %v", err)
+	}
+	if _, err := mux.OpenSession("snap"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestSessionCapabilities(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("rw", newDirFS(t))
+	mux.Mount("ro", newDirFS(t), WithReadOnly())
+
+	rw, _ := mux.OpenSession("rw")
+	defer rw.Close()
+	if c := rw.Capabilities(); !c.OpenFile || !c.Remove || !c.Rename || !c.Mkdir {
+		t.Fatalf("writable mount: got %+v", c)
+	}
+
+	ro, _ := mux.OpenSession("ro")
+	defer ro.Close()
+	if c := ro.Capabilities(); c.OpenFile || c.Remove || c.Chmod || !c.Stat || !c.ReadDir || c.Xattr {
+		t.Fatalf("read-only mount: got %+v", c)
+	}
+
+	mux.Mount("attrs", xattrFS{MapFS: fstest.MapFS{}}, WithReadOnly())
+	attrs, _ := mux.OpenSession("attrs")
+	defer attrs.Close()
+	if c := attrs.Capabilities(); !c.Xattr {
+		t.Fatalf("read-only mount with attributes: got %+v", c)
+	}
+}
+
+func TestCapabilityMatrixJSON(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("ro", fstest.MapFS{}, WithReadOnly())
+	mux.Mount("rw", newDirFS(t))
+
+	data, err := json.Marshal(mux.CapabilityMatrix())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `[{"id":"ro","readonly":true,"context":false,"stat":true,"readdir":true,"readfile":true,` +
+		`"openfile":false,"mkdir":false,"remove":false,"rename":false,"chmod":false,"chtimes":false,` +
+		`"chown":false,"symlink":false,"link":false,"xattr":false,"sparse":false,"watch":false},` +
+		`{"id":"rw","readonly":false,"context":false,"stat":false,"readdir":false,"readfile":false,` +
+		`"openfile":true,"mkdir":true,"remove":true,"rename":true,"chmod":true,"chtimes":true,` +
+		`"chown":true,"symlink":true,"link":true,"xattr":false,"sparse":false,"watch":false}]`
+	if string(data) != want {
+		t.Fatalf("got  %s\nwant %s", data, want)
+	}
+}
+
+func TestSessionHoldsOwnedFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mux := NewMultiFS()
+	if err := mux.MountDir("snap", dir); err != nil {
+		t.Fatalf("MountDir: %v", err)
+	}
+	s, err := mux.OpenSession("snap")
+	if err != nil {
+		t.Fatalf("OpenSession: %v", err)
+	}
+	defer s.Close()
+
+	// The directory stays open for the session once replaced
+	if err := mux.Replace("snap", fstest.MapFS{}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if data, err := fs.ReadFile(s.FS(), "a.txt"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile through session: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,213 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Router picks which of n shards holds the file name.
+type Router func(name string, n int) int
+
+// HashRouter routes files by the FNV-1a hash of their path.
+func HashRouter(name string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(n))
+}
+
+// MountShards mounts under id a tree sharded across shards: each file is
+// stored in the shard its path is routed to by router, HashRouter when
+// nil, while directories exist in any shard and list the union of their
+// entries in all of them. The tree is read-only.
+func (m *MultiFS) MountShards(id string, shards []fs.FS, router Router, opts ...MountOption) error {
+	if len(shards) == 0 || slices.Contains(shards, nil) {
+		return errors.New("multifs: shards are missing")
+	}
+	if router == nil {
+		router = HashRouter
+	}
+	return m.Mount(id, &shardedFS{shards: slices.Clone(shards), route: router}, opts...)
+}
+
+type shardedFS struct {
+	shards []fs.FS
+	route  Router
+}
+
+var _ fs.StatFS = (*shardedFS)(nil)
+var _ fs.ReadDirFS = (*shardedFS)(nil)
+
+// shard returns the index of the shard holding the file name.
+func (s *shardedFS) shard(name string) int {
+	i := s.route(name, len(s.shards))
+	if i < 0 || i >= len(s.shards) {
+		return 0
+	}
+	return i
+}
+
+func (s *shardedFS) Open(name string) (fs.File, error) {
+	return s.OpenContext(context.Background(), name)
+}
+
+func (s *shardedFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		var f fs.File
+		var err error
+		if cfs, ok := s.shards[s.shard(name)].(ContextFS); ok {
+			f, err = cfs.OpenContext(ctx, name)
+		} else {
+			f, err = s.shards[s.shard(name)].Open(name)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			info, err := f.Stat()
+			if err != nil || !info.IsDir() {
+				return f, nil
+			}
+			f.Close()
+		}
+	}
+	info, dirs, err := s.dir(ctx, "open", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.readDir(ctx, name, dirs)
+	if err != nil {
+		return nil, err
+	}
+	return &staticDir{info: info, entries: entries}, nil
+}
+
+func (s *shardedFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		info, err := fs.Stat(s.shards[s.shard(name)], name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return info, err
+		}
+	}
+	info, _, err := s.dir(context.Background(), "stat", name)
+	return info, err
+}
+
+func (s *shardedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	_, dirs, err := s.dir(context.Background(), "readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.readDir(context.Background(), name, dirs)
+}
+
+// fanOut calls fn on the shards of the given indices concurrently, and
+// returns the first error other than fs.ErrNotExist.
+func (s *shardedFS) fanOut(indices []int, fn func(i int, shard fs.FS) error) error {
+	errs := make([]error, len(indices))
+	var wg sync.WaitGroup
+	for j, i := range indices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[j] = fn(i, s.shards[i])
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// dir returns the info of the directory name, from the first shard
+// holding it, and the indices of the shards holding it.
+func (s *shardedFS) dir(ctx context.Context, op, name string) (fs.FileInfo, []int, error) {
+	all := make([]int, len(s.shards))
+	for i := range all {
+		all[i] = i
+	}
+	infos := make([]fs.FileInfo, len(s.shards))
+	err := s.fanOut(all, func(i int, shard fs.FS) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := fs.Stat(shard, name)
+		if err == nil && info.IsDir() {
+			infos[i] = info
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var first fs.FileInfo
+	var dirs []int
+	for i, info := range infos {
+		if info == nil {
+			continue
+		}
+		if first == nil {
+			first = info
+		}
+		dirs = append(dirs, i)
+	}
+	if first == nil {
+		if op == "readdir" && name != "." {
+			if _, err := fs.Stat(s.shards[s.shard(name)], name); err == nil {
+				return nil, nil, &fs.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+			}
+		}
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return mergedInfo(name, first), dirs, nil
+}
+
+// readDir lists the union of the directory name in dirs. Files are only
+// listed from the shard they are routed to, where they are opened from.
+func (s *shardedFS) readDir(ctx context.Context, name string, dirs []int) ([]fs.DirEntry, error) {
+	listings := make([][]fs.DirEntry, len(s.shards))
+	err := s.fanOut(dirs, func(i int, shard fs.FS) (err error) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		listings[i], err = fs.ReadDir(shard, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for i, listing := range listings {
+		for _, e := range listing {
+			if seen[e.Name()] {
+				continue
+			}
+			if !e.IsDir() && s.shard(path.Join(name, e.Name())) != i {
+				continue
+			}
+			seen[e.Name()] = true
+			entries = append(entries, e)
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
@@ -0,0 +1,59 @@
+package multifs
+
+import (
+	"io/fs"
+	"path"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountShards(t *testing.T) {
+	files := []string{"a", "b", "dir/c", "dir/d", "dir/sub/e", "f", "g", "h"}
+	shards := []fs.FS{fstest.MapFS{}, fstest.MapFS{}, fstest.MapFS{}}
+	for _, name := range files {
+		shards[HashRouter(name, len(shards))].(fstest.MapFS)[name] = &fstest.MapFile{Data: []byte(name)}
+	}
+	// Strays on other shards are not listed, and not served
+	stray := shards[(HashRouter("a", 3)+1)%3].(fstest.MapFS)
+	stray["a"] = &fstest.MapFile{Data: []byte("stray")}
+
+	mux := NewMultiFS()
+	if err := mux.MountShards("s", shards, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]string, len(files))
+	for i, name := range files {
+		expected[i] = path.Join("s", name)
+	}
+	if err := fstest.TestFS(mux, expected...); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(mux, "s/a"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	entries, err := fs.ReadDir(mux, "s/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, []string{"c", "d", "sub"}) {
+		t.Fatalf("ReadDir: %v", names)
+	}
+}
+
+func TestMountShardsRouter(t *testing.T) {
+	byFirstLetter := func(name string, n int) int { return int(path.Base(name)[0]) % n }
+	shards := []fs.FS{
+		fstest.MapFS{"x/b": &fstest.MapFile{}},
+		fstest.MapFS{"x/a": &fstest.MapFile{}},
+	}
+	mux := NewMultiFS()
+	mux.MountShards("s", shards, byFirstLetter)
+	if err := fstest.TestFS(mux, "s/x/a", "s/x/b"); err != nil {
+		t.Fatal(err)
+	}
+}
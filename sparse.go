@@ -0,0 +1,217 @@
+package multifs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// holeBlock is the granularity of zero-run detection: shorter runs of
+// zeros are not worth a hole on most filesystems.
+const holeBlock = 4096
+
+var zeroBlock [holeBlock]byte
+
+// Extent is a region of a file holding data. The rest of a sparse file is
+// made of holes, which read as zeros.
+type Extent struct {
+	Offset, Length int64
+}
+
+// SparseFS is implemented by filesystems that know where the holes of
+// their files are. Extents returns the data regions of name in increasing
+// order; a file without holes has a single extent covering it.
+type SparseFS interface {
+	fs.FS
+	Extents(name string) ([]Extent, error)
+}
+
+var _ SparseFS = (*MultiFS)(nil)
+
+// Extents returns the data regions of name, as reported by its mount's
+// filesystem when it implements SparseFS, or else found by reading the
+// file, blocks of zeros counting as holes.
+func (m *MultiFS) Extents(name string) ([]Extent, error) {
+	extents, _, err := m.extents(context.Background(), name, true)
+	return extents, err
+}
+
+// extents is Extents, only reading the file when scan is set. ok is false
+// when the extents are unknown.
+func (m *MultiFS) extents(ctx context.Context, name string, scan bool) (extents []Extent, ok bool, err error) {
+	r, err := m.resolve("extents", name)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.mnt != nil {
+		if extents, ok, err := r.mnt.extents(ctx, r.subpath); ok || err != nil {
+			return extents, ok, err
+		}
+	}
+	if !scan {
+		return nil, false, nil
+	}
+	f, err := m.OpenContext(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	extents, err = scanExtents(f)
+	return extents, err == nil, err
+}
+
+// extents returns the extents the mount's filesystem reports for name. ok
+// is false when it cannot tell, or when the mount changes file contents.
+func (mnt *mount) extents(ctx context.Context, name string) (extents []Extent, ok bool, err error) {
+	s, ok := mnt.fsys.(SparseFS)
+	if !ok || mnt.opts.decrypter != nil || mnt.opts.transform != nil || len(mnt.opts.afterOpen) > 0 {
+		return nil, false, nil
+	}
+	if err := mnt.check(ctx, OpOpen, name); err != nil {
+		return nil, false, err
+	}
+	bname, err := mnt.backendName("extents", name)
+	if err != nil {
+		return nil, false, err
+	}
+	extents, err = s.Extents(bname)
+	return extents, err == nil, mnt.record("extents", name, err)
+}
+
+func (r readOnlyFS) Extents(name string) ([]Extent, error) {
+	s, ok := r.fsys.(SparseFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "extents", Path: name, Err: errors.ErrUnsupported}
+	}
+	return s.Extents(name)
+}
+
+// sparse reports whether extents leave holes in a file of the given size.
+func sparse(extents []Extent, size int64) bool {
+	return len(extents) != 1 || extents[0].Offset != 0 || extents[0].Length != size
+}
+
+// runs splits p, found at off in a file, into runs of data and runs of
+// holeBlock-aligned zeros, calling fn with each.
+func runs(p []byte, off int64, fn func(p []byte, zero bool) error) error {
+	for len(p) > 0 {
+		zero := isZeroBlock(p, off)
+		n := 0
+		for n < len(p) && isZeroBlock(p[n:], off+int64(n)) == zero {
+			n += min(int(holeBlock-(off+int64(n))%holeBlock), len(p)-n)
+		}
+		if err := fn(p[:n], zero); err != nil {
+			return err
+		}
+		p, off = p[n:], off+int64(n)
+	}
+	return nil
+}
+
+// isZeroBlock reports whether the block of p starting at off, up to the
+// next holeBlock boundary, only holds zeros.
+func isZeroBlock(p []byte, off int64) bool {
+	n := min(int(holeBlock-off%holeBlock), len(p))
+	return bytes.Equal(p[:n], zeroBlock[:n])
+}
+
+// scanExtents reads r to its end and returns its runs of data.
+func scanExtents(r io.Reader) ([]Extent, error) {
+	var extents []Extent
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+	buf := *bufp
+	off := int64(0)
+	for {
+		n, err := io.ReadFull(r, buf)
+		runs(buf[:n], off, func(p []byte, zero bool) error {
+			if !zero {
+				if last := len(extents) - 1; last >= 0 && extents[last].Offset+extents[last].Length == off {
+					extents[last].Length += int64(len(p))
+				} else {
+					extents = append(extents, Extent{Offset: off, Length: int64(len(p))})
+				}
+			}
+			off += int64(len(p))
+			return nil
+		})
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return extents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// copySparse copies src to dst, seeking over blocks of zeros instead of
+// writing them, so that dst gets holes where its filesystem supports them.
+func copySparse(dst File, src io.Reader) error {
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+	buf := *bufp
+	off := int64(0)
+	hole := false
+	for {
+		n, err := io.ReadFull(src, buf)
+		werr := runs(buf[:n], off, func(p []byte, zero bool) error {
+			var err error
+			if zero {
+				_, err = dst.Seek(int64(len(p)), io.SeekCurrent)
+			} else {
+				_, err = dst.Write(p)
+			}
+			off += int64(len(p))
+			hole = zero
+			return err
+		})
+		if werr != nil {
+			return werr
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// A trailing hole is only made by extending the file
+	if hole {
+		return dst.Truncate(off)
+	}
+	return nil
+}
+
+// copyExtents copies the extents of src, a file of the given size, to
+// dst, leaving holes in between.
+func copyExtents(dst File, src io.Reader, extents []Extent, size int64) error {
+	pos := int64(0)
+	for _, e := range extents {
+		if err := skip(src, e.Offset-pos); err != nil {
+			return err
+		}
+		if _, err := dst.Seek(e.Offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := copyN(dst, src, e.Length); err != nil {
+			return err
+		}
+		pos = e.Offset + e.Length
+	}
+	return dst.Truncate(size)
+}
+
+// skip moves r n bytes forward, seeking when it can.
+func skip(r io.Reader, n int64) error {
+	if n == 0 {
+		return nil
+	}
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
@@ -0,0 +1,176 @@
+package multifs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// sparseFS is a MapFS reporting the extents of its files.
+type sparseFS struct {
+	fstest.MapFS
+	extents map[string][]Extent
+}
+
+func (s sparseFS) Extents(name string) ([]Extent, error) {
+	return s.extents[name], nil
+}
+
+// newSparseFile returns a file of size bytes holding data at each offset,
+// and its extents.
+func newSparseFile(size int64, data map[int64]string) ([]byte, []Extent) {
+	b := make([]byte, size)
+	var extents []Extent
+	for _, off := range slices.Sorted(maps.Keys(data)) {
+		copy(b[off:], data[off])
+		extents = append(extents, Extent{Offset: off, Length: int64(len(data[off]))})
+	}
+	return b, extents
+}
+
+func TestExtents(t *testing.T) {
+	data, _ := newSparseFile(3*holeBlock+10, map[int64]string{1: "a", 2*holeBlock + 5: "b"})
+	mux := NewMultiFS()
+	mux.Mount("plain", fstest.MapFS{"f": &fstest.MapFile{Data: data}, "empty": &fstest.MapFile{}})
+
+	extents, err := mux.Extents("plain/f")
+	if err != nil {
+		t.Fatalf("Extents: %v", err)
+	}
+	want := []Extent{{0, holeBlock}, {2 * holeBlock, holeBlock}}
+	if !slices.Equal(extents, want) {
+		t.Fatalf("got %v, want %v", extents, want)
+	}
+	if extents, err := mux.Extents("plain/empty"); err != nil || len(extents) != 0 {
+		t.Fatalf("Extents of an empty file: %v, %v", extents, err)
+	}
+}
+
+func TestWriteTarSparse(t *testing.T) {
+	const size = 1 << 20
+	data, extents := newSparseFile(size, map[int64]string{0: "head", 600000: "middle"})
+	mux := NewMultiFS()
+	mux.Mount("vm", sparseFS{
+		MapFS:   fstest.MapFS{"disk.img": &fstest.MapFile{Data: data, Mode: 0o600}},
+		extents: map[string][]Extent{"disk.img": extents},
+	}, WithReadOnly())
+	mux.Mount("plain", fstest.MapFS{"disk.img": &fstest.MapFile{Data: data}})
+
+	read := func(opts TarOptions, root string) (*tar.Header, []byte, int) {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := mux.WriteTarWith(context.Background(), &buf, opts, root); err != nil {
+			t.Fatalf("WriteTar: %v", err)
+		}
+		n := buf.Len()
+		tr := tar.NewReader(&buf)
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return hdr, got, n
+	}
+
+	hdr, got, n := read(TarOptions{}, "vm/disk.img")
+	if hdr.Name != "disk.img" || hdr.Size != size || hdr.Mode&0o777 != 0o600 {
+		t.Fatalf("header: %q size %d mode %o", hdr.Name, hdr.Size, hdr.Mode)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("contents differ")
+	}
+	if n > 8*tarBlock {
+		t.Fatalf("archive of %d bytes", n)
+	}
+
+	// Without SparseFS, holes are only found on demand
+	if _, _, n := read(TarOptions{}, "plain/disk.img"); n < size {
+		t.Fatalf("archive of %d bytes without DetectHoles", n)
+	}
+	hdr, got, n = read(TarOptions{DetectHoles: true}, "plain/disk.img")
+	if hdr.Size != size || !bytes.Equal(got, data) || n > 4*holeBlock {
+		t.Fatalf("DetectHoles: size %d, archive of %d bytes", hdr.Size, n)
+	}
+}
+
+// countingFS counts the bytes written to its files.
+type countingFS struct {
+	dirFS
+	written *int64
+}
+
+func (c countingFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := c.dirFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return writeCountingFile{File: f, written: c.written}, nil
+}
+
+type writeCountingFile struct {
+	File
+	written *int64
+}
+
+func (f writeCountingFile) Write(p []byte) (int, error) {
+	*f.written += int64(len(p))
+	return f.File.Write(p)
+}
+
+// sparseDirFS is a dirFS reporting the extents of its files.
+type sparseDirFS struct {
+	dirFS
+	extents map[string][]Extent
+}
+
+func (s sparseDirFS) Extents(name string) ([]Extent, error) {
+	return s.extents[name], nil
+}
+
+func TestMoveSparse(t *testing.T) {
+	const size = 1 << 20
+	data, extents := newSparseFile(size, map[int64]string{10: "head", size - 1: "z"})
+	src := sparseDirFS{dirFS: newDirFS(t), extents: map[string][]Extent{"disk.img": extents}}
+	writeFile(t, src, "disk.img", string(data))
+	plain := newDirFS(t)
+	writeFile(t, plain, "disk.img", "x"+string(data[1:]))
+
+	var written int64
+	dst := countingFS{dirFS: newDirFS(t), written: &written}
+	mux := NewMultiFS()
+	mux.Mount("src", src)
+	mux.Mount("plain", plain)
+	mux.Mount("dst", dst)
+
+	check := func(name string, want []byte, wantWritten int64) {
+		t.Helper()
+		got, err := os.ReadFile(dst.path(name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: contents differ, %v", name, err)
+		}
+		if written != wantWritten {
+			t.Fatalf("%s: %d bytes written, want %d", name, written, wantWritten)
+		}
+		written = 0
+	}
+	if err := mux.Move("src/disk.img", "dst/a.img"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	check("a.img", data, 5)
+
+	// Without SparseFS, blocks of zeros are skipped
+	if err := mux.Move("plain/disk.img", "dst/b.img"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	check("b.img", append([]byte("x"), data[1:]...), 2*holeBlock)
+}
@@ -0,0 +1,79 @@
+package multifs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+)
+
+// WithStatFunc registers a function that may enrich or replace the
+// FileInfo the mount reports for a path, for example to fill in sizes
+// known from an index when the backend reports none. It applies to Stat,
+// to opened files and to directory listings, so listings are corrected
+// without opening each file.
+func WithStatFunc(fn func(name string, info fs.FileInfo) fs.FileInfo) MountOption {
+	return func(o *mountOptions) {
+		o.statFuncs = append(o.statFuncs, fn)
+	}
+}
+
+func (mnt *mount) enrich(name string, info fs.FileInfo) fs.FileInfo {
+	info = mnt.logicalInfo(name, info)
+	for _, fn := range mnt.opts.statFuncs {
+		info = fn(name, info)
+	}
+	return info
+}
+
+func (mnt *mount) enrichFile(name string, f fs.File) fs.File {
+	ef := enrichedFile{File: f, mnt: mnt, name: name}
+	var dir readDirer
+	if d, ok := f.(fs.ReadDirFile); ok {
+		dir = enrichedDir{enrichedFile: ef, dir: d}
+	}
+	seeker, _ := f.(io.Seeker)
+	readerAt, _ := f.(io.ReaderAt)
+	writer, _ := f.(io.Writer)
+	return compose(ef, seeker, readerAt, writer, dir)
+}
+
+type enrichedFile struct {
+	fs.File
+	mnt  *mount
+	name string
+}
+
+func (f enrichedFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return f.mnt.enrich(f.name, info), nil
+}
+
+type enrichedDir struct {
+	enrichedFile
+	dir fs.ReadDirFile
+}
+
+func (d enrichedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.dir.ReadDir(n)
+	for i, e := range entries {
+		entries[i] = enrichedEntry{DirEntry: e, mnt: d.mnt, name: path.Join(d.name, e.Name())}
+	}
+	return entries, err
+}
+
+type enrichedEntry struct {
+	fs.DirEntry
+	mnt  *mount
+	name string
+}
+
+func (e enrichedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.mnt.enrich(e.name, info), nil
+}
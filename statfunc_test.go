@@ -0,0 +1,43 @@
+package multifs
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithStatFunc(t *testing.T) {
+	sizes := map[string]int64{"dir/a.bin": 4096}
+	enrich := func(name string, info fs.FileInfo) fs.FileInfo {
+		if size, ok := sizes[name]; ok && info.Size() == 0 {
+			return sizedInfo{FileInfo: info, size: size}
+		}
+		return info
+	}
+
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"dir/a.bin": &fstest.MapFile{}}, WithStatFunc(enrich))
+
+	info, err := fs.Stat(mux, "snap/dir/a.bin")
+	if err != nil || info.Size() != 4096 {
+		t.Fatalf("Stat: size %v, err %v", info, err)
+	}
+
+	entries, err := fs.ReadDir(mux, "snap/dir")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	info, err = entries[0].Info()
+	if err != nil || info.Size() != 4096 {
+		t.Fatalf("listing: size %v, err %v", info, err)
+	}
+
+	f, err := mux.Open("snap/dir/a.bin")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() != 4096 {
+		t.Fatalf("file Stat: size %v, err %v", info, err)
+	}
+}
@@ -0,0 +1,55 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+// MountStats are counters of the operations made on a mount through
+// MultiFS since it was mounted.
+type MountStats struct {
+	Opens        int64
+	BytesRead    int64
+	BytesWritten int64
+	Errors       int64
+	// LastAccess is the time of the last operation, zero if none.
+	LastAccess time.Time
+}
+
+func (m *MultiFS) MountStats(id string) (MountStats, error) {
+	mnt, ok := m.mount(id)
+	if !ok {
+		return MountStats{}, fs.ErrNotExist
+	}
+	s := MountStats{
+		Opens:        mnt.usage.opens.Load(),
+		BytesRead:    mnt.usage.bytesRead.Load(),
+		BytesWritten: mnt.usage.bytesWritten.Load(),
+		Errors:       mnt.usage.errors.Load(),
+	}
+	if t := mnt.usage.lastAccess.Load(); t != 0 {
+		s.LastAccess = time.Unix(0, t)
+	}
+	return s, nil
+}
+
+// Size is the result of ComputeSize.
+type Size struct {
+	Files int64
+	Dirs  int64
+	// Bytes is the total size of the regular files.
+	Bytes    int64
+	Computed time.Time
+}
+
+// ComputeSize walks the filesystem mounted under id and totals its files.
+// The result is cached until the mount is written to through MultiFS or
+// its cache is invalidated with InvalidateCache.
+func (m *MultiFS) ComputeSize(ctx context.Context, id string) (Size, error) {
+	mnt, ok := m.mount(id)
+	if !ok {
+		return Size{}, fs.ErrNotExist
+	}
+	return mnt.diskUsage(ctx, ".", nil)
+}
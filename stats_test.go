@@ -0,0 +1,65 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMountStats(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"a": &fstest.MapFile{Data: []byte("hello")}})
+
+	if s, err := mux.MountStats("snap"); err != nil || s != (MountStats{}) {
+		t.Fatalf("MountStats before use: %+v, %v", s, err)
+	}
+	before := time.Now()
+	fs.ReadFile(mux, "snap/a")
+	mux.Stat("snap/missing")
+
+	s, err := mux.MountStats("snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Opens != 1 || s.BytesRead != 5 || s.Errors != 1 || s.LastAccess.Before(before) {
+		t.Fatalf("MountStats: %+v", s)
+	}
+	if _, err := mux.MountStats("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("MountStats on missing mount: %v", err)
+	}
+}
+
+func TestComputeSize(t *testing.T) {
+	d := newDirFS(t)
+	writeFile(t, d, "a", "hello")
+	d.Mkdir("sub", 0o755)
+	writeFile(t, d, "sub/b", "bee")
+	mux := NewMultiFS()
+	mux.Mount("d", d)
+	ctx := context.Background()
+
+	size, err := mux.ComputeSize(ctx, "d")
+	if err != nil || size.Files != 2 || size.Dirs != 2 || size.Bytes != 8 {
+		t.Fatalf("ComputeSize: %+v, %v", size, err)
+	}
+	if again, _ := mux.ComputeSize(ctx, "d"); again != size {
+		t.Fatalf("size not cached: %+v", again)
+	}
+
+	writeFile(t, d, "c", "sea")
+	if size, _ := mux.ComputeSize(ctx, "d"); size.Files != 2 {
+		t.Fatalf("ComputeSize after backend change: %+v", size)
+	}
+	if err := mux.Move("d/c", "d/sub/c"); err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := mux.ComputeSize(ctx, "d"); size.Files != 3 || size.Bytes != 11 {
+		t.Fatalf("ComputeSize after Move: %+v", size)
+	}
+	if _, err := mux.ComputeSize(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ComputeSize on missing mount: %v", err)
+	}
+}
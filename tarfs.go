@@ -0,0 +1,238 @@
+package multifs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MountZip mounts the zip archive held by r, of the given size. The
+// archive's directory is only read on first access.
+func (m *MultiFS) MountZip(id string, r io.ReaderAt, size int64, opts ...MountOption) error {
+	return m.Mount(id, &lazyFS{build: func() (fs.FS, error) {
+		return zip.NewReader(r, size)
+	}}, opts...)
+}
+
+// MountTar mounts the tar archive held by r. The archive is scanned for
+// its index on first access; file contents are then read in place.
+func (m *MultiFS) MountTar(id string, r io.ReaderAt, opts ...MountOption) error {
+	return m.Mount(id, newTarFS(r), opts...)
+}
+
+// lazyFS builds its filesystem on first use.
+type lazyFS struct {
+	once  sync.Once
+	build func() (fs.FS, error)
+	fsys  fs.FS
+	err   error
+}
+
+func (l *lazyFS) Open(name string) (fs.File, error) {
+	l.once.Do(func() { l.fsys, l.err = l.build() })
+	if l.err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: l.err}
+	}
+	return l.fsys.Open(name)
+}
+
+// tarFS serves a tar archive read through an io.ReaderAt.
+type tarFS struct {
+	r     io.ReaderAt
+	once  sync.Once
+	err   error
+	files map[string]*tarEntry
+}
+
+type tarEntry struct {
+	info     fs.FileInfo
+	hdr      *tar.Header // nil for directories implied by their content
+	offset   int64
+	children []string
+}
+
+var _ fs.ReadDirFS = (*tarFS)(nil)
+var _ readLinkFS = (*tarFS)(nil)
+
+func newTarFS(r io.ReaderAt) *tarFS {
+	return &tarFS{r: r}
+}
+
+// offsetReader tracks the position of the tar reader in the archive. It
+// seeks so that skipping over file contents does not read them.
+type offsetReader struct {
+	*io.SectionReader
+	pos int64
+}
+
+func (o *offsetReader) Read(p []byte) (int, error) {
+	n, err := o.SectionReader.Read(p)
+	o.pos += int64(n)
+	return n, err
+}
+
+func (o *offsetReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := o.SectionReader.Seek(offset, whence)
+	if err == nil {
+		o.pos = pos
+	}
+	return pos, err
+}
+
+func (t *tarFS) index() error {
+	t.once.Do(func() {
+		t.files = map[string]*tarEntry{
+			".": {info: dirInfo{name: ".", perm: 0o555}},
+		}
+		or := &offsetReader{SectionReader: io.NewSectionReader(t.r, 0, math.MaxInt64)}
+		tr := tar.NewReader(or)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.err = err
+				return
+			}
+			name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+			if !fs.ValidPath(name) || name == "." {
+				continue
+			}
+			t.add(name, &tarEntry{info: hdr.FileInfo(), hdr: hdr, offset: or.pos})
+		}
+		for _, e := range t.files {
+			slices.Sort(e.children)
+		}
+	})
+	return t.err
+}
+
+// add records e under name, creating the parent directories the archive
+// does not list itself. A later entry replaces an earlier one.
+func (t *tarFS) add(name string, e *tarEntry) {
+	if old, ok := t.files[name]; ok {
+		if old.info.IsDir() && e.info.IsDir() {
+			e.children = old.children
+		}
+		t.files[name] = e
+		return
+	}
+	t.files[name] = e
+
+	dir := path.Dir(name)
+	parent, ok := t.files[dir]
+	if !ok {
+		parent = &tarEntry{info: dirInfo{name: path.Base(dir), perm: 0o555}}
+		t.add(dir, parent)
+	}
+	parent.children = append(parent.children, path.Base(name))
+}
+
+// lookup returns the entry of name, following symbolic and hard links.
+func (t *tarFS) lookup(op, name string) (string, *tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if err := t.index(); err != nil {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	for range 40 {
+		e, ok := t.files[name]
+		if !ok {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if e.hdr == nil {
+			return name, e, nil
+		}
+		switch e.hdr.Typeflag {
+		case tar.TypeSymlink:
+			name = path.Join(path.Dir(name), e.hdr.Linkname)
+		case tar.TypeLink:
+			name = path.Clean(strings.TrimPrefix(e.hdr.Linkname, "/"))
+		default:
+			return name, e, nil
+		}
+	}
+	return "", nil, &fs.PathError{Op: op, Path: name, Err: errors.New("too many links")}
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	name, e, err := t.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.info.IsDir() {
+		return &staticDir{info: e.info, entries: t.entries(name, e)}, nil
+	}
+	// Sparse files are not stored contiguously.
+	if e.hdr.Typeflag == tar.TypeGNUSparse || e.hdr.PAXRecords["GNU.sparse.map"] != "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	return &tarFile{SectionReader: io.NewSectionReader(t.r, e.offset, e.hdr.Size), info: e.info}, nil
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name, e, err := t.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return t.entries(name, e), nil
+}
+
+func (t *tarFS) entries(name string, e *tarEntry) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(e.children))
+	for _, child := range e.children {
+		entries = append(entries, fs.FileInfoToDirEntry(t.files[path.Join(name, child)].info))
+	}
+	return entries
+}
+
+func (t *tarFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := t.index(); err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	e, ok := t.files[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.hdr == nil || e.hdr.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return e.hdr.Linkname, nil
+}
+
+func (t *tarFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := t.index(); err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	e, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return e.info, nil
+}
+
+type tarFile struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tarFile) Close() error               { return nil }
@@ -0,0 +1,94 @@
+package multifs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// countingReaderAt counts the calls made to the archive.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+func buildTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range []struct {
+		hdr  tar.Header
+		data string
+	}{
+		{tar.Header{Name: "./docs/a.txt", Mode: 0o644}, "hello"},
+		{tar.Header{Name: "docs/sub/", Typeflag: tar.TypeDir, Mode: 0o755}, ""},
+		{tar.Header{Name: "docs/sub/b.txt", Mode: 0o600}, "world"},
+		{tar.Header{Name: "docs/link", Typeflag: tar.TypeSymlink, Linkname: "sub/b.txt"}, ""},
+		{tar.Header{Name: "top.txt", Mode: 0o644}, "top"},
+	} {
+		e.hdr.Size = int64(len(e.data))
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, e.data)
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func TestMountTar(t *testing.T) {
+	data := buildTar(t)
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	mux := NewMultiFS()
+	if err := mux.MountTar("arch", r); err != nil {
+		t.Fatalf("MountTar: %v", err)
+	}
+	if r.reads != 0 {
+		t.Fatalf("archive read %d times before first access", r.reads)
+	}
+
+	sub, err := fs.Sub(mux, "arch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "docs/a.txt", "docs/sub/b.txt", "top.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(mux, "arch/docs/link"); err != nil || string(data) != "world" {
+		t.Fatalf("ReadFile through link: %q, %v", data, err)
+	}
+	if target, err := mux.ReadLink("arch/docs/link"); err != nil || target != "sub/b.txt" {
+		t.Fatalf("readLink: %q, %v", target, err)
+	}
+	if got := listNames(t, mux, "arch/docs"); got != "a.txt,link,sub" {
+		t.Fatalf("listing: got %s", got)
+	}
+}
+
+func TestMountZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("dir/a.txt")
+	io.WriteString(w, "zipped")
+	zw.Close()
+
+	r := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+	mux := NewMultiFS()
+	if err := mux.MountZip("z", r, int64(buf.Len())); err != nil {
+		t.Fatalf("MountZip: %v", err)
+	}
+	if r.reads != 0 {
+		t.Fatalf("archive read %d times before first access", r.reads)
+	}
+	if data, err := fs.ReadFile(mux, "z/dir/a.txt"); err != nil || string(data) != "zipped" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+}
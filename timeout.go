@@ -0,0 +1,72 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+// WithTimeout bounds how long op may take on the mount, after which it
+// fails with context.DeadlineExceeded. Context-aware backends see the
+// deadline through their context; calls to other backends are abandoned
+// to finish in the background, the file they eventually open being
+// closed. The timeout bounds the call only: files opened in time are read
+// under the caller's context.
+func WithTimeout(op Op, d time.Duration) MountOption {
+	return func(o *mountOptions) {
+		if o.timeouts == nil {
+			o.timeouts = make(map[Op]time.Duration)
+		}
+		o.timeouts[op] = d
+	}
+}
+
+// timed runs fn within the mount's timeout for op, if any. discard
+// releases the result of a call completing after the timeout.
+func timed[T any](mnt *mount, ctx context.Context, op Op, name string, fn func(context.Context) (T, error), discard func(T)) (T, error) {
+	d, ok := mnt.opts.timeouts[op]
+	if !ok || d <= 0 {
+		return fn(ctx)
+	}
+	caller := outliving(ctx)
+	ctx, cancel := context.WithTimeout(ctx, d)
+	ctx = context.WithValue(ctx, outlivingKey{}, caller)
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(ctx)
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		cancel()
+		return r.v, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil && discard != nil {
+				discard(r.v)
+			}
+			cancel()
+		}()
+		var zero T
+		return zero, mnt.record(op.String(), name, &fs.PathError{Op: op.String(), Path: name, Err: ctx.Err()})
+	}
+}
+
+// outlivingKey carries, in the context of a timed call, the context of its
+// caller.
+type outlivingKey struct{}
+
+// outliving returns the context for what outlives the call made with ctx,
+// such as the files it opens: the caller's context when ctx only bounds a
+// timed call.
+func outliving(ctx context.Context) context.Context {
+	if caller, ok := ctx.Value(outlivingKey{}).(context.Context); ok {
+		return caller
+	}
+	return ctx
+}
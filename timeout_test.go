@@ -0,0 +1,93 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// hungFS blocks opening the files in hang until released.
+type hungFS struct {
+	fstest.MapFS
+	hang    map[string]bool
+	release chan struct{}
+}
+
+func (h *hungFS) Open(name string) (fs.File, error) {
+	if h.hang[name] {
+		<-h.release
+	}
+	return h.MapFS.Open(name)
+}
+
+func TestTimeout(t *testing.T) {
+	backend := &hungFS{
+		MapFS: fstest.MapFS{
+			"ok/a":   &fstest.MapFile{Data: []byte("a")},
+			"hung/b": &fstest.MapFile{Data: []byte("b")},
+		},
+		hang:    map[string]bool{"hung": true, "hung/b": true},
+		release: make(chan struct{}),
+	}
+	defer close(backend.release)
+	mux := NewMultiFS()
+	mux.Mount("s", backend, WithTimeout(OpOpen, 20*time.Millisecond), WithTimeout(OpReadDir, 20*time.Millisecond))
+
+	if _, err := fs.ReadFile(mux, "s/ok/a"); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if _, err := mux.Open("s/hung/b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Open: %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	var walked []string
+	err := fs.WalkDir(mux, "s", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s: %v", name, err)
+			}
+			return fs.SkipDir
+		}
+		walked = append(walked, name)
+		return nil
+	})
+	if err != nil || len(walked) != 4 {
+		t.Fatalf("WalkDir: %v, %v", walked, err)
+	}
+}
+
+func TestTimeoutContext(t *testing.T) {
+	rec := &recordingFS{MapFS: fstest.MapFS{"f": &fstest.MapFile{}}}
+	mux := NewMultiFS()
+	mux.Mount("s", rec, WithTimeout(OpOpen, time.Minute))
+	f, err := mux.Open("s/f")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	f.Close()
+	if _, ok := rec.last.Deadline(); !ok {
+		t.Fatalf("backend context has no deadline")
+	}
+}
+
+func TestTimeoutOutlivedByFile(t *testing.T) {
+	backend := fstest.MapFS{"f": &fstest.MapFile{Data: make([]byte, 3000)}}
+
+	// 1000 bytes of burst, then 2000 bytes read well after the open timed out
+	mux := NewMultiFS()
+	mux.Mount("s", backend, WithTimeout(OpOpen, 20*time.Millisecond), WithRateLimit(10000, 1000))
+	if data, err := fs.ReadFile(mux, "s/f"); err != nil || len(data) != 3000 {
+		t.Fatalf("ReadFile: %d, %v", len(data), err)
+	}
+
+	flaky := &flakyFS{FS: backend, breakAt: 5}
+	mux = NewMultiFS()
+	mux.Mount("s", flaky, WithTimeout(OpOpen, time.Minute), WithRetry(RetryPolicy{Backoff: time.Millisecond}))
+	if data, err := fs.ReadFile(mux, "s/f"); err != nil || len(data) != 3000 || flaky.opens != 2 {
+		t.Fatalf("ReadFile: %d, %v after %d opens", len(data), err, flaky.opens)
+	}
+
+}
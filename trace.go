@@ -0,0 +1,58 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Tracer starts a span around an operation delegated to a mount, for
+// tracing systems such as OpenTelemetry. id and name locate the target:
+// name is the path inside the mount. The returned context, which carries
+// the span, is the one passed down to context-aware backends.
+type Tracer interface {
+	Start(ctx context.Context, op, id, name string) (context.Context, Span)
+}
+
+type Span interface {
+	End(err error)
+}
+
+// WithTracer traces Open, Stat and ReadDir through MultiFS, and reads on
+// the files it returns. Read spans are children of the span of the open
+// that returned the file.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// opTrace is an operation being instrumented.
+type opTrace struct {
+	mnt   *mount
+	op    string
+	name  string
+	start time.Time
+	span  Span
+}
+
+// begin starts instrumenting op; the operation must then run with the
+// returned context and report its outcome to end.
+func (mnt *mount) begin(ctx context.Context, op, name string) (context.Context, opTrace) {
+	t := opTrace{mnt: mnt, op: op, name: name, start: time.Now()}
+	if mnt.metrics != nil && mnt.metrics.tracer != nil {
+		ctx, t.span = mnt.metrics.tracer.Start(ctx, op, mnt.id, name)
+	}
+	return ctx, t
+}
+
+func (t opTrace) end(bytes int64, err error) {
+	t.mnt.observe(t.op, t.start, bytes, err)
+	t.mnt.logOp(t.op, t.name, t.start, err)
+	if t.span != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		t.span.End(err)
+	}
+}
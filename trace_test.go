@@ -0,0 +1,74 @@
+package multifs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+type spanKey struct{}
+
+// recordingTracer keeps a line per ended span, naming its parent.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type recordedSpan struct {
+	t     *recordingTracer
+	label string
+}
+
+func (r *recordingTracer) Start(ctx context.Context, op, id, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	label := fmt.Sprintf("%s %s/%s", op, id, name)
+	if parent != "" {
+		label += " < " + parent
+	}
+	return context.WithValue(ctx, spanKey{}, op), &recordedSpan{t: r, label: label}
+}
+
+func (s *recordedSpan) End(err error) {
+	if err != nil {
+		s.label += " !" + err.Error()
+	}
+	s.t.mu.Lock()
+	s.t.spans = append(s.t.spans, s.label)
+	s.t.mu.Unlock()
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	mux := NewMultiFS(WithTracer(tracer))
+	backend := &recordingFS{MapFS: fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}}
+	mux.Mount("m", backend)
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "request")
+	f, err := mux.OpenContext(ctx, "m/a.txt")
+	if err != nil {
+		t.Fatalf("OpenContext: %v", err)
+	}
+	buf := make([]byte, 8)
+	f.Read(buf)
+	f.Read(buf)
+	f.Close()
+	fs.Stat(mux, "m/missing")
+
+	got := strings.Join(tracer.spans, "\n")
+	want := strings.Join([]string{
+		"open m/a.txt < request",
+		"read m/a.txt < open",
+		"read m/a.txt < open",
+		"stat m/missing !open missing: file does not exist",
+	}, "\n")
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	if v, _ := backend.last.Value(spanKey{}).(string); v != "stat" {
+		t.Fatalf("backend did not get the span context: %q", v)
+	}
+}
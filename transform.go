@@ -0,0 +1,155 @@
+package multifs
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Transform turns the contents of files as stored by a backend into their
+// logical contents, for example by decompressing them.
+type Transform struct {
+	// Match selects the files transformed, every regular file when nil.
+	Match func(name string) bool
+	// Reader returns the logical contents of name, read from r.
+	Reader func(name string, r io.Reader) (io.Reader, error)
+	// LogicalSize makes Stat and listings report the size of the logical
+	// contents instead of the stored size. It is computed by reading the
+	// file through Reader, once per version of the file; sizes known
+	// otherwise are better provided with WithStatFunc.
+	LogicalSize bool
+}
+
+// Gunzip decompresses files stored with gzip.
+var Gunzip = Transform{
+	Reader: func(name string, r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+}
+
+// WithTransform applies t to the files of the mount. Transformed files
+// can only be read sequentially: they are not io.Seeker nor io.ReaderAt.
+func WithTransform(t Transform) MountOption {
+	return func(o *mountOptions) {
+		o.transform = &t
+	}
+}
+
+func (t *Transform) matches(name string) bool {
+	return t.Match == nil || t.Match(name)
+}
+
+// transformFile wraps f, opened at name, unless it is a directory.
+func (mnt *mount) transformFile(name string, f fs.File) fs.File {
+	if !mnt.opts.transform.matches(name) || isDir(f) {
+		return f
+	}
+	return compose(&transformedFile{File: f, t: mnt.opts.transform, name: name}, nil, nil, nil, nil)
+}
+
+type transformedFile struct {
+	fs.File
+	t    *Transform
+	name string
+	r    io.Reader
+}
+
+func (f *transformedFile) Read(p []byte) (int, error) {
+	if f.r == nil {
+		r, err := f.t.Reader(f.name, f.File)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.r = r
+	}
+	return f.r.Read(p)
+}
+
+func (f *transformedFile) Close() error {
+	if c, ok := f.r.(io.Closer); ok {
+		c.Close()
+	}
+	return f.File.Close()
+}
+
+// logicalSizes caches the logical sizes of the files of a mount, along
+// with the stored attributes they were computed for.
+type logicalSizes struct {
+	sizes sync.Map // name -> logicalSize
+}
+
+type logicalSize struct {
+	stored  int64
+	modTime time.Time
+	size    int64
+}
+
+// logicalInfo reports info, stat'ed for name, with the size of its
+// contents as read through the mount's decrypter and transform.
+func (mnt *mount) logicalInfo(name string, info fs.FileInfo) fs.FileInfo {
+	measure := mnt.measure(name)
+	if measure == nil || !info.Mode().IsRegular() {
+		return info
+	}
+	if v, ok := mnt.logical.sizes.Load(name); ok {
+		ls := v.(logicalSize)
+		if ls.stored == info.Size() && ls.modTime.Equal(info.ModTime()) {
+			return sizedInfo{FileInfo: info, size: ls.size}
+		}
+	}
+
+	f, err := mnt.rawOpen(context.Background(), name)
+	if err != nil {
+		return info
+	}
+	defer f.Close()
+	size, err := measure(f)
+	if err != nil {
+		return info
+	}
+	mnt.logical.sizes.Store(name, logicalSize{stored: info.Size(), modTime: info.ModTime(), size: size})
+	return sizedInfo{FileInfo: info, size: size}
+}
+
+// measures reports whether the mount reports other sizes than the stored
+// ones.
+func (mnt *mount) measures() bool {
+	t := mnt.opts.transform
+	return mnt.opts.decrypter != nil || t != nil && t.LogicalSize
+}
+
+// measure returns how to compute the logical size of name from the stored
+// file, or nil if the stored size is to be reported.
+func (mnt *mount) measure(name string) func(f fs.File) (int64, error) {
+	d, t := mnt.opts.decrypter, mnt.opts.transform
+	switch {
+	case t != nil && t.LogicalSize && t.matches(name):
+		return func(f fs.File) (int64, error) {
+			if d != nil {
+				f = mnt.decryptFile(name, f)
+			}
+			r, err := t.Reader(name, f)
+			if err != nil {
+				return 0, err
+			}
+			if c, ok := r.(io.Closer); ok {
+				defer c.Close()
+			}
+			return io.Copy(io.Discard, r)
+		}
+	case d != nil:
+		return func(f fs.File) (int64, error) { return plaintextSize(d, name, f) }
+	}
+	return nil
+}
+
+// sizedInfo reports another size than the one stat'ed.
+type sizedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i sizedInfo) Size() int64 { return i.size }
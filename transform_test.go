@@ -0,0 +1,75 @@
+package multifs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, s)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTransform(t *testing.T) {
+	text := strings.Repeat("hello ", 100)
+	backend := fstest.MapFS{
+		"a.gz":  &fstest.MapFile{Data: gzipped(t, text)},
+		"plain": &fstest.MapFile{Data: []byte("plain")},
+	}
+	for _, b := range backendsOf(t, backend) {
+		t.Run(b.name, func(t *testing.T) {
+			testTransform(t, b.fsys, text, int64(len(backend["a.gz"].Data)))
+		})
+	}
+}
+
+func testTransform(t *testing.T, backend fs.FS, text string, stored int64) {
+	gz := Gunzip
+	gz.Match = func(name string) bool { return strings.HasSuffix(name, ".gz") }
+
+	mux := NewMultiFS()
+	mux.Mount("stored", backend, WithTransform(gz))
+	gz.LogicalSize = true
+	mux.Mount("logical", backend, WithTransform(gz))
+
+	for _, id := range []string{"stored", "logical"} {
+		if data, err := fs.ReadFile(mux, id+"/a.gz"); err != nil || string(data) != text {
+			t.Fatalf("%s: ReadFile: %q, %v", id, data, err)
+		}
+		if data, err := fs.ReadFile(mux, id+"/plain"); err != nil || string(data) != "plain" {
+			t.Fatalf("%s: ReadFile plain: %q, %v", id, data, err)
+		}
+	}
+
+	if info, err := mux.Stat("stored/a.gz"); err != nil || info.Size() != stored {
+		t.Fatalf("stored size: %v, %v", info, err)
+	}
+	if info, err := mux.Stat("logical/a.gz"); err != nil || info.Size() != int64(len(text)) {
+		t.Fatalf("logical size: %v, %v", info, err)
+	}
+	entries, _ := mux.ReadDir("logical")
+	i := slices.IndexFunc(entries, func(e fs.DirEntry) bool { return e.Name() == "a.gz" })
+	if info, _ := entries[i].Info(); info.Size() != int64(len(text)) {
+		t.Fatalf("logical size in listing: %d", info.Size())
+	}
+	f, _ := mux.Open("logical/a.gz")
+	defer f.Close()
+	if _, ok := f.(io.Seeker); ok {
+		t.Fatal("transformed file is seekable")
+	}
+	if err := fstest.TestFS(mux, "stored/a.gz", "logical/a.gz", "logical/plain"); err != nil {
+		t.Fatal(err)
+	}
+}
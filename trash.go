@@ -0,0 +1,280 @@
+package multifs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trashDir holds removed entries, one directory per removal named after its
+// time, keeping the removed path below it.
+const trashDir = ".trash"
+
+// TrashFS turns Remove on a writable filesystem into a move to a trash
+// area at its root, from which entries can be restored until they expire.
+// The trash area itself is hidden and cannot be accessed directly.
+type TrashFS struct {
+	fsys      WritableFS
+	retention time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+var _ WritableFS = (*TrashFS)(nil)
+
+// TrashEntry is a removed path waiting in the trash.
+type TrashEntry struct {
+	Path    string
+	Removed time.Time
+}
+
+// NewTrashFS wraps fsys so that removed entries are kept for retention.
+// A zero retention keeps them until restored or purged explicitly.
+func NewTrashFS(fsys WritableFS, retention time.Duration) *TrashFS {
+	return &TrashFS{fsys: fsys, retention: retention, now: time.Now}
+}
+
+func (t *TrashFS) Open(name string) (fs.File, error) {
+	if err := checkTrashPath("open", name); err != nil {
+		return nil, err
+	}
+	f, err := t.fsys.Open(name)
+	if err != nil || name != "." {
+		return f, err
+	}
+	return filterDir(f, func(e fs.DirEntry) bool { return e.Name() != trashDir }), nil
+}
+
+func (t *TrashFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := checkTrashPath("open", name); err != nil {
+		return nil, err
+	}
+	return t.fsys.OpenFile(name, flag, perm)
+}
+
+func (t *TrashFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := checkTrashPath("mkdir", name); err != nil {
+		return err
+	}
+	return t.fsys.Mkdir(name, perm)
+}
+
+func (t *TrashFS) Rename(oldname, newname string) error {
+	if err := checkTrashPath("rename", oldname); err != nil {
+		return err
+	}
+	if err := checkTrashPath("rename", newname); err != nil {
+		return err
+	}
+	return t.fsys.Rename(oldname, newname)
+}
+
+// Remove moves name to the trash. As with a plain Remove, directories must
+// be empty. Expired entries are purged on the way.
+func (t *TrashFS) Remove(name string) error {
+	if err := checkTrashPath("remove", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fs.Stat(t.fsys, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(t.fsys, name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	dst := path.Join(trashDir, fmt.Sprintf("%020d", now.UnixNano()), name)
+	if err := mkdirAll(t.fsys, path.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := t.fsys.Rename(name, dst); err != nil {
+		return err
+	}
+	// Purging is housekeeping: the removal itself succeeded.
+	t.purge(now)
+	return nil
+}
+
+// Restore moves the most recently removed version of name back in place.
+func (t *TrashFS) Restore(name string) error {
+	if err := checkTrashPath("restore", name); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stamps, err := t.stamps()
+	if err != nil {
+		return err
+	}
+	for _, stamp := range slices.Backward(stamps) {
+		src := path.Join(trashDir, stamp.dir, name)
+		if _, err := fs.Stat(t.fsys, src); err != nil {
+			continue
+		}
+		if _, err := fs.Stat(t.fsys, name); err == nil {
+			return &fs.PathError{Op: "restore", Path: name, Err: fs.ErrExist}
+		}
+		if err := mkdirAll(t.fsys, path.Dir(name), 0o755); err != nil {
+			return err
+		}
+		if err := t.fsys.Rename(src, name); err != nil {
+			return err
+		}
+		// The now empty directories leading to it in the trash go too.
+		for dir := path.Dir(src); dir != trashDir; dir = path.Dir(dir) {
+			if t.fsys.Remove(dir) != nil {
+				break
+			}
+		}
+		return nil
+	}
+	return &fs.PathError{Op: "restore", Path: name, Err: fs.ErrNotExist}
+}
+
+// Trashed lists the entries in the trash, most recently removed first.
+func (t *TrashFS) Trashed() ([]TrashEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stamps, err := t.stamps()
+	if err != nil {
+		return nil, err
+	}
+	var out []TrashEntry
+	for _, stamp := range slices.Backward(stamps) {
+		root := path.Join(trashDir, stamp.dir)
+		err := fs.WalkDir(t.fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if name == root {
+				return nil
+			}
+			// Directories only show up when removed themselves, that is
+			// when they are empty.
+			if d.IsDir() {
+				if entries, err := fs.ReadDir(t.fsys, name); err != nil || len(entries) > 0 {
+					return err
+				}
+			}
+			out = append(out, TrashEntry{Path: strings.TrimPrefix(name, root+"/"), Removed: stamp.time})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Purge permanently deletes the entries whose retention has expired.
+func (t *TrashFS) Purge() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.purge(t.now())
+}
+
+func (t *TrashFS) purge(now time.Time) error {
+	if t.retention <= 0 {
+		return nil
+	}
+	stamps, err := t.stamps()
+	if err != nil {
+		return err
+	}
+	for _, stamp := range stamps {
+		if now.Sub(stamp.time) < t.retention {
+			break
+		}
+		if err := removeAll(t.fsys, path.Join(trashDir, stamp.dir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type trashStamp struct {
+	dir  string
+	time time.Time
+}
+
+// stamps returns the removals in the trash, oldest first.
+func (t *TrashFS) stamps() ([]trashStamp, error) {
+	entries, err := fs.ReadDir(t.fsys, trashDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stamps []trashStamp
+	for _, e := range entries {
+		nsec, err := strconv.ParseInt(e.Name(), 10, 64)
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		stamps = append(stamps, trashStamp{dir: e.Name(), time: time.Unix(0, nsec)})
+	}
+	slices.SortFunc(stamps, func(a, b trashStamp) int { return strings.Compare(a.dir, b.dir) })
+	return stamps, nil
+}
+
+func checkTrashPath(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == trashDir || strings.HasPrefix(name, trashDir+"/") {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+func mkdirAll(fsys MkdirFS, name string, perm fs.FileMode) error {
+	if name == "." {
+		return nil
+	}
+	if info, err := fs.Stat(fsys, name); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	if err := mkdirAll(fsys, path.Dir(name), perm); err != nil {
+		return err
+	}
+	if err := fsys.Mkdir(name, perm); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+func removeAll(fsys RemoveFS, name string) error {
+	entries, _ := fs.ReadDir(fsys, name)
+	for _, e := range entries {
+		if err := removeAll(fsys, path.Join(name, e.Name())); err != nil {
+			return err
+		}
+	}
+	return fsys.Remove(name)
+}
@@ -0,0 +1,89 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func newTestTrash(t *testing.T, retention time.Duration) (*TrashFS, *time.Time) {
+	d := newDirFS(t)
+	if err := d.Mkdir("docs", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, d, "docs/a.txt", "v1")
+	tr := NewTrashFS(d, retention)
+	now := time.Unix(1_000_000, 0)
+	tr.now = func() time.Time { return now }
+	return tr, &now
+}
+
+func TestTrashRemoveRestore(t *testing.T) {
+	tr, _ := newTestTrash(t, 0)
+
+	if err := tr.Remove("docs/a.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := fs.Stat(tr, "docs/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist after Remove, got %v", err)
+	}
+	if got := listNames(t, tr, "."); got != "docs" {
+		t.Fatalf("root listing exposes the trash: %s", got)
+	}
+	if _, err := tr.Open(".trash"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the trash to be hidden, got %v", err)
+	}
+
+	trashed, err := tr.Trashed()
+	if err != nil || len(trashed) != 1 || trashed[0].Path != "docs/a.txt" {
+		t.Fatalf("Trashed: %+v, %v", trashed, err)
+	}
+
+	if err := tr.Restore("docs/a.txt"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if data, err := fs.ReadFile(tr, "docs/a.txt"); err != nil || string(data) != "v1" {
+		t.Fatalf("ReadFile after Restore: %q, %v", data, err)
+	}
+	if trashed, _ := tr.Trashed(); len(trashed) != 0 {
+		t.Fatalf("trash not empty after Restore: %+v", trashed)
+	}
+	if err := tr.Restore("docs/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist restoring twice, got %v", err)
+	}
+}
+
+func TestTrashRestoresLatest(t *testing.T) {
+	tr, now := newTestTrash(t, 0)
+
+	tr.Remove("docs/a.txt")
+	*now = now.Add(time.Second)
+	writeFile(t, tr, "docs/a.txt", "v2")
+	tr.Remove("docs/a.txt")
+
+	if err := tr.Restore("docs/a.txt"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if data, _ := fs.ReadFile(tr, "docs/a.txt"); string(data) != "v2" {
+		t.Fatalf("restored %q, want v2", data)
+	}
+	if err := tr.Restore("docs/a.txt"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected ErrExist over an existing file, got %v", err)
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	tr, now := newTestTrash(t, time.Hour)
+
+	tr.Remove("docs/a.txt")
+	*now = now.Add(2 * time.Hour)
+	if err := tr.Remove("docs"); err != nil {
+		t.Fatalf("Remove docs: %v", err)
+	}
+
+	trashed, err := tr.Trashed()
+	if err != nil || len(trashed) != 1 || trashed[0].Path != "docs" {
+		t.Fatalf("expired entry not purged: %+v, %v", trashed, err)
+	}
+}
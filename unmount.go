@@ -0,0 +1,97 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+)
+
+// ErrUnmounted is returned by reads on files of a mount that was forcibly
+// unmounted.
+var ErrUnmounted = errors.New("multifs: mount was unmounted")
+
+// UnmountMode tells UnmountContext what to do with a mount still in use,
+// that is with open files or sessions.
+type UnmountMode int
+
+const (
+	// UnmountFail fails with ErrMountBusy.
+	UnmountFail UnmountMode = iota
+	// UnmountWait waits for the files and sessions to be closed, or for
+	// the context to be done.
+	UnmountWait
+	// UnmountForce unmounts right away. Reads on the files still open
+	// fail with ErrUnmounted from then on.
+	UnmountForce
+)
+
+func (mode UnmountMode) String() string {
+	switch mode {
+	case UnmountFail:
+		return "fail"
+	case UnmountWait:
+		return "wait"
+	case UnmountForce:
+		return "force"
+	}
+	return "unknown"
+}
+
+// UnmountContext removes the mount under id, accounting for the files
+// opened on it as well as its sessions according to mode.
+func (m *MultiFS) UnmountContext(ctx context.Context, id string, mode UnmountMode) error {
+	for {
+		m.mu.Lock()
+		mid, mnt, ok := m.tab.Load().lookup(id)
+		if !ok {
+			m.mu.Unlock()
+			return fs.ErrNotExist
+		}
+		released := mnt.released()
+		if !mnt.busy() || mode == UnmountForce {
+			// Only a forced unmount fails the files still open, including
+			// those opened by calls that resolved the mount just before.
+			if mode == UnmountForce {
+				mnt.detached.Store(true)
+			}
+			m.detach(mid, mnt)
+			m.unlock()
+			return nil
+		}
+		m.mu.Unlock()
+
+		if mode != UnmountWait {
+			return ErrMountBusy
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// busy reports whether files or sessions are open on the mount.
+func (mnt *mount) busy() bool {
+	return mnt.sessions.Load() > 0 || mnt.usage.openFiles.Load() > 0
+}
+
+// released returns a channel closed the next time a file or session of
+// the mount is closed.
+func (mnt *mount) released() <-chan struct{} {
+	mnt.releaseMu.Lock()
+	defer mnt.releaseMu.Unlock()
+	if mnt.releaseCh == nil {
+		mnt.releaseCh = make(chan struct{})
+	}
+	return mnt.releaseCh
+}
+
+func (mnt *mount) release() {
+	mnt.releaseMu.Lock()
+	defer mnt.releaseMu.Unlock()
+	if mnt.releaseCh != nil {
+		close(mnt.releaseCh)
+		mnt.releaseCh = nil
+	}
+}
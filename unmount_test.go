@@ -0,0 +1,117 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newBusyMux(t *testing.T) (*MultiFS, fs.File) {
+	t.Helper()
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("data")}})
+	f, err := mux.Open("snap/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mux, f
+}
+
+func TestUnmountFail(t *testing.T) {
+	mux, f := newBusyMux(t)
+	ctx := context.Background()
+
+	if err := mux.UnmountContext(ctx, "snap", UnmountFail); !errors.Is(err, ErrMountBusy) {
+		t.Fatalf("UnmountContext with an open file: %v", err)
+	}
+	f.Close()
+	if err := mux.UnmountContext(ctx, "snap", UnmountFail); err != nil {
+		t.Fatalf("UnmountContext once closed: %v", err)
+	}
+	if err := mux.UnmountContext(ctx, "snap", UnmountFail); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("UnmountContext twice: %v", err)
+	}
+}
+
+func TestUnmountWait(t *testing.T) {
+	mux, f := newBusyMux(t)
+	s, err := mux.OpenSession("snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mux.UnmountContext(ctx, "snap", UnmountWait); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("UnmountContext timing out: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- mux.UnmountContext(context.Background(), "snap", UnmountWait) }()
+	f.Close()
+	select {
+	case err := <-done:
+		t.Fatalf("UnmountContext returned %v with a session open", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("UnmountContext: %v", err)
+	}
+	if _, err := mux.Stat("snap"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("still mounted: %v", err)
+	}
+}
+
+func TestUnmountForce(t *testing.T) {
+	mux, f := newBusyMux(t)
+	defer f.Close()
+	dir, err := mux.Open("snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+
+	if err := mux.UnmountContext(context.Background(), "snap", UnmountForce); err != nil {
+		t.Fatalf("UnmountContext: %v", err)
+	}
+	if _, err := io.ReadAll(f); !errors.Is(err, ErrUnmounted) {
+		t.Fatalf("Read after forced unmount: %v", err)
+	}
+	if _, err := dir.(fs.ReadDirFile).ReadDir(-1); !errors.Is(err, ErrUnmounted) {
+		t.Fatalf("ReadDir after forced unmount: %v", err)
+	}
+}
+
+func TestUnmountKeepsOpenFiles(t *testing.T) {
+	mux, f := newBusyMux(t)
+	defer f.Close()
+	if err := mux.Unmount("snap"); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+	if data, err := io.ReadAll(f); err != nil || string(data) != "data" {
+		t.Fatalf("Read after Unmount: %q, %v", data, err)
+	}
+}
+
+func TestUnmountRacingOpen(t *testing.T) {
+	mux := NewMultiFS()
+	mux.Mount("snap", fstest.MapFS{"file.txt": &fstest.MapFile{Data: []byte("data")}})
+	// An open that resolved the mount just before it was unmounted
+	mnt, _ := mux.mount("snap")
+	if err := mux.UnmountContext(context.Background(), "snap", UnmountFail); err != nil {
+		t.Fatalf("UnmountContext: %v", err)
+	}
+	f, err := mnt.open(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	if data, err := io.ReadAll(f); err != nil || string(data) != "data" {
+		t.Fatalf("Read: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,109 @@
+package multifs
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+)
+
+// MountURL mounts the backend named by rawURL under id, or as the root
+// mount if id is empty. The scheme of the URL is the backend type, as
+// registered with Register; the backend's Opener gets the host and path of
+// the URL as the "host" and "path" parameters, along with the query
+// parameters. Such mounts are saved by SaveConfig, without opts.
+//
+// The "file", "tar" and "zip" types are built in: "file:///srv/data"
+// mounts a directory as MountDir does, and "tar:///backups/a.tar" or "zip:///b.zip" an
+// archive, which is only opened while being read. Their URLs have no host,
+// or "localhost".
+func (m *MultiFS) MountURL(id, rawURL string, opts ...MountOption) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("multifs: %w", err)
+	}
+	if u.Scheme == "" {
+		return fmt.Errorf("multifs: URL %q has no scheme", rawURL)
+	}
+
+	params := make(map[string]string)
+	for k, v := range u.Query() {
+		params[k] = v[0]
+	}
+	if u.Host != "" {
+		params["host"] = u.Host
+	}
+	if u.Path != "" {
+		params["path"] = u.Path
+	}
+	c := MountConfig{ID: id, Type: u.Scheme, Params: params}
+	return m.mountBackend(context.Background(), c, opts)
+}
+
+func init() {
+	Register("file", openDir)
+	Register("tar", openTar)
+	Register("zip", openZip)
+}
+
+// localPath returns the path of a local file or directory. A host is
+// refused rather than dropped, as "file://./data" names /data.
+func localPath(params map[string]string) (string, error) {
+	if host := params["host"]; host != "" && host != "localhost" {
+		return "", fmt.Errorf("host %q is not local", host)
+	}
+	name := params["path"]
+	if name == "" {
+		return "", errors.New("missing path")
+	}
+	return name, nil
+}
+
+func openDir(ctx context.Context, params map[string]string) (fs.FS, error) {
+	name, err := localPath(params)
+	if err != nil {
+		return nil, err
+	}
+	return openHostDir(name)
+}
+
+func openTar(ctx context.Context, params map[string]string) (fs.FS, error) {
+	name, err := localPath(params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(name); err != nil {
+		return nil, err
+	}
+	return newTarFS(fileReaderAt(name)), nil
+}
+
+func openZip(ctx context.Context, params map[string]string) (fs.FS, error) {
+	name, err := localPath(params)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return &lazyFS{build: func() (fs.FS, error) {
+		return zip.NewReader(fileReaderAt(name), info.Size())
+	}}, nil
+}
+
+// fileReaderAt reads a file of the host, opening it for every read so
+// that mounts of it hold no descriptor.
+type fileReaderAt string
+
+func (name fileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	f, err := os.Open(string(name))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.ReadAt(p, off)
+}
@@ -0,0 +1,64 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountURL(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "backup.tar")
+	if err := os.WriteFile(archive, buildTar(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewMultiFS()
+	for id, u := range map[string]string{
+		"dir":   "file://" + filepath.ToSlash(dir),
+		"tar":   "tar://localhost" + filepath.ToSlash(archive),
+		"files": "test-files://host.example?files=x,y",
+	} {
+		if err := mux.MountURL(id, u); err != nil {
+			t.Fatalf("MountURL %s: %v", u, err)
+		}
+	}
+	for id, expected := range map[string][]string{
+		"dir":   {"a.txt"},
+		"tar":   {"docs/sub/b.txt", "top.txt"},
+		"files": {"x", "y"},
+	} {
+		sub, _ := fs.Sub(mux, id)
+		if err := fstest.TestFS(sub, expected...); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+
+	c, err := mux.Config()
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	for _, mc := range c.Mounts {
+		if mc.ID == "files" && (mc.Params["host"] != "host.example" || mc.Params["files"] != "x,y") {
+			t.Fatalf("params: %v", mc.Params)
+		}
+	}
+}
+
+func TestMountURLErrors(t *testing.T) {
+	mux := NewMultiFS()
+	for _, u := range []string{"no-scheme", "unknown://x", "file:///does/not/exist", "tar://", "file://./data", "zip://host/b.zip"} {
+		if err := mux.MountURL("x", u); err == nil {
+			t.Errorf("MountURL %q succeeded", u)
+		}
+	}
+	if _, err := mux.Stat("x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("failed MountURL left a mount: %v", err)
+	}
+}
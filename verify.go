@@ -0,0 +1,120 @@
+package multifs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"time"
+)
+
+type VerifyConfig struct {
+	// Delay is waited between two visited entries so that the pass stays
+	// low priority.
+	Delay time.Duration
+
+	// SampleEvery reads the content of one regular file out of every
+	// SampleEvery, surfacing read errors that a metadata walk would miss.
+	// Zero disables content sampling.
+	SampleEvery int
+
+	// Report receives the result of each background pass.
+	Report func(VerifyResult)
+}
+
+type VerifyResult struct {
+	ID      string
+	Dirs    int
+	Files   int
+	Sampled int
+	Errors  []error
+	Elapsed time.Duration
+}
+
+func (r VerifyResult) OK() bool { return len(r.Errors) == 0 }
+
+// WithVerifyOnMount starts a verification pass in the background every time
+// a filesystem is mounted. The pass stops early if the id is unmounted.
+func WithVerifyOnMount(cfg VerifyConfig) Option {
+	return func(o *options) {
+		o.verify = &cfg
+	}
+}
+
+// Verify walks the filesystem mounted under id, checking that every entry
+// can be listed and stat'ed, and reads a sample of files according to cfg.
+// Problems found along the way are collected in the result; the returned
+// error is only set when the pass could not run to completion.
+func (m *MultiFS) Verify(ctx context.Context, id string, cfg VerifyConfig) (VerifyResult, error) {
+	mnt, ok := m.mount(id)
+	if !ok {
+		return VerifyResult{ID: id}, fs.ErrNotExist
+	}
+	return verify(ctx, mnt, cfg)
+}
+
+func (m *MultiFS) verifyInBackground(mnt *mount, cfg VerifyConfig) {
+	res, _ := verify(mnt.ctx, mnt, cfg)
+	if cfg.Report != nil {
+		cfg.Report(res)
+	}
+}
+
+func verify(ctx context.Context, mnt *mount, cfg VerifyConfig) (VerifyResult, error) {
+	res := VerifyResult{ID: mnt.id}
+	start := time.Now()
+
+	var timer *time.Timer
+	if cfg.Delay > 0 {
+		timer = time.NewTimer(cfg.Delay)
+		defer timer.Stop()
+	}
+
+	err := fs.WalkDir(mnt, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			res.Errors = append(res.Errors, err)
+			return nil
+		}
+		if timer != nil {
+			timer.Reset(cfg.Delay)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := d.Info(); err != nil {
+			res.Errors = append(res.Errors, &fs.PathError{Op: "stat", Path: name, Err: err})
+			return nil
+		}
+		if d.IsDir() {
+			res.Dirs++
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		res.Files++
+		if cfg.SampleEvery > 0 && res.Files%cfg.SampleEvery == 0 {
+			res.Sampled++
+			if err := readThrough(mnt, name); err != nil {
+				res.Errors = append(res.Errors, &fs.PathError{Op: "read", Path: name, Err: err})
+			}
+		}
+		return nil
+	})
+	res.Elapsed = time.Since(start)
+	return res, err
+}
+
+func readThrough(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(io.Discard, f)
+	return err
+}
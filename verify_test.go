@@ -0,0 +1,77 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestVerifyOnMount(t *testing.T) {
+	results := make(chan VerifyResult, 1)
+	mux := NewMultiFS(WithVerifyOnMount(VerifyConfig{
+		SampleEvery: 1,
+		Report:      func(r VerifyResult) { results <- r },
+	}))
+
+	fs1 := fstest.MapFS{
+		"a.txt":     &fstest.MapFile{Data: []byte("a")},
+		"dir/b.txt": &fstest.MapFile{Data: []byte("b")},
+	}
+	if err := mux.Mount("one", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	select {
+	case r := <-results:
+		if r.ID != "one" {
+			t.Fatalf("result id: got %q, want %q", r.ID, "one")
+		}
+		if !r.OK() {
+			t.Fatalf("unexpected errors: %v", r.Errors)
+		}
+		if r.Dirs != 2 || r.Files != 2 || r.Sampled != 2 {
+			t.Fatalf("unexpected counts: dirs=%d files=%d sampled=%d", r.Dirs, r.Files, r.Sampled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no verification result reported")
+	}
+}
+
+type brokenReadFS struct {
+	fstest.MapFS
+}
+
+func (b brokenReadFS) Open(name string) (fs.File, error) {
+	f, err := b.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, _ := f.Stat(); info != nil && !info.IsDir() {
+		f.Close()
+		return nil, errors.New("backend unavailable")
+	}
+	return f, nil
+}
+
+func TestVerifyReportsReadErrors(t *testing.T) {
+	mux := NewMultiFS()
+	fs1 := brokenReadFS{fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}}
+	if err := mux.Mount("one", fs1); err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	r, err := mux.Verify(context.Background(), "one", VerifyConfig{SampleEvery: 1})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if r.OK() || len(r.Errors) != 1 {
+		t.Fatalf("expected one read error, got %v", r.Errors)
+	}
+
+	if _, err := mux.Verify(context.Background(), "missing", VerifyConfig{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist for unknown id, got %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+type WalkOptions struct {
+	// Prune is consulted for every directory before it is visited. When it
+	// returns true the directory is skipped altogether: the walk function
+	// is not called for it and it is never listed.
+	Prune func(name string, d fs.DirEntry) bool
+
+	// Concurrency is the number of directories listed in parallel. Above
+	// one, the walk function and Prune are still never called
+	// concurrently, but directories are no longer visited in lexical
+	// order.
+	Concurrency int
+}
+
+// WalkDir is fs.WalkDir with cancellation and pruning. The walk stops with
+// ctx's error once ctx is done. fs.SkipDir and fs.SkipAll behave as with
+// fs.WalkDir, including in parallel walks.
+func WalkDir(ctx context.Context, fsys fs.FS, root string, opts WalkOptions, fn fs.WalkDirFunc) error {
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else if opts.Concurrency > 1 {
+		err = walkParallel(ctx, fsys, root, fs.FileInfoToDirEntry(info), &opts, fn)
+	} else {
+		err = walkDir(ctx, fsys, root, fs.FileInfoToDirEntry(info), &opts, fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walkDir(ctx context.Context, fsys fs.FS, name string, d fs.DirEntry, opts *WalkOptions, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d.IsDir() && opts.Prune != nil && opts.Prune(name, d) {
+		return nil
+	}
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := readDirContext(ctx, fsys, name)
+	if err != nil {
+		// Second call, to report the ReadDir error.
+		err = fn(name, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		if err := walkDir(ctx, fsys, path.Join(name, e.Name()), e, opts, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readDirContext lists name, letting ctx reach context-aware filesystems.
+func readDirContext(ctx context.Context, fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	if m, ok := fsys.(interface {
+		ReadDirContext(context.Context, string) ([]fs.DirEntry, error)
+	}); ok {
+		return m.ReadDirContext(ctx, name)
+	}
+	return fs.ReadDir(fsys, name)
+}
+
+// parallelWalker lists directories from a pool of workers while calling
+// the walk function under a lock, one entry at a time.
+type parallelWalker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	fsys   fs.FS
+	opts   *WalkOptions
+	fn     fs.WalkDirFunc
+
+	fnMu    sync.Mutex
+	stopped bool
+	err     error
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []walkTask
+	pending int
+}
+
+type walkTask struct {
+	name string
+	d    fs.DirEntry
+}
+
+func walkParallel(ctx context.Context, fsys fs.FS, root string, d fs.DirEntry, opts *WalkOptions, fn fs.WalkDirFunc) error {
+	if opts.Prune != nil && opts.Prune(root, d) {
+		return nil
+	}
+
+	w := &parallelWalker{fsys: fsys, opts: opts, fn: fn}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	defer w.cancel()
+	w.cond = sync.NewCond(&w.mu)
+
+	if err := w.call(root, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir || err == errWalkStopped {
+			err = nil
+		}
+		return err
+	}
+	w.push(walkTask{name: root, d: d})
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+
+	if w.err != nil {
+		return w.err
+	}
+	return ctx.Err()
+}
+
+var errWalkStopped = errors.New("walk stopped")
+
+// call invokes the walk function unless the walk was stopped, recording
+// fs.SkipAll and errors as a reason to stop and cancel outstanding work.
+func (w *parallelWalker) call(name string, d fs.DirEntry, err error) error {
+	w.fnMu.Lock()
+	defer w.fnMu.Unlock()
+
+	if w.stopped {
+		return errWalkStopped
+	}
+	err = w.fn(name, d, err)
+	switch err {
+	case nil, fs.SkipDir:
+	case fs.SkipAll:
+		w.stop(nil)
+	default:
+		w.stop(err)
+	}
+	return err
+}
+
+// prune calls Prune under fnMu, as the walk function is.
+func (w *parallelWalker) prune(name string, d fs.DirEntry) bool {
+	if w.opts.Prune == nil {
+		return false
+	}
+	w.fnMu.Lock()
+	defer w.fnMu.Unlock()
+	return w.opts.Prune(name, d)
+}
+
+// stop must be called with fnMu held.
+func (w *parallelWalker) stop(err error) {
+	w.stopped = true
+	w.err = err
+	w.cancel()
+}
+
+func (w *parallelWalker) push(t walkTask) {
+	w.mu.Lock()
+	w.queue = append(w.queue, t)
+	w.pending++
+	w.mu.Unlock()
+	w.cond.Signal()
+}
+
+func (w *parallelWalker) work() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.pending > 0 {
+			w.cond.Wait()
+		}
+		if w.pending == 0 {
+			w.mu.Unlock()
+			w.cond.Broadcast()
+			return
+		}
+		t := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		w.mu.Unlock()
+
+		w.list(t)
+
+		w.mu.Lock()
+		w.pending--
+		done := w.pending == 0
+		w.mu.Unlock()
+		if done {
+			w.cond.Broadcast()
+		}
+	}
+}
+
+// list reads a directory already visited by the walk function and visits
+// its entries, queueing subdirectories.
+func (w *parallelWalker) list(t walkTask) {
+	if err := w.ctx.Err(); err != nil {
+		w.fnMu.Lock()
+		if !w.stopped {
+			w.stop(err)
+		}
+		w.fnMu.Unlock()
+		return
+	}
+
+	entries, err := readDirContext(w.ctx, w.fsys, t.name)
+	if err != nil {
+		// Second call, to report the ReadDir error.
+		if err := w.call(t.name, t.d, err); err != nil {
+			return
+		}
+	}
+
+	for _, e := range entries {
+		name := path.Join(t.name, e.Name())
+		if e.IsDir() && w.prune(name, e) {
+			continue
+		}
+		err := w.call(name, e, nil)
+		if err == fs.SkipDir {
+			if e.IsDir() {
+				continue
+			}
+			// SkipDir on a file skips the rest of its directory.
+			return
+		}
+		if err != nil {
+			return
+		}
+		if e.IsDir() {
+			w.push(walkTask{name: name, d: e})
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// listingFS counts the directories it is asked to list.
+type listingFS struct {
+	fstest.MapFS
+	listed []string
+}
+
+func (l *listingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	l.listed = append(l.listed, name)
+	return l.MapFS.ReadDir(name)
+}
+
+func TestWalkDirPrune(t *testing.T) {
+	fsys := &listingFS{MapFS: fstest.MapFS{
+		"src/main.go":                 &fstest.MapFile{},
+		"src/node_modules/a/index.js": &fstest.MapFile{},
+		"src/node_modules/b/index.js": &fstest.MapFile{},
+		"docs/readme.md":              &fstest.MapFile{},
+	}}
+
+	var visited []string
+	prune := func(name string, d fs.DirEntry) bool { return d.Name() == "node_modules" }
+	err := WalkDir(context.Background(), fsys, ".", WalkOptions{Prune: prune}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+
+	if got := strings.Join(visited, ","); got != ".,docs,docs/readme.md,src,src/main.go" {
+		t.Fatalf("visited: got %s", got)
+	}
+	for _, name := range fsys.listed {
+		if strings.Contains(name, "node_modules") {
+			t.Fatalf("pruned directory %s was listed", name)
+		}
+	}
+}
+
+func TestWalkDirMatchesFSWalkDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/c.txt": &fstest.MapFile{},
+		"a/d.txt":   &fstest.MapFile{},
+		"e/f.txt":   &fstest.MapFile{},
+		"g.txt":     &fstest.MapFile{},
+	}
+	collect := func(walk func(fs.WalkDirFunc) error) string {
+		var visited []string
+		err := walk(func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			visited = append(visited, name)
+			if name == "a/b" {
+				return fs.SkipDir
+			}
+			if name == "e/f.txt" {
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walk: %v", err)
+		}
+		return strings.Join(visited, ",")
+	}
+
+	want := collect(func(fn fs.WalkDirFunc) error { return fs.WalkDir(fsys, ".", fn) })
+	got := collect(func(fn fs.WalkDirFunc) error {
+		return WalkDir(context.Background(), fsys, ".", WalkOptions{}, fn)
+	})
+	if got != want {
+		t.Fatalf("WalkDir: got %s, want %s", got, want)
+	}
+}
+
+func TestWalkDirCancel(t *testing.T) {
+	fsys := fstest.MapFS{"a.txt": &fstest.MapFile{}, "b.txt": &fstest.MapFile{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	err := WalkDir(ctx, fsys, ".", WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+		cancel()
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWalkDirParallelSkip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/c.txt": &fstest.MapFile{},
+		"a/d.txt":   &fstest.MapFile{},
+		"e/f.txt":   &fstest.MapFile{},
+		"e/g.txt":   &fstest.MapFile{},
+		"e/h/i.txt": &fstest.MapFile{},
+		"j.txt":     &fstest.MapFile{},
+	}
+	collect := func(walk func(fs.WalkDirFunc) error) string {
+		var visited []string
+		err := walk(func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			visited = append(visited, name)
+			if name == "a/b" || name == "e/f.txt" {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walk: %v", err)
+		}
+		sort.Strings(visited)
+		return strings.Join(visited, ",")
+	}
+
+	want := collect(func(fn fs.WalkDirFunc) error { return fs.WalkDir(fsys, ".", fn) })
+	got := collect(func(fn fs.WalkDirFunc) error {
+		return WalkDir(context.Background(), fsys, ".", WalkOptions{Concurrency: 4}, fn)
+	})
+	if got != want {
+		t.Fatalf("WalkDir: got %s, want %s", got, want)
+	}
+}
+
+func TestWalkDirParallelSkipAll(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 50; i++ {
+		fsys[fmt.Sprintf("d%d/sub/file.txt", i)] = &fstest.MapFile{}
+	}
+
+	var calls, after int
+	skipped := false
+	err := WalkDir(context.Background(), fsys, ".", WalkOptions{Concurrency: 8}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		calls++
+		if skipped {
+			after++
+		}
+		if calls == 20 {
+			skipped = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	if after != 0 {
+		t.Fatalf("walk function called %d times after SkipAll", after)
+	}
+}
+
+func TestWalkDirParallelError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b.txt": &fstest.MapFile{},
+		"c/d.txt": &fstest.MapFile{},
+	}
+	boom := errors.New("boom")
+	err := WalkDir(context.Background(), fsys, ".", WalkOptions{Concurrency: 2}, func(name string, d fs.DirEntry, err error) error {
+		if name == "a/b.txt" {
+			return boom
+		}
+		return err
+	})
+	if err != boom {
+		t.Fatalf("expected %v, got %v", boom, err)
+	}
+}
+
+func TestWalkDirParallelPrune(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := range 20 {
+		for j := range 5 {
+			fsys[fmt.Sprintf("d%d/e%d/f", i, j)] = &fstest.MapFile{}
+		}
+	}
+
+	// Prune and the walk function never run at the same time
+	var busy, overlaps atomic.Int64
+	enter := func() {
+		if busy.Add(1) != 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(50 * time.Microsecond)
+		busy.Add(-1)
+	}
+	opts := WalkOptions{
+		Concurrency: 8,
+		Prune: func(name string, d fs.DirEntry) bool {
+			enter()
+			return d.Name() == "e0"
+		},
+	}
+	var visited int
+	err := WalkDir(context.Background(), fsys, ".", opts, func(name string, d fs.DirEntry, err error) error {
+		enter()
+		visited++
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	if n := overlaps.Load(); n != 0 {
+		t.Fatalf("Prune called concurrently %d times", n)
+	}
+	// The root, 20 directories with 4 unpruned subdirectories holding a file
+	if visited != 1+20+20*4*2 {
+		t.Fatalf("visited %d entries", visited)
+	}
+}
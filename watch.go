@@ -0,0 +1,265 @@
+package multifs
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EventOp int
+
+const (
+	EventCreate EventOp = iota
+	EventWrite
+	EventRemove
+	EventMount
+	EventUnmount
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "create"
+	case EventWrite:
+		return "write"
+	case EventRemove:
+		return "remove"
+	case EventMount:
+		return "mount"
+	case EventUnmount:
+		return "unmount"
+	}
+	return "unknown"
+}
+
+// Event is a change below a watched path. Filesystems report paths
+// relative to themselves; MultiFS reports them with the mount id.
+type Event struct {
+	Op   EventOp
+	Path string
+}
+
+// WatchableFS is implemented by filesystems able to report their own
+// changes. The channel is closed once ctx is done.
+type WatchableFS interface {
+	fs.FS
+	Watch(ctx context.Context, name string) (<-chan Event, error)
+}
+
+// WithWatchPollInterval sets how often Watch rescans mounts that cannot
+// report their own changes. The default is two seconds.
+func WithWatchPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+// watcher is a Watch call in progress.
+type watcher struct {
+	ctx    context.Context
+	id     string // empty when watching every mount
+	sub    string
+	events chan Event
+	wg     sync.WaitGroup
+	poll   time.Duration
+}
+
+// Watch reports changes below prefix, which is "." or a path inside a
+// mount, until ctx is done. Mounts are watched natively when they
+// implement WatchableFS and by periodic rescans otherwise. Mounting,
+// replacing and unmounting ids are reported as well. The filesystem
+// mounted with MountRoot is not watched.
+func (m *MultiFS) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	first, sub, err := split(prefix)
+	if err != nil {
+		return nil, &fs.PathError{Op: "watch", Path: prefix, Err: err}
+	}
+
+	w := &watcher{
+		ctx:    ctx,
+		sub:    ".",
+		events: make(chan Event, 64),
+		poll:   m.opts.pollInterval,
+	}
+
+	m.mu.Lock()
+	if first != "" {
+		id, _, ok := m.tab.Load().lookup(first)
+		if !ok {
+			m.mu.Unlock()
+			return nil, fs.ErrNotExist
+		}
+		w.id, w.sub = id, sub
+	}
+	for id, mnt := range m.tab.Load().roots.all {
+		if w.id == "" || w.id == id {
+			w.start(mnt, nil)
+		}
+	}
+	m.watchers = append(m.watchers, w)
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		m.watchers = slices.DeleteFunc(m.watchers, func(other *watcher) bool { return other == w })
+		m.mu.Unlock()
+		w.wg.Wait()
+		close(w.events)
+	}()
+	return w.events, nil
+}
+
+// attached and detached are called by MultiFS with m.mu held, so they
+// leave sending to goroutines.
+func (w *watcher) attached(mnt *mount) {
+	if w.id == "" || w.id == mnt.id {
+		w.start(mnt, &Event{Op: EventMount, Path: mnt.id})
+	}
+}
+
+func (w *watcher) detached(id string) {
+	if w.id != "" && w.id != id {
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.send(Event{Op: EventUnmount, Path: id})
+	}()
+}
+
+// start watches mnt until it is unmounted or replaced, or the watch ends,
+// first sending ev if set.
+func (w *watcher) start(mnt *mount, ev *Event) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if ev != nil && !w.send(*ev) {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(w.ctx)
+		defer cancel()
+		stop := context.AfterFunc(mnt.ctx, cancel)
+		defer stop()
+
+		// Paths rewritten by the mount cannot be watched natively
+		if wfs, ok := mnt.fsys.(WatchableFS); ok && mnt.opts.rewrite == nil {
+			if ch, err := wfs.Watch(ctx, path.Join(mnt.opts.prefix, w.sub)); err == nil {
+				w.forward(ctx, mnt, ch)
+				return
+			}
+		}
+		w.pollMount(ctx, mnt)
+	}()
+}
+
+func (w *watcher) send(ev Event) bool {
+	select {
+	case w.events <- ev:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+func (w *watcher) forward(ctx context.Context, mnt *mount, ch <-chan Event) {
+	for ev := range ch {
+		if p := mnt.opts.prefix; p != "" {
+			rel, ok := strings.CutPrefix(ev.Path, p+"/")
+			switch {
+			case ev.Path == p:
+				rel = "."
+			case !ok:
+				continue
+			}
+			ev.Path = rel
+		}
+		if !mnt.visible(ctx, ev.Path) {
+			continue
+		}
+		ev.Path = path.Join(mnt.id, ev.Path)
+		if !w.send(ev) {
+			return
+		}
+	}
+}
+
+type pollState struct {
+	dir     bool
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// pollMount rescans the watched subtree of mnt periodically and reports
+// the differences between two scans.
+func (w *watcher) pollMount(ctx context.Context, mnt *mount) {
+	prev := w.scan(ctx, mnt)
+	ticker := time.NewTicker(w.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cur := w.scan(ctx, mnt)
+		if ctx.Err() != nil {
+			return
+		}
+		var events []Event
+		for name, st := range cur {
+			old, ok := prev[name]
+			switch {
+			case !ok:
+				events = append(events, Event{Op: EventCreate, Path: name})
+			case !st.dir && (st.size != old.size || st.mode != old.mode || !st.modTime.Equal(old.modTime)):
+				events = append(events, Event{Op: EventWrite, Path: name})
+			}
+		}
+		for name := range prev {
+			if _, ok := cur[name]; !ok {
+				events = append(events, Event{Op: EventRemove, Path: name})
+			}
+		}
+		slices.SortFunc(events, func(a, b Event) int { return strings.Compare(a.Path, b.Path) })
+		for _, ev := range events {
+			ev.Path = path.Join(mnt.id, ev.Path)
+			if !w.send(ev) {
+				return
+			}
+		}
+		prev = cur
+	}
+}
+
+func (w *watcher) scan(ctx context.Context, mnt *mount) map[string]pollState {
+	state := make(map[string]pollState)
+	WalkDir(ctx, mnt, w.sub, WalkOptions{}, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		state[name] = pollState{
+			dir:     d.IsDir(),
+			size:    info.Size(),
+			mode:    info.Mode(),
+			modTime: info.ModTime(),
+		}
+		return nil
+	})
+	return state
+}
@@ -0,0 +1,106 @@
+package multifs
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// watchableFS reports the events pushed to it by the test.
+type watchableFS struct {
+	fstest.MapFS
+	events chan Event
+}
+
+func (w watchableFS) Watch(ctx context.Context, name string) (<-chan Event, error) {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case ev := <-w.events:
+				out <- ev
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return Event{}
+}
+
+func TestWatchNative(t *testing.T) {
+	backend := watchableFS{MapFS: fstest.MapFS{}, events: make(chan Event)}
+	mux := NewMultiFS()
+	mux.Mount("live", backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := mux.Watch(ctx, ".")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	backend.events <- Event{Op: EventCreate, Path: "a.txt"}
+	if ev := nextEvent(t, ch); ev != (Event{Op: EventCreate, Path: "live/a.txt"}) {
+		t.Fatalf("got %+v", ev)
+	}
+
+	mux.Mount("other", fstest.MapFS{})
+	if ev := nextEvent(t, ch); ev != (Event{Op: EventMount, Path: "other"}) {
+		t.Fatalf("got %+v", ev)
+	}
+	mux.Unmount("other")
+	if ev := nextEvent(t, ch); ev != (Event{Op: EventUnmount, Path: "other"}) {
+		t.Fatalf("got %+v", ev)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatchPolling(t *testing.T) {
+	d := newDirFS(t)
+	writeFile(t, d, "a.txt", "a")
+	mux := NewMultiFS(WithWatchPollInterval(10 * time.Millisecond))
+	mux.Mount("work", d)
+	mux.Mount("ignored", fstest.MapFS{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := mux.Watch(ctx, "work")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	writeFile(t, d, "b.txt", "b")
+	if ev := nextEvent(t, ch); ev != (Event{Op: EventCreate, Path: "work/b.txt"}) {
+		t.Fatalf("got %+v", ev)
+	}
+	if err := d.Remove("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, ch); ev != (Event{Op: EventRemove, Path: "work/a.txt"}) {
+		t.Fatalf("got %+v", ev)
+	}
+
+	mux.Unmount("ignored")
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event: %+v", ev)
+	default:
+	}
+}
@@ -0,0 +1,414 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// File is a file opened through OpenFile, which may be written to.
+type File interface {
+	fs.File
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// OpenFileFS is implemented by filesystems that can open files for
+// writing. flag takes the os.O_* flags.
+type OpenFileFS interface {
+	fs.FS
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+}
+
+type MkdirFS interface {
+	fs.FS
+	Mkdir(name string, perm fs.FileMode) error
+}
+
+type RemoveFS interface {
+	fs.FS
+	Remove(name string) error
+}
+
+type RenameFS interface {
+	fs.FS
+	Rename(oldname, newname string) error
+}
+
+// ChmodFS, ChtimesFS and ChownFS are implemented by filesystems whose
+// metadata can be changed, with the semantics of their counterparts in
+// package os.
+type ChmodFS interface {
+	fs.FS
+	Chmod(name string, mode fs.FileMode) error
+}
+
+type ChtimesFS interface {
+	fs.FS
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+type ChownFS interface {
+	fs.FS
+	Chown(name string, uid, gid int) error
+}
+
+// WritableFS is a filesystem supporting the whole write path.
+type WritableFS interface {
+	OpenFileFS
+	MkdirFS
+	RemoveFS
+	RenameFS
+}
+
+var _ OpenFileFS = (*MultiFS)(nil)
+
+// OpenFile opens name with the os.O_* flags. Files opened for reading only
+// are opened as by Open; others are opened by the filesystem of their
+// mount, which must implement OpenFileFS. The synthetic root and the
+// mount points themselves cannot be opened for writing. Neither can, with
+// errors.ErrUnsupported, the files a mount reads through WithDecrypter,
+// WithTransform or digests from WithIntegrity, which writes would bypass.
+func (m *MultiFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return m.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+// OpenFileContext is OpenFile with a context, passed to the access
+// functions, hooks and audit of the mount. Files opened for writing are
+// checked as OpWrite, or OpCreate with os.O_CREATE.
+func (m *MultiFS) OpenFileContext(ctx context.Context, name string, flag int, perm fs.FileMode) (File, error) {
+	r, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if flag&writeFlags == 0 {
+		f, err := r.open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return readOnlyHandleOf(f, name), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.mnt == nil || (r.id != "" && r.subpath == ".") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	op := OpWrite
+	if flag&os.O_CREATE != 0 {
+		op = OpCreate
+	}
+	if err := r.mnt.check(ctx, op, r.subpath); err != nil {
+		return nil, err
+	}
+	ctx, t := r.mnt.begin(ctx, "open", r.subpath)
+	f, err := timed(r.mnt, ctx, op, r.subpath, func(ctx context.Context) (File, error) {
+		return r.mnt.openFile(outliving(ctx), r.subpath, flag, perm)
+	}, func(f File) { f.Close() })
+	t.end(0, err)
+	if err != nil {
+		return nil, err
+	}
+	r.audit(ctx, "open")
+	return f, nil
+}
+
+// Mkdir creates the directory name inside a mount, whose filesystem must
+// implement MkdirFS. Entries of the synthetic root are only created by
+// mounting, so creating one fails with fs.ErrPermission unless a root
+// mount takes it.
+func (m *MultiFS) Mkdir(name string, perm fs.FileMode) error {
+	return m.MkdirContext(context.Background(), name, perm)
+}
+
+func (m *MultiFS) MkdirContext(ctx context.Context, name string, perm fs.FileMode) error {
+	r, err := m.resolveWrite("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if r.mnt == nil || (r.id != "" && r.subpath == ".") {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	return r.mkdirAs(ctx, perm, false)
+}
+
+// MkdirAll creates the directory name inside a mount along with its
+// missing parents, as Mkdir does.
+func (m *MultiFS) MkdirAll(name string, perm fs.FileMode) error {
+	return m.MkdirAllContext(context.Background(), name, perm)
+}
+
+func (m *MultiFS) MkdirAllContext(ctx context.Context, name string, perm fs.FileMode) error {
+	r, err := m.resolveWrite("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if r.mnt == nil || (r.id != "" && r.subpath == ".") {
+		return nil
+	}
+	return r.mkdirAs(ctx, perm, true)
+}
+
+// Chmod changes the mode of name inside a mount, whose filesystem must
+// implement ChmodFS. The synthetic root cannot be changed.
+func (m *MultiFS) Chmod(name string, mode fs.FileMode) error {
+	return m.ChmodContext(context.Background(), name, mode)
+}
+
+func (m *MultiFS) ChmodContext(ctx context.Context, name string, mode fs.FileMode) error {
+	r, err := m.resolveMeta("chmod", name)
+	if err != nil {
+		return err
+	}
+	return r.write(ctx, OpChmod, func() error { return r.mnt.chmod(r.subpath, mode) })
+}
+
+// Chtimes is Chmod for the access and modification times, with ChtimesFS.
+func (m *MultiFS) Chtimes(name string, atime, mtime time.Time) error {
+	return m.ChtimesContext(context.Background(), name, atime, mtime)
+}
+
+func (m *MultiFS) ChtimesContext(ctx context.Context, name string, atime, mtime time.Time) error {
+	r, err := m.resolveMeta("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return r.write(ctx, OpChtimes, func() error { return r.mnt.chtimes(r.subpath, atime, mtime) })
+}
+
+// Chown is Chmod for the owner, with ChownFS.
+func (m *MultiFS) Chown(name string, uid, gid int) error {
+	return m.ChownContext(context.Background(), name, uid, gid)
+}
+
+func (m *MultiFS) ChownContext(ctx context.Context, name string, uid, gid int) error {
+	r, err := m.resolveMeta("chown", name)
+	if err != nil {
+		return err
+	}
+	return r.write(ctx, OpChown, func() error { return r.mnt.chown(r.subpath, uid, gid) })
+}
+
+// resolveMeta resolves name for op, which may not change the synthetic
+// root.
+func (m *MultiFS) resolveMeta(op, name string) (resolved, error) {
+	r, err := m.resolve(op, name)
+	if err == nil && r.mnt == nil {
+		err = &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return r, err
+}
+
+// resolveWrite resolves name for op, which may not create entries in the
+// synthetic root.
+func (m *MultiFS) resolveWrite(op, name string) (resolved, error) {
+	r, err := m.resolve(op, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return r, err
+}
+
+func (r resolved) mkdirAs(ctx context.Context, perm fs.FileMode, all bool) error {
+	return r.write(ctx, OpMkdir, func() error {
+		if all {
+			return mkdirAll(mountDirs{r.mnt}, r.subpath, perm)
+		}
+		return r.mnt.mkdir(r.subpath, perm)
+	})
+}
+
+// write runs the write operation op on the mount with fn, checking access
+// and instrumenting it first.
+func (r resolved) write(ctx context.Context, op Op, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := r.mnt.check(ctx, op, r.subpath); err != nil {
+		return err
+	}
+	ctx, t := r.mnt.begin(ctx, op.String(), r.subpath)
+	_, err := timed(r.mnt, ctx, op, r.subpath, func(context.Context) (struct{}, error) {
+		return struct{}{}, fn()
+	}, nil)
+	t.end(0, err)
+	if err != nil {
+		return err
+	}
+	r.audit(ctx, op.String())
+	return nil
+}
+
+// mountDirs adapts a mount to MkdirFS.
+type mountDirs struct {
+	*mount
+}
+
+func (d mountDirs) Mkdir(name string, perm fs.FileMode) error { return d.mount.mkdir(name, perm) }
+
+// unsupported is the error returned when the mount cannot perform a write
+// operation: read-only mounts deny it, others just lack the interface.
+func (mnt *mount) unsupported(op, name string) error {
+	err := errors.ErrUnsupported
+	if mnt.opts.readOnly {
+		err = fs.ErrPermission
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (mnt *mount) openFile(ctx context.Context, name string, flag int, perm fs.FileMode) (File, error) {
+	w, ok := mnt.fsys.(OpenFileFS)
+	if !ok {
+		return nil, mnt.unsupported("open", name)
+	}
+	if err := mnt.writable(name); err != nil {
+		return nil, err
+	}
+	bname, err := mnt.backendName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range mnt.opts.beforeOpen {
+		if err := hook(ctx, name); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	if err := mnt.admit(name); err != nil {
+		return nil, err
+	}
+	f, err := w.OpenFile(bname, flag, perm)
+	if err == nil && mnt.opts.conflicts {
+		f, err = mnt.detectConflicts(name, bname, f)
+	}
+	if err != nil {
+		mnt.dismiss()
+		return nil, mnt.record("open", name, err)
+	}
+	mnt.invalidate(name)
+	wf := mnt.wrapWritable(ctx, name, &invalidatingFile{File: f, mnt: mnt, name: name})
+
+	// Hooks see files opened for writing as they see others, but must
+	// return a File for them to stay writable.
+	for _, hook := range mnt.opts.afterOpen {
+		wrapped, err := hook(ctx, name, wf)
+		if err == nil {
+			var ok bool
+			if wf, ok = wrapped.(File); !ok {
+				wrapped.Close()
+				err = errors.ErrUnsupported
+			}
+		} else {
+			wf.Close()
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	return wf, nil
+}
+
+func (mnt *mount) mkdir(name string, perm fs.FileMode) error {
+	w, ok := mnt.fsys.(MkdirFS)
+	if !ok {
+		return mnt.unsupported("mkdir", name)
+	}
+	bname, err := mnt.backendName("mkdir", name)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(name)
+	return mnt.record("mkdir", name, w.Mkdir(bname, perm))
+}
+
+func (mnt *mount) remove(name string) error {
+	w, ok := mnt.fsys.(RemoveFS)
+	if !ok {
+		return mnt.unsupported("remove", name)
+	}
+	bname, err := mnt.backendName("remove", name)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(name)
+	return mnt.record("remove", name, w.Remove(bname))
+}
+
+func (mnt *mount) rename(oldname, newname string) error {
+	w, ok := mnt.fsys.(RenameFS)
+	if !ok {
+		return mnt.unsupported("rename", oldname)
+	}
+	oldb, err := mnt.backendName("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newb, err := mnt.backendName("rename", newname)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(oldname)
+	defer mnt.invalidate(newname)
+	return mnt.record("rename", oldname, w.Rename(oldb, newb))
+}
+
+// writable fails for the files that the mount reads through a layer that
+// writes would bypass, storing contents it cannot read back: encrypted or
+// transformed files, and files with digests.
+func (mnt *mount) writable(name string) error {
+	encoded := mnt.opts.decrypter != nil ||
+		(mnt.opts.transform != nil && mnt.opts.transform.matches(name))
+	if !encoded && mnt.opts.integrity != nil {
+		d, err := mnt.opts.integrity.Digests(name)
+		if err != nil {
+			return &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		encoded = d != nil
+	}
+	if encoded {
+		return &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	return nil
+}
+
+func (mnt *mount) chmod(name string, mode fs.FileMode) error {
+	w, ok := mnt.fsys.(ChmodFS)
+	if !ok {
+		return mnt.unsupported("chmod", name)
+	}
+	bname, err := mnt.backendName("chmod", name)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(name)
+	return mnt.record("chmod", name, w.Chmod(bname, mode))
+}
+
+func (mnt *mount) chtimes(name string, atime, mtime time.Time) error {
+	w, ok := mnt.fsys.(ChtimesFS)
+	if !ok {
+		return mnt.unsupported("chtimes", name)
+	}
+	bname, err := mnt.backendName("chtimes", name)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(name)
+	return mnt.record("chtimes", name, w.Chtimes(bname, atime, mtime))
+}
+
+func (mnt *mount) chown(name string, uid, gid int) error {
+	w, ok := mnt.fsys.(ChownFS)
+	if !ok {
+		return mnt.unsupported("chown", name)
+	}
+	bname, err := mnt.backendName("chown", name)
+	if err != nil {
+		return err
+	}
+	defer mnt.invalidate(name)
+	return mnt.record("chown", name, w.Chown(bname, uid, gid))
+}
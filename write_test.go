@@ -0,0 +1,275 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestOpenFile(t *testing.T) {
+	scratch := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch)
+	mux.Mount("ro", fstest.MapFS{"f": &fstest.MapFile{Data: []byte("data")}})
+
+	for _, line := range []string{"one\n", "two\n"} {
+		f, err := mux.OpenFile("scratch/log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+		if _, err := io.WriteString(f, line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		f.Close()
+	}
+	if data, err := fs.ReadFile(mux, "scratch/log"); err != nil || string(data) != "one\ntwo\n" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	f, err := mux.OpenFile("ro/f", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile for reading: %v", err)
+	}
+	if data, _ := io.ReadAll(f); string(data) != "data" {
+		t.Fatalf("read %q", data)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Write on a file opened for reading: %v", err)
+	}
+	f.Close()
+
+	for name, want := range map[string]error{
+		"ro/f":    errors.ErrUnsupported,
+		"scratch": fs.ErrPermission,
+		"new":     fs.ErrNotExist,
+	} {
+		if _, err := mux.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644); !errors.Is(err, want) {
+			t.Errorf("OpenFile(%q): %v, want %v", name, err, want)
+		}
+	}
+}
+
+func TestMkdirAll(t *testing.T) {
+	scratch := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch)
+	mux.Mount("ro", fstest.MapFS{"f": &fstest.MapFile{}})
+
+	if err := mux.MkdirAll("scratch/a/b/c", 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if info, err := fs.Stat(mux, "scratch/a/b/c"); err != nil || !info.IsDir() {
+		t.Fatalf("Stat: %v, %v", info, err)
+	}
+	if err := mux.Mkdir("scratch/a/d", 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	for _, name := range []string{".", "scratch", "scratch/a/b"} {
+		if err := mux.MkdirAll(name, 0o755); err != nil {
+			t.Errorf("MkdirAll(%q) on an existing directory: %v", name, err)
+		}
+	}
+
+	for name, want := range map[string]error{
+		"scratch":   fs.ErrExist,
+		"scratch/a": fs.ErrExist,
+		"new":       fs.ErrPermission,
+		"new/a":     fs.ErrPermission,
+		"ro/a":      errors.ErrUnsupported,
+	} {
+		if err := mux.Mkdir(name, 0o755); !errors.Is(err, want) {
+			t.Errorf("Mkdir(%q): %v, want %v", name, err, want)
+		}
+	}
+	if err := mux.MkdirAll("new/a", 0o755); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("MkdirAll below the root: %v", err)
+	}
+	if err := mux.MkdirAll("scratch/a/b/c/../../x", 0o755); err == nil {
+		t.Errorf("MkdirAll of an invalid path succeeded")
+	}
+}
+
+func TestChmodChtimes(t *testing.T) {
+	scratch := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch)
+	mux.Mount("ro", fstest.MapFS{"f": &fstest.MapFile{}})
+	writeFile(t, scratch, "f", "data")
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := mux.Chmod("scratch/f", 0o600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	if err := mux.Chtimes("scratch/f", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := mux.Chown("scratch/f", os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("Chown: %v", err)
+	}
+	info, err := fs.Stat(mux, "scratch/f")
+	if err != nil || info.Mode().Perm() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Stat: %v %v, %v", info.Mode(), info.ModTime(), err)
+	}
+
+	if err := mux.Chmod("ro/f", 0o600); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Chmod on a mount without ChmodFS: %v", err)
+	}
+	if err := mux.Chtimes(".", mtime, mtime); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Chtimes on the root: %v", err)
+	}
+}
+
+func TestWriteAccessOps(t *testing.T) {
+	var ops []string
+	deny := func(ctx context.Context, op Op, name string) error {
+		if op == OpStat || op == OpOpen || op == OpReadDir {
+			return nil
+		}
+		ops = append(ops, op.String()+" "+name)
+		if ctx.Value(callerKey{}) != "admin" {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+	var records []AuditRecord
+	audit := AuditFunc(func(rec AuditRecord) { records = append(records, rec) })
+	who := func(ctx context.Context) string {
+		s, _ := ctx.Value(callerKey{}).(string)
+		return s
+	}
+
+	mux := NewMultiFS(WithAudit(audit, who))
+	mux.Mount("scratch", newDirFS(t), WithAccessFunc(deny))
+
+	if _, err := mux.OpenFile("scratch/f", os.O_WRONLY|os.O_CREATE, 0o644); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("OpenFile without a caller: %v", err)
+	}
+	if err := mux.Mkdir("scratch/d", 0o755); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Mkdir without a caller: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), callerKey{}, "admin")
+	f, err := mux.OpenFileContext(ctx, "scratch/f", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFileContext: %v", err)
+	}
+	f.Close()
+	f, err = mux.OpenFileContext(ctx, "scratch/f", os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFileContext: %v", err)
+	}
+	f.Close()
+	if err := mux.MkdirContext(ctx, "scratch/d", 0o755); err != nil {
+		t.Fatalf("MkdirContext: %v", err)
+	}
+	if err := mux.MoveContext(ctx, "scratch/f", "scratch/d/f"); err != nil {
+		t.Fatalf("MoveContext: %v", err)
+	}
+	if err := mux.RemoveAllContext(ctx, "scratch/d"); err != nil {
+		t.Fatalf("RemoveAllContext: %v", err)
+	}
+
+	want := []string{
+		"create f", "mkdir d",
+		"create f", "write f", "mkdir d", "rename d/f", "rename f", "remove d",
+	}
+	if strings.Join(ops, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("operations checked:\n got %v\nwant %v", ops, want)
+	}
+	for _, rec := range records {
+		if rec.Who != "admin" {
+			t.Fatalf("audit record without its caller: %+v", rec)
+		}
+	}
+	if len(records) != 5 {
+		t.Fatalf("audit records: %+v", records)
+	}
+}
+
+func TestWriteOpenHooks(t *testing.T) {
+	var opened []string
+	mux := NewMultiFS()
+	mux.Mount("scratch", newDirFS(t),
+		WithBeforeOpen(func(ctx context.Context, name string) error {
+			if name == "vetoed" {
+				return fs.ErrPermission
+			}
+			return nil
+		}),
+		WithAfterOpen(func(ctx context.Context, name string, f fs.File) (fs.File, error) {
+			opened = append(opened, name)
+			return f, nil
+		}))
+
+	if _, err := mux.OpenFile("scratch/vetoed", os.O_WRONLY|os.O_CREATE, 0o644); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("OpenFile vetoed: %v", err)
+	}
+	if _, err := fs.Stat(mux, "scratch/vetoed"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("vetoed file created: %v", err)
+	}
+	f, err := mux.OpenFile("scratch/f", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := io.WriteString(f, "data"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	f.Close()
+	if len(opened) != 1 || opened[0] != "f" {
+		t.Fatalf("after open hook calls: %v", opened)
+	}
+}
+
+func TestOpenFileEncoded(t *testing.T) {
+	scratch := newDirFS(t)
+	mux := NewMultiFS()
+	mux.Mount("gz", scratch, WithTransform(Transform{
+		Match:  func(name string) bool { return strings.HasSuffix(name, ".gz") },
+		Reader: Gunzip.Reader,
+	}))
+	mux.Mount("vault", scratch, WithDecrypter(xorDecrypter{}))
+	mux.Mount("checked", scratch, WithIntegrity(digestMap{"sum": digestsOf("data", 0)}))
+
+	flag := os.O_WRONLY | os.O_CREATE
+	for _, name := range []string{"gz/a.gz", "vault/a", "checked/sum"} {
+		if _, err := mux.OpenFile(name, flag, 0o644); !errors.Is(err, errors.ErrUnsupported) {
+			t.Fatalf("OpenFile %s: %v, want %v", name, err, errors.ErrUnsupported)
+		}
+	}
+	for _, name := range []string{"gz/a.txt", "checked/other"} {
+		f, err := mux.OpenFile(name, flag, 0o644)
+		if err != nil {
+			t.Fatalf("OpenFile %s: %v", name, err)
+		}
+		f.Close()
+	}
+}
+
+// hungChmodFS blocks Chmod until released.
+type hungChmodFS struct {
+	dirFS
+	release chan struct{}
+}
+
+func (h hungChmodFS) Chmod(name string, mode fs.FileMode) error {
+	<-h.release
+	return h.dirFS.Chmod(name, mode)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	scratch := hungChmodFS{newDirFS(t), make(chan struct{})}
+	defer close(scratch.release)
+	writeFile(t, scratch, "f", "data")
+	mux := NewMultiFS()
+	mux.Mount("scratch", scratch, WithTimeout(OpChmod, 20*time.Millisecond))
+
+	if err := mux.Chmod("scratch/f", 0o600); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Chmod: %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,97 @@
+package multifs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+)
+
+// ErrNoXattr is the error of GetXattr for attributes that are not set.
+var ErrNoXattr = errors.New("multifs: no such attribute")
+
+// XattrFS is implemented by filesystems exposing extended attributes.
+// GetXattr fails with an error wrapping ErrNoXattr for attributes that are
+// not set.
+type XattrFS interface {
+	fs.FS
+	ListXattr(name string) ([]string, error)
+	GetXattr(name, attr string) ([]byte, error)
+}
+
+var _ XattrFS = (*MultiFS)(nil)
+
+// ListXattr returns the names of the extended attributes of name, on
+// mounts whose filesystem implements XattrFS. Directories MultiFS makes up
+// have none.
+func (m *MultiFS) ListXattr(name string) ([]string, error) {
+	r, err := m.resolve("listxattr", name)
+	if err != nil || r.mnt == nil {
+		return nil, err
+	}
+	return r.mnt.listXattr(context.Background(), r.subpath)
+}
+
+// GetXattr returns the value of the extended attribute attr of name, as
+// ListXattr does.
+func (m *MultiFS) GetXattr(name, attr string) ([]byte, error) {
+	r, err := m.resolve("getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	if r.mnt == nil {
+		return nil, &fs.PathError{Op: "getxattr", Path: name, Err: ErrNoXattr}
+	}
+	return r.mnt.getXattr(context.Background(), r.subpath, attr)
+}
+
+func (mnt *mount) listXattr(ctx context.Context, name string) ([]string, error) {
+	if err := mnt.check(ctx, OpStat, name); err != nil {
+		return nil, err
+	}
+	x, ok := mnt.fsys.(XattrFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "listxattr", Path: name, Err: errors.ErrUnsupported}
+	}
+	bname, err := mnt.backendName("listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := x.ListXattr(bname)
+	return attrs, mnt.record("listxattr", name, err)
+}
+
+func (mnt *mount) getXattr(ctx context.Context, name, attr string) ([]byte, error) {
+	if err := mnt.check(ctx, OpStat, name); err != nil {
+		return nil, err
+	}
+	x, ok := mnt.fsys.(XattrFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "getxattr", Path: name, Err: errors.ErrUnsupported}
+	}
+	bname, err := mnt.backendName("getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	value, err := x.GetXattr(bname, attr)
+	if errors.Is(err, ErrNoXattr) {
+		return nil, err
+	}
+	return value, mnt.record("getxattr", name, err)
+}
+
+// ListXattr and GetXattr are kept by read-only mounts, as they only read.
+func (r readOnlyFS) ListXattr(name string) ([]string, error) {
+	x, ok := r.fsys.(XattrFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "listxattr", Path: name, Err: errors.ErrUnsupported}
+	}
+	return x.ListXattr(name)
+}
+
+func (r readOnlyFS) GetXattr(name, attr string) ([]byte, error) {
+	x, ok := r.fsys.(XattrFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "getxattr", Path: name, Err: errors.ErrUnsupported}
+	}
+	return x.GetXattr(name, attr)
+}
@@ -0,0 +1,68 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// xattrFS is a MapFS with extended attributes, by path.
+type xattrFS struct {
+	fstest.MapFS
+	attrs map[string]map[string]string
+}
+
+func (x xattrFS) ListXattr(name string) ([]string, error) {
+	if _, err := x.Stat(name); err != nil {
+		return nil, err
+	}
+	var attrs []string
+	for attr := range x.attrs[name] {
+		attrs = append(attrs, attr)
+	}
+	slices.Sort(attrs)
+	return attrs, nil
+}
+
+func (x xattrFS) GetXattr(name, attr string) ([]byte, error) {
+	value, ok := x.attrs[name][attr]
+	if !ok {
+		return nil, &fs.PathError{Op: "getxattr", Path: name, Err: ErrNoXattr}
+	}
+	return []byte(value), nil
+}
+
+func TestXattr(t *testing.T) {
+	backend := xattrFS{
+		MapFS: fstest.MapFS{"f": &fstest.MapFile{}, "g": &fstest.MapFile{}},
+		attrs: map[string]map[string]string{"f": {
+			"security.selinux": "system_u:object_r:etc_t:s0",
+			"user.comment":     "hello",
+		}},
+	}
+	mux := NewMultiFS()
+	mux.Mount("s", backend, WithReadOnly())
+	mux.Mount("plain", fstest.MapFS{"f": &fstest.MapFile{}})
+
+	attrs, err := mux.ListXattr("s/f")
+	if err != nil || !slices.Equal(attrs, []string{"security.selinux", "user.comment"}) {
+		t.Fatalf("ListXattr: %v, %v", attrs, err)
+	}
+	if value, err := mux.GetXattr("s/f", "user.comment"); err != nil || string(value) != "hello" {
+		t.Fatalf("GetXattr: %q, %v", value, err)
+	}
+	if _, err := mux.GetXattr("s/g", "user.comment"); !errors.Is(err, ErrNoXattr) {
+		t.Fatalf("GetXattr of an unset attribute: %v", err)
+	}
+	if _, err := mux.ListXattr("s/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ListXattr of a missing file: %v", err)
+	}
+	if attrs, err := mux.ListXattr("."); err != nil || len(attrs) != 0 {
+		t.Fatalf("ListXattr on the root: %v, %v", attrs, err)
+	}
+	if _, err := mux.ListXattr("plain/f"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("ListXattr without XattrFS: %v", err)
+	}
+}